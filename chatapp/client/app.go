@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// chatClient is everything one logged in user has going on: the RoomChat
+// stream every joined room shares, a chatSession per room, the screen and the
+// commands.
+type chatClient struct {
+	client   pb.ChatClient
+	user     string
+	keys     *keyRing
+	ui       *terminalUI
+	commands *commandRegistry
+
+	mu       sync.Mutex
+	sessions map[string]*chatSession
+	quitting bool
+
+	sendMu sync.Mutex // a stream takes one Send at a time
+	stream pb.Chat_RoomChatClient
+}
+
+func newChatClient(client pb.ChatClient, user string, keys *keyRing, ui *terminalUI) *chatClient {
+	c := &chatClient{
+		client:   client,
+		user:     user,
+		keys:     keys,
+		ui:       ui,
+		commands: newCommandRegistry(),
+		sessions: make(map[string]*chatSession),
+	}
+	registerChatCommands(c.commands)
+	registerModerationCommands(c.commands)
+	registerPresenceCommands(c.commands)
+	registerKeyCommands(c.commands)
+	registerAttachmentCommands(c.commands)
+	registerMessageCommands(c.commands)
+	registerBotCommands(c.commands)
+	ui.onEdit = c.edited
+	go c.run()
+	return c
+}
+
+// notice prints a line of our own on the pane on screen.
+func (c *chatClient) notice(format string, args ...any) {
+	c.ui.Printf("", format, args...)
+}
+
+func (c *chatClient) session(room string) *chatSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessions[room]
+}
+
+// join enters the room, or just shows it if we are in it already.
+func (c *chatClient) join(req *pb.JoinRequest) error {
+	room := req.Room
+	if c.session(room) != nil {
+		c.ui.Show(room)
+		return nil
+	}
+
+	session := newChatSession(c.client, c.user, room, c.send)
+	session.password = req.Password
+	session.inviteCode = req.InviteCode
+	session.visibility = req.Visibility
+	resp, err := session.join(replayOnJoin)
+	if err != nil {
+		return err
+	}
+
+	session.print = func(format string, args ...any) { c.ui.Printf(room, format, args...) }
+	session.receipts.print = session.print
+	session.receipts.echo = c.ui.Split()
+	session.typing.show = func(status string) { c.ui.SetTyping(room, status) }
+	session.ended = func() { c.forget(room) }
+
+	c.mu.Lock()
+	c.sessions[room] = session
+	c.mu.Unlock()
+	c.ui.AddRoom(room)
+
+	if len(resp.History) > 0 {
+		session.print("---- last %d messages in %s ----", len(resp.History), room)
+		for _, msg := range resp.History {
+			session.print("%s", formatMessage(msg))
+		}
+		session.print("---- end of history ----")
+	}
+	session.print("You joined room %s with %v other members in the room: %v", room, len(resp.Members), resp.Members)
+	if resp.Topic != "" {
+		session.print("Topic: %s", resp.Topic)
+	}
+	if resp.Role != "member" {
+		session.print("You are the %s of this room, /modhelp lists the moderation commands", resp.Role)
+	}
+
+	// if the stream isn't up yet it subscribes once it is
+	if err := session.subscribe(); err != nil && !errors.Is(err, errNotConnected) {
+		session.print("Failed to subscribe to %s: %v", room, err)
+	}
+	return nil
+}
+
+func (c *chatClient) leave(room, leaveType string) error {
+	session := c.session(room)
+	if session == nil {
+		return fmt.Errorf("you are not in %s", room)
+	}
+	c.forget(room)
+	return session.leave(leaveType)
+}
+
+// forget drops the room from the client once we are out of it.
+func (c *chatClient) forget(room string) {
+	c.mu.Lock()
+	delete(c.sessions, room)
+	c.mu.Unlock()
+	c.ui.RemoveRoom(room)
+}
+
+// quit leaves every room and closes the stream, handleLine returns false
+// after it.
+func (c *chatClient) quit(leaveType string) {
+	c.mu.Lock()
+	c.quitting = true
+	rooms := make([]string, 0, len(c.sessions))
+	for room := range c.sessions {
+		rooms = append(rooms, room)
+	}
+	c.mu.Unlock()
+
+	for _, room := range rooms {
+		if err := c.leave(room, leaveType); err != nil {
+			c.notice("Error leaving %s: %v", room, err)
+		}
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.stream != nil {
+		c.stream.CloseSend()
+	}
+}
+
+func (c *chatClient) isQuitting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.quitting
+}
+
+// run keeps the RoomChat stream up until we quit, subscribing to every room
+// we are in each time it connects.
+func (c *chatClient) run() {
+	var b backoff
+	for !c.isQuitting() {
+		started := time.Now()
+		err := c.connectAndServe()
+		if c.isQuitting() {
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		c.notice("Connection to the server lost: %v", err)
+		if time.Since(started) > stableConnection {
+			b.reset()
+		}
+		b.wait()
+	}
+}
+
+func (c *chatClient) connectAndServe() error {
+	stream, err := c.client.RoomChat(context.Background())
+	if err != nil {
+		return err
+	}
+
+	c.sendMu.Lock()
+	c.stream = stream
+	c.sendMu.Unlock()
+	defer func() {
+		c.sendMu.Lock()
+		c.stream = nil
+		c.sendMu.Unlock()
+	}()
+
+	c.mu.Lock()
+	sessions := make([]*chatSession, 0, len(c.sessions))
+	for _, session := range c.sessions {
+		sessions = append(sessions, session)
+	}
+	c.mu.Unlock()
+	for _, session := range sessions {
+		if err := session.subscribe(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.dispatch(msg)
+	}
+}
+
+// send puts msg on the stream.
+func (c *chatClient) send(msg *pb.ChatRoomMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.stream == nil {
+		return errNotConnected
+	}
+	return c.stream.Send(msg)
+}
+
+// dispatch hands what the stream got to the room it is about.
+func (c *chatClient) dispatch(msg *pb.ChatRoomMessage) {
+	if msg.Type == "private" {
+		// not about any room, it goes wherever the user is looking
+		content := msg.Content
+		if msg.Private != nil {
+			content = c.privateContent(msg.Private)
+		}
+		c.notice("%s Private message from [%s]: %s", formatTime(msg.Timestamp), msg.Sender, content)
+		return
+	}
+	if session := c.session(msg.Room); session != nil {
+		session.handle(msg)
+	}
+}
+
+// handleLine runs a command or sends text to the room on screen. It returns
+// false once the user quit.
+func (c *chatClient) handleLine(text string) bool {
+	text = strings.TrimSpace(text)
+	room := c.ui.Active()
+	session := c.session(room)
+
+	// anything typed after a message arrived means the user has seen it
+	if session != nil {
+		if seq := session.receipts.unread(); seq > 0 {
+			session.ack(seq, "read")
+		}
+	}
+
+	switch {
+	case c.commands.run(c, text):
+	case text == "":
+	case session == nil:
+		c.notice("You are not in a room, /join one or /help for the commands")
+	default:
+		err := session.send(&pb.ChatRoomMessage{
+			Sender:   c.user,
+			Room:     room,
+			Content:  text,
+			ClientId: session.receipts.newClientID(text),
+		})
+		if err != nil {
+			session.print("Failed to send message: %v", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.quitting
+}
+
+// edited lets the room on screen know we are typing.
+func (c *chatClient) edited(line string) {
+	if session := c.session(c.ui.Active()); session != nil {
+		session.edited(line)
+	}
+}
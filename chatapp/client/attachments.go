@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+const uploadChunkSize = 32 << 10
+
+func formatAttachment(a *pb.Attachment) string {
+	return fmt.Sprintf("[%s, %s, %s -- /save %s]", a.Name, a.ContentType, formatSize(a.Size), a.Id)
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// upload streams the file at path to the server.
+func (c *chatClient) upload(path string) (*pb.Attachment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stream, err := c.client.UploadAttachment(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	chunk := &pb.AttachmentChunk{Info: &pb.Attachment{Name: filepath.Base(path)}}
+	buf := make([]byte, uploadChunkSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				// the server gave up on the upload, CloseAndRecv says why
+				break
+			}
+			chunk = &pb.AttachmentChunk{}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stream.CloseSend()
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// download saves attachment id to path, or to its own name in the current
+// directory when path is empty. It never overwrites a file.
+func (c *chatClient) download(id, path string) (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.client.DownloadAttachment(ctx, &pb.AttachmentRequest{Id: id})
+	if err != nil {
+		return "", err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = filepath.Base(first.GetInfo().GetName())
+		if path == "." || path == "/" || path == "" {
+			path = id
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return path, err
+	}
+	chunk := first
+	for {
+		if _, err := file.Write(chunk.Data); err != nil {
+			file.Close()
+			os.Remove(path)
+			return "", err
+		}
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(path)
+			return "", err
+		}
+	}
+	return path, file.Close()
+}
+
+func registerAttachmentCommands(r *commandRegistry) {
+	r.register("/send", &command{
+		usage:  "/send <path> [caption]",
+		help:   "upload a file and send it to the room",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			path, caption, _ := strings.Cut(args, " ")
+			if path == "" {
+				return errUsage
+			}
+			room := c.ui.Active()
+			session := c.session(room)
+			if session == nil {
+				return fmt.Errorf("you are not in %s", room)
+			}
+
+			attachment, err := c.upload(path)
+			if err != nil {
+				return err
+			}
+			caption = strings.TrimSpace(caption)
+			shown := strings.TrimSpace(caption + " " + formatAttachment(attachment))
+			return session.send(&pb.ChatRoomMessage{
+				Sender:     c.user,
+				Room:       room,
+				Content:    caption,
+				Attachment: attachment,
+				ClientId:   session.receipts.newClientID(shown),
+			})
+		},
+	})
+	r.register("/save", &command{
+		usage: "/save <id> [path]",
+		help:  "download an attachment, to its own name unless a path is given",
+		run: func(c *chatClient, args string) error {
+			id, path, _ := strings.Cut(args, " ")
+			if id == "" {
+				return errUsage
+			}
+			saved, err := c.download(id, strings.TrimSpace(path))
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("%s already exists, give /save a path", saved)
+			}
+			if err != nil {
+				return err
+			}
+			c.notice("Saved %s", saved)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// tokenAuth attaches the session token to every call once we are logged in.
+type tokenAuth struct {
+	mu    sync.RWMutex
+	token string
+}
+
+func (t *tokenAuth) Set(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+}
+
+func (t *tokenAuth) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.token == "" {
+		return nil, nil
+	}
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t *tokenAuth) RequireTransportSecurity() bool {
+	return false
+}
+
+// login asks for credentials until Login or Register succeeds and returns the
+// username the server issued the token for.
+func login(client pb.ChatClient, auth *tokenAuth, reader *bufio.Reader) string {
+	for {
+		log.Print("Enter your username: ")
+		username, _ := reader.ReadString('\n')
+		username = strings.TrimSpace(username)
+
+		log.Print("Enter your password: ")
+		password, _ := reader.ReadString('\n')
+		password = strings.TrimSpace(password)
+
+		creds := &pb.Credentials{Username: username, Password: password}
+		resp, err := client.Login(context.Background(), creds)
+		if err != nil {
+			log.Printf("Login failed: %v", err)
+			log.Print("Register a new account with these credentials? [y/N]: ")
+			answer, _ := reader.ReadString('\n')
+			if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+				continue
+			}
+			resp, err = client.Register(context.Background(), creds)
+			if err != nil {
+				log.Printf("Registration failed: %v", err)
+				continue
+			}
+		}
+
+		auth.Set(resp.Token)
+		return resp.Username
+	}
+}
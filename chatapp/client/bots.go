@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// registerBotCommands adds the commands room owners and moderators manage
+// bots and webhooks with.
+func registerBotCommands(r *commandRegistry) {
+	r.register("/bots", &command{
+		usage:  "/bots",
+		help:   "list the room's bots and webhooks",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			list, err := c.client.ListIntegrations(context.Background(), &pb.RoomInfoRequest{Room: c.ui.Active()})
+			if err != nil {
+				return err
+			}
+			if len(list.Bots) == 0 && len(list.Webhooks) == 0 {
+				c.notice("No bots or webhooks, /bot add <name> or /webhook add to make one")
+				return nil
+			}
+			for _, bot := range list.Bots {
+				c.notice("  bot %s, added by %s on %s", bot.Name, bot.Creator, time.Unix(bot.Created, 0).Format(time.DateOnly))
+			}
+			for _, hook := range list.Webhooks {
+				trigger := hook.Command
+				if hook.Pattern != "" {
+					trigger = strings.TrimSpace(trigger + " /" + hook.Pattern + "/")
+				}
+				c.notice("  webhook %s (%s) on %s to %s, added by %s", hook.Name, hook.Id, trigger, hook.Url, hook.Creator)
+			}
+			return nil
+		},
+	})
+	r.register("/bot", &command{
+		usage:  "/bot add|remove <name>",
+		help:   "add a bot and get its token, or delete it",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			action, name, _ := strings.Cut(args, " ")
+			name = strings.TrimSpace(name)
+			if name == "" || strings.Contains(name, " ") {
+				return errUsage
+			}
+			room := c.ui.Active()
+			req := &pb.BotRequest{Room: room, Name: name}
+			switch action {
+			case "add":
+				bot, err := c.client.CreateBot(context.Background(), req)
+				if err != nil {
+					return err
+				}
+				c.notice("Bot %s can post to %s with this token, it isn't shown again:", bot.Name, room)
+				c.notice("  %s", bot.Token)
+				c.notice("  curl -H 'Authorization: Bearer %s' -d '{\"text\": \"hello\"}' http://<server's -http address>/api/rooms/%s/messages", bot.Token, room)
+			case "remove":
+				resp, err := c.client.DeleteBot(context.Background(), req)
+				if err != nil {
+					return err
+				}
+				c.notice("%s", resp.Status)
+			default:
+				return errUsage
+			}
+			return nil
+		},
+	})
+	r.register("/webhook", &command{
+		usage:  "/webhook add <name> <url> <command or /regex/> | remove <id>",
+		help:   "POST messages starting with the command or matching the regex to url",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			parts := strings.Fields(args)
+			room := c.ui.Active()
+			switch {
+			case len(parts) >= 4 && parts[0] == "add":
+				hook := &pb.Webhook{Room: room, Name: parts[1], Url: parts[2]}
+				trigger := strings.Join(parts[3:], " ")
+				if len(trigger) > 2 && strings.HasPrefix(trigger, "/") && strings.HasSuffix(trigger, "/") {
+					hook.Pattern = trigger[1 : len(trigger)-1]
+				} else {
+					hook.Command = trigger
+				}
+				added, err := c.client.AddWebhook(context.Background(), hook)
+				if err != nil {
+					return err
+				}
+				c.notice("Webhook %s added. Check its requests with this secret, it isn't shown again:", added.Id)
+				c.notice("  %s", added.Secret)
+				c.notice("  X-Chat-Signature is sha256= and the hex HMAC-SHA256 of X-Chat-Timestamp + \".\" + body")
+			case len(parts) == 2 && parts[0] == "remove":
+				resp, err := c.client.RemoveWebhook(context.Background(), &pb.WebhookRequest{Room: room, Id: parts[1]})
+				if err != nil {
+					return err
+				}
+				c.notice("%s", resp.Status)
+			default:
+				return errUsage
+			}
+			return nil
+		},
+	})
+}
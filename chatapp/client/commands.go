@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/status"
+)
+
+// errUsage makes the registry print how to use the command.
+var errUsage = errors.New("wrong arguments")
+
+// command is one of the slash commands the client understands.
+type command struct {
+	usage string // shown by /help, starts with the command itself
+	help  string
+	// inRoom commands act on the room on screen and can't run without one.
+	inRoom bool
+	// run gets everything typed after the command, trimmed.
+	run func(c *chatClient, args string) error
+}
+
+// commandRegistry maps slash commands to what they do. Other files add their
+// commands with register.
+type commandRegistry struct {
+	commands map[string]*command
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{commands: make(map[string]*command)}
+}
+
+func (r *commandRegistry) register(name string, cmd *command) {
+	r.commands[name] = cmd
+}
+
+// run executes line if it is a command and reports whether it was one.
+// Unknown commands count, so they aren't sent to the room by accident.
+func (r *commandRegistry) run(c *chatClient, line string) bool {
+	if !strings.HasPrefix(line, "/") {
+		return false
+	}
+	name, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+
+	cmd := r.commands[name]
+	switch {
+	case cmd == nil:
+		c.notice("Unknown command %s, /help lists them", name)
+	case cmd.inRoom && c.ui.Active() == "":
+		c.notice("%s needs a room, /join one first", name)
+	default:
+		err := cmd.run(c, args)
+		if errors.Is(err, errUsage) {
+			c.notice("Use: %s", cmd.usage)
+		} else if err != nil {
+			if st, ok := status.FromError(err); ok {
+				err = errors.New(st.Message())
+			}
+			c.notice("%s failed: %v", name, err)
+		}
+	}
+	return true
+}
+
+func (r *commandRegistry) help() string {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Commands:")
+	for _, name := range names {
+		cmd := r.commands[name]
+		fmt.Fprintf(&b, "\n  %-38s %s", cmd.usage, cmd.help)
+	}
+	b.WriteString("\nTab switches between rooms, anything else you type goes to the room on screen.")
+	return b.String()
+}
+
+func registerChatCommands(r *commandRegistry) {
+	r.register("/help", &command{
+		usage: "/help",
+		help:  "list the commands",
+		run: func(c *chatClient, args string) error {
+			c.notice("%s", r.help())
+			return nil
+		},
+	})
+	r.register("/join", &command{
+		usage: "/join <room> [password or invite code]",
+		help:  "join or create a room, or switch to one you are in",
+		run: func(c *chatClient, args string) error {
+			fields := strings.Fields(args)
+			if len(fields) < 1 || len(fields) > 2 {
+				return errUsage
+			}
+			req := &pb.JoinRequest{Room: fields[0]}
+			if len(fields) == 2 {
+				// the server only looks at the one the room needs
+				req.Password = fields[1]
+				req.InviteCode = fields[1]
+			}
+			return c.join(req)
+		},
+	})
+	r.register("/create", &command{
+		usage: "/create <room> <visibility> [password]",
+		help:  "create a public, unlisted, password or invite room",
+		run: func(c *chatClient, args string) error {
+			fields := strings.Fields(args)
+			if len(fields) < 2 || len(fields) > 3 {
+				return errUsage
+			}
+			req := &pb.JoinRequest{Room: fields[0], Visibility: fields[1]}
+			if len(fields) == 3 {
+				req.Password = fields[2]
+			}
+			return c.join(req)
+		},
+	})
+	r.register("/leave", &command{
+		usage: "/leave [room]",
+		help:  "leave the room on screen or the one named",
+		run: func(c *chatClient, args string) error {
+			room := args
+			if room == "" {
+				room = c.ui.Active()
+			}
+			if room == "" {
+				return errUsage
+			}
+			return c.leave(room, "")
+		},
+	})
+	r.register("/rooms", &command{
+		usage: "/rooms",
+		help:  "list the rooms you can join",
+		run: func(c *chatClient, args string) error {
+			rooms, err := c.client.GetExistingChatRooms(context.Background(), &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			if len(rooms.Details) == 0 {
+				c.notice("No rooms yet, /join <room> creates one")
+				return nil
+			}
+			c.notice("Rooms:")
+			for _, room := range rooms.Details {
+				line := "  " + room.Name
+				switch room.Visibility {
+				case "password":
+					line += " (password)"
+				case "invite":
+					line += " (invite only)"
+				case "unlisted":
+					line += " (unlisted)"
+				}
+				if c.session(room.Name) != nil {
+					line += " [joined]"
+				}
+				if room.Topic != "" {
+					line += " - " + room.Topic
+				}
+				c.notice("%s", line)
+			}
+			return nil
+		},
+	})
+	r.register("/who", &command{
+		usage:  "/who",
+		help:   "list who is in the room and whether they are around",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			room := c.ui.Active()
+			resp, err := c.client.GetPresence(context.Background(), &pb.PresenceRequest{Room: room})
+			if err != nil {
+				return err
+			}
+			c.notice("In %s:", room)
+			for _, p := range resp.Users {
+				c.notice("  %s (%s)", p.User, p.Status)
+			}
+			return nil
+		},
+	})
+	r.register("/pm", &command{
+		usage: "/pm <user> <message>",
+		help:  "send an end-to-end encrypted private message",
+		run: func(c *chatClient, args string) error {
+			recipient, message, ok := strings.Cut(args, " ")
+			if !ok || strings.TrimSpace(message) == "" {
+				return errUsage
+			}
+			key, err := c.recipientKey(recipient)
+			if err != nil {
+				return err
+			}
+			encrypted, err := c.keys.seal(message, key)
+			if err != nil {
+				return err
+			}
+			resp, err := c.client.SendPrivateMessage(context.Background(), &pb.PrivateMessage{
+				Sender:    c.user,
+				Recipient: recipient,
+				Encrypted: encrypted,
+			})
+			if err != nil {
+				return err
+			}
+			c.notice("%s To [%s]: %s", time.Now().Format("15:04:05"), recipient, message)
+			if strings.HasPrefix(resp.Status, "Message queued") {
+				c.notice("%s", resp.Status)
+			}
+			return nil
+		},
+	})
+	r.register("/conversations", &command{
+		usage: "/conversations",
+		help:  "list who you have private messages with",
+		run: func(c *chatClient, args string) error {
+			list, err := c.client.ListConversations(context.Background(), &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			if len(list.Conversations) == 0 {
+				c.notice("No private messages yet, /pm <user> <message> starts a conversation")
+				return nil
+			}
+			c.notice("Conversations:")
+			for _, conv := range list.Conversations {
+				last := conv.Last
+				c.notice("  %s - %s [%s]: %s", conv.With, formatTime(last.Timestamp), last.Sender, c.privateContent(last))
+			}
+			return nil
+		},
+	})
+	r.register("/dm", &command{
+		usage: "/dm <user> [count]",
+		help:  "show earlier private messages with a user",
+		run: func(c *chatClient, args string) error {
+			fields := strings.Fields(args)
+			if len(fields) == 0 || len(fields) > 2 {
+				return errUsage
+			}
+			limit := replayOnJoin
+			if len(fields) == 2 {
+				n, err := strconv.Atoi(fields[1])
+				if err != nil || n <= 0 {
+					return errUsage
+				}
+				limit = n
+			}
+			conv, err := c.client.GetConversation(context.Background(), &pb.ConversationRequest{With: fields[0], Limit: int32(limit)})
+			if err != nil {
+				return err
+			}
+			if len(conv.Messages) == 0 {
+				c.notice("No private messages with %s yet", fields[0])
+				return nil
+			}
+			for _, msg := range conv.Messages {
+				c.notice("%s [%s]: %s", formatTime(msg.Timestamp), msg.Sender, c.privateContent(msg))
+			}
+			return nil
+		},
+	})
+	r.register("/topic", &command{
+		usage:  "/topic [text or -]",
+		help:   "show the topic, set it, or clear it with -",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			room := c.ui.Active()
+			if args == "" {
+				info, err := c.client.GetRoomInfo(context.Background(), &pb.RoomInfoRequest{Room: room})
+				if err != nil {
+					return err
+				}
+				if info.Topic == "" {
+					c.notice("%s has no topic", room)
+				} else {
+					c.notice("Topic of %s: %s", room, info.Topic)
+				}
+				return nil
+			}
+			if args == "-" {
+				args = ""
+			}
+			resp, err := c.client.SetRoomTopic(context.Background(), &pb.TopicRequest{Room: room, Topic: args})
+			if err != nil {
+				return err
+			}
+			c.notice("%s", resp.Status)
+			return nil
+		},
+	})
+	r.register("/history", &command{
+		usage:  "/history [count]",
+		help:   "show earlier messages of the room",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			limit := replayOnJoin
+			if args != "" {
+				n, err := strconv.Atoi(args)
+				if err != nil || n <= 0 {
+					return errUsage
+				}
+				limit = n
+			}
+			room := c.ui.Active()
+			history, err := c.client.GetRoomHistory(context.Background(), &pb.HistoryRequest{Room: room, Limit: int32(limit)})
+			if err != nil {
+				return err
+			}
+			for _, msg := range history.Messages {
+				c.ui.Printf(room, "%s", formatMessage(msg))
+			}
+			return nil
+		},
+	})
+	r.register("/exit", &command{
+		usage: "/exit",
+		help:  "leave every room and quit",
+		run: func(c *chatClient, args string) error {
+			c.quit("")
+			return nil
+		},
+	})
+}
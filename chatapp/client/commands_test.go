@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// plainClient is a chatClient printing plain lines into out, the way it does
+// when the terminal can't be split.
+func plainClient(out *strings.Builder) *chatClient {
+	c := &chatClient{
+		ui:       &terminalUI{out: out, onEdit: func(string) {}, panes: make(map[string]*pane)},
+		commands: newCommandRegistry(),
+		sessions: make(map[string]*chatSession),
+	}
+	registerChatCommands(c.commands)
+	return c
+}
+
+func TestCommandRegistry(t *testing.T) {
+	var out strings.Builder
+	c := plainClient(&out)
+	var got []string
+	c.commands.register("/echo", &command{
+		usage:  "/echo <text>",
+		help:   "say it back",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			switch args {
+			case "":
+				return errUsage
+			case "fail":
+				return status.Error(codes.PermissionDenied, "not allowed")
+			}
+			got = append(got, args)
+			return nil
+		},
+	})
+	printed := func(what, want string) {
+		t.Helper()
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("%s printed %q, want %q in it", what, out.String(), want)
+		}
+		out.Reset()
+	}
+
+	if c.commands.run(c, "hello /echo") || out.Len() > 0 {
+		t.Fatalf("a line that isn't a command was taken for one, printing %q", out.String())
+	}
+	if !c.commands.run(c, "/nope") {
+		t.Fatal("an unknown command would be sent to the room")
+	}
+	printed("an unknown command", "Unknown command /nope")
+	c.commands.run(c, "/echo hi")
+	printed("a room command without a room", "/echo needs a room")
+
+	c.ui.AddRoom("lobby")
+	c.commands.run(c, "/echo   hi there  ")
+	if len(got) != 1 || got[0] != "hi there" {
+		t.Fatalf("the command got %q", got)
+	}
+	c.commands.run(c, "/echo")
+	printed("missing arguments", "Use: /echo <text>")
+	c.commands.run(c, "/echo fail")
+	printed("a failing command", "/echo failed: not allowed")
+
+	c.commands.run(c, "/join")
+	printed("/join without a room", "Use: /join <room>")
+	c.commands.run(c, "/help")
+	help := out.String()
+	if i, j := strings.Index(help, "/echo <text>"), strings.Index(help, "/join <room>"); i < 0 || j < i {
+		t.Fatalf("/help printed %q", help)
+	}
+}
+
+func TestPlainLinesNameTheirRoom(t *testing.T) {
+	var out strings.Builder
+	ui := plainClient(&out).ui
+	ui.AddRoom("lobby")
+	ui.Printf("lobby", "only one room, no tag")
+	ui.AddRoom("games")
+	ui.Printf("lobby", "two rooms")
+	ui.Printf("nowhere", "for the room on screen")
+
+	want := "only one room, no tag\n[lobby] two rooms\n[games] for the room on screen\n"
+	if out.String() != want {
+		t.Fatalf("printed %q, want %q", out.String(), want)
+	}
+}
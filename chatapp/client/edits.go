@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// sendChange sends msg, an edit, delete, reaction or reply, to the room on
+// screen. shown is what we echo once the server took it.
+func (c *chatClient) sendChange(msg *pb.ChatRoomMessage, shown string) error {
+	room := c.ui.Active()
+	session := c.session(room)
+	if session == nil {
+		return fmt.Errorf("you are not in %s", room)
+	}
+	msg.Sender = c.user
+	msg.Room = room
+	msg.ClientId = session.receipts.newClientID(shown)
+	return session.send(msg)
+}
+
+// seqAndRest splits "<seq> <rest>", as most of these commands take.
+func seqAndRest(args string) (uint64, string, error) {
+	first, rest, _ := strings.Cut(args, " ")
+	seq, err := strconv.ParseUint(strings.TrimPrefix(first, "#"), 10, 64)
+	if err != nil || seq == 0 {
+		return 0, "", errUsage
+	}
+	return seq, strings.TrimSpace(rest), nil
+}
+
+func registerMessageCommands(r *commandRegistry) {
+	r.register("/reply", &command{
+		usage:  "/reply <#> <message>",
+		help:   "reply to a message, by the # it is shown with",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			seq, text, err := seqAndRest(args)
+			if err != nil || text == "" {
+				return errUsage
+			}
+			return c.sendChange(&pb.ChatRoomMessage{Content: text, ReplyTo: seq}, fmt.Sprintf("(re #%d) %s", seq, text))
+		},
+	})
+	r.register("/edit", &command{
+		usage:  "/edit <#> <new text>",
+		help:   "change a message you sent",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			seq, text, err := seqAndRest(args)
+			if err != nil || text == "" {
+				return errUsage
+			}
+			return c.sendChange(&pb.ChatRoomMessage{Type: "edit", Target: seq, Content: text}, fmt.Sprintf("edited #%d: %s", seq, text))
+		},
+	})
+	r.register("/delete", &command{
+		usage:  "/delete <#>",
+		help:   "delete a message you sent, moderators can delete any",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			seq, rest, err := seqAndRest(args)
+			if err != nil || rest != "" {
+				return errUsage
+			}
+			return c.sendChange(&pb.ChatRoomMessage{Type: "delete", Target: seq}, fmt.Sprintf("deleted #%d", seq))
+		},
+	})
+	r.register("/react", &command{
+		usage:  "/react <#> <emoji>",
+		help:   "react to a message",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			seq, emoji, err := seqAndRest(args)
+			if err != nil || emoji == "" {
+				return errUsage
+			}
+			return c.sendChange(&pb.ChatRoomMessage{Type: "reaction", Target: seq, Reaction: emoji}, fmt.Sprintf("reacted %s to #%d", emoji, seq))
+		},
+	})
+	r.register("/unreact", &command{
+		usage:  "/unreact <#> <emoji>",
+		help:   "take a reaction back",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			seq, emoji, err := seqAndRest(args)
+			if err != nil || emoji == "" {
+				return errUsage
+			}
+			return c.sendChange(&pb.ChatRoomMessage{Type: "reaction", Target: seq, Reaction: emoji, Undo: true}, fmt.Sprintf("took back %s on #%d", emoji, seq))
+		},
+	})
+	r.register("/thread", &command{
+		usage:  "/thread <#>",
+		help:   "show a message with the replies to it",
+		inRoom: true,
+		run: func(c *chatClient, args string) error {
+			seq, rest, err := seqAndRest(args)
+			if err != nil || rest != "" {
+				return errUsage
+			}
+			room := c.ui.Active()
+			resp, err := c.client.GetRoomHistory(context.Background(), &pb.HistoryRequest{Room: room, Thread: seq})
+			if err != nil {
+				return err
+			}
+			if len(resp.Messages) == 0 {
+				return fmt.Errorf("there is no message #%d", seq)
+			}
+			c.ui.Printf(room, "---- thread of #%d ----", seq)
+			for _, msg := range resp.Messages {
+				c.ui.Printf(room, "%s", formatMessage(msg))
+			}
+			c.ui.Printf(room, "---- end of thread ----")
+			return nil
+		},
+	})
+}
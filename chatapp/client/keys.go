@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	pb "example/hello/chatapp/grpc"
+
+	"golang.org/x/crypto/nacl/box"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// keyRing is our X25519 key pair and the keys we trust for everyone we talk
+// to privately. The first key we see for someone is trusted; after that a
+// different one has to be accepted with /trust before we encrypt to it.
+type keyRing struct {
+	dir     string
+	public  *[32]byte
+	private *[32]byte
+
+	mu    sync.Mutex
+	known map[string][]byte
+}
+
+// defaultKeyDir is where the key pairs and trusted keys live unless -keys says
+// otherwise.
+func defaultKeyDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".chatapp-keys"
+	}
+	return filepath.Join(dir, "chatapp")
+}
+
+// loadKeyRing reads user's key pair from dir, making one the first time.
+func loadKeyRing(dir, user string) (*keyRing, error) {
+	dir = filepath.Join(dir, user)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	k := &keyRing{
+		dir:   dir,
+		known: make(map[string][]byte),
+	}
+
+	path := filepath.Join(dir, "identity.key")
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		k.public, k.private, err = box.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, k.private[:], 0600); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case len(data) != 32:
+		return nil, fmt.Errorf("%s is not a key", path)
+	default:
+		private, err := ecdh.X25519().NewPrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		k.private = (*[32]byte)(data)
+		k.public = (*[32]byte)(private.PublicKey().Bytes())
+	}
+
+	data, err = os.ReadFile(k.knownPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &k.known); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", k.knownPath(), err)
+		}
+	}
+	return k, nil
+}
+
+func (k *keyRing) knownPath() string {
+	return filepath.Join(k.dir, "known_keys.json")
+}
+
+// fingerprint is what two people read out to each other to compare keys.
+func fingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	digits := hex.EncodeToString(sum[:16])
+	groups := make([]string, 0, len(digits)/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// seen records key as peer's if we don't know one yet. It returns the key we
+// trust, which differs from key when peer's key changed.
+func (k *keyRing) seen(peer string, key []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if trusted, ok := k.known[peer]; ok {
+		return trusted, nil
+	}
+	k.known[peer] = key
+	return key, k.saveLocked()
+}
+
+// trust replaces the key we trust for peer.
+func (k *keyRing) trust(peer string, key []byte) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.known[peer] = key
+	return k.saveLocked()
+}
+
+func (k *keyRing) saveLocked() error {
+	data, err := json.MarshalIndent(k.known, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(k.knownPath(), data, 0600)
+}
+
+// seal encrypts content from us to recipientKey.
+func (k *keyRing) seal(content string, recipientKey []byte) (*pb.EncryptedContent, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	return &pb.EncryptedContent{
+		Ciphertext:   box.Seal(nil, []byte(content), &nonce, (*[32]byte)(recipientKey), k.private),
+		Nonce:        nonce[:],
+		SenderKey:    k.public[:],
+		RecipientKey: recipientKey,
+	}, nil
+}
+
+// open decrypts a message we sent or got. Either way the other side's key
+// is the one that isn't ours.
+func (k *keyRing) open(enc *pb.EncryptedContent) (string, error) {
+	other := enc.SenderKey
+	if bytes.Equal(other, k.public[:]) {
+		other = enc.RecipientKey
+	}
+	if len(other) != 32 || len(enc.Nonce) != 24 {
+		return "", errors.New("malformed encrypted message")
+	}
+	plain, ok := box.Open(nil, enc.Ciphertext, (*[24]byte)(enc.Nonce), (*[32]byte)(other), k.private)
+	if !ok {
+		return "", errors.New("can't decrypt, it wasn't sealed for this key")
+	}
+	return string(plain), nil
+}
+
+// publishKey makes sure the key directory has our current key, and whether
+// we only take encrypted private messages.
+func (c *chatClient) publishKey(encryptedOnly bool) error {
+	current, err := c.client.GetPublicKey(context.Background(), &pb.PublicKeyRequest{User: c.user})
+	if err == nil && bytes.Equal(current.Key, c.keys.public[:]) && current.EncryptedOnly == encryptedOnly {
+		return nil
+	}
+	if err != nil && status.Code(err) != codes.NotFound {
+		return err
+	}
+	_, err = c.client.PublishKey(context.Background(), &pb.PublicKey{Key: c.keys.public[:], EncryptedOnly: encryptedOnly})
+	return err
+}
+
+// recipientKey looks up who we are writing to in the key directory and
+// checks the key against the one we trust.
+func (c *chatClient) recipientKey(peer string) ([]byte, error) {
+	resp, err := c.client.GetPublicKey(context.Background(), &pb.PublicKeyRequest{User: peer})
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%s hasn't published a key yet, private messages to them can't be encrypted", peer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := c.keys.seen(peer, resp.Key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(trusted, resp.Key) {
+		c.warnKeyChange(peer, trusted, resp.Key)
+		return nil, fmt.Errorf("not sent, %s's key changed", peer)
+	}
+	return resp.Key, nil
+}
+
+func (c *chatClient) warnKeyChange(peer string, trusted, key []byte) {
+	c.notice("WARNING: %s's key changed! It was %s and is now %s.", peer, fingerprint(trusted), fingerprint(key))
+	c.notice("They may have a new device, or someone is listening in. Compare fingerprints with them, then /trust %s.", peer)
+}
+
+// privateContent is what to show for msg, decrypting it if it is encrypted.
+// Messages from someone whose key changed are shown with a warning.
+func (c *chatClient) privateContent(msg *pb.PrivateMessage) string {
+	enc := msg.GetEncrypted()
+	if enc == nil {
+		return msg.GetContent() + " (not encrypted)"
+	}
+	content, err := c.keys.open(enc)
+	if err != nil {
+		return "[" + err.Error() + "]"
+	}
+	if msg.Sender != c.user {
+		if trusted, err := c.keys.seen(msg.Sender, enc.SenderKey); err == nil && !bytes.Equal(trusted, enc.SenderKey) {
+			content += " (sent with a key you haven't trusted, /trust " + msg.Sender + ")"
+		}
+	}
+	return content
+}
+
+func registerKeyCommands(r *commandRegistry) {
+	r.register("/fingerprint", &command{
+		usage: "/fingerprint [user]",
+		help:  "show your key fingerprint, or the one of a user's key",
+		run: func(c *chatClient, args string) error {
+			if args == "" {
+				c.notice("Your key: %s", fingerprint(c.keys.public[:]))
+				return nil
+			}
+			resp, err := c.client.GetPublicKey(context.Background(), &pb.PublicKeyRequest{User: args})
+			if err != nil {
+				return err
+			}
+			c.notice("%s's key: %s", args, fingerprint(resp.Key))
+			trusted, err := c.keys.seen(args, resp.Key)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(trusted, resp.Key) {
+				c.warnKeyChange(args, trusted, resp.Key)
+			}
+			return nil
+		},
+	})
+	r.register("/trust", &command{
+		usage: "/trust <user>",
+		help:  "accept a user's new key once you compared fingerprints",
+		run: func(c *chatClient, args string) error {
+			if args == "" || strings.Contains(args, " ") {
+				return errUsage
+			}
+			resp, err := c.client.GetPublicKey(context.Background(), &pb.PublicKeyRequest{User: args})
+			if err != nil {
+				return err
+			}
+			if err := c.keys.trust(args, resp.Key); err != nil {
+				return err
+			}
+			c.notice("Trusting %s's key %s", args, fingerprint(resp.Key))
+			return nil
+		},
+	})
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"bufio"
-	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -14,10 +17,26 @@ import (
 	pb "example/hello/chatapp/grpc"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+const replayOnJoin = 20
+
 func main() {
-	conn, err := grpc.Dial("localhost:50051", grpc.WithInsecure())
+	keyDir := flag.String("keys", defaultKeyDir(), "directory for your private message keys")
+	serverAddr := flag.String("server", "localhost:50051", "address of the chat server")
+	useTLS := flag.Bool("tls", false, "connect with TLS, checking the server's certificate against the system's CAs")
+	tlsCA := flag.String("tls-ca", "", "PEM CA certificate to check the server's certificate with, implies -tls")
+	encryptedOnly := flag.Bool("encrypted-only", false, "turn down private messages that aren't encrypted, like those sent from the web gateway")
+	flag.Parse()
+
+	creds, err := transportCredentials(*useTLS, *tlsCA)
+	if err != nil {
+		log.Fatalf("Failed to load TLS settings: %v", err)
+	}
+	auth := &tokenAuth{}
+	conn, err := grpc.Dial(*serverAddr, grpc.WithTransportCredentials(creds), grpc.WithPerRPCCredentials(auth))
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -29,134 +48,109 @@ func main() {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
-	log.Print("Enter your username: ")
-	sender, _ := reader.ReadString('\n')
-	sender = strings.TrimSpace(sender)
-
-	rooms, err := client.GetExistingChatRooms(context.Background(), &pb.Empty{})
+	sender := login(client, auth, reader)
+	keys, err := loadKeyRing(*keyDir, sender)
 	if err != nil {
-		log.Fatalf("Failed to fetch the rooms: %v", err)
+		log.Fatalf("Failed to load your keys: %v", err)
 	}
 
-	if len(rooms.Rooms) == 0 {
-		log.Print("No existing rooms found. You can create one by entering a room name.")
-	} else {
-		log.Printf("Available rooms: %v.Enter the room you want to join or you can also create your own room by entering a new room name.", rooms.Rooms)
-	}
+	ui := newTerminalUI(reader)
+	defer ui.Close()
+	// whatever else gets logged shows up on screen like our own notices
+	log.SetOutput(ui)
+	log.SetFlags(0)
 
-	room, _ := reader.ReadString('\n')
-	room = strings.TrimSpace(room)
-
-	roomJoined, err := client.JoinRoom(context.Background(), &pb.JoinRequest{Sender: sender, Room: room})
-	if err != nil {
-		log.Fatalf("Failed to join room: %v", err)
-	}
-	log.Printf("You joined room %s with %v other members in the room: %v", room,len(roomJoined.Members),roomJoined.Members)
+	app := newChatClient(client, sender, keys, ui)
 
 	go func() {
 		<-signalChan
+		app.quit("sigexit")
+		ui.Close()
 		fmt.Println()
-		_, err := client.LeaveChatRoom(context.Background(), &pb.LeaveRequest{
-			Sender: sender,
-			Room:   room,
-			Type:   "sigexit",
-		})
-		if err != nil {
-			log.Printf("Error leaving chat on Ctrl+C: %v", err)
-		}
 		os.Exit(0)
 	}()
 
-	go func() {
-		joinReq := &pb.JoinRequest{
-			Sender: sender,
-			Room:   room,
-		}
-		stream, err := client.BroadcastRoomUpdate(context.Background(), joinReq)
-		if err != nil {
-			log.Printf("Error joining update stream: %v", err)
-			return
-		}
-		for {
-			update, err := stream.Recv()
-			if err != nil {
-				log.Printf("Update stream ended: %v", err)
-				return
-			}
-			log.Printf("[UPDATE]: %s", update.Update)
-			if update.Type == "joined" && update.Sender == sender {
-				fmt.Print("press ENTER to start chatting")
-			}
-		}
-	}()
-
-	stream, err := client.RoomChat(context.Background())
-	if err != nil {
-		log.Fatalf("Failed to start room chat stream: %v", err)
+	app.notice("Logged in as %s. /join <room> joins or creates a room, /help lists the commands.", sender)
+	if err := app.publishKey(*encryptedOnly); err != nil {
+		app.notice("Couldn't publish your key, others can't send you private messages: %v", err)
 	}
-
-	go func() {
-		for {
-			msg, err := stream.Recv()
-			if err != nil {
-				log.Printf("Error receiving message: %v", err)
-				return
-			}
-			format := "[%s]: %s"
-			if msg.Room == "private" {
-				format = "Private message from [%s]: %s"
-			}
-			log.Printf(format, msg.Sender, msg.Content)
-		}
-	}()
+	app.notice("Your key fingerprint is %s", fingerprint(keys.public[:]))
+	app.handleLine("/rooms")
 
 	for {
-		text, _ := reader.ReadString('\n')
-		text = strings.TrimSpace(text)
-
-		if strings.HasPrefix(text, "/pm") {
-			parts := strings.SplitN(text, " ", 3)
-			if len(parts) != 3 {
-				log.Println("Use: /pm <recipient> <message> to send a private message")
-				continue
-			}
-			recipient := parts[1]
-			message := parts[2]
-
-			_, err := client.SendPrivateMessage(context.Background(), &pb.PrivateMessage{
-				Sender:    sender,
-				Recipient: recipient,
-				Content:   message,
-			})
-			if err != nil {
-				log.Printf("Failed to send private message: %v", err)
-			}
-			continue
+		text, err := ui.ReadLine()
+		if err == io.EOF {
+			text = "/exit"
 		}
-
-		if text == "/exit" {
-			_, err := client.LeaveChatRoom(context.Background(), &pb.LeaveRequest{
-				Sender: sender,
-				Room:   room,
-			})
-			if err != nil {
-				log.Printf("Leave error: %v", err)
-			}
-			log.Println("You left the room.")
+		if !app.handleLine(text) {
 			break
 		}
+	}
+}
 
-		err := stream.Send(&pb.ChatRoomMessage{
-			Sender:  sender,
-			Room:    room,
-			Content: text,
-		})
+// transportCredentials is plain TCP unless the server is asked for over TLS.
+func transportCredentials(useTLS bool, caFile string) (credentials.TransportCredentials, error) {
+	if !useTLS && caFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
 		if err != nil {
-			log.Printf("Failed to send message: %v", err)
-			break
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
 		}
 	}
+	return credentials.NewTLS(config), nil
+}
 
-	time.Sleep(time.Second)
-	stream.CloseSend()
+func formatMessage(msg *pb.ChatRoomMessage) string {
+	sender := msg.Sender
+	if msg.Bot {
+		sender += " (bot)"
+	}
+	prefix := fmt.Sprintf("%s #%d [%s]", formatTime(msg.Timestamp), msg.Seq, sender)
+	switch msg.Type {
+	case "edit":
+		if msg.Content == "" {
+			// scrubbed when the message was deleted
+			return fmt.Sprintf("%s edited #%d", prefix, msg.Target)
+		}
+		return fmt.Sprintf("%s edited #%d: %s", prefix, msg.Target, msg.Content)
+	case "delete":
+		return fmt.Sprintf("%s deleted #%d", prefix, msg.Target)
+	case "reaction":
+		if msg.Undo {
+			return fmt.Sprintf("%s took back %s on #%d", prefix, msg.Reaction, msg.Target)
+		}
+		return fmt.Sprintf("%s reacted %s to #%d", prefix, msg.Reaction, msg.Target)
+	}
+
+	if msg.Deleted {
+		return prefix + ": [deleted]"
+	}
+	content := msg.Content
+	if msg.ReplyTo > 0 {
+		content = fmt.Sprintf("(re #%d) %s", msg.ReplyTo, content)
+	}
+	if msg.Attachment != nil {
+		content = strings.TrimSpace(content + " " + formatAttachment(msg.Attachment))
+	}
+	if msg.Edited > 0 {
+		content += " (edited)"
+	}
+	for _, r := range msg.Reactions {
+		content += fmt.Sprintf(" [%s %d]", r.Emoji, len(r.Users))
+	}
+	return prefix + ": " + content
+}
+
+func formatTime(millis int64) string {
+	if millis == 0 {
+		return "--:--:--"
+	}
+	return time.UnixMilli(millis).Format("15:04:05")
 }
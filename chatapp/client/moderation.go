@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+var moderationCommands = []struct{ name, usage, help string }{
+	{"/info", "/info", "show owner, topic, roles and members"},
+	{"/kick", "/kick <user> [reason]", ""},
+	{"/ban", "/ban <user> [duration] [reason]", "duration like 10m or 2h, none means until /unban"},
+	{"/unban", "/unban <user>", ""},
+	{"/mute", "/mute <user> [duration] [reason]", ""},
+	{"/unmute", "/unmute <user>", ""},
+	{"/mod", "/mod <user>", "owner only"},
+	{"/demote", "/demote <user>", "owner only"},
+	{"/invite", "/invite [user] [ttl]", "invite code, without a user anyone can use it until it expires"},
+	{"/visibility", "/visibility <mode> [password]", "public, unlisted, password or invite, owner only"},
+}
+
+// registerModerationCommands adds the moderation commands, which all act on
+// the room on screen.
+func registerModerationCommands(r *commandRegistry) {
+	for _, mc := range moderationCommands {
+		name := mc.name
+		r.register(name, &command{
+			usage:  mc.usage,
+			help:   mc.help,
+			inRoom: true,
+			run: func(c *chatClient, args string) error {
+				moderationCommand(c.client, c.ui.Active(), name+" "+args)
+				return nil
+			},
+		})
+	}
+	r.register("/modhelp", &command{
+		usage: "/modhelp",
+		help:  "list the moderation commands",
+		run: func(c *chatClient, args string) error {
+			c.notice("Moderation commands:")
+			for _, mc := range moderationCommands {
+				c.notice("  %-38s %s", mc.usage, mc.help)
+			}
+			c.notice("  %-38s %s", "/topic [text or -]", "show, set or clear the room topic")
+			return nil
+		},
+	})
+}
+
+// moderationCommand runs text if it is one of the room moderation commands
+// and reports whether it was.
+func moderationCommand(client pb.ChatClient, room, text string) bool {
+	parts := strings.Fields(text)
+	if len(parts) == 0 {
+		return false
+	}
+	ctx := context.Background()
+
+	var (
+		resp *pb.MessageResponse
+		err  error
+	)
+	switch parts[0] {
+	case "/info":
+		info, err := client.GetRoomInfo(ctx, &pb.RoomInfoRequest{Room: room})
+		if err != nil {
+			log.Printf("Failed to get room info: %v", err)
+			return true
+		}
+		log.Printf("Room %s, owner %s, topic: %q", info.Room, info.Owner, info.Topic)
+		for user, role := range info.Roles {
+			log.Printf("  %s is a %s", user, role)
+		}
+		log.Printf("  members online: %v", info.Members)
+		return true
+	case "/kick":
+		if len(parts) < 2 {
+			log.Println("Use: /kick <user> [reason]")
+			return true
+		}
+		resp, err = client.KickMember(ctx, &pb.ModerationRequest{Room: room, Target: parts[1], Reason: strings.Join(parts[2:], " ")})
+	case "/ban", "/mute":
+		if len(parts) < 2 {
+			log.Printf("Use: %s <user> [duration] [reason]", parts[0])
+			return true
+		}
+		req := &pb.ModerationRequest{Room: room, Target: parts[1]}
+		rest := parts[2:]
+		if len(rest) > 0 {
+			if d, perr := time.ParseDuration(rest[0]); perr == nil {
+				req.DurationSeconds = int64(d.Seconds())
+				rest = rest[1:]
+			}
+		}
+		req.Reason = strings.Join(rest, " ")
+		if parts[0] == "/ban" {
+			resp, err = client.BanMember(ctx, req)
+		} else {
+			resp, err = client.MuteMember(ctx, req)
+		}
+	case "/unban", "/unmute":
+		if len(parts) != 2 {
+			log.Printf("Use: %s <user>", parts[0])
+			return true
+		}
+		req := &pb.ModerationRequest{Room: room, Target: parts[1]}
+		if parts[0] == "/unban" {
+			resp, err = client.UnbanMember(ctx, req)
+		} else {
+			resp, err = client.UnmuteMember(ctx, req)
+		}
+	case "/mod", "/demote":
+		if len(parts) != 2 {
+			log.Printf("Use: %s <user>", parts[0])
+			return true
+		}
+		role := "moderator"
+		if parts[0] == "/demote" {
+			role = "member"
+		}
+		resp, err = client.SetMemberRole(ctx, &pb.RoleRequest{Room: room, Target: parts[1], Role: role})
+	case "/invite":
+		req := &pb.InviteRequest{Room: room}
+		for _, arg := range parts[1:] {
+			if d, perr := time.ParseDuration(arg); perr == nil {
+				req.TtlSeconds = int64(d.Seconds())
+			} else {
+				req.Invitee = arg
+			}
+		}
+		invite, err := client.InviteToRoom(ctx, req)
+		if err != nil {
+			log.Printf("/invite failed: %v", err)
+			return true
+		}
+		log.Printf("Invite code %s, valid until %s", invite.Code, time.Unix(invite.ExpiresAt, 0).Format(time.RFC1123))
+		return true
+	case "/visibility":
+		if len(parts) < 2 || len(parts) > 3 {
+			log.Println("Use: /visibility <public|unlisted|password|invite> [password]")
+			return true
+		}
+		req := &pb.VisibilityRequest{Room: room, Visibility: parts[1]}
+		if len(parts) == 3 {
+			req.Password = parts[2]
+		}
+		resp, err = client.SetRoomVisibility(ctx, req)
+	default:
+		return false
+	}
+
+	if err != nil {
+		log.Printf("%s failed: %v", parts[0], err)
+		return true
+	}
+	log.Println(resp.Status)
+	return true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// typingRefresh is how often we tell the room we are still typing, the server
+// forgets about it a little after.
+const typingRefresh = 3 * time.Second
+
+// typingTracker knows who in the room is typing right now.
+type typingTracker struct {
+	mu     sync.Mutex
+	typing map[string]bool
+	// show is called with a line like "bob is typing…" whenever who is typing
+	// changes, or "" once nobody is.
+	show func(status string)
+}
+
+func newTypingTracker() *typingTracker {
+	return &typingTracker{
+		typing: make(map[string]bool),
+		show:   func(string) {},
+	}
+}
+
+func (t *typingTracker) set(user string, typing bool) {
+	t.mu.Lock()
+	if t.typing[user] == typing {
+		t.mu.Unlock()
+		return
+	}
+	if typing {
+		t.typing[user] = true
+	} else {
+		delete(t.typing, user)
+	}
+	users := make([]string, 0, len(t.typing))
+	for user := range t.typing {
+		users = append(users, user)
+	}
+	t.mu.Unlock()
+
+	sort.Strings(users)
+	switch len(users) {
+	case 0:
+		t.show("")
+	case 1:
+		t.show(users[0] + " is typing…")
+	case 2, 3:
+		t.show(strings.Join(users[:len(users)-1], ", ") + " and " + users[len(users)-1] + " are typing…")
+	default:
+		t.show(fmt.Sprintf("%d people are typing…", len(users)))
+	}
+}
+
+func registerPresenceCommands(r *commandRegistry) {
+	r.register("/presence", &command{
+		usage: "/presence [user...]",
+		help:  "show when people were last around, everyone in the room by default",
+		run: func(c *chatClient, args string) error {
+			req := &pb.PresenceRequest{Users: strings.Fields(args)}
+			if len(req.Users) == 0 {
+				req.Room = c.ui.Active()
+				if req.Room == "" {
+					return errUsage
+				}
+			}
+			resp, err := c.client.GetPresence(context.Background(), req)
+			if err != nil {
+				return err
+			}
+			for _, p := range resp.Users {
+				line := fmt.Sprintf("  %s is %s", p.User, p.Status)
+				if p.Status != "online" && p.LastSeen > 0 {
+					line += ", last seen " + time.UnixMilli(p.LastSeen).Format("Jan 2 15:04:05")
+				}
+				c.notice("%s", line)
+			}
+			return nil
+		},
+	})
+}
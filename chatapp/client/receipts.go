@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// receiptTracker remembers which seq our own messages got and which seq we
+// have seen from the room, so we can ack them and show delivered/read state.
+type receiptTracker struct {
+	mu       sync.Mutex
+	self     string
+	nextID   int
+	pending  map[string]string // client id -> content, waiting for the "sent" receipt
+	own      map[uint64]string // seq -> content of messages we sent
+	reported map[string]uint64 // "member/status" -> highest own seq already shown
+	lastSeen uint64
+	lastRead uint64
+	print    func(format string, args ...any)
+	// echo prints our own messages once the room took them, for screens that
+	// don't keep what was typed.
+	echo bool
+}
+
+func newReceiptTracker(self string) *receiptTracker {
+	return &receiptTracker{
+		self:     self,
+		pending:  make(map[string]string),
+		own:      make(map[uint64]string),
+		reported: make(map[string]uint64),
+		print:    log.Printf,
+	}
+}
+
+func (t *receiptTracker) newClientID(content string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	id := fmt.Sprintf("%s-%d", t.self, t.nextID)
+	t.pending[id] = content
+	return id
+}
+
+// seen records a room message and reports whether it is new to us. Messages
+// replayed from history after we already got them live are dropped.
+func (t *receiptTracker) seen(seq uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if seq == 0 {
+		return true
+	}
+	if seq <= t.lastSeen {
+		return false
+	}
+	t.lastSeen = seq
+	return true
+}
+
+func (t *receiptTracker) last() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastSeen
+}
+
+// unread returns the seq to send a "read" ack for, or 0 if there is nothing new.
+func (t *receiptTracker) unread() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lastSeen <= t.lastRead {
+		return 0
+	}
+	t.lastRead = t.lastSeen
+	return t.lastRead
+}
+
+func (t *receiptTracker) handle(msg *pb.ChatRoomMessage) {
+	r := msg.Receipt
+	if r == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r.Member == t.self {
+		content := t.pending[msg.ClientId]
+		delete(t.pending, msg.ClientId)
+		if r.Status == "failed" {
+			t.print("Message %q could not be delivered: %s", content, r.Error)
+			return
+		}
+		t.own[r.Seq] = content
+		if t.echo {
+			t.print("%s", formatMessage(&pb.ChatRoomMessage{
+				Seq:       r.Seq,
+				Sender:    t.self,
+				Content:   content,
+				Timestamp: time.Now().UnixMilli(),
+			}))
+		}
+		if r.Seq > t.lastSeen {
+			t.lastSeen = r.Seq
+		}
+		return
+	}
+
+	// only report the newest of our own messages the receipt covers
+	key := r.Member + "/" + r.Status
+	var newest uint64
+	for seq := range t.own {
+		if seq <= r.Seq && seq > t.reported[key] && seq > newest {
+			newest = seq
+		}
+	}
+	if newest == 0 {
+		return
+	}
+	t.reported[key] = newest
+
+	verb := "delivered to"
+	if r.Status == "read" {
+		verb = "read by"
+	}
+	t.print("(#%d %q %s %s)", newest, t.own[newest], verb, r.Member)
+}
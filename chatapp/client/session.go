@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+	// a stream that stayed up this long counts as healthy again
+	stableConnection = 30 * time.Second
+)
+
+var errNotConnected = errors.New("not connected to the server, reconnecting")
+
+type backoff struct {
+	next time.Duration
+}
+
+func (b *backoff) wait() {
+	d := b.next
+	if d == 0 {
+		d = minBackoff
+	}
+	jittered := d/2 + time.Duration(rand.Int63n(int64(d)))
+	log.Printf("Reconnecting in %v...", jittered.Round(100*time.Millisecond))
+	time.Sleep(jittered)
+
+	b.next = d * 2
+	if b.next > maxBackoff {
+		b.next = maxBackoff
+	}
+}
+
+func (b *backoff) reset() {
+	b.next = 0
+}
+
+// chatSession is our membership in one room. Its traffic goes over the
+// client's one RoomChat stream; after a reconnect it subscribes again with the
+// session id and the last seq we saw, so nothing is missed.
+type chatSession struct {
+	client   pb.ChatClient
+	user     string
+	room     string
+	receipts *receiptTracker
+	typing   *typingTracker
+	// post sends on the RoomChat stream
+	post func(msg *pb.ChatRoomMessage) error
+
+	// sent with every join, so a rejoin after the session expired gets in too
+	password   string
+	inviteCode string
+	visibility string
+
+	// print shows a line in the room's pane, ended is called when the server
+	// let go of us without us leaving, e.g. on a kick.
+	print func(format string, args ...any)
+	ended func()
+
+	mu        sync.Mutex
+	sessionID string
+	leaving   bool
+	// when we last told the room we are typing, zero once we sent the message
+	typedAt time.Time
+}
+
+func newChatSession(client pb.ChatClient, user, room string, post func(*pb.ChatRoomMessage) error) *chatSession {
+	return &chatSession{
+		client:   client,
+		user:     user,
+		room:     room,
+		receipts: newReceiptTracker(user),
+		typing:   newTypingTracker(),
+		post:     post,
+		print:    log.Printf,
+		ended:    func() {},
+	}
+}
+
+func (c *chatSession) join(replay int32) (*pb.JoinRoomResponse, error) {
+	resp, err := c.client.JoinRoom(context.Background(), &pb.JoinRequest{
+		Sender:     c.user,
+		Room:       c.room,
+		Replay:     replay,
+		Password:   c.password,
+		InviteCode: c.inviteCode,
+		Visibility: c.visibility,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range resp.History {
+		c.receipts.seen(msg.Seq)
+	}
+
+	c.mu.Lock()
+	c.sessionID = resp.SessionId
+	c.mu.Unlock()
+	return resp, nil
+}
+
+// subscribe asks for the room's traffic on the stream, starting after the
+// last message we saw.
+func (c *chatSession) subscribe() error {
+	c.mu.Lock()
+	resume := &pb.Resume{SessionId: c.sessionID, LastSeq: c.receipts.last()}
+	c.mu.Unlock()
+
+	return c.post(&pb.ChatRoomMessage{Sender: c.user, Room: c.room, Type: "subscribe", Resume: resume})
+}
+
+func (c *chatSession) send(msg *pb.ChatRoomMessage) error {
+	if msg.Type == "" {
+		// the server stops showing us as typing when a message arrives
+		c.mu.Lock()
+		c.typedAt = time.Time{}
+		c.mu.Unlock()
+	}
+	return c.post(msg)
+}
+
+// edited is called with the line being typed after every key, and lets the
+// room know we are typing a message.
+func (c *chatSession) edited(line string) {
+	if line == "" || strings.HasPrefix(line, "/") {
+		return
+	}
+
+	c.mu.Lock()
+	if time.Since(c.typedAt) < typingRefresh {
+		c.mu.Unlock()
+		return
+	}
+	c.typedAt = time.Now()
+	c.mu.Unlock()
+
+	// losing one of these doesn't matter, the next key sends another
+	c.post(&pb.ChatRoomMessage{Sender: c.user, Room: c.room, Type: "typing"})
+}
+
+// leave tells the server we are gone for good.
+func (c *chatSession) leave(leaveType string) error {
+	c.mu.Lock()
+	c.leaving = true
+	c.mu.Unlock()
+
+	_, err := c.client.LeaveChatRoom(context.Background(), &pb.LeaveRequest{
+		Sender: c.user,
+		Room:   c.room,
+		Type:   leaveType,
+	})
+	return err
+}
+
+func (c *chatSession) isLeaving() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leaving
+}
+
+// handle takes whatever the stream got for this room.
+func (c *chatSession) handle(msg *pb.ChatRoomMessage) {
+	switch msg.Type {
+	case "receipt":
+		c.receipts.handle(msg)
+		return
+	case "update":
+		c.update(msg.Update)
+		return
+	case "unsubscribed":
+		c.unsubscribed(codes.Code(msg.Code), msg.Content)
+		return
+	}
+	if !c.receipts.seen(msg.Seq) {
+		return
+	}
+	c.typing.set(msg.Sender, false)
+	c.print("%s", formatMessage(msg))
+	c.ack(msg.Seq, "delivered")
+}
+
+func (c *chatSession) update(update *pb.Update) {
+	if update == nil {
+		return
+	}
+	switch update.Type {
+	case "typing":
+		if update.Sender != c.user {
+			c.typing.set(update.Sender, update.Typing)
+		}
+		return
+	case "left", "kicked", "banned":
+		c.typing.set(update.Sender, false)
+	}
+	c.print("[UPDATE]: %s", update.Update)
+}
+
+// unsubscribed is told why the server stopped sending us the room.
+func (c *chatSession) unsubscribed(code codes.Code, reason string) {
+	if c.isLeaving() {
+		return
+	}
+
+	switch code {
+	case codes.OK:
+		// the server let go of us on purpose, e.g. we were kicked
+		c.ended()
+		c.print("You are no longer in %s", c.room)
+	case codes.NotFound, codes.FailedPrecondition:
+		// the grace period ran out, join again and replay from our last seq
+		c.print("Chat session expired, rejoining %s", c.room)
+		go c.rejoin()
+	case codes.Aborted:
+		c.ended()
+		c.print("Chat session of %s was taken over by another connection: %s", c.room, reason)
+	default:
+		// e.g. we fell behind, pick up from the last seq we saw
+		c.print("Lost the room: %s, catching up", reason)
+		if err := c.subscribe(); err != nil && !errors.Is(err, errNotConnected) {
+			c.print("Failed to subscribe to %s: %v", c.room, err)
+		}
+	}
+}
+
+// rejoin joins the room again after our session expired, then subscribes.
+func (c *chatSession) rejoin() {
+	var b backoff
+	for !c.isLeaving() {
+		_, err := c.join(0)
+		if err == nil {
+			if err := c.subscribe(); err != nil && !errors.Is(err, errNotConnected) {
+				c.print("Failed to subscribe to %s: %v", c.room, err)
+			}
+			return
+		}
+		c.print("Failed to rejoin room: %v", err)
+		if status.Code(err) == codes.PermissionDenied {
+			c.ended()
+			return
+		}
+		b.wait()
+	}
+}
+
+func (c *chatSession) ack(seq uint64, state string) {
+	err := c.send(&pb.ChatRoomMessage{
+		Sender:  c.user,
+		Room:    c.room,
+		Type:    "ack",
+		Receipt: &pb.Receipt{Member: c.user, Seq: seq, Status: state},
+	})
+	if err != nil && !errors.Is(err, errNotConnected) {
+		c.print("Failed to acknowledge message: %v", err)
+	}
+}
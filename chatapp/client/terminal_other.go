@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errNoTerminal = errors.New("the split screen is only supported on linux")
+
+func makeCbreak(fd int) (restore func(), err error) {
+	return nil, errNoTerminal
+}
+
+func terminalSize(fd int) (width, height int, err error) {
+	return 0, 0, errNoTerminal
+}
+
+func notifyResize(fn func()) {}
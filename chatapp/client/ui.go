@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// lines kept per pane, older ones scroll off for good
+	scrollback    = 1000
+	roomListWidth = 22
+	// below this the room list is left out to make room for the messages
+	minSplitWidth = 60
+)
+
+// pane is what has been said in one room.
+type pane struct {
+	lines  []string
+	unread int
+	typing string // like "bob is typing…"
+}
+
+// terminalUI splits the terminal into the messages of the room being looked
+// at, the list of joined rooms on the right, who is typing and the line being
+// typed at the bottom. Everything is redrawn on every change, so incoming
+// messages never end up in the middle of what the user is typing. Input is
+// read key by key so the room can be told we are typing.
+//
+// Where the terminal can't be switched to key by key input it falls back to
+// printing lines tagged with their room and reading whole lines.
+type terminalUI struct {
+	in      *bufio.Reader
+	out     io.Writer
+	restore func() // nil when printing plain lines
+	// onEdit is called with the line being typed after every key.
+	onEdit func(line string)
+
+	mu            sync.Mutex
+	width, height int
+	home          pane // for whatever isn't about a joined room, while none is shown
+	panes         map[string]*pane
+	rooms         []string // in the order they were joined
+	active        string   // "" shows home
+	line          []rune
+}
+
+func newTerminalUI(in *bufio.Reader) *terminalUI {
+	u := &terminalUI{
+		in:     in,
+		out:    os.Stdout,
+		onEdit: func(string) {},
+		panes:  make(map[string]*pane),
+	}
+
+	fd := int(os.Stdin.Fd())
+	width, height, err := terminalSize(fd)
+	if err != nil || height < 5 {
+		return u
+	}
+	restore, err := makeCbreak(fd)
+	if err != nil {
+		return u
+	}
+	u.restore = restore
+	u.width, u.height = width, height
+	// the alternate screen keeps the shell's scrollback as it was
+	io.WriteString(u.out, "\033[?1049h")
+	u.drawLocked()
+
+	notifyResize(func() {
+		width, height, err := terminalSize(fd)
+		if err != nil {
+			return
+		}
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.width, u.height = width, height
+		u.drawLocked()
+	})
+	return u
+}
+
+// Close gives the terminal back the way we found it.
+func (u *terminalUI) Close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.restore != nil {
+		io.WriteString(u.out, "\033[?1049l")
+		u.restore()
+		u.restore = nil
+	}
+}
+
+// Printf adds a line to the room's pane. Lines for rooms we are not in, or
+// for no room at all, go to whatever is on screen.
+func (u *terminalUI) Printf(room, format string, args ...any) {
+	text := fmt.Sprintf(format, args...)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p := u.panes[room]
+	if p == nil {
+		room = u.active
+		p = u.paneLocked(room)
+	}
+	if u.restore == nil {
+		if room != "" && len(u.rooms) > 1 {
+			text = "[" + room + "] " + text
+		}
+		fmt.Fprintln(u.out, text)
+		return
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		p.lines = append(p.lines, line)
+	}
+	if over := len(p.lines) - scrollback; over > 0 {
+		p.lines = append(p.lines[:0], p.lines[over:]...)
+	}
+	if room != u.active {
+		p.unread++
+	}
+	u.drawLocked()
+}
+
+// Write shows what log prints on the pane on screen.
+func (u *terminalUI) Write(b []byte) (int, error) {
+	u.Printf("", "%s", strings.TrimRight(string(b), "\n"))
+	return len(b), nil
+}
+
+func (u *terminalUI) paneLocked(room string) *pane {
+	if p := u.panes[room]; p != nil {
+		return p
+	}
+	return &u.home
+}
+
+// AddRoom gives room a pane and shows it.
+func (u *terminalUI) AddRoom(room string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.panes[room] == nil {
+		u.panes[room] = &pane{}
+		u.rooms = append(u.rooms, room)
+	}
+	u.showLocked(room)
+}
+
+// RemoveRoom drops room's pane, showing the next room if it was on screen.
+func (u *terminalUI) RemoveRoom(room string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.panes[room] == nil {
+		return
+	}
+	delete(u.panes, room)
+	for i, r := range u.rooms {
+		if r == room {
+			u.rooms = append(u.rooms[:i], u.rooms[i+1:]...)
+			break
+		}
+	}
+	if u.active == room {
+		next := ""
+		if len(u.rooms) > 0 {
+			next = u.rooms[len(u.rooms)-1]
+		}
+		u.showLocked(next)
+		return
+	}
+	u.drawLocked()
+}
+
+// Show puts room's pane on screen and reports whether we are in it.
+func (u *terminalUI) Show(room string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.panes[room] == nil {
+		return false
+	}
+	u.showLocked(room)
+	return true
+}
+
+// ShowNext cycles through the joined rooms.
+func (u *terminalUI) ShowNext() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.rooms) == 0 {
+		return
+	}
+	next := u.rooms[0]
+	for i, r := range u.rooms {
+		if r == u.active && i+1 < len(u.rooms) {
+			next = u.rooms[i+1]
+		}
+	}
+	u.showLocked(next)
+}
+
+func (u *terminalUI) showLocked(room string) {
+	u.active = room
+	u.paneLocked(room).unread = 0
+	u.drawLocked()
+}
+
+// Split reports whether the screen is split into panes rather than printing
+// plain lines.
+func (u *terminalUI) Split() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.restore != nil
+}
+
+// Active returns the room on screen, "" if none.
+func (u *terminalUI) Active() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.active
+}
+
+// SetTyping shows status under the room's messages while it is on screen.
+func (u *terminalUI) SetTyping(room, status string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	p := u.panes[room]
+	if p == nil || p.typing == status {
+		return
+	}
+	p.typing = status
+	if room == u.active {
+		u.drawLocked()
+	}
+}
+
+// ReadLine returns the next line typed, without the newline. Tab switches to
+// the next room.
+func (u *terminalUI) ReadLine() (string, error) {
+	if u.restore == nil {
+		line, err := u.in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	for {
+		r, _, err := u.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		u.mu.Lock()
+		switch {
+		case r == '\r' || r == '\n':
+			line := string(u.line)
+			u.line = u.line[:0]
+			u.drawLocked()
+			u.mu.Unlock()
+			u.onEdit("")
+			return line, nil
+		case r == 4 && len(u.line) == 0: // ctrl+d
+			u.mu.Unlock()
+			return "", io.EOF
+		case r == '\t':
+			u.mu.Unlock()
+			u.ShowNext()
+			continue
+		case r == 127 || r == '\b':
+			if len(u.line) > 0 {
+				u.line = u.line[:len(u.line)-1]
+			}
+		case r == 21: // ctrl+u
+			u.line = u.line[:0]
+		case r == 27: // escape sequences like the arrow keys aren't supported
+			u.skipEscape()
+		case unicode.IsPrint(r):
+			u.line = append(u.line, r)
+		}
+		line := string(u.line)
+		u.drawLocked()
+		u.mu.Unlock()
+
+		u.onEdit(line)
+	}
+}
+
+// skipEscape reads the rest of an escape sequence like ESC [ A.
+func (u *terminalUI) skipEscape() {
+	if u.in.Buffered() == 0 {
+		return
+	}
+	if r, _, _ := u.in.ReadRune(); r != '[' && r != 'O' {
+		return
+	}
+	for u.in.Buffered() > 0 {
+		if r, _, _ := u.in.ReadRune(); r >= 0x40 && r <= 0x7e {
+			return
+		}
+	}
+}
+
+func (u *terminalUI) drawLocked() {
+	if u.restore == nil {
+		return
+	}
+
+	listWidth := roomListWidth
+	if u.width < minSplitWidth {
+		listWidth = 0
+	}
+	msgWidth := u.width
+	if listWidth > 0 {
+		msgWidth -= listWidth + 1
+	}
+	msgRows := u.height - 2
+
+	// the newest lines that fit, wrapped to the pane
+	p := u.paneLocked(u.active)
+	var rows []string
+	for i := len(p.lines) - 1; i >= 0 && len(rows) < msgRows; i-- {
+		wrapped := wrap(p.lines[i], msgWidth)
+		for j := len(wrapped) - 1; j >= 0 && len(rows) < msgRows; j-- {
+			rows = append(rows, wrapped[j])
+		}
+	}
+
+	list := []string{" Rooms"}
+	for _, room := range u.rooms {
+		entry := "  " + room
+		if room == u.active {
+			entry = "> " + room
+		}
+		if unread := u.panes[room].unread; unread > 0 {
+			entry += fmt.Sprintf(" (%d)", unread)
+		}
+		list = append(list, entry)
+	}
+	if len(u.rooms) == 0 {
+		list = append(list, "  /join <room>")
+	}
+
+	var b strings.Builder
+	for row := 0; row < msgRows; row++ {
+		fmt.Fprintf(&b, "\033[%d;1H", row+1)
+		line := ""
+		if i := msgRows - 1 - row; i < len(rows) {
+			line = rows[i]
+		}
+		b.WriteString(fit(line, msgWidth))
+		if listWidth > 0 {
+			b.WriteString("│")
+			entry := ""
+			if row < len(list) {
+				entry = list[row]
+			}
+			b.WriteString(fit(entry, listWidth))
+		}
+	}
+
+	fmt.Fprintf(&b, "\033[%d;1H\033[2m%s\033[0m", msgRows+1, fit(p.typing, u.width))
+
+	prompt := "> "
+	if u.active != "" {
+		prompt = "[" + u.active + "] "
+	}
+	input := []rune(prompt + string(u.line))
+	if over := len(input) - (u.width - 1); over > 0 {
+		input = input[over:]
+	}
+	fmt.Fprintf(&b, "\033[%d;1H\033[K%s", u.height, string(input))
+
+	io.WriteString(u.out, b.String())
+}
+
+// fit cuts or pads s to exactly width runes.
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// wrap breaks s into lines of at most width runes.
+func wrap(s string, width int) []string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return []string{s}
+	}
+	var lines []string
+	for len(r) > width {
+		lines = append(lines, string(r[:width]))
+		r = r[width:]
+	}
+	return append(lines, string(r))
+}
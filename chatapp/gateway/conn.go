@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	callTimeout  = 10 * time.Second
+	replayOnJoin = 50
+)
+
+var errNotLoggedIn = errors.New("log in first")
+
+// pendingMessage is one of ours waiting for its receipt. The server doesn't
+// send our own messages back, the browser gets it with the receipt.
+type pendingMessage struct {
+	id  string // the browser's request id
+	msg *pb.ChatRoomMessage
+}
+
+// conn is one browser. It logs in to the chat server as the browser's user
+// and carries everything over one RoomChat stream, like the terminal client.
+// Requests are handled one at a time in the order they arrive.
+type conn struct {
+	ws     *websocket.Conn
+	client pb.ChatClient
+	addr   string // the browser's, passed on so logins are limited per browser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	user    string
+	token   string
+	stream  pb.Chat_RoomChatClient
+	cancel  context.CancelFunc
+	rooms   map[string]bool
+	pending map[string]pendingMessage // by client_id
+	nextID  int
+}
+
+func serveConn(client pb.ChatClient, ws *websocket.Conn) {
+	addr, _, err := net.SplitHostPort(ws.Request().RemoteAddr)
+	if err != nil {
+		addr = ws.Request().RemoteAddr
+	}
+	c := &conn{
+		ws:      ws,
+		client:  client,
+		addr:    addr,
+		rooms:   make(map[string]bool),
+		pending: make(map[string]pendingMessage),
+	}
+	defer c.close()
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			if err != io.EOF {
+				log.Printf("websocket from %s: %v", ws.Request().RemoteAddr, err)
+			}
+			return
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.send(event{Event: "error", Text: "that is not JSON: " + err.Error()})
+			continue
+		}
+		var trailer metadata.MD
+		if err := c.handle(&req, grpc.Trailer(&trailer)); err != nil {
+			c.fail(&req, err, trailer)
+		}
+	}
+}
+
+func (c *conn) send(ev event) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := websocket.JSON.Send(c.ws, ev); err != nil {
+		log.Printf("failed to write to %s: %v", c.ws.Request().RemoteAddr, err)
+	}
+}
+
+// fail tells the browser why req didn't work, passing on how long to wait
+// if the server rate limited us.
+func (c *conn) fail(req *request, err error, trailer metadata.MD) {
+	ev := event{Event: "error", Op: req.Op, ID: req.ID, Text: status.Convert(err).Message()}
+	if v := trailer.Get("retry-after"); len(v) > 0 {
+		if secs, err := strconv.Atoi(v[0]); err == nil {
+			ev.RetryAfter = int64(secs) * 1000
+		}
+	}
+	c.send(ev)
+}
+
+// ctx carries the user's token to the chat server.
+func (c *conn) ctx() (context.Context, context.CancelFunc) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	return context.WithTimeout(ctx, callTimeout)
+}
+
+func (c *conn) handle(req *request, opts ...grpc.CallOption) error {
+	if req.Op == "login" {
+		return c.login(req, opts...)
+	}
+
+	c.mu.Lock()
+	loggedIn := c.stream != nil
+	c.mu.Unlock()
+	if !loggedIn {
+		return errNotLoggedIn
+	}
+
+	ctx, cancel := c.ctx()
+	defer cancel()
+
+	switch req.Op {
+	case "rooms":
+		resp, err := c.client.GetExistingChatRooms(ctx, &pb.Empty{}, opts...)
+		if err != nil {
+			return err
+		}
+		rooms := make([]roomInfo, 0, len(resp.Details))
+		for _, r := range resp.Details {
+			rooms = append(rooms, roomInfo{Name: r.Name, Visibility: r.Visibility, Topic: r.Topic})
+		}
+		c.send(event{Event: "rooms", Rooms: rooms})
+	case "join":
+		return c.join(ctx, req, opts...)
+	case "leave":
+		if _, err := c.client.LeaveChatRoom(ctx, &pb.LeaveRequest{Room: req.Room}, opts...); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		delete(c.rooms, req.Room)
+		c.mu.Unlock()
+		c.send(event{Event: "left", Room: req.Room, Text: "you left " + req.Room})
+	case "send":
+		return c.post(req, &pb.ChatRoomMessage{Content: req.Text, ReplyTo: req.ReplyTo})
+	case "edit":
+		return c.post(req, &pb.ChatRoomMessage{Type: "edit", Target: req.Seq, Content: req.Text})
+	case "delete":
+		return c.post(req, &pb.ChatRoomMessage{Type: "delete", Target: req.Seq})
+	case "react":
+		return c.post(req, &pb.ChatRoomMessage{Type: "reaction", Target: req.Seq, Reaction: req.Emoji, Undo: req.Undo})
+	case "typing":
+		return c.streamSend(&pb.ChatRoomMessage{Type: "typing", Room: req.Room})
+	case "read":
+		return c.ack(req.Room, req.Seq, "read")
+	case "history":
+		resp, err := c.client.GetRoomHistory(ctx, &pb.HistoryRequest{Room: req.Room, Limit: req.Limit}, opts...)
+		if err != nil {
+			return err
+		}
+		c.send(event{Event: "history", Room: req.Room, Messages: fromRoomMessages(resp.Messages)})
+	case "pm":
+		msg := &pb.PrivateMessage{Recipient: req.To, Content: req.Text}
+		resp, err := c.client.SendPrivateMessage(ctx, msg, opts...)
+		if status.Code(err) == codes.FailedPrecondition {
+			// we have no key to encrypt with
+			return errors.New(status.Convert(err).Message() + ", which the browser can't send; use the terminal client")
+		}
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		msg.Sender = c.user
+		c.mu.Unlock()
+		msg.Timestamp = time.Now().UnixMilli()
+		m := fromPrivateMessage(msg)
+		c.send(event{Event: "private", Message: &m, Text: resp.Status})
+	default:
+		return errors.New("unknown op " + strconv.Quote(req.Op))
+	}
+	return nil
+}
+
+// login gets a token for the user, or takes the one the browser kept, and
+// opens the RoomChat stream.
+func (c *conn) login(req *request, opts ...grpc.CallOption) error {
+	c.mu.Lock()
+	loggedIn := c.stream != nil
+	c.mu.Unlock()
+	if loggedIn {
+		return errors.New("already logged in")
+	}
+
+	// the server counts logins against the browser's address rather than
+	// ours if it was started with -gateways
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-forwarded-for", c.addr)
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	user, token := req.Username, req.Token
+	if token == "" {
+		creds := &pb.Credentials{Username: req.Username, Password: req.Password}
+		var (
+			resp *pb.AuthResponse
+			err  error
+		)
+		if req.Register {
+			resp, err = c.client.Register(ctx, creds, opts...)
+		} else {
+			resp, err = c.client.Login(ctx, creds, opts...)
+		}
+		if err != nil {
+			return err
+		}
+		user, token = resp.Username, resp.Token
+	}
+
+	c.mu.Lock()
+	c.user, c.token = user, token
+	c.mu.Unlock()
+
+	// the stream doesn't say whether the token is good until it ends, a
+	// call does
+	callCtx, cancelCall := c.ctx()
+	defer cancelCall()
+	if _, err := c.client.GetExistingChatRooms(callCtx, &pb.Empty{}, opts...); err != nil {
+		return err
+	}
+
+	streamCtx, stop := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token))
+	stream, err := c.client.RoomChat(streamCtx)
+	if err != nil {
+		stop()
+		return err
+	}
+
+	c.mu.Lock()
+	c.stream, c.cancel = stream, stop
+	c.mu.Unlock()
+	go c.receive(stream)
+
+	c.send(event{Event: "ready", User: user, Token: token})
+	return nil
+}
+
+func (c *conn) join(ctx context.Context, req *request, opts ...grpc.CallOption) error {
+	resp, err := c.client.JoinRoom(ctx, &pb.JoinRequest{
+		Room:       req.Room,
+		Password:   req.Password,
+		InviteCode: req.Invite,
+		Replay:     replayOnJoin,
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	var last uint64
+	if n := len(resp.History); n > 0 {
+		last = resp.History[n-1].Seq
+	}
+	err = c.streamSend(&pb.ChatRoomMessage{
+		Type:   "subscribe",
+		Room:   req.Room,
+		Resume: &pb.Resume{SessionId: resp.SessionId, LastSeq: last},
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.rooms[req.Room] = true
+	c.mu.Unlock()
+	c.send(event{
+		Event:    "joined",
+		Room:     req.Room,
+		Members:  resp.Members,
+		Topic:    resp.Topic,
+		Role:     resp.Role,
+		Messages: fromRoomMessages(resp.History),
+	})
+	return nil
+}
+
+// post sends msg to req.Room, remembering req.ID to answer with once the
+// server says whether it took it.
+func (c *conn) post(req *request, msg *pb.ChatRoomMessage) error {
+	c.mu.Lock()
+	if !c.rooms[req.Room] {
+		c.mu.Unlock()
+		return errors.New("join " + req.Room + " first")
+	}
+	c.nextID++
+	msg.ClientId = "gw" + strconv.Itoa(c.nextID)
+	c.pending[msg.ClientId] = pendingMessage{id: req.ID, msg: msg}
+	msg.Sender = c.user
+	c.mu.Unlock()
+
+	msg.Room = req.Room
+	return c.streamSend(msg)
+}
+
+func (c *conn) ack(room string, seq uint64, state string) error {
+	return c.streamSend(&pb.ChatRoomMessage{
+		Type:    "ack",
+		Room:    room,
+		Receipt: &pb.Receipt{Seq: seq, Status: state},
+	})
+}
+
+func (c *conn) streamSend(msg *pb.ChatRoomMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stream == nil {
+		return errNotLoggedIn
+	}
+	return c.stream.Send(msg)
+}
+
+// receive passes what the chat server sends on to the browser. If the
+// stream breaks the browser is told and disconnected; it logs in again with
+// its token and joins its rooms again.
+func (c *conn) receive(stream pb.Chat_RoomChatClient) {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) != codes.Canceled { // the browser went away
+				c.send(event{Event: "error", Text: "lost the chat server: " + status.Convert(err).Message()})
+			}
+			c.ws.Close()
+			return
+		}
+
+		switch msg.Type {
+		case "message", "edit", "delete", "reaction":
+			m := fromRoomMessage(msg)
+			c.send(event{Event: "message", Room: msg.Room, Message: &m})
+			c.ack(msg.Room, msg.Seq, "delivered")
+		case "receipt":
+			c.receipt(msg)
+		case "update":
+			u := msg.Update
+			if u.GetType() == "typing" && !u.GetTyping() {
+				// stopped typing, the browser only shows who just typed
+				continue
+			}
+			c.send(event{Event: "update", Room: msg.Room, Text: u.GetUpdate(), Kind: u.GetType(), Sender: u.GetSender()})
+		case "private":
+			if msg.Private != nil {
+				m := fromPrivateMessage(msg.Private)
+				c.send(event{Event: "private", Message: &m})
+			}
+		case "unsubscribed":
+			c.mu.Lock()
+			delete(c.rooms, msg.Room)
+			c.mu.Unlock()
+			text := msg.Content
+			if text == "" {
+				text = "you are no longer in " + msg.Room
+			}
+			c.send(event{Event: "left", Room: msg.Room, Text: text})
+		}
+	}
+}
+
+// receipt answers the request that sent one of our messages. Receipts for
+// what others got and read aren't passed on.
+func (c *conn) receipt(msg *pb.ChatRoomMessage) {
+	r := msg.Receipt
+	c.mu.Lock()
+	sent, ours := c.pending[msg.ClientId]
+	delete(c.pending, msg.ClientId)
+	c.mu.Unlock()
+	if !ours || r == nil {
+		return
+	}
+
+	if r.Status == "failed" {
+		c.send(event{Event: "error", Op: "send", ID: sent.id, Room: msg.Room, Text: r.Error, RetryAfter: r.RetryAfter})
+		return
+	}
+	sent.msg.Seq = r.Seq
+	sent.msg.Timestamp = time.Now().UnixMilli()
+	m := fromRoomMessage(sent.msg)
+	c.send(event{Event: "sent", ID: sent.id, Room: msg.Room, Seq: r.Seq, Message: &m})
+}
+
+func (c *conn) close() {
+	c.mu.Lock()
+	stream, cancel := c.stream, c.cancel
+	c.stream = nil
+	c.mu.Unlock()
+	if stream == nil {
+		return
+	}
+	// closing our side leaves the rooms, like quitting the terminal client
+	stream.CloseSend()
+	time.AfterFunc(time.Second, cancel)
+}
+
+// sameOrigin keeps other sites from opening the socket from a user's
+// browser. Clients that aren't browsers send no Origin.
+func sameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && !strings.EqualFold(origin.Host, r.Host) {
+		return errors.New("cross-origin websocket")
+	}
+	config.Origin = origin
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeChat stands in for the chat server: everyone's password is "secret"
+// and their token is "token-" and their name.
+type fakeChat struct {
+	pb.UnimplementedChatServer
+
+	mu        sync.Mutex
+	logins    int
+	forwarded []string // x-forwarded-for of each login
+	private   []*pb.PrivateMessage
+
+	streams chan *fakeStream
+}
+
+// fakeStream is an open RoomChat stream. What the gateway sends comes out
+// of recv, what goes into send is passed on to it, and end closes it.
+type fakeStream struct {
+	recv chan *pb.ChatRoomMessage
+	send chan *pb.ChatRoomMessage
+	end  chan error
+}
+
+func (f *fakeChat) user(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if auth := md.Get("authorization"); len(auth) == 1 && strings.HasPrefix(auth[0], "Bearer token-") {
+		return strings.TrimPrefix(auth[0], "Bearer token-"), nil
+	}
+	return "", status.Error(codes.Unauthenticated, "invalid session token")
+}
+
+func (f *fakeChat) Login(ctx context.Context, creds *pb.Credentials) (*pb.AuthResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.mu.Lock()
+	f.logins++
+	f.forwarded = append(f.forwarded, md.Get("x-forwarded-for")...)
+	f.mu.Unlock()
+
+	if creds.Password != "secret" {
+		return nil, status.Error(codes.Unauthenticated, "invalid username or password")
+	}
+	return &pb.AuthResponse{Username: creds.Username, Token: "token-" + creds.Username}, nil
+}
+
+func (f *fakeChat) GetExistingChatRooms(ctx context.Context, _ *pb.Empty) (*pb.AvailableRooms, error) {
+	if _, err := f.user(ctx); err != nil {
+		return nil, err
+	}
+	return &pb.AvailableRooms{Rooms: []string{"lobby"}, Details: []*pb.RoomSummary{{Name: "lobby", Visibility: "public"}}}, nil
+}
+
+func (f *fakeChat) JoinRoom(ctx context.Context, req *pb.JoinRequest) (*pb.JoinRoomResponse, error) {
+	user, err := f.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.JoinRoomResponse{
+		SessionId: "session-1",
+		Members:   []string{user},
+		History: []*pb.ChatRoomMessage{
+			{Seq: 1, Room: req.Room, Sender: "bob", Content: "first"},
+			{Seq: 2, Room: req.Room, Sender: "bob", Content: "second"},
+		},
+	}, nil
+}
+
+func (f *fakeChat) SendPrivateMessage(ctx context.Context, msg *pb.PrivateMessage) (*pb.MessageResponse, error) {
+	user, err := f.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Recipient == "carol" {
+		return nil, status.Error(codes.FailedPrecondition, "carol only takes encrypted private messages")
+	}
+	msg.Sender = user
+	f.mu.Lock()
+	f.private = append(f.private, msg)
+	f.mu.Unlock()
+	return &pb.MessageResponse{Status: "Message sent"}, nil
+}
+
+func (f *fakeChat) RoomChat(stream pb.Chat_RoomChatServer) error {
+	if _, err := f.user(stream.Context()); err != nil {
+		return err
+	}
+	s := &fakeStream{
+		recv: make(chan *pb.ChatRoomMessage, 16),
+		send: make(chan *pb.ChatRoomMessage, 16),
+		end:  make(chan error, 1),
+	}
+	f.streams <- s
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			s.recv <- msg
+		}
+	}()
+	for {
+		select {
+		case msg := <-s.send:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case err := <-s.end:
+			return err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// startGateway serves the WebSocket in front of a fakeChat on bufconn and
+// returns its ws:// URL.
+func startGateway(t *testing.T) (*fakeChat, string) {
+	t.Helper()
+	chat := &fakeChat{streams: make(chan *fakeStream, 4)}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterChatServer(srv, chat)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewChatClient(conn)
+
+	web := httptest.NewServer(websocket.Server{
+		Handshake: sameOrigin,
+		Handler:   func(ws *websocket.Conn) { serveConn(client, ws) },
+	})
+	t.Cleanup(web.Close)
+	return chat, "ws" + strings.TrimPrefix(web.URL, "http")
+}
+
+type browser struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+func dial(t *testing.T, url string) *browser {
+	t.Helper()
+	ws, err := websocket.Dial(url, "", strings.Replace(url, "ws", "http", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return &browser{t: t, ws: ws}
+}
+
+func (b *browser) send(req request) {
+	b.t.Helper()
+	if err := websocket.JSON.Send(b.ws, req); err != nil {
+		b.t.Fatal(err)
+	}
+}
+
+// expect reads the next frame, which has to be a kind event.
+func (b *browser) expect(kind string) event {
+	b.t.Helper()
+	b.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev event
+	if err := websocket.JSON.Receive(b.ws, &ev); err != nil {
+		b.t.Fatalf("waiting for %s: %v", kind, err)
+	}
+	if ev.Event != kind {
+		b.t.Fatalf("got %+v, want a %s event", ev, kind)
+	}
+	return ev
+}
+
+func nextStream(t *testing.T, chat *fakeChat) *fakeStream {
+	t.Helper()
+	select {
+	case s := <-chat.streams:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("the gateway didn't open a RoomChat stream")
+		return nil
+	}
+}
+
+func (s *fakeStream) expect(t *testing.T, msgType string) *pb.ChatRoomMessage {
+	t.Helper()
+	select {
+	case msg := <-s.recv:
+		if msg.Type != msgType {
+			t.Fatalf("the gateway sent %+v, want a %q", msg, msgType)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the gateway didn't send a %q", msgType)
+		return nil
+	}
+}
+
+func TestGatewaySession(t *testing.T) {
+	chat, url := startGateway(t)
+	b := dial(t, url)
+
+	b.send(request{Op: "rooms", ID: "1"})
+	if ev := b.expect("error"); ev.Op != "rooms" || ev.ID != "1" || ev.Text != errNotLoggedIn.Error() {
+		t.Fatalf("rooms before logging in: %+v", ev)
+	}
+	b.send(request{Op: "login", Username: "alice", Password: "wrong"})
+	if ev := b.expect("error"); ev.Op != "login" || ev.Text != "invalid username or password" {
+		t.Fatalf("a wrong password: %+v", ev)
+	}
+
+	b.send(request{Op: "login", Username: "alice", Password: "secret"})
+	if ev := b.expect("ready"); ev.User != "alice" || ev.Token != "token-alice" {
+		t.Fatalf("logging in: %+v", ev)
+	}
+	chat.mu.Lock()
+	forwarded := chat.forwarded
+	chat.mu.Unlock()
+	if len(forwarded) != 2 || forwarded[1] != "127.0.0.1" {
+		t.Fatalf("the logins were forwarded for %v, want the browser's address", forwarded)
+	}
+	stream := nextStream(t, chat)
+
+	// joining picks up the room's stream after the history it came with
+	b.send(request{Op: "join", Room: "lobby"})
+	if ev := b.expect("joined"); ev.Room != "lobby" || len(ev.Messages) != 2 || ev.Messages[1].Text != "second" {
+		t.Fatalf("joining: %+v", ev)
+	}
+	sub := stream.expect(t, "subscribe")
+	if sub.Room != "lobby" || sub.Resume.GetSessionId() != "session-1" || sub.Resume.GetLastSeq() != 2 {
+		t.Fatalf("subscribed with %+v", sub)
+	}
+
+	// a message is answered once its receipt comes back
+	b.send(request{Op: "send", ID: "m1", Room: "lobby", Text: "hello"})
+	msg := stream.expect(t, "")
+	if msg.Content != "hello" || msg.Room != "lobby" || msg.ClientId == "" {
+		t.Fatalf("sent %+v", msg)
+	}
+	stream.send <- &pb.ChatRoomMessage{Type: "receipt", Room: "lobby", ClientId: msg.ClientId, Receipt: &pb.Receipt{Seq: 3, Status: "sent"}}
+	if ev := b.expect("sent"); ev.ID != "m1" || ev.Seq != 3 || ev.Message.Text != "hello" {
+		t.Fatalf("the receipt: %+v", ev)
+	}
+
+	b.send(request{Op: "pm", To: "bob", Text: "hi"})
+	if ev := b.expect("private"); ev.Text != "Message sent" || ev.Message.Sender != "alice" || ev.Message.To != "bob" {
+		t.Fatalf("sending a private message: %+v", ev)
+	}
+	chat.mu.Lock()
+	sent := chat.private
+	chat.mu.Unlock()
+	if len(sent) != 1 || sent[0].Sender != "alice" || sent[0].Recipient != "bob" || sent[0].Content != "hi" {
+		t.Fatalf("the server got %v", sent)
+	}
+	b.send(request{Op: "pm", ID: "p2", To: "carol", Text: "hi"})
+	if ev := b.expect("error"); ev.ID != "p2" || !strings.Contains(ev.Text, "the browser can't send") {
+		t.Fatalf("a private message to someone who only takes encrypted ones: %+v", ev)
+	}
+	stream.send <- &pb.ChatRoomMessage{Type: "private", Private: &pb.PrivateMessage{Sender: "bob", Recipient: "alice", Content: "hey"}}
+	if ev := b.expect("private"); ev.Message.Sender != "bob" || ev.Message.Text != "hey" {
+		t.Fatalf("receiving a private message: %+v", ev)
+	}
+
+	// the stream breaks, the browser comes back with the name and token it kept
+	stream.end <- status.Error(codes.Unavailable, "shutting down")
+	if ev := b.expect("error"); ev.Text != "lost the chat server: shutting down" {
+		t.Fatalf("losing the stream: %+v", ev)
+	}
+	b = dial(t, url)
+	b.send(request{Op: "login", Username: "alice", Token: "token-alice"})
+	if ev := b.expect("ready"); ev.User != "alice" || ev.Token != "token-alice" {
+		t.Fatalf("logging in with the token: %+v", ev)
+	}
+	chat.mu.Lock()
+	logins := chat.logins
+	chat.mu.Unlock()
+	if logins != 2 {
+		t.Fatalf("logging in with the token called Login, %d logins", logins)
+	}
+	stream = nextStream(t, chat)
+	b.send(request{Op: "join", Room: "lobby"})
+	b.expect("joined")
+	if sub := stream.expect(t, "subscribe"); sub.Resume.GetLastSeq() != 2 {
+		t.Fatalf("subscribed again with %+v", sub)
+	}
+
+	b.send(request{Op: "login", Username: "alice", Token: "token-alice"})
+	if ev := b.expect("error"); ev.Text != "already logged in" {
+		t.Fatalf("logging in twice: %+v", ev)
+	}
+}
@@ -0,0 +1,87 @@
+// Command gateway lets browsers into the chat. It serves a small web client
+// and a WebSocket at /ws speaking the JSON protocol in protocol.go, and
+// turns what comes over it into calls on the chat server, so people in a
+// browser and in the terminal client share the same rooms.
+//
+// Private messages from the browser go out unencrypted: the terminal client
+// only sends to users who published a key and shows the browser's messages
+// as they are, while what terminal users encrypt shows as unreadable in the
+// browser. Someone running the terminal client with -encrypted-only can't be
+// sent private messages from the browser at all.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+
+	pb "example/hello/chatapp/grpc"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+//go:embed static
+var static embed.FS
+
+func main() {
+	listenAddr := flag.String("listen", ":8080", "address to serve the web client and its WebSocket on")
+	serverAddr := flag.String("server", "localhost:50051", "address of the chat server")
+	useTLS := flag.Bool("tls", false, "connect to the chat server with TLS, checking its certificate against the system's CAs")
+	tlsCA := flag.String("tls-ca", "", "PEM CA certificate to check the chat server's certificate with, implies -tls")
+	flag.Parse()
+
+	creds, err := transportCredentials(*useTLS, *tlsCA)
+	if err != nil {
+		log.Fatalf("Failed to load TLS settings: %v", err)
+	}
+	conn, err := grpc.NewClient(*serverAddr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewChatClient(conn)
+
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(files)))
+	mux.Handle("/ws", websocket.Server{
+		Handshake: sameOrigin,
+		Handler:   func(ws *websocket.Conn) { serveConn(client, ws) },
+	})
+
+	log.Printf("Gateway is listening on %s, chat server at %s...", *listenAddr, *serverAddr)
+	if err := http.ListenAndServe(*listenAddr, mux); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+}
+
+// transportCredentials is plain TCP unless the server is asked for over TLS.
+func transportCredentials(useTLS bool, caFile string) (credentials.TransportCredentials, error) {
+	if !useTLS && caFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	return credentials.NewTLS(config), nil
+}
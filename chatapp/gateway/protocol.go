@@ -0,0 +1,158 @@
+package main
+
+import (
+	pb "example/hello/chatapp/grpc"
+)
+
+// request is a frame from the browser. Op says what it wants and which of
+// the other fields matter:
+//
+//	login    username, password, register; or token to pick up a session
+//	rooms
+//	join     room, password, invite
+//	leave    room
+//	send     room, text, reply_to
+//	edit     room, seq, text
+//	delete   room, seq
+//	react    room, seq, emoji, undo
+//	typing   room
+//	read     room, seq
+//	history  room, limit
+//	pm       to, text
+//
+// Whatever id the browser sets comes back on the error an op ends in.
+type request struct {
+	Op       string `json:"op"`
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Register bool   `json:"register,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Room     string `json:"room,omitempty"`
+	Invite   string `json:"invite,omitempty"`
+	Text     string `json:"text,omitempty"`
+	To       string `json:"to,omitempty"`
+	Seq      uint64 `json:"seq,omitempty"`
+	ReplyTo  uint64 `json:"reply_to,omitempty"`
+	Emoji    string `json:"emoji,omitempty"`
+	Undo     bool   `json:"undo,omitempty"`
+	Limit    int32  `json:"limit,omitempty"`
+}
+
+// event is a frame to the browser:
+//
+//	ready     user, token: logged in, keep the token to log in again with
+//	rooms     rooms
+//	joined    room, members, topic, role, messages: the room's recent history
+//	history   room, messages
+//	message   room, message: also edits, deletes and reactions, see message.kind
+//	sent      room, seq, id, message: the server took one of our messages
+//	update    room, text, kind, sender
+//	private   message
+//	left      room, text
+//	error     op, id, text, retry_after
+type event struct {
+	Event      string     `json:"event"`
+	ID         string     `json:"id,omitempty"`
+	Op         string     `json:"op,omitempty"`
+	User       string     `json:"user,omitempty"`
+	Token      string     `json:"token,omitempty"`
+	Room       string     `json:"room,omitempty"`
+	Rooms      []roomInfo `json:"rooms,omitempty"`
+	Members    []string   `json:"members,omitempty"`
+	Topic      string     `json:"topic,omitempty"`
+	Role       string     `json:"role,omitempty"`
+	Messages   []message  `json:"messages,omitempty"`
+	Message    *message   `json:"message,omitempty"`
+	Seq        uint64     `json:"seq,omitempty"`
+	Text       string     `json:"text,omitempty"`
+	Kind       string     `json:"kind,omitempty"`
+	Sender     string     `json:"sender,omitempty"`
+	RetryAfter int64      `json:"retry_after,omitempty"` // millis
+}
+
+type roomInfo struct {
+	Name       string `json:"name"`
+	Visibility string `json:"visibility"`
+	Topic      string `json:"topic,omitempty"`
+}
+
+type message struct {
+	Kind       string      `json:"kind"` // message, edit, delete, reaction or private
+	Seq        uint64      `json:"seq,omitempty"`
+	Sender     string      `json:"sender"`
+	Bot        bool        `json:"bot,omitempty"`
+	To         string      `json:"to,omitempty"`
+	Text       string      `json:"text,omitempty"`
+	Time       int64       `json:"time"` // unix millis
+	ReplyTo    uint64      `json:"reply_to,omitempty"`
+	Target     uint64      `json:"target,omitempty"`
+	Emoji      string      `json:"emoji,omitempty"`
+	Undo       bool        `json:"undo,omitempty"`
+	Edited     bool        `json:"edited,omitempty"`
+	Deleted    bool        `json:"deleted,omitempty"`
+	Reactions  []reaction  `json:"reactions,omitempty"`
+	Attachment *attachment `json:"attachment,omitempty"`
+	// private messages encrypted by a terminal client, the gateway has no
+	// key to read them with
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+type reaction struct {
+	Emoji string   `json:"emoji"`
+	Users []string `json:"users"`
+}
+
+type attachment struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+}
+
+func fromRoomMessage(msg *pb.ChatRoomMessage) message {
+	m := message{
+		Kind:    msg.Type,
+		Seq:     msg.Seq,
+		Sender:  msg.Sender,
+		Bot:     msg.Bot,
+		Text:    msg.Content,
+		Time:    msg.Timestamp,
+		ReplyTo: msg.ReplyTo,
+		Target:  msg.Target,
+		Emoji:   msg.Reaction,
+		Undo:    msg.Undo,
+		Edited:  msg.Edited > 0,
+		Deleted: msg.Deleted,
+	}
+	if m.Kind == "" {
+		m.Kind = "message"
+	}
+	for _, r := range msg.Reactions {
+		m.Reactions = append(m.Reactions, reaction{Emoji: r.Emoji, Users: r.Users})
+	}
+	if a := msg.Attachment; a != nil {
+		m.Attachment = &attachment{ID: a.Id, Name: a.Name, Type: a.ContentType, Size: a.Size}
+	}
+	return m
+}
+
+func fromRoomMessages(msgs []*pb.ChatRoomMessage) []message {
+	out := make([]message, 0, len(msgs))
+	for _, msg := range msgs {
+		out = append(out, fromRoomMessage(msg))
+	}
+	return out
+}
+
+func fromPrivateMessage(msg *pb.PrivateMessage) message {
+	return message{
+		Kind:      "private",
+		Seq:       msg.Seq,
+		Sender:    msg.Sender,
+		To:        msg.Recipient,
+		Text:      msg.Content,
+		Time:      msg.Timestamp,
+		Encrypted: msg.Encrypted != nil,
+	}
+}
@@ -0,0 +1,342 @@
+// A small browser client for the gateway. It speaks the JSON protocol in
+// protocol.go over /ws and keeps what it is shown per room in memory.
+"use strict";
+
+const PRIVATE = "Private messages";
+const HELP = [
+  "/join <room> [password]   join or create a room",
+  "/leave                    leave this room",
+  "/pm <user> <text>         send a private message (not end-to-end encrypted)",
+  "/reply <seq> <text>       reply to a message",
+  "/edit <seq> <text>        change one of your messages",
+  "/delete <seq>             delete one of your messages",
+  "/react <seq> <emoji>      react to a message, /unreact takes it back",
+  "/history [count]          show earlier messages",
+  "/rooms                    refresh the room list",
+];
+
+const $ = (id) => document.getElementById(id);
+
+let ws = null;
+let user = null;
+let active = null;
+let nextID = 0;
+const rooms = new Map(); // name -> {topic, role, members, messages: Map seq -> message, lines: []}
+const typing = new Map(); // room -> Map user -> time
+
+function send(req) {
+  req.id = String(++nextID);
+  if (ws && ws.readyState === WebSocket.OPEN) {
+    ws.send(JSON.stringify(req));
+  }
+  return req.id;
+}
+
+function connect(login) {
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  ws = new WebSocket(scheme + "//" + location.host + "/ws");
+  ws.onopen = () => ws.send(JSON.stringify(Object.assign({ op: "login" }, login)));
+  ws.onmessage = (e) => handle(JSON.parse(e.data));
+  ws.onclose = () => {
+    const saved = sessionStorage.getItem("chat");
+    if (!user || !saved) {
+      return;
+    }
+    notice(active, "Disconnected, reconnecting...");
+    setTimeout(() => connect(JSON.parse(saved)), 2000);
+  };
+}
+
+function handle(ev) {
+  switch (ev.event) {
+    case "ready": {
+      const rejoin = user !== null;
+      user = ev.user;
+      sessionStorage.setItem("chat", JSON.stringify({ username: ev.user, token: ev.token }));
+      $("login").style.display = "none";
+      $("chat").classList.add("open");
+      send({ op: "rooms" });
+      if (rejoin) {
+        for (const name of rooms.keys()) {
+          if (name !== PRIVATE) send({ op: "join", room: name });
+        }
+      } else {
+        room(PRIVATE);
+        show(PRIVATE);
+        notice(PRIVATE, "Logged in as " + user + ". Pick a room or type /join <room>, /help lists the commands.");
+      }
+      break;
+    }
+    case "rooms":
+      renderRoomList(ev.rooms || []);
+      break;
+    case "joined": {
+      const r = room(ev.room);
+      r.topic = ev.topic || "";
+      r.role = ev.role || "";
+      r.members = ev.members || [];
+      for (const m of ev.messages || []) apply(r, m);
+      show(ev.room);
+      break;
+    }
+    case "history": {
+      // earlier messages go above what is shown already
+      const r = room(ev.room);
+      const earlier = [];
+      for (const m of ev.messages || []) {
+        if (m.kind === "message" && !r.messages.has(m.seq)) {
+          r.messages.set(m.seq, m);
+          earlier.push({ seq: m.seq });
+        } else {
+          apply(r, m);
+        }
+      }
+      r.lines.unshift(...earlier);
+      render();
+      break;
+    }
+    case "message":
+      apply(room(ev.room), ev.message);
+      if (ev.message.kind === "message") {
+        typing.get(ev.room)?.delete(ev.message.sender);
+        send({ op: "read", room: ev.room, seq: ev.message.seq });
+      }
+      if (ev.room === active) render();
+      break;
+    case "sent":
+      apply(room(ev.room), ev.message);
+      if (ev.room === active) render();
+      break;
+    case "update":
+      if (ev.kind === "typing") {
+        if (ev.sender !== user) {
+          if (!typing.has(ev.room)) typing.set(ev.room, new Map());
+          typing.get(ev.room).set(ev.sender, Date.now());
+          renderTyping();
+        }
+      } else {
+        notice(ev.room, ev.text);
+      }
+      break;
+    case "private": {
+      const m = ev.message;
+      const text = m.encrypted ? "(end-to-end encrypted, open a terminal client to read it)" : m.text;
+      const who = m.sender === user ? "to " + m.to : "from " + m.sender;
+      line(PRIVATE, time(m.time) + " " + who + ": " + text, "msg");
+      if (ev.text && ev.text.startsWith("Message queued")) notice(PRIVATE, ev.text);
+      if (active !== PRIVATE && m.sender !== user) notice(active, "Private message from " + m.sender + ", see " + PRIVATE);
+      break;
+    }
+    case "left":
+      rooms.delete(ev.room);
+      if (active === ev.room) show(PRIVATE);
+      notice(PRIVATE, ev.text);
+      break;
+    case "error": {
+      let text = ev.text;
+      if (ev.retry_after) text += " (wait " + Math.ceil(ev.retry_after / 1000) + "s)";
+      if (!user) {
+        $("login-error").textContent = text;
+        sessionStorage.removeItem("chat");
+        ws.onclose = null;
+        ws.close();
+      } else {
+        line(ev.room || active, text, "error");
+      }
+      break;
+    }
+  }
+}
+
+function room(name) {
+  if (!rooms.has(name)) {
+    rooms.set(name, { topic: "", role: "", members: [], messages: new Map(), lines: [] });
+    renderJoined();
+  }
+  return rooms.get(name);
+}
+
+// apply takes a message and the edits, deletes and reactions to it.
+function apply(r, m) {
+  if (m.kind === "message") {
+    if (!r.messages.has(m.seq)) r.lines.push({ seq: m.seq });
+    r.messages.set(m.seq, m);
+    return;
+  }
+  const target = r.messages.get(m.target);
+  if (!target) return;
+  if (m.kind === "edit") {
+    target.text = m.text;
+    target.edited = true;
+  } else if (m.kind === "delete") {
+    target.text = "";
+    target.attachment = null;
+    target.reactions = [];
+    target.deleted = true;
+  } else if (m.kind === "reaction") {
+    const reactions = target.reactions || (target.reactions = []);
+    let r = reactions.find((x) => x.emoji === m.emoji);
+    if (!r && !m.undo) reactions.push((r = { emoji: m.emoji, users: [] }));
+    if (!r) return;
+    r.users = r.users.filter((u) => u !== m.sender);
+    if (!m.undo) r.users.push(m.sender);
+    target.reactions = reactions.filter((x) => x.users.length > 0);
+  }
+}
+
+function line(name, text, cls) {
+  if (!name) return;
+  room(name).lines.push({ text: text, cls: cls });
+  if (name === active) render();
+}
+
+function notice(name, text) {
+  line(name || PRIVATE, text, "notice");
+}
+
+function time(ms) {
+  return new Date(ms).toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+}
+
+function show(name) {
+  active = name;
+  renderJoined();
+  render();
+  $("input").focus();
+}
+
+function el(tag, cls, text) {
+  const e = document.createElement(tag);
+  if (cls) e.className = cls;
+  if (text !== undefined) e.textContent = text;
+  return e;
+}
+
+function render() {
+  const r = rooms.get(active);
+  $("title").textContent = active || "";
+  $("topic").textContent = r && r.topic ? r.topic : "";
+  const box = $("messages");
+  box.textContent = "";
+  if (!r) return;
+  for (const l of r.lines) {
+    if (l.seq === undefined) {
+      box.append(el("div", l.cls, l.text));
+      continue;
+    }
+    const m = r.messages.get(l.seq);
+    const div = el("div", m.deleted ? "msg deleted" : "msg");
+    div.append(el("span", "meta", time(m.time) + " #" + m.seq));
+    div.append(el("span", "sender", m.bot ? m.sender + " (bot)" : m.sender));
+    let text = m.deleted ? "message deleted" : m.text;
+    if (m.reply_to) text = "(re #" + m.reply_to + ") " + text;
+    if (m.attachment) text += " [" + m.attachment.name + ", " + m.attachment.size + " bytes, open a terminal client to save it]";
+    if (m.edited && !m.deleted) text += " (edited)";
+    div.append(el("span", "text", text));
+    if (m.reactions && m.reactions.length) {
+      div.append(el("span", "reactions", m.reactions.map((x) => x.emoji + " " + x.users.length).join("  ")));
+    }
+    box.append(div);
+  }
+  box.scrollTop = box.scrollHeight;
+  renderTyping();
+}
+
+function renderTyping() {
+  const now = Date.now();
+  const who = [];
+  for (const [u, at] of typing.get(active) || []) {
+    if (now - at < 5000) who.push(u);
+  }
+  $("typing").textContent = who.length ? who.join(", ") + " typing..." : "";
+}
+
+function renderJoined() {
+  const ul = $("joined");
+  ul.textContent = "";
+  for (const name of rooms.keys()) {
+    const li = el("li", name === active ? "active" : "", name);
+    li.onclick = () => show(name);
+    ul.append(li);
+  }
+}
+
+function renderRoomList(list) {
+  const ul = $("rooms");
+  ul.textContent = "";
+  for (const r of list) {
+    const li = el("li", "", r.name + (r.visibility && r.visibility !== "public" ? " (" + r.visibility + ")" : ""));
+    li.title = r.topic || "";
+    li.onclick = () => (rooms.has(r.name) ? show(r.name) : send({ op: "join", room: r.name }));
+    ul.append(li);
+  }
+}
+
+// command runs what was typed into the input.
+function command(text) {
+  const inRoom = active && active !== PRIVATE;
+  if (!text.startsWith("/")) {
+    if (!inRoom) return notice(active, "Join a room first, or /pm <user> <text>");
+    return send({ op: "send", room: active, text: text });
+  }
+  const [cmd, ...rest] = text.split(" ");
+  const arg = (i) => rest[i] || "";
+  const tail = (i) => rest.slice(i).join(" ");
+  const seq = Number(arg(0));
+  switch (cmd) {
+    case "/help":
+      HELP.forEach((h) => notice(active, h));
+      return;
+    case "/rooms":
+      return send({ op: "rooms" });
+    case "/join":
+      return send({ op: "join", room: arg(0), password: tail(1) });
+    case "/pm":
+      return send({ op: "pm", to: arg(0), text: tail(1) });
+  }
+  if (!inRoom) return notice(active, "Switch to a room first");
+  switch (cmd) {
+    case "/leave":
+      return send({ op: "leave", room: active });
+    case "/reply":
+      return send({ op: "send", room: active, reply_to: seq, text: tail(1) });
+    case "/edit":
+      return send({ op: "edit", room: active, seq: seq, text: tail(1) });
+    case "/delete":
+      return send({ op: "delete", room: active, seq: seq });
+    case "/react":
+    case "/unreact":
+      return send({ op: "react", room: active, seq: seq, emoji: arg(1), undo: cmd === "/unreact" });
+    case "/history":
+      return send({ op: "history", room: active, limit: Number(arg(0)) || 50 });
+  }
+  notice(active, "Unknown command " + cmd + ", /help lists them");
+}
+
+$("login").onsubmit = (e) => {
+  e.preventDefault();
+  const f = e.target;
+  $("login-error").textContent = "";
+  connect({ username: f.username.value, password: f.password.value, register: f.register.checked });
+};
+
+let lastTyping = 0;
+$("input").oninput = () => {
+  if (active && active !== PRIVATE && Date.now() - lastTyping > 3000) {
+    lastTyping = Date.now();
+    send({ op: "typing", room: active });
+  }
+};
+
+$("send").onsubmit = (e) => {
+  e.preventDefault();
+  const input = $("input");
+  const text = input.value.trim();
+  input.value = "";
+  if (text) command(text);
+};
+
+setInterval(renderTyping, 1000);
+
+const saved = sessionStorage.getItem("chat");
+if (saved) connect(JSON.parse(saved));
@@ -61,6 +61,10 @@ type JoinRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
 	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Replay        int32                  `protobuf:"varint,3,opt,name=replay,proto3" json:"replay,omitempty"`
+	Password      string                 `protobuf:"bytes,4,opt,name=password,proto3" json:"password,omitempty"`
+	InviteCode    string                 `protobuf:"bytes,5,opt,name=invite_code,json=inviteCode,proto3" json:"invite_code,omitempty"`
+	Visibility    string                 `protobuf:"bytes,6,opt,name=visibility,proto3" json:"visibility,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -109,9 +113,38 @@ func (x *JoinRequest) GetRoom() string {
 	return ""
 }
 
+func (x *JoinRequest) GetReplay() int32 {
+	if x != nil {
+		return x.Replay
+	}
+	return 0
+}
+
+func (x *JoinRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *JoinRequest) GetInviteCode() string {
+	if x != nil {
+		return x.InviteCode
+	}
+	return ""
+}
+
+func (x *JoinRequest) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
 type AvailableRooms struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Rooms         []string               `protobuf:"bytes,1,rep,name=rooms,proto3" json:"rooms,omitempty"`
+	Details       []*RoomSummary         `protobuf:"bytes,2,rep,name=details,proto3" json:"details,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -153,19 +186,104 @@ func (x *AvailableRooms) GetRooms() []string {
 	return nil
 }
 
+func (x *AvailableRooms) GetDetails() []*RoomSummary {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+type RoomSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Visibility    string                 `protobuf:"bytes,2,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	Topic         string                 `protobuf:"bytes,3,opt,name=topic,proto3" json:"topic,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomSummary) Reset() {
+	*x = RoomSummary{}
+	mi := &file_chatapp_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomSummary) ProtoMessage() {}
+
+func (x *RoomSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomSummary.ProtoReflect.Descriptor instead.
+func (*RoomSummary) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RoomSummary) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *RoomSummary) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+func (x *RoomSummary) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
 type ChatRoomMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
 	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
 	Room          string                 `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
 	IsJoin        bool                   `protobuf:"varint,4,opt,name=isJoin,proto3" json:"isJoin,omitempty"`
+	Seq           uint64                 `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Type          string                 `protobuf:"bytes,7,opt,name=type,proto3" json:"type,omitempty"`
+	ClientId      string                 `protobuf:"bytes,8,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Receipt       *Receipt               `protobuf:"bytes,9,opt,name=receipt,proto3" json:"receipt,omitempty"`
+	Resume        *Resume                `protobuf:"bytes,10,opt,name=resume,proto3" json:"resume,omitempty"`
+	Update        *Update                `protobuf:"bytes,11,opt,name=update,proto3" json:"update,omitempty"`
+	Private       *PrivateMessage        `protobuf:"bytes,13,opt,name=private,proto3" json:"private,omitempty"`
+	Code          int32                  `protobuf:"varint,12,opt,name=code,proto3" json:"code,omitempty"`
+	Attachment    *Attachment            `protobuf:"bytes,14,opt,name=attachment,proto3" json:"attachment,omitempty"`
+	Target        uint64                 `protobuf:"varint,15,opt,name=target,proto3" json:"target,omitempty"`
+	ReplyTo       uint64                 `protobuf:"varint,16,opt,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	Reaction      string                 `protobuf:"bytes,17,opt,name=reaction,proto3" json:"reaction,omitempty"`
+	Undo          bool                   `protobuf:"varint,18,opt,name=undo,proto3" json:"undo,omitempty"`
+	Deleted       bool                   `protobuf:"varint,19,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Edited        int64                  `protobuf:"varint,20,opt,name=edited,proto3" json:"edited,omitempty"`
+	Reactions     []*Reaction            `protobuf:"bytes,21,rep,name=reactions,proto3" json:"reactions,omitempty"`
+	Bot           bool                   `protobuf:"varint,22,opt,name=bot,proto3" json:"bot,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatRoomMessage) Reset() {
 	*x = ChatRoomMessage{}
-	mi := &file_chatapp_proto_msgTypes[3]
+	mi := &file_chatapp_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -177,7 +295,7 @@ func (x *ChatRoomMessage) String() string {
 func (*ChatRoomMessage) ProtoMessage() {}
 
 func (x *ChatRoomMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_chatapp_proto_msgTypes[3]
+	mi := &file_chatapp_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -190,7 +308,7 @@ func (x *ChatRoomMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatRoomMessage.ProtoReflect.Descriptor instead.
 func (*ChatRoomMessage) Descriptor() ([]byte, []int) {
-	return file_chatapp_proto_rawDescGZIP(), []int{3}
+	return file_chatapp_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ChatRoomMessage) GetSender() string {
@@ -221,88 +339,154 @@ func (x *ChatRoomMessage) GetIsJoin() bool {
 	return false
 }
 
-type PrivateMessage struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
-	Recipient     string                 `protobuf:"bytes,2,opt,name=recipient,proto3" json:"recipient,omitempty"`
-	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ChatRoomMessage) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
 }
 
-func (x *PrivateMessage) Reset() {
-	*x = PrivateMessage{}
-	mi := &file_chatapp_proto_msgTypes[4]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *ChatRoomMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
 }
 
-func (x *PrivateMessage) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ChatRoomMessage) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
 }
 
-func (*PrivateMessage) ProtoMessage() {}
+func (x *ChatRoomMessage) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
 
-func (x *PrivateMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_chatapp_proto_msgTypes[4]
+func (x *ChatRoomMessage) GetReceipt() *Receipt {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.Receipt
 	}
-	return mi.MessageOf(x)
+	return nil
 }
 
-// Deprecated: Use PrivateMessage.ProtoReflect.Descriptor instead.
-func (*PrivateMessage) Descriptor() ([]byte, []int) {
-	return file_chatapp_proto_rawDescGZIP(), []int{4}
+func (x *ChatRoomMessage) GetResume() *Resume {
+	if x != nil {
+		return x.Resume
+	}
+	return nil
 }
 
-func (x *PrivateMessage) GetSender() string {
+func (x *ChatRoomMessage) GetUpdate() *Update {
 	if x != nil {
-		return x.Sender
+		return x.Update
 	}
-	return ""
+	return nil
 }
 
-func (x *PrivateMessage) GetRecipient() string {
+func (x *ChatRoomMessage) GetPrivate() *PrivateMessage {
 	if x != nil {
-		return x.Recipient
+		return x.Private
 	}
-	return ""
+	return nil
 }
 
-func (x *PrivateMessage) GetContent() string {
+func (x *ChatRoomMessage) GetCode() int32 {
 	if x != nil {
-		return x.Content
+		return x.Code
+	}
+	return 0
+}
+
+func (x *ChatRoomMessage) GetAttachment() *Attachment {
+	if x != nil {
+		return x.Attachment
+	}
+	return nil
+}
+
+func (x *ChatRoomMessage) GetTarget() uint64 {
+	if x != nil {
+		return x.Target
+	}
+	return 0
+}
+
+func (x *ChatRoomMessage) GetReplyTo() uint64 {
+	if x != nil {
+		return x.ReplyTo
+	}
+	return 0
+}
+
+func (x *ChatRoomMessage) GetReaction() string {
+	if x != nil {
+		return x.Reaction
 	}
 	return ""
 }
 
-type JoinRoomResponse struct {
+func (x *ChatRoomMessage) GetUndo() bool {
+	if x != nil {
+		return x.Undo
+	}
+	return false
+}
+
+func (x *ChatRoomMessage) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *ChatRoomMessage) GetEdited() int64 {
+	if x != nil {
+		return x.Edited
+	}
+	return 0
+}
+
+func (x *ChatRoomMessage) GetReactions() []*Reaction {
+	if x != nil {
+		return x.Reactions
+	}
+	return nil
+}
+
+func (x *ChatRoomMessage) GetBot() bool {
+	if x != nil {
+		return x.Bot
+	}
+	return false
+}
+
+type Reaction struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	Members       []string               `protobuf:"bytes,2,rep,name=members,proto3" json:"members,omitempty"`
+	Emoji         string                 `protobuf:"bytes,1,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Users         []string               `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *JoinRoomResponse) Reset() {
-	*x = JoinRoomResponse{}
+func (x *Reaction) Reset() {
+	*x = Reaction{}
 	mi := &file_chatapp_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *JoinRoomResponse) String() string {
+func (x *Reaction) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*JoinRoomResponse) ProtoMessage() {}
+func (*Reaction) ProtoMessage() {}
 
-func (x *JoinRoomResponse) ProtoReflect() protoreflect.Message {
+func (x *Reaction) ProtoReflect() protoreflect.Message {
 	mi := &file_chatapp_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -314,46 +498,49 @@ func (x *JoinRoomResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use JoinRoomResponse.ProtoReflect.Descriptor instead.
-func (*JoinRoomResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use Reaction.ProtoReflect.Descriptor instead.
+func (*Reaction) Descriptor() ([]byte, []int) {
 	return file_chatapp_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *JoinRoomResponse) GetStatus() string {
+func (x *Reaction) GetEmoji() string {
 	if x != nil {
-		return x.Status
+		return x.Emoji
 	}
 	return ""
 }
 
-func (x *JoinRoomResponse) GetMembers() []string {
+func (x *Reaction) GetUsers() []string {
 	if x != nil {
-		return x.Members
+		return x.Users
 	}
 	return nil
 }
 
-type MessageResponse struct {
+type Attachment struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ContentType   string                 `protobuf:"bytes,3,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *MessageResponse) Reset() {
-	*x = MessageResponse{}
+func (x *Attachment) Reset() {
+	*x = Attachment{}
 	mi := &file_chatapp_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *MessageResponse) String() string {
+func (x *Attachment) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*MessageResponse) ProtoMessage() {}
+func (*Attachment) ProtoMessage() {}
 
-func (x *MessageResponse) ProtoReflect() protoreflect.Message {
+func (x *Attachment) ProtoReflect() protoreflect.Message {
 	mi := &file_chatapp_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -365,41 +552,61 @@ func (x *MessageResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use MessageResponse.ProtoReflect.Descriptor instead.
-func (*MessageResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
 	return file_chatapp_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *MessageResponse) GetStatus() string {
+func (x *Attachment) GetId() string {
 	if x != nil {
-		return x.Status
+		return x.Id
 	}
 	return ""
 }
 
-type LeaveRequest struct {
+func (x *Attachment) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Attachment) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Attachment) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type AttachmentChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
-	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
-	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Info          *Attachment            `protobuf:"bytes,1,opt,name=info,proto3" json:"info,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LeaveRequest) Reset() {
-	*x = LeaveRequest{}
+func (x *AttachmentChunk) Reset() {
+	*x = AttachmentChunk{}
 	mi := &file_chatapp_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LeaveRequest) String() string {
+func (x *AttachmentChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LeaveRequest) ProtoMessage() {}
+func (*AttachmentChunk) ProtoMessage() {}
 
-func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
+func (x *AttachmentChunk) ProtoReflect() protoreflect.Message {
 	mi := &file_chatapp_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -411,56 +618,46 @@ func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LeaveRequest.ProtoReflect.Descriptor instead.
-func (*LeaveRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use AttachmentChunk.ProtoReflect.Descriptor instead.
+func (*AttachmentChunk) Descriptor() ([]byte, []int) {
 	return file_chatapp_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *LeaveRequest) GetSender() string {
-	if x != nil {
-		return x.Sender
-	}
-	return ""
-}
-
-func (x *LeaveRequest) GetRoom() string {
+func (x *AttachmentChunk) GetInfo() *Attachment {
 	if x != nil {
-		return x.Room
+		return x.Info
 	}
-	return ""
+	return nil
 }
 
-func (x *LeaveRequest) GetType() string {
+func (x *AttachmentChunk) GetData() []byte {
 	if x != nil {
-		return x.Type
+		return x.Data
 	}
-	return ""
+	return nil
 }
 
-type Update struct {
+type AttachmentRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Update        string                 `protobuf:"bytes,1,opt,name=update,proto3" json:"update,omitempty"`
-	Sender        string                 `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
-	Room          string                 `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
-	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Update) Reset() {
-	*x = Update{}
+func (x *AttachmentRequest) Reset() {
+	*x = AttachmentRequest{}
 	mi := &file_chatapp_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Update) String() string {
+func (x *AttachmentRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Update) ProtoMessage() {}
+func (*AttachmentRequest) ProtoMessage() {}
 
-func (x *Update) ProtoReflect() protoreflect.Message {
+func (x *AttachmentRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_chatapp_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -472,80 +669,2644 @@ func (x *Update) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Update.ProtoReflect.Descriptor instead.
-func (*Update) Descriptor() ([]byte, []int) {
+// Deprecated: Use AttachmentRequest.ProtoReflect.Descriptor instead.
+func (*AttachmentRequest) Descriptor() ([]byte, []int) {
 	return file_chatapp_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *Update) GetUpdate() string {
+func (x *AttachmentRequest) GetId() string {
 	if x != nil {
-		return x.Update
+		return x.Id
 	}
 	return ""
 }
 
-func (x *Update) GetSender() string {
-	if x != nil {
-		return x.Sender
-	}
-	return ""
+type Resume struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	LastSeq       uint64                 `protobuf:"varint,2,opt,name=last_seq,json=lastSeq,proto3" json:"last_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Update) GetRoom() string {
-	if x != nil {
+func (x *Resume) Reset() {
+	*x = Resume{}
+	mi := &file_chatapp_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Resume) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Resume) ProtoMessage() {}
+
+func (x *Resume) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Resume.ProtoReflect.Descriptor instead.
+func (*Resume) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Resume) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Resume) GetLastSeq() uint64 {
+	if x != nil {
+		return x.LastSeq
+	}
+	return 0
+}
+
+type Receipt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Member        string                 `protobuf:"bytes,1,opt,name=member,proto3" json:"member,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	RetryAfter    int64                  `protobuf:"varint,5,opt,name=retry_after,json=retryAfter,proto3" json:"retry_after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Receipt) Reset() {
+	*x = Receipt{}
+	mi := &file_chatapp_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Receipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Receipt) ProtoMessage() {}
+
+func (x *Receipt) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Receipt.ProtoReflect.Descriptor instead.
+func (*Receipt) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Receipt) GetMember() string {
+	if x != nil {
+		return x.Member
+	}
+	return ""
+}
+
+func (x *Receipt) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Receipt) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Receipt) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Receipt) GetRetryAfter() int64 {
+	if x != nil {
+		return x.RetryAfter
+	}
+	return 0
+}
+
+type PrivateMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
+	Recipient     string                 `protobuf:"bytes,2,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Seq           uint64                 `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Encrypted     *EncryptedContent      `protobuf:"bytes,6,opt,name=encrypted,proto3" json:"encrypted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrivateMessage) Reset() {
+	*x = PrivateMessage{}
+	mi := &file_chatapp_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrivateMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrivateMessage) ProtoMessage() {}
+
+func (x *PrivateMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrivateMessage.ProtoReflect.Descriptor instead.
+func (*PrivateMessage) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PrivateMessage) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *PrivateMessage) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *PrivateMessage) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *PrivateMessage) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PrivateMessage) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *PrivateMessage) GetEncrypted() *EncryptedContent {
+	if x != nil {
+		return x.Encrypted
+	}
+	return nil
+}
+
+type EncryptedContent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ciphertext    []byte                 `protobuf:"bytes,1,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`
+	Nonce         []byte                 `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	SenderKey     []byte                 `protobuf:"bytes,3,opt,name=sender_key,json=senderKey,proto3" json:"sender_key,omitempty"`
+	RecipientKey  []byte                 `protobuf:"bytes,4,opt,name=recipient_key,json=recipientKey,proto3" json:"recipient_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncryptedContent) Reset() {
+	*x = EncryptedContent{}
+	mi := &file_chatapp_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncryptedContent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptedContent) ProtoMessage() {}
+
+func (x *EncryptedContent) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptedContent.ProtoReflect.Descriptor instead.
+func (*EncryptedContent) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *EncryptedContent) GetCiphertext() []byte {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return nil
+}
+
+func (x *EncryptedContent) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *EncryptedContent) GetSenderKey() []byte {
+	if x != nil {
+		return x.SenderKey
+	}
+	return nil
+}
+
+func (x *EncryptedContent) GetRecipientKey() []byte {
+	if x != nil {
+		return x.RecipientKey
+	}
+	return nil
+}
+
+type PublicKey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Key           []byte                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Published     int64                  `protobuf:"varint,3,opt,name=published,proto3" json:"published,omitempty"`
+	EncryptedOnly bool                   `protobuf:"varint,4,opt,name=encrypted_only,json=encryptedOnly,proto3" json:"encrypted_only,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublicKey) Reset() {
+	*x = PublicKey{}
+	mi := &file_chatapp_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublicKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublicKey) ProtoMessage() {}
+
+func (x *PublicKey) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublicKey.ProtoReflect.Descriptor instead.
+func (*PublicKey) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PublicKey) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *PublicKey) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *PublicKey) GetPublished() int64 {
+	if x != nil {
+		return x.Published
+	}
+	return 0
+}
+
+func (x *PublicKey) GetEncryptedOnly() bool {
+	if x != nil {
+		return x.EncryptedOnly
+	}
+	return false
+}
+
+type PublicKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublicKeyRequest) Reset() {
+	*x = PublicKeyRequest{}
+	mi := &file_chatapp_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublicKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublicKeyRequest) ProtoMessage() {}
+
+func (x *PublicKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublicKeyRequest.ProtoReflect.Descriptor instead.
+func (*PublicKeyRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PublicKeyRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+type ConversationList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Conversations []*ConversationSummary `protobuf:"bytes,1,rep,name=conversations,proto3" json:"conversations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationList) Reset() {
+	*x = ConversationList{}
+	mi := &file_chatapp_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationList) ProtoMessage() {}
+
+func (x *ConversationList) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationList.ProtoReflect.Descriptor instead.
+func (*ConversationList) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ConversationList) GetConversations() []*ConversationSummary {
+	if x != nil {
+		return x.Conversations
+	}
+	return nil
+}
+
+type ConversationSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	With          string                 `protobuf:"bytes,1,opt,name=with,proto3" json:"with,omitempty"`
+	Last          *PrivateMessage        `protobuf:"bytes,2,opt,name=last,proto3" json:"last,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationSummary) Reset() {
+	*x = ConversationSummary{}
+	mi := &file_chatapp_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationSummary) ProtoMessage() {}
+
+func (x *ConversationSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationSummary.ProtoReflect.Descriptor instead.
+func (*ConversationSummary) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ConversationSummary) GetWith() string {
+	if x != nil {
+		return x.With
+	}
+	return ""
+}
+
+func (x *ConversationSummary) GetLast() *PrivateMessage {
+	if x != nil {
+		return x.Last
+	}
+	return nil
+}
+
+type ConversationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	With          string                 `protobuf:"bytes,1,opt,name=with,proto3" json:"with,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Since         uint64                 `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationRequest) Reset() {
+	*x = ConversationRequest{}
+	mi := &file_chatapp_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationRequest) ProtoMessage() {}
+
+func (x *ConversationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationRequest.ProtoReflect.Descriptor instead.
+func (*ConversationRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ConversationRequest) GetWith() string {
+	if x != nil {
+		return x.With
+	}
+	return ""
+}
+
+func (x *ConversationRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ConversationRequest) GetSince() uint64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+type ConversationResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*PrivateMessage      `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	Cursor        uint64                 `protobuf:"varint,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationResponse) Reset() {
+	*x = ConversationResponse{}
+	mi := &file_chatapp_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationResponse) ProtoMessage() {}
+
+func (x *ConversationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationResponse.ProtoReflect.Descriptor instead.
+func (*ConversationResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ConversationResponse) GetMessages() []*PrivateMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *ConversationResponse) GetCursor() uint64 {
+	if x != nil {
+		return x.Cursor
+	}
+	return 0
+}
+
+type JoinRoomResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Members       []string               `protobuf:"bytes,2,rep,name=members,proto3" json:"members,omitempty"`
+	History       []*ChatRoomMessage     `protobuf:"bytes,3,rep,name=history,proto3" json:"history,omitempty"`
+	SessionId     string                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	ResumeWindow  int32                  `protobuf:"varint,5,opt,name=resume_window,json=resumeWindow,proto3" json:"resume_window,omitempty"`
+	Topic         string                 `protobuf:"bytes,6,opt,name=topic,proto3" json:"topic,omitempty"`
+	Role          string                 `protobuf:"bytes,7,opt,name=role,proto3" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JoinRoomResponse) Reset() {
+	*x = JoinRoomResponse{}
+	mi := &file_chatapp_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JoinRoomResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinRoomResponse) ProtoMessage() {}
+
+func (x *JoinRoomResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinRoomResponse.ProtoReflect.Descriptor instead.
+func (*JoinRoomResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *JoinRoomResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JoinRoomResponse) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *JoinRoomResponse) GetHistory() []*ChatRoomMessage {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+func (x *JoinRoomResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *JoinRoomResponse) GetResumeWindow() int32 {
+	if x != nil {
+		return x.ResumeWindow
+	}
+	return 0
+}
+
+func (x *JoinRoomResponse) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *JoinRoomResponse) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+type MessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageResponse) Reset() {
+	*x = MessageResponse{}
+	mi := &file_chatapp_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageResponse) ProtoMessage() {}
+
+func (x *MessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageResponse.ProtoReflect.Descriptor instead.
+func (*MessageResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *MessageResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type LeaveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sender        string                 `protobuf:"bytes,1,opt,name=sender,proto3" json:"sender,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LeaveRequest) Reset() {
+	*x = LeaveRequest{}
+	mi := &file_chatapp_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRequest) ProtoMessage() {}
+
+func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRequest.ProtoReflect.Descriptor instead.
+func (*LeaveRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *LeaveRequest) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *LeaveRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *LeaveRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type Update struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Update        string                 `protobuf:"bytes,1,opt,name=update,proto3" json:"update,omitempty"`
+	Sender        string                 `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Room          string                 `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Actor         string                 `protobuf:"bytes,5,opt,name=actor,proto3" json:"actor,omitempty"`
+	Detail        string                 `protobuf:"bytes,6,opt,name=detail,proto3" json:"detail,omitempty"`
+	Presence      *UserPresence          `protobuf:"bytes,7,opt,name=presence,proto3" json:"presence,omitempty"`
+	Typing        bool                   `protobuf:"varint,8,opt,name=typing,proto3" json:"typing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Update) Reset() {
+	*x = Update{}
+	mi := &file_chatapp_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Update) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Update) ProtoMessage() {}
+
+func (x *Update) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Update.ProtoReflect.Descriptor instead.
+func (*Update) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Update) GetUpdate() string {
+	if x != nil {
+		return x.Update
+	}
+	return ""
+}
+
+func (x *Update) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *Update) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *Update) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Update) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
+}
+
+func (x *Update) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *Update) GetPresence() *UserPresence {
+	if x != nil {
+		return x.Presence
+	}
+	return nil
+}
+
+func (x *Update) GetTyping() bool {
+	if x != nil {
+		return x.Typing
+	}
+	return false
+}
+
+type PresenceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Users         []string               `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PresenceRequest) Reset() {
+	*x = PresenceRequest{}
+	mi := &file_chatapp_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PresenceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PresenceRequest) ProtoMessage() {}
+
+func (x *PresenceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PresenceRequest.ProtoReflect.Descriptor instead.
+func (*PresenceRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *PresenceRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *PresenceRequest) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+type PresenceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Users         []*UserPresence        `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PresenceResponse) Reset() {
+	*x = PresenceResponse{}
+	mi := &file_chatapp_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PresenceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PresenceResponse) ProtoMessage() {}
+
+func (x *PresenceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PresenceResponse.ProtoReflect.Descriptor instead.
+func (*PresenceResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *PresenceResponse) GetUsers() []*UserPresence {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+type UserPresence struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	LastSeen      int64                  `protobuf:"varint,3,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserPresence) Reset() {
+	*x = UserPresence{}
+	mi := &file_chatapp_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserPresence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserPresence) ProtoMessage() {}
+
+func (x *UserPresence) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserPresence.ProtoReflect.Descriptor instead.
+func (*UserPresence) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *UserPresence) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *UserPresence) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *UserPresence) GetLastSeen() int64 {
+	if x != nil {
+		return x.LastSeen
+	}
+	return 0
+}
+
+type HistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Since         uint64                 `protobuf:"varint,3,opt,name=since,proto3" json:"since,omitempty"`
+	Thread        uint64                 `protobuf:"varint,4,opt,name=thread,proto3" json:"thread,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryRequest) Reset() {
+	*x = HistoryRequest{}
+	mi := &file_chatapp_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryRequest) ProtoMessage() {}
+
+func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
+func (*HistoryRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *HistoryRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *HistoryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetSince() uint64 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetThread() uint64 {
+	if x != nil {
+		return x.Thread
+	}
+	return 0
+}
+
+type HistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*ChatRoomMessage     `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	Cursor        uint64                 `protobuf:"varint,2,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryResponse) Reset() {
+	*x = HistoryResponse{}
+	mi := &file_chatapp_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryResponse) ProtoMessage() {}
+
+func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
+func (*HistoryResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *HistoryResponse) GetMessages() []*ChatRoomMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *HistoryResponse) GetCursor() uint64 {
+	if x != nil {
+		return x.Cursor
+	}
+	return 0
+}
+
+type Credentials struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Credentials) Reset() {
+	*x = Credentials{}
+	mi := &file_chatapp_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Credentials) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Credentials) ProtoMessage() {}
+
+func (x *Credentials) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Credentials.ProtoReflect.Descriptor instead.
+func (*Credentials) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *Credentials) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Credentials) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type AuthResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	ExpiresAt     int64                  `protobuf:"varint,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuthResponse) Reset() {
+	*x = AuthResponse{}
+	mi := &file_chatapp_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthResponse) ProtoMessage() {}
+
+func (x *AuthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthResponse.ProtoReflect.Descriptor instead.
+func (*AuthResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *AuthResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *AuthResponse) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *AuthResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type RoomInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomInfoRequest) Reset() {
+	*x = RoomInfoRequest{}
+	mi := &file_chatapp_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomInfoRequest) ProtoMessage() {}
+
+func (x *RoomInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomInfoRequest.ProtoReflect.Descriptor instead.
+func (*RoomInfoRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *RoomInfoRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+type RoomInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Owner         string                 `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
+	Topic         string                 `protobuf:"bytes,3,opt,name=topic,proto3" json:"topic,omitempty"`
+	Roles         map[string]string      `protobuf:"bytes,4,rep,name=roles,proto3" json:"roles,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Members       []string               `protobuf:"bytes,5,rep,name=members,proto3" json:"members,omitempty"`
+	Visibility    string                 `protobuf:"bytes,6,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomInfo) Reset() {
+	*x = RoomInfo{}
+	mi := &file_chatapp_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomInfo) ProtoMessage() {}
+
+func (x *RoomInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomInfo.ProtoReflect.Descriptor instead.
+func (*RoomInfo) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *RoomInfo) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *RoomInfo) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *RoomInfo) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *RoomInfo) GetRoles() map[string]string {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
+func (x *RoomInfo) GetMembers() []string {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *RoomInfo) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+type ModerationRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Room            string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Target          string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	DurationSeconds int64                  `protobuf:"varint,3,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	Reason          string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ModerationRequest) Reset() {
+	*x = ModerationRequest{}
+	mi := &file_chatapp_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModerationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModerationRequest) ProtoMessage() {}
+
+func (x *ModerationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModerationRequest.ProtoReflect.Descriptor instead.
+func (*ModerationRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *ModerationRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ModerationRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *ModerationRequest) GetDurationSeconds() int64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *ModerationRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type RoleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	Role          string                 `protobuf:"bytes,3,opt,name=role,proto3" json:"role,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoleRequest) Reset() {
+	*x = RoleRequest{}
+	mi := &file_chatapp_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoleRequest) ProtoMessage() {}
+
+func (x *RoleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoleRequest.ProtoReflect.Descriptor instead.
+func (*RoleRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *RoleRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *RoleRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *RoleRequest) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+type TopicRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Topic         string                 `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TopicRequest) Reset() {
+	*x = TopicRequest{}
+	mi := &file_chatapp_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopicRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopicRequest) ProtoMessage() {}
+
+func (x *TopicRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopicRequest.ProtoReflect.Descriptor instead.
+func (*TopicRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *TopicRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *TopicRequest) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+type VisibilityRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Visibility    string                 `protobuf:"bytes,2,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	Password      string                 `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VisibilityRequest) Reset() {
+	*x = VisibilityRequest{}
+	mi := &file_chatapp_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VisibilityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VisibilityRequest) ProtoMessage() {}
+
+func (x *VisibilityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VisibilityRequest.ProtoReflect.Descriptor instead.
+func (*VisibilityRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *VisibilityRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *VisibilityRequest) GetVisibility() string {
+	if x != nil {
+		return x.Visibility
+	}
+	return ""
+}
+
+func (x *VisibilityRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type InviteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Invitee       string                 `protobuf:"bytes,2,opt,name=invitee,proto3" json:"invitee,omitempty"`
+	TtlSeconds    int64                  `protobuf:"varint,3,opt,name=ttl_seconds,json=ttlSeconds,proto3" json:"ttl_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InviteRequest) Reset() {
+	*x = InviteRequest{}
+	mi := &file_chatapp_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InviteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InviteRequest) ProtoMessage() {}
+
+func (x *InviteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InviteRequest.ProtoReflect.Descriptor instead.
+func (*InviteRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *InviteRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *InviteRequest) GetInvitee() string {
+	if x != nil {
+		return x.Invitee
+	}
+	return ""
+}
+
+func (x *InviteRequest) GetTtlSeconds() int64 {
+	if x != nil {
+		return x.TtlSeconds
+	}
+	return 0
+}
+
+type InviteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	ExpiresAt     int64                  `protobuf:"varint,2,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InviteResponse) Reset() {
+	*x = InviteResponse{}
+	mi := &file_chatapp_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InviteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InviteResponse) ProtoMessage() {}
+
+func (x *InviteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InviteResponse.ProtoReflect.Descriptor instead.
+func (*InviteResponse) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *InviteResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *InviteResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type BotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BotRequest) Reset() {
+	*x = BotRequest{}
+	mi := &file_chatapp_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BotRequest) ProtoMessage() {}
+
+func (x *BotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BotRequest.ProtoReflect.Descriptor instead.
+func (*BotRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *BotRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *BotRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type BotToken struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Token         string                 `protobuf:"bytes,3,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BotToken) Reset() {
+	*x = BotToken{}
+	mi := &file_chatapp_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BotToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BotToken) ProtoMessage() {}
+
+func (x *BotToken) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BotToken.ProtoReflect.Descriptor instead.
+func (*BotToken) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *BotToken) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *BotToken) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BotToken) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+type Bot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Creator       string                 `protobuf:"bytes,2,opt,name=creator,proto3" json:"creator,omitempty"`
+	Created       int64                  `protobuf:"varint,3,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Bot) Reset() {
+	*x = Bot{}
+	mi := &file_chatapp_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Bot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Bot) ProtoMessage() {}
+
+func (x *Bot) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Bot.ProtoReflect.Descriptor instead.
+func (*Bot) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *Bot) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Bot) GetCreator() string {
+	if x != nil {
+		return x.Creator
+	}
+	return ""
+}
+
+func (x *Bot) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+type Webhook struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Room          string                 `protobuf:"bytes,2,opt,name=room,proto3" json:"room,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Url           string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	Command       string                 `protobuf:"bytes,5,opt,name=command,proto3" json:"command,omitempty"`
+	Pattern       string                 `protobuf:"bytes,6,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Secret        string                 `protobuf:"bytes,7,opt,name=secret,proto3" json:"secret,omitempty"`
+	Creator       string                 `protobuf:"bytes,8,opt,name=creator,proto3" json:"creator,omitempty"`
+	Created       int64                  `protobuf:"varint,9,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Webhook) Reset() {
+	*x = Webhook{}
+	mi := &file_chatapp_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Webhook) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Webhook) ProtoMessage() {}
+
+func (x *Webhook) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Webhook.ProtoReflect.Descriptor instead.
+func (*Webhook) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *Webhook) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Webhook) GetRoom() string {
+	if x != nil {
 		return x.Room
 	}
 	return ""
 }
 
-func (x *Update) GetType() string {
+func (x *Webhook) GetName() string {
 	if x != nil {
-		return x.Type
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Webhook) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Webhook) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *Webhook) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *Webhook) GetSecret() string {
+	if x != nil {
+		return x.Secret
+	}
+	return ""
+}
+
+func (x *Webhook) GetCreator() string {
+	if x != nil {
+		return x.Creator
+	}
+	return ""
+}
+
+func (x *Webhook) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+type WebhookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Room          string                 `protobuf:"bytes,1,opt,name=room,proto3" json:"room,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WebhookRequest) Reset() {
+	*x = WebhookRequest{}
+	mi := &file_chatapp_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebhookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebhookRequest) ProtoMessage() {}
+
+func (x *WebhookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebhookRequest.ProtoReflect.Descriptor instead.
+func (*WebhookRequest) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *WebhookRequest) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *WebhookRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Integrations struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bots          []*Bot                 `protobuf:"bytes,1,rep,name=bots,proto3" json:"bots,omitempty"`
+	Webhooks      []*Webhook             `protobuf:"bytes,2,rep,name=webhooks,proto3" json:"webhooks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Integrations) Reset() {
+	*x = Integrations{}
+	mi := &file_chatapp_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Integrations) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Integrations) ProtoMessage() {}
+
+func (x *Integrations) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Integrations.ProtoReflect.Descriptor instead.
+func (*Integrations) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *Integrations) GetBots() []*Bot {
+	if x != nil {
+		return x.Bots
+	}
+	return nil
+}
+
+func (x *Integrations) GetWebhooks() []*Webhook {
+	if x != nil {
+		return x.Webhooks
+	}
+	return nil
+}
+
+type ClusterEvent struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Topic          string                 `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Origin         string                 `protobuf:"bytes,2,opt,name=origin,proto3" json:"origin,omitempty"`
+	Room           string                 `protobuf:"bytes,3,opt,name=room,proto3" json:"room,omitempty"`
+	Message        *ChatRoomMessage       `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Update         *Update                `protobuf:"bytes,5,opt,name=update,proto3" json:"update,omitempty"`
+	Private        *PrivateMessage        `protobuf:"bytes,6,opt,name=private,proto3" json:"private,omitempty"`
+	Presence       *Presence              `protobuf:"bytes,7,opt,name=presence,proto3" json:"presence,omitempty"`
+	RoomInfo       []byte                 `protobuf:"bytes,8,opt,name=room_info,json=roomInfo,proto3" json:"room_info,omitempty"`
+	Queued         bool                   `protobuf:"varint,9,opt,name=queued,proto3" json:"queued,omitempty"`
+	InboxDelivered bool                   `protobuf:"varint,10,opt,name=inbox_delivered,json=inboxDelivered,proto3" json:"inbox_delivered,omitempty"`
+	PublicKey      *PublicKey             `protobuf:"bytes,11,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Account        []byte                 `protobuf:"bytes,12,opt,name=account,proto3" json:"account,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ClusterEvent) Reset() {
+	*x = ClusterEvent{}
+	mi := &file_chatapp_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClusterEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterEvent) ProtoMessage() {}
+
+func (x *ClusterEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterEvent.ProtoReflect.Descriptor instead.
+func (*ClusterEvent) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *ClusterEvent) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *ClusterEvent) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *ClusterEvent) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *ClusterEvent) GetMessage() *ChatRoomMessage {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *ClusterEvent) GetUpdate() *Update {
+	if x != nil {
+		return x.Update
+	}
+	return nil
+}
+
+func (x *ClusterEvent) GetPrivate() *PrivateMessage {
+	if x != nil {
+		return x.Private
+	}
+	return nil
+}
+
+func (x *ClusterEvent) GetPresence() *Presence {
+	if x != nil {
+		return x.Presence
+	}
+	return nil
+}
+
+func (x *ClusterEvent) GetRoomInfo() []byte {
+	if x != nil {
+		return x.RoomInfo
+	}
+	return nil
+}
+
+func (x *ClusterEvent) GetQueued() bool {
+	if x != nil {
+		return x.Queued
+	}
+	return false
+}
+
+func (x *ClusterEvent) GetInboxDelivered() bool {
+	if x != nil {
+		return x.InboxDelivered
+	}
+	return false
+}
+
+func (x *ClusterEvent) GetPublicKey() *PublicKey {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *ClusterEvent) GetAccount() []byte {
+	if x != nil {
+		return x.Account
+	}
+	return nil
+}
+
+type Presence struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Online        bool                   `protobuf:"varint,2,opt,name=online,proto3" json:"online,omitempty"`
+	Evicted       bool                   `protobuf:"varint,3,opt,name=evicted,proto3" json:"evicted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Presence) Reset() {
+	*x = Presence{}
+	mi := &file_chatapp_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Presence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Presence) ProtoMessage() {}
+
+func (x *Presence) ProtoReflect() protoreflect.Message {
+	mi := &file_chatapp_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Presence.ProtoReflect.Descriptor instead.
+func (*Presence) Descriptor() ([]byte, []int) {
+	return file_chatapp_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *Presence) GetUser() string {
+	if x != nil {
+		return x.User
 	}
 	return ""
 }
 
+func (x *Presence) GetOnline() bool {
+	if x != nil {
+		return x.Online
+	}
+	return false
+}
+
+func (x *Presence) GetEvicted() bool {
+	if x != nil {
+		return x.Evicted
+	}
+	return false
+}
+
 var File_chatapp_proto protoreflect.FileDescriptor
 
 const file_chatapp_proto_rawDesc = "" +
 	"\n" +
 	"\rchatapp.proto\x12\x04chat\"\a\n" +
-	"\x05Empty\"9\n" +
+	"\x05Empty\"\xae\x01\n" +
 	"\vJoinRequest\x12\x16\n" +
 	"\x06sender\x18\x01 \x01(\tR\x06sender\x12\x12\n" +
-	"\x04room\x18\x02 \x01(\tR\x04room\"&\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12\x16\n" +
+	"\x06replay\x18\x03 \x01(\x05R\x06replay\x12\x1a\n" +
+	"\bpassword\x18\x04 \x01(\tR\bpassword\x12\x1f\n" +
+	"\vinvite_code\x18\x05 \x01(\tR\n" +
+	"inviteCode\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x06 \x01(\tR\n" +
+	"visibility\"S\n" +
 	"\x0eAvailableRooms\x12\x14\n" +
-	"\x05rooms\x18\x01 \x03(\tR\x05rooms\"o\n" +
+	"\x05rooms\x18\x01 \x03(\tR\x05rooms\x12+\n" +
+	"\adetails\x18\x02 \x03(\v2\x11.chat.RoomSummaryR\adetails\"W\n" +
+	"\vRoomSummary\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x02 \x01(\tR\n" +
+	"visibility\x12\x14\n" +
+	"\x05topic\x18\x03 \x01(\tR\x05topic\"\x90\x05\n" +
 	"\x0fChatRoomMessage\x12\x16\n" +
 	"\x06sender\x18\x01 \x01(\tR\x06sender\x12\x18\n" +
 	"\acontent\x18\x02 \x01(\tR\acontent\x12\x12\n" +
 	"\x04room\x18\x03 \x01(\tR\x04room\x12\x16\n" +
-	"\x06isJoin\x18\x04 \x01(\bR\x06isJoin\"`\n" +
+	"\x06isJoin\x18\x04 \x01(\bR\x06isJoin\x12\x10\n" +
+	"\x03seq\x18\x05 \x01(\x04R\x03seq\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\x12\x12\n" +
+	"\x04type\x18\a \x01(\tR\x04type\x12\x1b\n" +
+	"\tclient_id\x18\b \x01(\tR\bclientId\x12'\n" +
+	"\areceipt\x18\t \x01(\v2\r.chat.ReceiptR\areceipt\x12$\n" +
+	"\x06resume\x18\n" +
+	" \x01(\v2\f.chat.ResumeR\x06resume\x12$\n" +
+	"\x06update\x18\v \x01(\v2\f.chat.UpdateR\x06update\x12.\n" +
+	"\aprivate\x18\r \x01(\v2\x14.chat.PrivateMessageR\aprivate\x12\x12\n" +
+	"\x04code\x18\f \x01(\x05R\x04code\x120\n" +
+	"\n" +
+	"attachment\x18\x0e \x01(\v2\x10.chat.AttachmentR\n" +
+	"attachment\x12\x16\n" +
+	"\x06target\x18\x0f \x01(\x04R\x06target\x12\x19\n" +
+	"\breply_to\x18\x10 \x01(\x04R\areplyTo\x12\x1a\n" +
+	"\breaction\x18\x11 \x01(\tR\breaction\x12\x12\n" +
+	"\x04undo\x18\x12 \x01(\bR\x04undo\x12\x18\n" +
+	"\adeleted\x18\x13 \x01(\bR\adeleted\x12\x16\n" +
+	"\x06edited\x18\x14 \x01(\x03R\x06edited\x12,\n" +
+	"\treactions\x18\x15 \x03(\v2\x0e.chat.ReactionR\treactions\x12\x10\n" +
+	"\x03bot\x18\x16 \x01(\bR\x03bot\"6\n" +
+	"\bReaction\x12\x14\n" +
+	"\x05emoji\x18\x01 \x01(\tR\x05emoji\x12\x14\n" +
+	"\x05users\x18\x02 \x03(\tR\x05users\"g\n" +
+	"\n" +
+	"Attachment\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12!\n" +
+	"\fcontent_type\x18\x03 \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\"K\n" +
+	"\x0fAttachmentChunk\x12$\n" +
+	"\x04info\x18\x01 \x01(\v2\x10.chat.AttachmentR\x04info\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"#\n" +
+	"\x11AttachmentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"B\n" +
+	"\x06Resume\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x19\n" +
+	"\blast_seq\x18\x02 \x01(\x04R\alastSeq\"\x82\x01\n" +
+	"\aReceipt\x12\x16\n" +
+	"\x06member\x18\x01 \x01(\tR\x06member\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1f\n" +
+	"\vretry_after\x18\x05 \x01(\x03R\n" +
+	"retryAfter\"\xc6\x01\n" +
 	"\x0ePrivateMessage\x12\x16\n" +
 	"\x06sender\x18\x01 \x01(\tR\x06sender\x12\x1c\n" +
 	"\trecipient\x18\x02 \x01(\tR\trecipient\x12\x18\n" +
-	"\acontent\x18\x03 \x01(\tR\acontent\"D\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12\x10\n" +
+	"\x03seq\x18\x04 \x01(\x04R\x03seq\x12\x1c\n" +
+	"\ttimestamp\x18\x05 \x01(\x03R\ttimestamp\x124\n" +
+	"\tencrypted\x18\x06 \x01(\v2\x16.chat.EncryptedContentR\tencrypted\"\x8c\x01\n" +
+	"\x10EncryptedContent\x12\x1e\n" +
+	"\n" +
+	"ciphertext\x18\x01 \x01(\fR\n" +
+	"ciphertext\x12\x14\n" +
+	"\x05nonce\x18\x02 \x01(\fR\x05nonce\x12\x1d\n" +
+	"\n" +
+	"sender_key\x18\x03 \x01(\fR\tsenderKey\x12#\n" +
+	"\rrecipient_key\x18\x04 \x01(\fR\frecipientKey\"v\n" +
+	"\tPublicKey\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\fR\x03key\x12\x1c\n" +
+	"\tpublished\x18\x03 \x01(\x03R\tpublished\x12%\n" +
+	"\x0eencrypted_only\x18\x04 \x01(\bR\rencryptedOnly\"&\n" +
+	"\x10PublicKeyRequest\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\"S\n" +
+	"\x10ConversationList\x12?\n" +
+	"\rconversations\x18\x01 \x03(\v2\x19.chat.ConversationSummaryR\rconversations\"S\n" +
+	"\x13ConversationSummary\x12\x12\n" +
+	"\x04with\x18\x01 \x01(\tR\x04with\x12(\n" +
+	"\x04last\x18\x02 \x01(\v2\x14.chat.PrivateMessageR\x04last\"U\n" +
+	"\x13ConversationRequest\x12\x12\n" +
+	"\x04with\x18\x01 \x01(\tR\x04with\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x04R\x05since\"`\n" +
+	"\x14ConversationResponse\x120\n" +
+	"\bmessages\x18\x01 \x03(\v2\x14.chat.PrivateMessageR\bmessages\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\x04R\x06cursor\"\xe3\x01\n" +
 	"\x10JoinRoomResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
-	"\amembers\x18\x02 \x03(\tR\amembers\")\n" +
+	"\amembers\x18\x02 \x03(\tR\amembers\x12/\n" +
+	"\ahistory\x18\x03 \x03(\v2\x15.chat.ChatRoomMessageR\ahistory\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12#\n" +
+	"\rresume_window\x18\x05 \x01(\x05R\fresumeWindow\x12\x14\n" +
+	"\x05topic\x18\x06 \x01(\tR\x05topic\x12\x12\n" +
+	"\x04role\x18\a \x01(\tR\x04role\")\n" +
 	"\x0fMessageResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\"N\n" +
 	"\fLeaveRequest\x12\x16\n" +
 	"\x06sender\x18\x01 \x01(\tR\x06sender\x12\x12\n" +
 	"\x04room\x18\x02 \x01(\tR\x04room\x12\x12\n" +
-	"\x04type\x18\x03 \x01(\tR\x04type\"`\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\"\xd6\x01\n" +
 	"\x06Update\x12\x16\n" +
 	"\x06update\x18\x01 \x01(\tR\x06update\x12\x16\n" +
 	"\x06sender\x18\x02 \x01(\tR\x06sender\x12\x12\n" +
 	"\x04room\x18\x03 \x01(\tR\x04room\x12\x12\n" +
-	"\x04type\x18\x04 \x01(\tR\x04type2\xef\x02\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x14\n" +
+	"\x05actor\x18\x05 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06detail\x18\x06 \x01(\tR\x06detail\x12.\n" +
+	"\bpresence\x18\a \x01(\v2\x12.chat.UserPresenceR\bpresence\x12\x16\n" +
+	"\x06typing\x18\b \x01(\bR\x06typing\";\n" +
+	"\x0fPresenceRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05users\x18\x02 \x03(\tR\x05users\"<\n" +
+	"\x10PresenceResponse\x12(\n" +
+	"\x05users\x18\x01 \x03(\v2\x12.chat.UserPresenceR\x05users\"W\n" +
+	"\fUserPresence\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1b\n" +
+	"\tlast_seen\x18\x03 \x01(\x03R\blastSeen\"h\n" +
+	"\x0eHistoryRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05since\x18\x03 \x01(\x04R\x05since\x12\x16\n" +
+	"\x06thread\x18\x04 \x01(\x04R\x06thread\"\\\n" +
+	"\x0fHistoryResponse\x121\n" +
+	"\bmessages\x18\x01 \x03(\v2\x15.chat.ChatRoomMessageR\bmessages\x12\x16\n" +
+	"\x06cursor\x18\x02 \x01(\x04R\x06cursor\"E\n" +
+	"\vCredentials\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"_\n" +
+	"\fAuthResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\x03R\texpiresAt\"%\n" +
+	"\x0fRoomInfoRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\"\xef\x01\n" +
+	"\bRoomInfo\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05owner\x18\x02 \x01(\tR\x05owner\x12\x14\n" +
+	"\x05topic\x18\x03 \x01(\tR\x05topic\x12/\n" +
+	"\x05roles\x18\x04 \x03(\v2\x19.chat.RoomInfo.RolesEntryR\x05roles\x12\x18\n" +
+	"\amembers\x18\x05 \x03(\tR\amembers\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x06 \x01(\tR\n" +
+	"visibility\x1a8\n" +
+	"\n" +
+	"RolesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x82\x01\n" +
+	"\x11ModerationRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12)\n" +
+	"\x10duration_seconds\x18\x03 \x01(\x03R\x0fdurationSeconds\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"M\n" +
+	"\vRoleRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12\x12\n" +
+	"\x04role\x18\x03 \x01(\tR\x04role\"8\n" +
+	"\fTopicRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x14\n" +
+	"\x05topic\x18\x02 \x01(\tR\x05topic\"c\n" +
+	"\x11VisibilityRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x02 \x01(\tR\n" +
+	"visibility\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\"^\n" +
+	"\rInviteRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x18\n" +
+	"\ainvitee\x18\x02 \x01(\tR\ainvitee\x12\x1f\n" +
+	"\vttl_seconds\x18\x03 \x01(\x03R\n" +
+	"ttlSeconds\"C\n" +
+	"\x0eInviteResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x02 \x01(\x03R\texpiresAt\"4\n" +
+	"\n" +
+	"BotRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"H\n" +
+	"\bBotToken\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05token\x18\x03 \x01(\tR\x05token\"M\n" +
+	"\x03Bot\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\acreator\x18\x02 \x01(\tR\acreator\x12\x18\n" +
+	"\acreated\x18\x03 \x01(\x03R\acreated\"\xd3\x01\n" +
+	"\aWebhook\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04room\x18\x02 \x01(\tR\x04room\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12\x18\n" +
+	"\acommand\x18\x05 \x01(\tR\acommand\x12\x18\n" +
+	"\apattern\x18\x06 \x01(\tR\apattern\x12\x16\n" +
+	"\x06secret\x18\a \x01(\tR\x06secret\x12\x18\n" +
+	"\acreator\x18\b \x01(\tR\acreator\x12\x18\n" +
+	"\acreated\x18\t \x01(\x03R\acreated\"4\n" +
+	"\x0eWebhookRequest\x12\x12\n" +
+	"\x04room\x18\x01 \x01(\tR\x04room\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"X\n" +
+	"\fIntegrations\x12\x1d\n" +
+	"\x04bots\x18\x01 \x03(\v2\t.chat.BotR\x04bots\x12)\n" +
+	"\bwebhooks\x18\x02 \x03(\v2\r.chat.WebhookR\bwebhooks\"\xab\x03\n" +
+	"\fClusterEvent\x12\x14\n" +
+	"\x05topic\x18\x01 \x01(\tR\x05topic\x12\x16\n" +
+	"\x06origin\x18\x02 \x01(\tR\x06origin\x12\x12\n" +
+	"\x04room\x18\x03 \x01(\tR\x04room\x12/\n" +
+	"\amessage\x18\x04 \x01(\v2\x15.chat.ChatRoomMessageR\amessage\x12$\n" +
+	"\x06update\x18\x05 \x01(\v2\f.chat.UpdateR\x06update\x12.\n" +
+	"\aprivate\x18\x06 \x01(\v2\x14.chat.PrivateMessageR\aprivate\x12*\n" +
+	"\bpresence\x18\a \x01(\v2\x0e.chat.PresenceR\bpresence\x12\x1b\n" +
+	"\troom_info\x18\b \x01(\fR\broomInfo\x12\x16\n" +
+	"\x06queued\x18\t \x01(\bR\x06queued\x12'\n" +
+	"\x0finbox_delivered\x18\n" +
+	" \x01(\bR\x0einboxDelivered\x12.\n" +
+	"\n" +
+	"public_key\x18\v \x01(\v2\x0f.chat.PublicKeyR\tpublicKey\x12\x18\n" +
+	"\aaccount\x18\f \x01(\fR\aaccount\"P\n" +
+	"\bPresence\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\x12\x16\n" +
+	"\x06online\x18\x02 \x01(\bR\x06online\x12\x18\n" +
+	"\aevicted\x18\x03 \x01(\bR\aevicted2\x81\x0e\n" +
 	"\x04Chat\x12<\n" +
 	"\bRoomChat\x12\x15.chat.ChatRoomMessage\x1a\x15.chat.ChatRoomMessage(\x010\x01\x12A\n" +
 	"\x12SendPrivateMessage\x12\x14.chat.PrivateMessage\x1a\x15.chat.MessageResponse\x12:\n" +
 	"\rLeaveChatRoom\x12\x12.chat.LeaveRequest\x1a\x15.chat.MessageResponse\x125\n" +
-	"\bJoinRoom\x12\x11.chat.JoinRequest\x1a\x16.chat.JoinRoomResponse\x128\n" +
-	"\x13BroadcastRoomUpdate\x12\x11.chat.JoinRequest\x1a\f.chat.Update0\x01\x129\n" +
-	"\x14GetExistingChatRooms\x12\v.chat.Empty\x1a\x14.chat.AvailableRoomsB\x1cZ\x1aexample/hello/chatapp/grpcb\x06proto3"
+	"\bJoinRoom\x12\x11.chat.JoinRequest\x1a\x16.chat.JoinRoomResponse\x129\n" +
+	"\x14GetExistingChatRooms\x12\v.chat.Empty\x1a\x14.chat.AvailableRooms\x12=\n" +
+	"\x0eGetRoomHistory\x12\x14.chat.HistoryRequest\x1a\x15.chat.HistoryResponse\x121\n" +
+	"\bRegister\x12\x11.chat.Credentials\x1a\x12.chat.AuthResponse\x12.\n" +
+	"\x05Login\x12\x11.chat.Credentials\x1a\x12.chat.AuthResponse\x124\n" +
+	"\vGetRoomInfo\x12\x15.chat.RoomInfoRequest\x1a\x0e.chat.RoomInfo\x12<\n" +
+	"\n" +
+	"KickMember\x12\x17.chat.ModerationRequest\x1a\x15.chat.MessageResponse\x12;\n" +
+	"\tBanMember\x12\x17.chat.ModerationRequest\x1a\x15.chat.MessageResponse\x12=\n" +
+	"\vUnbanMember\x12\x17.chat.ModerationRequest\x1a\x15.chat.MessageResponse\x12<\n" +
+	"\n" +
+	"MuteMember\x12\x17.chat.ModerationRequest\x1a\x15.chat.MessageResponse\x12>\n" +
+	"\fUnmuteMember\x12\x17.chat.ModerationRequest\x1a\x15.chat.MessageResponse\x129\n" +
+	"\rSetMemberRole\x12\x11.chat.RoleRequest\x1a\x15.chat.MessageResponse\x129\n" +
+	"\fSetRoomTopic\x12\x12.chat.TopicRequest\x1a\x15.chat.MessageResponse\x12C\n" +
+	"\x11SetRoomVisibility\x12\x17.chat.VisibilityRequest\x1a\x15.chat.MessageResponse\x129\n" +
+	"\fInviteToRoom\x12\x13.chat.InviteRequest\x1a\x14.chat.InviteResponse\x12<\n" +
+	"\vGetPresence\x12\x15.chat.PresenceRequest\x1a\x16.chat.PresenceResponse\x128\n" +
+	"\x11ListConversations\x12\v.chat.Empty\x1a\x16.chat.ConversationList\x12H\n" +
+	"\x0fGetConversation\x12\x19.chat.ConversationRequest\x1a\x1a.chat.ConversationResponse\x124\n" +
+	"\n" +
+	"PublishKey\x12\x0f.chat.PublicKey\x1a\x15.chat.MessageResponse\x127\n" +
+	"\fGetPublicKey\x12\x16.chat.PublicKeyRequest\x1a\x0f.chat.PublicKey\x12=\n" +
+	"\x10UploadAttachment\x12\x15.chat.AttachmentChunk\x1a\x10.chat.Attachment(\x01\x12F\n" +
+	"\x12DownloadAttachment\x12\x17.chat.AttachmentRequest\x1a\x15.chat.AttachmentChunk0\x01\x12-\n" +
+	"\tCreateBot\x12\x10.chat.BotRequest\x1a\x0e.chat.BotToken\x124\n" +
+	"\tDeleteBot\x12\x10.chat.BotRequest\x1a\x15.chat.MessageResponse\x12*\n" +
+	"\n" +
+	"AddWebhook\x12\r.chat.Webhook\x1a\r.chat.Webhook\x12<\n" +
+	"\rRemoveWebhook\x12\x14.chat.WebhookRequest\x1a\x15.chat.MessageResponse\x12=\n" +
+	"\x10ListIntegrations\x12\x15.chat.RoomInfoRequest\x1a\x12.chat.Integrations2_\n" +
+	"\aCluster\x12(\n" +
+	"\x05Order\x12\x12.chat.ClusterEvent\x1a\v.chat.Empty\x12*\n" +
+	"\aDeliver\x12\x12.chat.ClusterEvent\x1a\v.chat.EmptyB\x1cZ\x1aexample/hello/chatapp/grpcb\x06proto3"
 
 var (
 	file_chatapp_proto_rawDescOnce sync.Once
@@ -559,36 +3320,150 @@ func file_chatapp_proto_rawDescGZIP() []byte {
 	return file_chatapp_proto_rawDescData
 }
 
-var file_chatapp_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_chatapp_proto_msgTypes = make([]protoimpl.MessageInfo, 47)
 var file_chatapp_proto_goTypes = []any{
-	(*Empty)(nil),            // 0: chat.Empty
-	(*JoinRequest)(nil),      // 1: chat.JoinRequest
-	(*AvailableRooms)(nil),   // 2: chat.AvailableRooms
-	(*ChatRoomMessage)(nil),  // 3: chat.ChatRoomMessage
-	(*PrivateMessage)(nil),   // 4: chat.PrivateMessage
-	(*JoinRoomResponse)(nil), // 5: chat.JoinRoomResponse
-	(*MessageResponse)(nil),  // 6: chat.MessageResponse
-	(*LeaveRequest)(nil),     // 7: chat.LeaveRequest
-	(*Update)(nil),           // 8: chat.Update
+	(*Empty)(nil),                // 0: chat.Empty
+	(*JoinRequest)(nil),          // 1: chat.JoinRequest
+	(*AvailableRooms)(nil),       // 2: chat.AvailableRooms
+	(*RoomSummary)(nil),          // 3: chat.RoomSummary
+	(*ChatRoomMessage)(nil),      // 4: chat.ChatRoomMessage
+	(*Reaction)(nil),             // 5: chat.Reaction
+	(*Attachment)(nil),           // 6: chat.Attachment
+	(*AttachmentChunk)(nil),      // 7: chat.AttachmentChunk
+	(*AttachmentRequest)(nil),    // 8: chat.AttachmentRequest
+	(*Resume)(nil),               // 9: chat.Resume
+	(*Receipt)(nil),              // 10: chat.Receipt
+	(*PrivateMessage)(nil),       // 11: chat.PrivateMessage
+	(*EncryptedContent)(nil),     // 12: chat.EncryptedContent
+	(*PublicKey)(nil),            // 13: chat.PublicKey
+	(*PublicKeyRequest)(nil),     // 14: chat.PublicKeyRequest
+	(*ConversationList)(nil),     // 15: chat.ConversationList
+	(*ConversationSummary)(nil),  // 16: chat.ConversationSummary
+	(*ConversationRequest)(nil),  // 17: chat.ConversationRequest
+	(*ConversationResponse)(nil), // 18: chat.ConversationResponse
+	(*JoinRoomResponse)(nil),     // 19: chat.JoinRoomResponse
+	(*MessageResponse)(nil),      // 20: chat.MessageResponse
+	(*LeaveRequest)(nil),         // 21: chat.LeaveRequest
+	(*Update)(nil),               // 22: chat.Update
+	(*PresenceRequest)(nil),      // 23: chat.PresenceRequest
+	(*PresenceResponse)(nil),     // 24: chat.PresenceResponse
+	(*UserPresence)(nil),         // 25: chat.UserPresence
+	(*HistoryRequest)(nil),       // 26: chat.HistoryRequest
+	(*HistoryResponse)(nil),      // 27: chat.HistoryResponse
+	(*Credentials)(nil),          // 28: chat.Credentials
+	(*AuthResponse)(nil),         // 29: chat.AuthResponse
+	(*RoomInfoRequest)(nil),      // 30: chat.RoomInfoRequest
+	(*RoomInfo)(nil),             // 31: chat.RoomInfo
+	(*ModerationRequest)(nil),    // 32: chat.ModerationRequest
+	(*RoleRequest)(nil),          // 33: chat.RoleRequest
+	(*TopicRequest)(nil),         // 34: chat.TopicRequest
+	(*VisibilityRequest)(nil),    // 35: chat.VisibilityRequest
+	(*InviteRequest)(nil),        // 36: chat.InviteRequest
+	(*InviteResponse)(nil),       // 37: chat.InviteResponse
+	(*BotRequest)(nil),           // 38: chat.BotRequest
+	(*BotToken)(nil),             // 39: chat.BotToken
+	(*Bot)(nil),                  // 40: chat.Bot
+	(*Webhook)(nil),              // 41: chat.Webhook
+	(*WebhookRequest)(nil),       // 42: chat.WebhookRequest
+	(*Integrations)(nil),         // 43: chat.Integrations
+	(*ClusterEvent)(nil),         // 44: chat.ClusterEvent
+	(*Presence)(nil),             // 45: chat.Presence
+	nil,                          // 46: chat.RoomInfo.RolesEntry
 }
 var file_chatapp_proto_depIdxs = []int32{
-	3, // 0: chat.Chat.RoomChat:input_type -> chat.ChatRoomMessage
-	4, // 1: chat.Chat.SendPrivateMessage:input_type -> chat.PrivateMessage
-	7, // 2: chat.Chat.LeaveChatRoom:input_type -> chat.LeaveRequest
-	1, // 3: chat.Chat.JoinRoom:input_type -> chat.JoinRequest
-	1, // 4: chat.Chat.BroadcastRoomUpdate:input_type -> chat.JoinRequest
-	0, // 5: chat.Chat.GetExistingChatRooms:input_type -> chat.Empty
-	3, // 6: chat.Chat.RoomChat:output_type -> chat.ChatRoomMessage
-	6, // 7: chat.Chat.SendPrivateMessage:output_type -> chat.MessageResponse
-	6, // 8: chat.Chat.LeaveChatRoom:output_type -> chat.MessageResponse
-	5, // 9: chat.Chat.JoinRoom:output_type -> chat.JoinRoomResponse
-	8, // 10: chat.Chat.BroadcastRoomUpdate:output_type -> chat.Update
-	2, // 11: chat.Chat.GetExistingChatRooms:output_type -> chat.AvailableRooms
-	6, // [6:12] is the sub-list for method output_type
-	0, // [0:6] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3,  // 0: chat.AvailableRooms.details:type_name -> chat.RoomSummary
+	10, // 1: chat.ChatRoomMessage.receipt:type_name -> chat.Receipt
+	9,  // 2: chat.ChatRoomMessage.resume:type_name -> chat.Resume
+	22, // 3: chat.ChatRoomMessage.update:type_name -> chat.Update
+	11, // 4: chat.ChatRoomMessage.private:type_name -> chat.PrivateMessage
+	6,  // 5: chat.ChatRoomMessage.attachment:type_name -> chat.Attachment
+	5,  // 6: chat.ChatRoomMessage.reactions:type_name -> chat.Reaction
+	6,  // 7: chat.AttachmentChunk.info:type_name -> chat.Attachment
+	12, // 8: chat.PrivateMessage.encrypted:type_name -> chat.EncryptedContent
+	16, // 9: chat.ConversationList.conversations:type_name -> chat.ConversationSummary
+	11, // 10: chat.ConversationSummary.last:type_name -> chat.PrivateMessage
+	11, // 11: chat.ConversationResponse.messages:type_name -> chat.PrivateMessage
+	4,  // 12: chat.JoinRoomResponse.history:type_name -> chat.ChatRoomMessage
+	25, // 13: chat.Update.presence:type_name -> chat.UserPresence
+	25, // 14: chat.PresenceResponse.users:type_name -> chat.UserPresence
+	4,  // 15: chat.HistoryResponse.messages:type_name -> chat.ChatRoomMessage
+	46, // 16: chat.RoomInfo.roles:type_name -> chat.RoomInfo.RolesEntry
+	40, // 17: chat.Integrations.bots:type_name -> chat.Bot
+	41, // 18: chat.Integrations.webhooks:type_name -> chat.Webhook
+	4,  // 19: chat.ClusterEvent.message:type_name -> chat.ChatRoomMessage
+	22, // 20: chat.ClusterEvent.update:type_name -> chat.Update
+	11, // 21: chat.ClusterEvent.private:type_name -> chat.PrivateMessage
+	45, // 22: chat.ClusterEvent.presence:type_name -> chat.Presence
+	13, // 23: chat.ClusterEvent.public_key:type_name -> chat.PublicKey
+	4,  // 24: chat.Chat.RoomChat:input_type -> chat.ChatRoomMessage
+	11, // 25: chat.Chat.SendPrivateMessage:input_type -> chat.PrivateMessage
+	21, // 26: chat.Chat.LeaveChatRoom:input_type -> chat.LeaveRequest
+	1,  // 27: chat.Chat.JoinRoom:input_type -> chat.JoinRequest
+	0,  // 28: chat.Chat.GetExistingChatRooms:input_type -> chat.Empty
+	26, // 29: chat.Chat.GetRoomHistory:input_type -> chat.HistoryRequest
+	28, // 30: chat.Chat.Register:input_type -> chat.Credentials
+	28, // 31: chat.Chat.Login:input_type -> chat.Credentials
+	30, // 32: chat.Chat.GetRoomInfo:input_type -> chat.RoomInfoRequest
+	32, // 33: chat.Chat.KickMember:input_type -> chat.ModerationRequest
+	32, // 34: chat.Chat.BanMember:input_type -> chat.ModerationRequest
+	32, // 35: chat.Chat.UnbanMember:input_type -> chat.ModerationRequest
+	32, // 36: chat.Chat.MuteMember:input_type -> chat.ModerationRequest
+	32, // 37: chat.Chat.UnmuteMember:input_type -> chat.ModerationRequest
+	33, // 38: chat.Chat.SetMemberRole:input_type -> chat.RoleRequest
+	34, // 39: chat.Chat.SetRoomTopic:input_type -> chat.TopicRequest
+	35, // 40: chat.Chat.SetRoomVisibility:input_type -> chat.VisibilityRequest
+	36, // 41: chat.Chat.InviteToRoom:input_type -> chat.InviteRequest
+	23, // 42: chat.Chat.GetPresence:input_type -> chat.PresenceRequest
+	0,  // 43: chat.Chat.ListConversations:input_type -> chat.Empty
+	17, // 44: chat.Chat.GetConversation:input_type -> chat.ConversationRequest
+	13, // 45: chat.Chat.PublishKey:input_type -> chat.PublicKey
+	14, // 46: chat.Chat.GetPublicKey:input_type -> chat.PublicKeyRequest
+	7,  // 47: chat.Chat.UploadAttachment:input_type -> chat.AttachmentChunk
+	8,  // 48: chat.Chat.DownloadAttachment:input_type -> chat.AttachmentRequest
+	38, // 49: chat.Chat.CreateBot:input_type -> chat.BotRequest
+	38, // 50: chat.Chat.DeleteBot:input_type -> chat.BotRequest
+	41, // 51: chat.Chat.AddWebhook:input_type -> chat.Webhook
+	42, // 52: chat.Chat.RemoveWebhook:input_type -> chat.WebhookRequest
+	30, // 53: chat.Chat.ListIntegrations:input_type -> chat.RoomInfoRequest
+	44, // 54: chat.Cluster.Order:input_type -> chat.ClusterEvent
+	44, // 55: chat.Cluster.Deliver:input_type -> chat.ClusterEvent
+	4,  // 56: chat.Chat.RoomChat:output_type -> chat.ChatRoomMessage
+	20, // 57: chat.Chat.SendPrivateMessage:output_type -> chat.MessageResponse
+	20, // 58: chat.Chat.LeaveChatRoom:output_type -> chat.MessageResponse
+	19, // 59: chat.Chat.JoinRoom:output_type -> chat.JoinRoomResponse
+	2,  // 60: chat.Chat.GetExistingChatRooms:output_type -> chat.AvailableRooms
+	27, // 61: chat.Chat.GetRoomHistory:output_type -> chat.HistoryResponse
+	29, // 62: chat.Chat.Register:output_type -> chat.AuthResponse
+	29, // 63: chat.Chat.Login:output_type -> chat.AuthResponse
+	31, // 64: chat.Chat.GetRoomInfo:output_type -> chat.RoomInfo
+	20, // 65: chat.Chat.KickMember:output_type -> chat.MessageResponse
+	20, // 66: chat.Chat.BanMember:output_type -> chat.MessageResponse
+	20, // 67: chat.Chat.UnbanMember:output_type -> chat.MessageResponse
+	20, // 68: chat.Chat.MuteMember:output_type -> chat.MessageResponse
+	20, // 69: chat.Chat.UnmuteMember:output_type -> chat.MessageResponse
+	20, // 70: chat.Chat.SetMemberRole:output_type -> chat.MessageResponse
+	20, // 71: chat.Chat.SetRoomTopic:output_type -> chat.MessageResponse
+	20, // 72: chat.Chat.SetRoomVisibility:output_type -> chat.MessageResponse
+	37, // 73: chat.Chat.InviteToRoom:output_type -> chat.InviteResponse
+	24, // 74: chat.Chat.GetPresence:output_type -> chat.PresenceResponse
+	15, // 75: chat.Chat.ListConversations:output_type -> chat.ConversationList
+	18, // 76: chat.Chat.GetConversation:output_type -> chat.ConversationResponse
+	20, // 77: chat.Chat.PublishKey:output_type -> chat.MessageResponse
+	13, // 78: chat.Chat.GetPublicKey:output_type -> chat.PublicKey
+	6,  // 79: chat.Chat.UploadAttachment:output_type -> chat.Attachment
+	7,  // 80: chat.Chat.DownloadAttachment:output_type -> chat.AttachmentChunk
+	39, // 81: chat.Chat.CreateBot:output_type -> chat.BotToken
+	20, // 82: chat.Chat.DeleteBot:output_type -> chat.MessageResponse
+	41, // 83: chat.Chat.AddWebhook:output_type -> chat.Webhook
+	20, // 84: chat.Chat.RemoveWebhook:output_type -> chat.MessageResponse
+	43, // 85: chat.Chat.ListIntegrations:output_type -> chat.Integrations
+	0,  // 86: chat.Cluster.Order:output_type -> chat.Empty
+	0,  // 87: chat.Cluster.Deliver:output_type -> chat.Empty
+	56, // [56:88] is the sub-list for method output_type
+	24, // [24:56] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
 }
 
 func init() { file_chatapp_proto_init() }
@@ -602,9 +3477,9 @@ func file_chatapp_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chatapp_proto_rawDesc), len(file_chatapp_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   47,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_chatapp_proto_goTypes,
 		DependencyIndexes: file_chatapp_proto_depIdxs,
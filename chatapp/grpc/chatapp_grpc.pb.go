@@ -23,8 +23,32 @@ const (
 	Chat_SendPrivateMessage_FullMethodName   = "/chat.Chat/SendPrivateMessage"
 	Chat_LeaveChatRoom_FullMethodName        = "/chat.Chat/LeaveChatRoom"
 	Chat_JoinRoom_FullMethodName             = "/chat.Chat/JoinRoom"
-	Chat_BroadcastRoomUpdate_FullMethodName  = "/chat.Chat/BroadcastRoomUpdate"
 	Chat_GetExistingChatRooms_FullMethodName = "/chat.Chat/GetExistingChatRooms"
+	Chat_GetRoomHistory_FullMethodName       = "/chat.Chat/GetRoomHistory"
+	Chat_Register_FullMethodName             = "/chat.Chat/Register"
+	Chat_Login_FullMethodName                = "/chat.Chat/Login"
+	Chat_GetRoomInfo_FullMethodName          = "/chat.Chat/GetRoomInfo"
+	Chat_KickMember_FullMethodName           = "/chat.Chat/KickMember"
+	Chat_BanMember_FullMethodName            = "/chat.Chat/BanMember"
+	Chat_UnbanMember_FullMethodName          = "/chat.Chat/UnbanMember"
+	Chat_MuteMember_FullMethodName           = "/chat.Chat/MuteMember"
+	Chat_UnmuteMember_FullMethodName         = "/chat.Chat/UnmuteMember"
+	Chat_SetMemberRole_FullMethodName        = "/chat.Chat/SetMemberRole"
+	Chat_SetRoomTopic_FullMethodName         = "/chat.Chat/SetRoomTopic"
+	Chat_SetRoomVisibility_FullMethodName    = "/chat.Chat/SetRoomVisibility"
+	Chat_InviteToRoom_FullMethodName         = "/chat.Chat/InviteToRoom"
+	Chat_GetPresence_FullMethodName          = "/chat.Chat/GetPresence"
+	Chat_ListConversations_FullMethodName    = "/chat.Chat/ListConversations"
+	Chat_GetConversation_FullMethodName      = "/chat.Chat/GetConversation"
+	Chat_PublishKey_FullMethodName           = "/chat.Chat/PublishKey"
+	Chat_GetPublicKey_FullMethodName         = "/chat.Chat/GetPublicKey"
+	Chat_UploadAttachment_FullMethodName     = "/chat.Chat/UploadAttachment"
+	Chat_DownloadAttachment_FullMethodName   = "/chat.Chat/DownloadAttachment"
+	Chat_CreateBot_FullMethodName            = "/chat.Chat/CreateBot"
+	Chat_DeleteBot_FullMethodName            = "/chat.Chat/DeleteBot"
+	Chat_AddWebhook_FullMethodName           = "/chat.Chat/AddWebhook"
+	Chat_RemoveWebhook_FullMethodName        = "/chat.Chat/RemoveWebhook"
+	Chat_ListIntegrations_FullMethodName     = "/chat.Chat/ListIntegrations"
 )
 
 // ChatClient is the client API for Chat service.
@@ -35,8 +59,32 @@ type ChatClient interface {
 	SendPrivateMessage(ctx context.Context, in *PrivateMessage, opts ...grpc.CallOption) (*MessageResponse, error)
 	LeaveChatRoom(ctx context.Context, in *LeaveRequest, opts ...grpc.CallOption) (*MessageResponse, error)
 	JoinRoom(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (*JoinRoomResponse, error)
-	BroadcastRoomUpdate(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Update], error)
 	GetExistingChatRooms(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AvailableRooms, error)
+	GetRoomHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error)
+	Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error)
+	GetRoomInfo(ctx context.Context, in *RoomInfoRequest, opts ...grpc.CallOption) (*RoomInfo, error)
+	KickMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	BanMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	UnbanMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	MuteMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	UnmuteMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	SetMemberRole(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	SetRoomTopic(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	SetRoomVisibility(ctx context.Context, in *VisibilityRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	InviteToRoom(ctx context.Context, in *InviteRequest, opts ...grpc.CallOption) (*InviteResponse, error)
+	GetPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (*PresenceResponse, error)
+	ListConversations(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ConversationList, error)
+	GetConversation(ctx context.Context, in *ConversationRequest, opts ...grpc.CallOption) (*ConversationResponse, error)
+	PublishKey(ctx context.Context, in *PublicKey, opts ...grpc.CallOption) (*MessageResponse, error)
+	GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKey, error)
+	UploadAttachment(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[AttachmentChunk, Attachment], error)
+	DownloadAttachment(ctx context.Context, in *AttachmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AttachmentChunk], error)
+	CreateBot(ctx context.Context, in *BotRequest, opts ...grpc.CallOption) (*BotToken, error)
+	DeleteBot(ctx context.Context, in *BotRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	AddWebhook(ctx context.Context, in *Webhook, opts ...grpc.CallOption) (*Webhook, error)
+	RemoveWebhook(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*MessageResponse, error)
+	ListIntegrations(ctx context.Context, in *RoomInfoRequest, opts ...grpc.CallOption) (*Integrations, error)
 }
 
 type chatClient struct {
@@ -90,13 +138,216 @@ func (c *chatClient) JoinRoom(ctx context.Context, in *JoinRequest, opts ...grpc
 	return out, nil
 }
 
-func (c *chatClient) BroadcastRoomUpdate(ctx context.Context, in *JoinRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Update], error) {
+func (c *chatClient) GetExistingChatRooms(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AvailableRooms, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AvailableRooms)
+	err := c.cc.Invoke(ctx, Chat_GetExistingChatRooms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) GetRoomHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, Chat_GetRoomHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, Chat_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, Chat_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) GetRoomInfo(ctx context.Context, in *RoomInfoRequest, opts ...grpc.CallOption) (*RoomInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoomInfo)
+	err := c.cc.Invoke(ctx, Chat_GetRoomInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) KickMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_KickMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) BanMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_BanMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) UnbanMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_UnbanMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) MuteMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_MuteMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) UnmuteMember(ctx context.Context, in *ModerationRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_UnmuteMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) SetMemberRole(ctx context.Context, in *RoleRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_SetMemberRole_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) SetRoomTopic(ctx context.Context, in *TopicRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_SetRoomTopic_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) SetRoomVisibility(ctx context.Context, in *VisibilityRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_SetRoomVisibility_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) InviteToRoom(ctx context.Context, in *InviteRequest, opts ...grpc.CallOption) (*InviteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InviteResponse)
+	err := c.cc.Invoke(ctx, Chat_InviteToRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) GetPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (*PresenceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PresenceResponse)
+	err := c.cc.Invoke(ctx, Chat_GetPresence_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) ListConversations(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ConversationList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConversationList)
+	err := c.cc.Invoke(ctx, Chat_ListConversations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) GetConversation(ctx context.Context, in *ConversationRequest, opts ...grpc.CallOption) (*ConversationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConversationResponse)
+	err := c.cc.Invoke(ctx, Chat_GetConversation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) PublishKey(ctx context.Context, in *PublicKey, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_PublishKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKey, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PublicKey)
+	err := c.cc.Invoke(ctx, Chat_GetPublicKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) UploadAttachment(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[AttachmentChunk, Attachment], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Chat_ServiceDesc.Streams[1], Chat_UploadAttachment_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AttachmentChunk, Attachment]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Chat_UploadAttachmentClient = grpc.ClientStreamingClient[AttachmentChunk, Attachment]
+
+func (c *chatClient) DownloadAttachment(ctx context.Context, in *AttachmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AttachmentChunk], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Chat_ServiceDesc.Streams[1], Chat_BroadcastRoomUpdate_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Chat_ServiceDesc.Streams[2], Chat_DownloadAttachment_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[JoinRequest, Update]{ClientStream: stream}
+	x := &grpc.GenericClientStream[AttachmentRequest, AttachmentChunk]{ClientStream: stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -107,12 +358,52 @@ func (c *chatClient) BroadcastRoomUpdate(ctx context.Context, in *JoinRequest, o
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type Chat_BroadcastRoomUpdateClient = grpc.ServerStreamingClient[Update]
+type Chat_DownloadAttachmentClient = grpc.ServerStreamingClient[AttachmentChunk]
 
-func (c *chatClient) GetExistingChatRooms(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AvailableRooms, error) {
+func (c *chatClient) CreateBot(ctx context.Context, in *BotRequest, opts ...grpc.CallOption) (*BotToken, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(AvailableRooms)
-	err := c.cc.Invoke(ctx, Chat_GetExistingChatRooms_FullMethodName, in, out, cOpts...)
+	out := new(BotToken)
+	err := c.cc.Invoke(ctx, Chat_CreateBot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) DeleteBot(ctx context.Context, in *BotRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_DeleteBot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) AddWebhook(ctx context.Context, in *Webhook, opts ...grpc.CallOption) (*Webhook, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Webhook)
+	err := c.cc.Invoke(ctx, Chat_AddWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) RemoveWebhook(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*MessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MessageResponse)
+	err := c.cc.Invoke(ctx, Chat_RemoveWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatClient) ListIntegrations(ctx context.Context, in *RoomInfoRequest, opts ...grpc.CallOption) (*Integrations, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Integrations)
+	err := c.cc.Invoke(ctx, Chat_ListIntegrations_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +418,32 @@ type ChatServer interface {
 	SendPrivateMessage(context.Context, *PrivateMessage) (*MessageResponse, error)
 	LeaveChatRoom(context.Context, *LeaveRequest) (*MessageResponse, error)
 	JoinRoom(context.Context, *JoinRequest) (*JoinRoomResponse, error)
-	BroadcastRoomUpdate(*JoinRequest, grpc.ServerStreamingServer[Update]) error
 	GetExistingChatRooms(context.Context, *Empty) (*AvailableRooms, error)
+	GetRoomHistory(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	Register(context.Context, *Credentials) (*AuthResponse, error)
+	Login(context.Context, *Credentials) (*AuthResponse, error)
+	GetRoomInfo(context.Context, *RoomInfoRequest) (*RoomInfo, error)
+	KickMember(context.Context, *ModerationRequest) (*MessageResponse, error)
+	BanMember(context.Context, *ModerationRequest) (*MessageResponse, error)
+	UnbanMember(context.Context, *ModerationRequest) (*MessageResponse, error)
+	MuteMember(context.Context, *ModerationRequest) (*MessageResponse, error)
+	UnmuteMember(context.Context, *ModerationRequest) (*MessageResponse, error)
+	SetMemberRole(context.Context, *RoleRequest) (*MessageResponse, error)
+	SetRoomTopic(context.Context, *TopicRequest) (*MessageResponse, error)
+	SetRoomVisibility(context.Context, *VisibilityRequest) (*MessageResponse, error)
+	InviteToRoom(context.Context, *InviteRequest) (*InviteResponse, error)
+	GetPresence(context.Context, *PresenceRequest) (*PresenceResponse, error)
+	ListConversations(context.Context, *Empty) (*ConversationList, error)
+	GetConversation(context.Context, *ConversationRequest) (*ConversationResponse, error)
+	PublishKey(context.Context, *PublicKey) (*MessageResponse, error)
+	GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKey, error)
+	UploadAttachment(grpc.ClientStreamingServer[AttachmentChunk, Attachment]) error
+	DownloadAttachment(*AttachmentRequest, grpc.ServerStreamingServer[AttachmentChunk]) error
+	CreateBot(context.Context, *BotRequest) (*BotToken, error)
+	DeleteBot(context.Context, *BotRequest) (*MessageResponse, error)
+	AddWebhook(context.Context, *Webhook) (*Webhook, error)
+	RemoveWebhook(context.Context, *WebhookRequest) (*MessageResponse, error)
+	ListIntegrations(context.Context, *RoomInfoRequest) (*Integrations, error)
 	mustEmbedUnimplementedChatServer()
 }
 
@@ -151,12 +466,84 @@ func (UnimplementedChatServer) LeaveChatRoom(context.Context, *LeaveRequest) (*M
 func (UnimplementedChatServer) JoinRoom(context.Context, *JoinRequest) (*JoinRoomResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method JoinRoom not implemented")
 }
-func (UnimplementedChatServer) BroadcastRoomUpdate(*JoinRequest, grpc.ServerStreamingServer[Update]) error {
-	return status.Errorf(codes.Unimplemented, "method BroadcastRoomUpdate not implemented")
-}
 func (UnimplementedChatServer) GetExistingChatRooms(context.Context, *Empty) (*AvailableRooms, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetExistingChatRooms not implemented")
 }
+func (UnimplementedChatServer) GetRoomHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoomHistory not implemented")
+}
+func (UnimplementedChatServer) Register(context.Context, *Credentials) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedChatServer) Login(context.Context, *Credentials) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedChatServer) GetRoomInfo(context.Context, *RoomInfoRequest) (*RoomInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoomInfo not implemented")
+}
+func (UnimplementedChatServer) KickMember(context.Context, *ModerationRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KickMember not implemented")
+}
+func (UnimplementedChatServer) BanMember(context.Context, *ModerationRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanMember not implemented")
+}
+func (UnimplementedChatServer) UnbanMember(context.Context, *ModerationRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnbanMember not implemented")
+}
+func (UnimplementedChatServer) MuteMember(context.Context, *ModerationRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MuteMember not implemented")
+}
+func (UnimplementedChatServer) UnmuteMember(context.Context, *ModerationRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnmuteMember not implemented")
+}
+func (UnimplementedChatServer) SetMemberRole(context.Context, *RoleRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMemberRole not implemented")
+}
+func (UnimplementedChatServer) SetRoomTopic(context.Context, *TopicRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRoomTopic not implemented")
+}
+func (UnimplementedChatServer) SetRoomVisibility(context.Context, *VisibilityRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRoomVisibility not implemented")
+}
+func (UnimplementedChatServer) InviteToRoom(context.Context, *InviteRequest) (*InviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InviteToRoom not implemented")
+}
+func (UnimplementedChatServer) GetPresence(context.Context, *PresenceRequest) (*PresenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPresence not implemented")
+}
+func (UnimplementedChatServer) ListConversations(context.Context, *Empty) (*ConversationList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConversations not implemented")
+}
+func (UnimplementedChatServer) GetConversation(context.Context, *ConversationRequest) (*ConversationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConversation not implemented")
+}
+func (UnimplementedChatServer) PublishKey(context.Context, *PublicKey) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishKey not implemented")
+}
+func (UnimplementedChatServer) GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPublicKey not implemented")
+}
+func (UnimplementedChatServer) UploadAttachment(grpc.ClientStreamingServer[AttachmentChunk, Attachment]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadAttachment not implemented")
+}
+func (UnimplementedChatServer) DownloadAttachment(*AttachmentRequest, grpc.ServerStreamingServer[AttachmentChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadAttachment not implemented")
+}
+func (UnimplementedChatServer) CreateBot(context.Context, *BotRequest) (*BotToken, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBot not implemented")
+}
+func (UnimplementedChatServer) DeleteBot(context.Context, *BotRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBot not implemented")
+}
+func (UnimplementedChatServer) AddWebhook(context.Context, *Webhook) (*Webhook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddWebhook not implemented")
+}
+func (UnimplementedChatServer) RemoveWebhook(context.Context, *WebhookRequest) (*MessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveWebhook not implemented")
+}
+func (UnimplementedChatServer) ListIntegrations(context.Context, *RoomInfoRequest) (*Integrations, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListIntegrations not implemented")
+}
 func (UnimplementedChatServer) mustEmbedUnimplementedChatServer() {}
 func (UnimplementedChatServer) testEmbeddedByValue()              {}
 
@@ -239,17 +626,6 @@ func _Chat_JoinRoom_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Chat_BroadcastRoomUpdate_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(JoinRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
-	}
-	return srv.(ChatServer).BroadcastRoomUpdate(m, &grpc.GenericServerStream[JoinRequest, Update]{ServerStream: stream})
-}
-
-// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type Chat_BroadcastRoomUpdateServer = grpc.ServerStreamingServer[Update]
-
 func _Chat_GetExistingChatRooms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -268,42 +644,711 @@ func _Chat_GetExistingChatRooms_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
-// Chat_ServiceDesc is the grpc.ServiceDesc for Chat service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var Chat_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "chat.Chat",
-	HandlerType: (*ChatServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "SendPrivateMessage",
-			Handler:    _Chat_SendPrivateMessage_Handler,
-		},
-		{
-			MethodName: "LeaveChatRoom",
-			Handler:    _Chat_LeaveChatRoom_Handler,
-		},
-		{
-			MethodName: "JoinRoom",
-			Handler:    _Chat_JoinRoom_Handler,
-		},
-		{
-			MethodName: "GetExistingChatRooms",
-			Handler:    _Chat_GetExistingChatRooms_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "RoomChat",
-			Handler:       _Chat_RoomChat_Handler,
-			ServerStreams: true,
-			ClientStreams: true,
-		},
-		{
-			StreamName:    "BroadcastRoomUpdate",
-			Handler:       _Chat_BroadcastRoomUpdate_Handler,
-			ServerStreams: true,
+func _Chat_GetRoomHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).GetRoomHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_GetRoomHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).GetRoomHistory(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).Register(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).Login(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_GetRoomInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).GetRoomInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_GetRoomInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).GetRoomInfo(ctx, req.(*RoomInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_KickMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).KickMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_KickMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).KickMember(ctx, req.(*ModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_BanMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).BanMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_BanMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).BanMember(ctx, req.(*ModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_UnbanMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).UnbanMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_UnbanMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).UnbanMember(ctx, req.(*ModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_MuteMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).MuteMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_MuteMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).MuteMember(ctx, req.(*ModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_UnmuteMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).UnmuteMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_UnmuteMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).UnmuteMember(ctx, req.(*ModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_SetMemberRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).SetMemberRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_SetMemberRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).SetMemberRole(ctx, req.(*RoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_SetRoomTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).SetRoomTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_SetRoomTopic_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).SetRoomTopic(ctx, req.(*TopicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_SetRoomVisibility_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VisibilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).SetRoomVisibility(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_SetRoomVisibility_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).SetRoomVisibility(ctx, req.(*VisibilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_InviteToRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).InviteToRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_InviteToRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).InviteToRoom(ctx, req.(*InviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_GetPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).GetPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_GetPresence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).GetPresence(ctx, req.(*PresenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_ListConversations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).ListConversations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_ListConversations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).ListConversations(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_GetConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).GetConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_GetConversation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).GetConversation(ctx, req.(*ConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_PublishKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicKey)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).PublishKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_PublishKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).PublishKey(ctx, req.(*PublicKey))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_GetPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).GetPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_GetPublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).GetPublicKey(ctx, req.(*PublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_UploadAttachment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChatServer).UploadAttachment(&grpc.GenericServerStream[AttachmentChunk, Attachment]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Chat_UploadAttachmentServer = grpc.ClientStreamingServer[AttachmentChunk, Attachment]
+
+func _Chat_DownloadAttachment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AttachmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServer).DownloadAttachment(m, &grpc.GenericServerStream[AttachmentRequest, AttachmentChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Chat_DownloadAttachmentServer = grpc.ServerStreamingServer[AttachmentChunk]
+
+func _Chat_CreateBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).CreateBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_CreateBot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).CreateBot(ctx, req.(*BotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_DeleteBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).DeleteBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_DeleteBot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).DeleteBot(ctx, req.(*BotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_AddWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Webhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).AddWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_AddWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).AddWebhook(ctx, req.(*Webhook))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_RemoveWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).RemoveWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_RemoveWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).RemoveWebhook(ctx, req.(*WebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chat_ListIntegrations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServer).ListIntegrations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chat_ListIntegrations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServer).ListIntegrations(ctx, req.(*RoomInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Chat_ServiceDesc is the grpc.ServiceDesc for Chat service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Chat_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.Chat",
+	HandlerType: (*ChatServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendPrivateMessage",
+			Handler:    _Chat_SendPrivateMessage_Handler,
+		},
+		{
+			MethodName: "LeaveChatRoom",
+			Handler:    _Chat_LeaveChatRoom_Handler,
+		},
+		{
+			MethodName: "JoinRoom",
+			Handler:    _Chat_JoinRoom_Handler,
+		},
+		{
+			MethodName: "GetExistingChatRooms",
+			Handler:    _Chat_GetExistingChatRooms_Handler,
+		},
+		{
+			MethodName: "GetRoomHistory",
+			Handler:    _Chat_GetRoomHistory_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _Chat_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Chat_Login_Handler,
+		},
+		{
+			MethodName: "GetRoomInfo",
+			Handler:    _Chat_GetRoomInfo_Handler,
+		},
+		{
+			MethodName: "KickMember",
+			Handler:    _Chat_KickMember_Handler,
+		},
+		{
+			MethodName: "BanMember",
+			Handler:    _Chat_BanMember_Handler,
+		},
+		{
+			MethodName: "UnbanMember",
+			Handler:    _Chat_UnbanMember_Handler,
+		},
+		{
+			MethodName: "MuteMember",
+			Handler:    _Chat_MuteMember_Handler,
+		},
+		{
+			MethodName: "UnmuteMember",
+			Handler:    _Chat_UnmuteMember_Handler,
+		},
+		{
+			MethodName: "SetMemberRole",
+			Handler:    _Chat_SetMemberRole_Handler,
+		},
+		{
+			MethodName: "SetRoomTopic",
+			Handler:    _Chat_SetRoomTopic_Handler,
+		},
+		{
+			MethodName: "SetRoomVisibility",
+			Handler:    _Chat_SetRoomVisibility_Handler,
+		},
+		{
+			MethodName: "InviteToRoom",
+			Handler:    _Chat_InviteToRoom_Handler,
+		},
+		{
+			MethodName: "GetPresence",
+			Handler:    _Chat_GetPresence_Handler,
+		},
+		{
+			MethodName: "ListConversations",
+			Handler:    _Chat_ListConversations_Handler,
+		},
+		{
+			MethodName: "GetConversation",
+			Handler:    _Chat_GetConversation_Handler,
+		},
+		{
+			MethodName: "PublishKey",
+			Handler:    _Chat_PublishKey_Handler,
+		},
+		{
+			MethodName: "GetPublicKey",
+			Handler:    _Chat_GetPublicKey_Handler,
+		},
+		{
+			MethodName: "CreateBot",
+			Handler:    _Chat_CreateBot_Handler,
+		},
+		{
+			MethodName: "DeleteBot",
+			Handler:    _Chat_DeleteBot_Handler,
+		},
+		{
+			MethodName: "AddWebhook",
+			Handler:    _Chat_AddWebhook_Handler,
+		},
+		{
+			MethodName: "RemoveWebhook",
+			Handler:    _Chat_RemoveWebhook_Handler,
+		},
+		{
+			MethodName: "ListIntegrations",
+			Handler:    _Chat_ListIntegrations_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RoomChat",
+			Handler:       _Chat_RoomChat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "UploadAttachment",
+			Handler:       _Chat_UploadAttachment_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadAttachment",
+			Handler:       _Chat_DownloadAttachment_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "chatapp.proto",
+}
+
+const (
+	Cluster_Order_FullMethodName   = "/chat.Cluster/Order"
+	Cluster_Deliver_FullMethodName = "/chat.Cluster/Deliver"
+)
+
+// ClusterClient is the client API for Cluster service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ClusterClient interface {
+	Order(ctx context.Context, in *ClusterEvent, opts ...grpc.CallOption) (*Empty, error)
+	Deliver(ctx context.Context, in *ClusterEvent, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type clusterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClusterClient(cc grpc.ClientConnInterface) ClusterClient {
+	return &clusterClient{cc}
+}
+
+func (c *clusterClient) Order(ctx context.Context, in *ClusterEvent, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Cluster_Order_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterClient) Deliver(ctx context.Context, in *ClusterEvent, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Cluster_Deliver_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClusterServer is the server API for Cluster service.
+// All implementations must embed UnimplementedClusterServer
+// for forward compatibility.
+type ClusterServer interface {
+	Order(context.Context, *ClusterEvent) (*Empty, error)
+	Deliver(context.Context, *ClusterEvent) (*Empty, error)
+	mustEmbedUnimplementedClusterServer()
+}
+
+// UnimplementedClusterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedClusterServer struct{}
+
+func (UnimplementedClusterServer) Order(context.Context, *ClusterEvent) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Order not implemented")
+}
+func (UnimplementedClusterServer) Deliver(context.Context, *ClusterEvent) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deliver not implemented")
+}
+func (UnimplementedClusterServer) mustEmbedUnimplementedClusterServer() {}
+func (UnimplementedClusterServer) testEmbeddedByValue()                 {}
+
+// UnsafeClusterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ClusterServer will
+// result in compilation errors.
+type UnsafeClusterServer interface {
+	mustEmbedUnimplementedClusterServer()
+}
+
+func RegisterClusterServer(s grpc.ServiceRegistrar, srv ClusterServer) {
+	// If the following call pancis, it indicates UnimplementedClusterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Cluster_ServiceDesc, srv)
+}
+
+func _Cluster_Order_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterEvent)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServer).Order(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cluster_Order_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServer).Order(ctx, req.(*ClusterEvent))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Cluster_Deliver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterEvent)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterServer).Deliver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Cluster_Deliver_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterServer).Deliver(ctx, req.(*ClusterEvent))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Cluster_ServiceDesc is the grpc.ServiceDesc for Cluster service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Cluster_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.Cluster",
+	HandlerType: (*ClusterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Order",
+			Handler:    _Cluster_Order_Handler,
+		},
+		{
+			MethodName: "Deliver",
+			Handler:    _Cluster_Deliver_Handler,
 		},
 	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "chatapp.proto",
 }
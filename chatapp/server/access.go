@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"log"
+	"strings"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultInviteTTL = 24 * time.Hour
+	maxInviteTTL     = 7 * 24 * time.Hour
+)
+
+func (r *roomInfo) visibility() string {
+	if r.Visibility == "" {
+		return visibilityPublic
+	}
+	return r.Visibility
+}
+
+// admitted reports whether user can enter without a password or invite.
+func (r *roomInfo) admitted(user string) bool {
+	return r.canModerate(user) || r.Allowed[user]
+}
+
+func (r *roomInfo) hasInviteFor(user string) bool {
+	now := time.Now()
+	for _, invite := range r.Invites {
+		if invite.Invitee == user && now.Before(invite.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// listedFor reports whether the room shows up in user's room list.
+func (r *roomInfo) listedFor(user string) bool {
+	switch r.visibility() {
+	case visibilityPublic, visibilityPassword:
+		return true
+	case visibilityInvite:
+		return r.admitted(user) || r.hasInviteFor(user)
+	default:
+		return r.admitted(user)
+	}
+}
+
+// readableBy reports whether user may read the room's history and details.
+func (r *roomInfo) readableBy(user string) bool {
+	switch r.visibility() {
+	case visibilityPublic, visibilityUnlisted:
+		return true
+	default:
+		return r.admitted(user)
+	}
+}
+
+func validateVisibility(visibility, password string) error {
+	switch visibility {
+	case visibilityPublic, visibilityUnlisted, visibilityInvite:
+		return nil
+	case visibilityPassword:
+		if len(password) < minPasswordLength {
+			return status.Errorf(codes.InvalidArgument, "room password must be at least %d characters", minPasswordLength)
+		}
+		return nil
+	}
+	return status.Error(codes.InvalidArgument, "visibility must be public, unlisted, password or invite")
+}
+
+func hashRoomPassword(visibility, password string) ([]byte, error) {
+	if visibility != visibilityPassword {
+		return nil, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't hash room password: %v", err)
+	}
+	return hash, nil
+}
+
+// admit creates the room if needed and checks that user may join it,
+// remembering users who got in with a password or invite.
+func (s *chatServer) admit(room, user string, req *pb.JoinRequest) (roomInfo, error) {
+	var hash []byte
+	if req.Visibility != "" {
+		if err := validateVisibility(req.Visibility, req.Password); err != nil {
+			return roomInfo{}, err
+		}
+		var err error
+		if hash, err = hashRoomPassword(req.Visibility, req.Password); err != nil {
+			return roomInfo{}, err
+		}
+	}
+
+	info, created, err := s.roomStore.Ensure(room, func() roomInfo {
+		return roomInfo{
+			Owner:        user,
+			Visibility:   req.Visibility,
+			PasswordHash: hash,
+			Created:      time.Now().UTC(),
+		}
+	})
+	if err != nil {
+		log.Printf("failed to create room %s: %v", room, err)
+		return roomInfo{}, status.Error(codes.Internal, "couldn't create the room")
+	}
+	if created {
+		s.shareRoom(room, info)
+		return info, nil
+	}
+
+	if until, banned := info.bannedUntil(user); banned {
+		return roomInfo{}, status.Errorf(codes.PermissionDenied, "you are banned from this room %s", describeUntil(until))
+	}
+	if info.admitted(user) || info.visibility() == visibilityPublic {
+		return info, nil
+	}
+
+	checkedHash := info.PasswordHash
+	if info.visibility() == visibilityPassword {
+		if req.Password == "" {
+			return roomInfo{}, status.Error(codes.PermissionDenied, "this room needs a password")
+		}
+		if bcrypt.CompareHashAndPassword(checkedHash, []byte(req.Password)) != nil {
+			return roomInfo{}, status.Error(codes.PermissionDenied, "wrong room password")
+		}
+	}
+
+	return s.updateRoom(room, func(info *roomInfo) error {
+		switch info.visibility() {
+		case visibilityPassword:
+			if !bytes.Equal(info.PasswordHash, checkedHash) {
+				return status.Error(codes.Aborted, "the room password just changed, try again")
+			}
+		case visibilityInvite:
+			invite, ok := info.Invites[req.InviteCode]
+			if req.InviteCode == "" || !ok {
+				return status.Error(codes.PermissionDenied, "this room is invite-only")
+			}
+			if invite.Invitee != "" {
+				if invite.Invitee != user {
+					return status.Error(codes.PermissionDenied, "this invite is for someone else")
+				}
+				delete(info.Invites, req.InviteCode)
+			}
+		}
+		if info.Allowed == nil {
+			info.Allowed = make(map[string]bool)
+		}
+		info.Allowed[user] = true
+		return nil
+	})
+}
+
+// checkReadable is called before anything is read for the room, so a room
+// nobody created doesn't get a history log just by being asked about.
+func (s *chatServer) checkReadable(room, user string) error {
+	info, ok := s.roomStore.Get(room)
+	if !ok {
+		return status.Error(codes.NotFound, errRoomNotFound.Error())
+	}
+	if !info.readableBy(user) {
+		return status.Error(codes.PermissionDenied, "you are not a member of this room")
+	}
+	return nil
+}
+
+// revokeAdmission makes user go through the password or an invite again.
+func (s *chatServer) revokeAdmission(room, user string) {
+	info, ok := s.roomStore.Get(room)
+	if !ok || !info.Allowed[user] {
+		return
+	}
+	_, err := s.updateRoom(room, func(info *roomInfo) error {
+		delete(info.Allowed, user)
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to revoke %s's access to room %s: %v", user, room, err)
+	}
+}
+
+func (s *chatServer) SetRoomVisibility(ctx context.Context, req *pb.VisibilityRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	if err := validateVisibility(req.Visibility, req.Password); err != nil {
+		return nil, err
+	}
+	hash, err := hashRoomPassword(req.Visibility, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.updateRoom(req.Room, func(info *roomInfo) error {
+		if info.role(actor) != roleOwner {
+			return status.Error(codes.PermissionDenied, "only the room owner can change who may join")
+		}
+		info.Visibility = req.Visibility
+		info.PasswordHash = hash
+		if hash != nil {
+			// a new password locks out everyone who only knew the old one
+			info.Allowed = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: actor + " made the room " + req.Visibility,
+		Sender: actor,
+		Room:   req.Room,
+		Type:   updateVisibility,
+		Actor:  actor,
+		Detail: req.Visibility,
+	})
+
+	return &pb.MessageResponse{Status: "Room is now " + req.Visibility}, nil
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func (s *chatServer) InviteToRoom(ctx context.Context, req *pb.InviteRequest) (*pb.InviteResponse, error) {
+	actor := userFromContext(ctx)
+
+	ttl := defaultInviteTTL
+	if req.TtlSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl can't be negative")
+	}
+	if req.TtlSeconds > 0 {
+		ttl = time.Duration(req.TtlSeconds) * time.Second
+	}
+	if ttl > maxInviteTTL {
+		ttl = maxInviteTTL
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't create invite: %v", err)
+	}
+	expiresAt := time.Now().Add(ttl).UTC()
+
+	_, err = s.updateRoom(req.Room, func(info *roomInfo) error {
+		if !info.canModerate(actor) {
+			return status.Error(codes.PermissionDenied, "only the room owner or a moderator can invite")
+		}
+		if info.Invites == nil {
+			info.Invites = make(map[string]roomInvite)
+		}
+		info.Invites[code] = roomInvite{
+			Invitee:   req.Invitee,
+			CreatedBy: actor,
+			ExpiresAt: expiresAt,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// let the invitee know, now or when they are next online
+	if req.Invitee != "" {
+		s.sendPrivate(&pb.PrivateMessage{
+			Sender:    actor,
+			Recipient: req.Invitee,
+			Content:   "You are invited to " + req.Room + ", join it with invite code " + code,
+		})
+	}
+
+	return &pb.InviteResponse{
+		Code:      code,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRoomAdmission(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	alice, bob, carol, dave := node.as(t, "alice"), node.as(t, "bob"), node.as(t, "carol"), node.as(t, "dave")
+	join := func(who string, req *pb.JoinRequest) error {
+		_, err := node.client.JoinRoom(node.as(t, who), req)
+		return err
+	}
+
+	if err := join("alice", &pb.JoinRequest{Room: "vault", Visibility: visibilityPassword, Password: "open sesame"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"", "open barley"} {
+		if err := join("bob", &pb.JoinRequest{Room: "vault", Password: password}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("joining with password %q: %v", password, err)
+		}
+	}
+	if err := join("bob", &pb.JoinRequest{Room: "vault", Password: "open sesame"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := join("bob", &pb.JoinRequest{Room: "vault"}); err != nil {
+		t.Fatalf("bob got in once, the password isn't asked again: %v", err)
+	}
+	// a kick makes bob go through the password again
+	if _, err := node.client.KickMember(alice, &pb.ModerationRequest{Room: "vault", Target: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := join("bob", &pb.JoinRequest{Room: "vault"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("bob joining after being kicked: %v", err)
+	}
+
+	if err := join("alice", &pb.JoinRequest{Room: "club", Visibility: visibilityInvite}); err != nil {
+		t.Fatal(err)
+	}
+	listed := func(user string) bool {
+		rooms, err := node.client.GetExistingChatRooms(node.as(t, user), &pb.Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.Contains(rooms.Rooms, "club")
+	}
+	if listed("carol") {
+		t.Fatal("carol sees an invite-only room without an invite")
+	}
+	if err := join("carol", &pb.JoinRequest{Room: "club"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("joining an invite-only room without a code: %v", err)
+	}
+	if _, err := node.client.InviteToRoom(bob, &pb.InviteRequest{Room: "club", Invitee: "carol"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("someone who isn't in the room inviting: %v", err)
+	}
+
+	invite, err := node.client.InviteToRoom(alice, &pb.InviteRequest{Room: "club", Invitee: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !listed("carol") {
+		t.Fatal("carol doesn't see the room after being invited")
+	}
+	if _, err := node.client.JoinRoom(dave, &pb.JoinRequest{Room: "club", InviteCode: invite.Code}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("dave using carol's invite: %v", err)
+	}
+	if _, err := node.client.JoinRoom(carol, &pb.JoinRequest{Room: "club", InviteCode: invite.Code}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a code without an invitee lets in whoever has it until it expires
+	open, err := node.client.InviteToRoom(alice, &pb.InviteRequest{Room: "club"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"bob", "dave"} {
+		if err := join(user, &pb.JoinRequest{Room: "club", InviteCode: open.Code}); err != nil {
+			t.Fatalf("%s using the open invite: %v", user, err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultMaxAttachmentSize = 10 << 20
+	attachmentChunkSize      = 32 << 10
+	maxAttachmentNameLength  = 255
+	sniffLength              = 512 // all http.DetectContentType looks at
+)
+
+var errNoSuchAttachment = status.Error(codes.NotFound, "no such attachment")
+
+// attachmentStore keeps uploaded files on disk named after the sha256 of
+// their data, so the same file uploaded twice is stored once. Files are
+// spread over subdirectories named after the first two hex digits.
+type attachmentStore struct {
+	dir     string
+	maxSize int64
+
+	mu sync.Mutex // held while an attachment's access changes
+}
+
+// attachmentAccess is who may download an attachment: whoever uploaded it,
+// and anyone who can read a room it was posted in. It is kept next to the
+// data, as <id>.json.
+type attachmentAccess struct {
+	Uploaders []string `json:"uploaders,omitempty"`
+	Rooms     []string `json:"rooms,omitempty"`
+}
+
+func newAttachmentStore(dir string, maxSize int64) (*attachmentStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating attachment dir: %w", err)
+	}
+	return &attachmentStore{dir: dir, maxSize: maxSize}, nil
+}
+
+func validAttachmentID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+func (a *attachmentStore) path(id string) string {
+	return filepath.Join(a.dir, id[:2], id)
+}
+
+// Save stores what r has, up to maxSize bytes, and describes it.
+func (a *attachmentStore) Save(r io.Reader) (*pb.Attachment, error) {
+	tmp, err := os.CreateTemp(a.dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	head := &prefixWriter{limit: sniffLength}
+	n, err := io.Copy(io.MultiWriter(tmp, hash, head), io.LimitReader(r, a.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > a.maxSize {
+		return nil, status.Errorf(codes.InvalidArgument, "attachments can be at most %d bytes", a.maxSize)
+	}
+	if n == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the attachment is empty")
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	id := hex.EncodeToString(hash.Sum(nil))
+	if err := os.MkdirAll(filepath.Dir(a.path(id)), 0755); err != nil {
+		return nil, err
+	}
+	// the same data might be there already, it is the same file either way
+	if err := os.Rename(tmp.Name(), a.path(id)); err != nil {
+		return nil, err
+	}
+	return &pb.Attachment{
+		Id:          id,
+		ContentType: http.DetectContentType(head.buf),
+		Size:        n,
+	}, nil
+}
+
+func (a *attachmentStore) access(id string) (attachmentAccess, error) {
+	var access attachmentAccess
+	if !validAttachmentID(id) {
+		return access, errNoSuchAttachment
+	}
+	data, err := os.ReadFile(a.path(id) + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return access, nil
+	}
+	if err != nil {
+		return access, err
+	}
+	return access, json.Unmarshal(data, &access)
+}
+
+// grant records that user uploaded the attachment, or that it was posted in
+// room.
+func (a *attachmentStore) grant(id, user, room string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	access, err := a.access(id)
+	if err != nil {
+		return err
+	}
+	if user != "" && !slices.Contains(access.Uploaders, user) {
+		access.Uploaders = append(access.Uploaders, user)
+	} else if room != "" && !slices.Contains(access.Rooms, room) {
+		access.Rooms = append(access.Rooms, room)
+	} else {
+		return nil
+	}
+	data, err := json.Marshal(access)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(a.path(id)+".json", data, 0644)
+}
+
+// Open returns the attachment's data and what it is.
+func (a *attachmentStore) Open(id string) (*os.File, *pb.Attachment, error) {
+	if !validAttachmentID(id) {
+		return nil, nil, errNoSuchAttachment
+	}
+	file, err := os.Open(a.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, errNoSuchAttachment
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := a.stat(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	info.Id = id
+	return file, info, nil
+}
+
+// Stat describes the attachment without handing out its data.
+func (a *attachmentStore) Stat(id string) (*pb.Attachment, error) {
+	file, info, err := a.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	return info, nil
+}
+
+func (a *attachmentStore) stat(file *os.File) (*pb.Attachment, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, sniffLength)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &pb.Attachment{
+		ContentType: http.DetectContentType(head[:n]),
+		Size:        fi.Size(),
+	}, nil
+}
+
+// prefixWriter keeps the first limit bytes written to it.
+type prefixWriter struct {
+	limit int
+	buf   []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if room := w.limit - len(w.buf); room > 0 {
+		w.buf = append(w.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// cleanAttachmentName keeps only the last element of the uploader's path.
+func cleanAttachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	if len(name) > maxAttachmentNameLength {
+		name = name[:maxAttachmentNameLength]
+	}
+	return name
+}
+
+// chunkReader reads the data of an upload stream.
+type chunkReader struct {
+	stream pb.Chat_UploadAttachmentServer
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = chunk.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (s *chatServer) UploadAttachment(stream pb.Chat_UploadAttachmentServer) error {
+	user := userFromContext(stream.Context())
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "the attachment is empty")
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := s.attachments.Save(&chunkReader{stream: stream, buf: first.Data})
+	if _, ok := status.FromError(err); err != nil && !ok {
+		log.Printf("failed to store attachment from %s: %v", user, err)
+		return status.Error(codes.Internal, "couldn't store the attachment")
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.attachments.grant(info.Id, user, ""); err != nil {
+		log.Printf("failed to record who uploaded attachment %s: %v", info.Id, err)
+		return status.Error(codes.Internal, "couldn't store the attachment")
+	}
+	info.Name = cleanAttachmentName(first.GetInfo().GetName())
+	return stream.SendAndClose(info)
+}
+
+// canReadAttachment reports whether user uploaded the attachment or can read
+// a room it was posted in. Anyone else is told there is no such attachment.
+func (s *chatServer) canReadAttachment(id, user string) error {
+	access, err := s.attachments.access(id)
+	if err == errNoSuchAttachment {
+		return err
+	}
+	if err != nil {
+		log.Printf("failed to read who may see attachment %s: %v", id, err)
+		return status.Error(codes.Internal, "couldn't read the attachment")
+	}
+	if slices.Contains(access.Uploaders, user) {
+		return nil
+	}
+	for _, room := range access.Rooms {
+		if info, ok := s.roomStore.Get(room); ok && info.readableBy(user) {
+			return nil
+		}
+	}
+	return errNoSuchAttachment
+}
+
+func (s *chatServer) DownloadAttachment(req *pb.AttachmentRequest, stream pb.Chat_DownloadAttachmentServer) error {
+	if err := s.canReadAttachment(req.Id, userFromContext(stream.Context())); err != nil {
+		return err
+	}
+	file, info, err := s.attachments.Open(req.Id)
+	if err == errNoSuchAttachment {
+		return err
+	}
+	if err != nil {
+		log.Printf("failed to open attachment %s: %v", req.Id, err)
+		return status.Error(codes.Internal, "couldn't read the attachment")
+	}
+	defer file.Close()
+
+	buf := make([]byte, attachmentChunkSize)
+	chunk := &pb.AttachmentChunk{Info: info}
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &pb.AttachmentChunk{}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("failed to read attachment %s: %v", req.Id, err)
+			return status.Error(codes.Internal, "couldn't read the attachment")
+		}
+	}
+}
+
+// checkAttachment fills in the details of the attachment msg refers to from
+// what is stored, so senders can't misdescribe it, and lets the room see
+// it. Only an attachment the sender may read can be posted.
+func (s *chatServer) checkAttachment(msg *pb.ChatRoomMessage) error {
+	if msg.Attachment == nil {
+		return nil
+	}
+	if err := s.canReadAttachment(msg.Attachment.Id, msg.Sender); err != nil {
+		return err
+	}
+	info, err := s.attachments.Stat(msg.Attachment.Id)
+	if err != nil {
+		return err
+	}
+	if err := s.attachments.grant(info.Id, "", msg.Room); err != nil {
+		log.Printf("failed to share attachment %s with room %s: %v", info.Id, msg.Room, err)
+		return status.Error(codes.Internal, "couldn't share the attachment")
+	}
+	info.Name = cleanAttachmentName(msg.Attachment.Name)
+	msg.Attachment = info
+	return nil
+}
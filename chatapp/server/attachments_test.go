@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func uploadAttachment(t *testing.T, ctx context.Context, client pb.ChatClient, data string) *pb.Attachment {
+	t.Helper()
+	stream, err := client.UploadAttachment(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.AttachmentChunk{Info: &pb.Attachment{Name: "notes.txt"}, Data: []byte(data)}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func downloadAttachment(ctx context.Context, client pb.ChatClient, id string) (string, error) {
+	stream, err := client.DownloadAttachment(ctx, &pb.AttachmentRequest{Id: id})
+	if err != nil {
+		return "", err
+	}
+	var data []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return string(data), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		data = append(data, chunk.Data...)
+	}
+}
+
+func TestAttachmentAccess(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	alice := node.as(t, "alice")
+	bob := node.as(t, "bob")
+	for room, visibility := range map[string]string{"secret": visibilityInvite, "lobby": visibilityPublic} {
+		if _, err := node.client.JoinRoom(alice, &pb.JoinRequest{Room: room, Visibility: visibility}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info := uploadAttachment(t, alice, node.client, "the plans")
+	if data, err := downloadAttachment(alice, node.client, info.Id); err != nil || data != "the plans" {
+		t.Fatalf("alice downloaded %q, %v", data, err)
+	}
+	if _, err := downloadAttachment(bob, node.client, info.Id); status.Code(err) != codes.NotFound {
+		t.Fatalf("bob downloaded an attachment nobody shared with them: %v", err)
+	}
+
+	if err := node.cs.checkAttachment(&pb.ChatRoomMessage{Room: "secret", Sender: "alice", Attachment: &pb.Attachment{Id: info.Id}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := downloadAttachment(bob, node.client, info.Id); status.Code(err) != codes.NotFound {
+		t.Fatalf("bob downloaded an attachment of an invite-only room they aren't in: %v", err)
+	}
+	// nor can they pass it off as their own in a room they can read
+	if err := node.cs.checkAttachment(&pb.ChatRoomMessage{Room: "lobby", Sender: "bob", Attachment: &pb.Attachment{Id: info.Id}}); status.Code(err) != codes.NotFound {
+		t.Fatalf("bob posted an attachment they can't read: %v", err)
+	}
+
+	if err := node.cs.checkAttachment(&pb.ChatRoomMessage{Room: "lobby", Sender: "alice", Attachment: &pb.Attachment{Id: info.Id}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := downloadAttachment(bob, node.client, info.Id); err != nil || data != "the plans" {
+		t.Fatalf("bob downloaded %q from the public room, %v", data, err)
+	}
+}
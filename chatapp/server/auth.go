@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	sessionTTL        = 24 * time.Hour
+	minPasswordLength = 6
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+
+	errUserExists         = errors.New("username already registered")
+	errInvalidCredentials = errors.New("invalid username or password")
+	errInvalidToken       = errors.New("invalid session token")
+)
+
+// Methods that can be called without a session token.
+var publicMethods = map[string]bool{
+	pb.Chat_Register_FullMethodName: true,
+	pb.Chat_Login_FullMethodName:    true,
+	// nodes check each other with the cluster token instead
+	pb.Cluster_Order_FullMethodName:   true,
+	pb.Cluster_Deliver_FullMethodName: true,
+	// for load balancers and tools like grpcurl
+	healthpb.Health_Check_FullMethodName:                                   true,
+	healthpb.Health_Watch_FullMethodName:                                   true,
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:      true,
+	reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: true,
+}
+
+type userRecord struct {
+	PasswordHash []byte    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// userStore keeps bcrypt hashed passwords in a JSON file in the data dir.
+type userStore struct {
+	mu    sync.Mutex
+	path  string
+	users map[string]userRecord
+}
+
+func newUserStore(path string) (*userStore, error) {
+	store := &userStore{
+		path:  path,
+		users: make(map[string]userRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.users); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// newUserRecord hashes password for a new account.
+func newUserRecord(password string) (userRecord, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return userRecord{}, err
+	}
+	return userRecord{PasswordHash: hash, CreatedAt: time.Now().UTC()}, nil
+}
+
+// Add saves an account registered on any node, unless the name is taken.
+func (u *userStore) Add(username string, rec userRecord) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, exists := u.users[username]; exists {
+		return errUserExists
+	}
+	u.users[username] = rec
+	if err := u.save(); err != nil {
+		delete(u.users, username)
+		return err
+	}
+	return nil
+}
+
+func (u *userStore) Exists(username string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, exists := u.users[username]
+	return exists
+}
+
+func (u *userStore) Verify(username, password string) error {
+	u.mu.Lock()
+	rec, exists := u.users[username]
+	u.mu.Unlock()
+
+	if !exists {
+		return errInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword(rec.PasswordHash, []byte(password)) != nil {
+		return errInvalidCredentials
+	}
+	return nil
+}
+
+// save must be called with u.mu held.
+func (u *userStore) save() error {
+	data, err := json.MarshalIndent(u.users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(u.path, data, 0600)
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type sessionClaims struct {
+	Username  string `json:"sub"`
+	SessionID string `json:"sid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// tokenSigner issues and checks "<payload>.<hmac>" tokens, both parts base64url.
+type tokenSigner struct {
+	key []byte
+}
+
+// loadSigningKey reads the HMAC key from path, creating a random one on first
+// start so sessions survive restarts.
+func loadSigningKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, fmt.Errorf("%s does not contain a valid signing key", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (t *tokenSigner) Issue(username string) (string, sessionClaims, error) {
+	sid := make([]byte, 16)
+	if _, err := rand.Read(sid); err != nil {
+		return "", sessionClaims{}, err
+	}
+	claims := sessionClaims{
+		Username:  username,
+		SessionID: hex.EncodeToString(sid),
+		ExpiresAt: time.Now().Add(sessionTTL).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", sessionClaims{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded)), claims, nil
+}
+
+func (t *tokenSigner) Verify(token string) (sessionClaims, error) {
+	var claims sessionClaims
+
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, errInvalidToken
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, t.sign(encoded)) {
+		return claims, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, errInvalidToken
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Username == "" {
+		return claims, errInvalidToken
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return claims, errors.New("session expired")
+	}
+	return claims, nil
+}
+
+func (t *tokenSigner) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+type claimsKey struct{}
+
+func userFromContext(ctx context.Context) string {
+	claims, _ := ctx.Value(claimsKey{}).(sessionClaims)
+	return claims.Username
+}
+
+func (s *chatServer) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing session token")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+	}
+	claims, err := s.tokens.Verify(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+func (s *chatServer) UnaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authedStream) Context() context.Context {
+	return a.ctx
+}
+
+func (s *chatServer) StreamAuthInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if publicMethods[info.FullMethod] {
+		return handler(srv, ss)
+	}
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (s *chatServer) Register(ctx context.Context, creds *pb.Credentials) (*pb.AuthResponse, error) {
+	if !usernamePattern.MatchString(creds.Username) {
+		return nil, status.Error(codes.InvalidArgument, "username must be 1-32 letters, digits, '.', '_' or '-'")
+	}
+	if len(creds.Password) < minPasswordLength {
+		return nil, status.Errorf(codes.InvalidArgument, "password must be at least %d characters", minPasswordLength)
+	}
+
+	if s.users.Exists(creds.Username) {
+		return nil, status.Error(codes.AlreadyExists, errUserExists.Error())
+	}
+	if s.roomStore.PostsAs(creds.Username) {
+		return nil, status.Errorf(codes.AlreadyExists, "%s is taken by a bot", creds.Username)
+	}
+
+	rec, err := newUserRecord(creds.Password)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't register user: %v", err)
+	}
+	if err := s.claimAccount(ctx, creds.Username, rec); err != nil {
+		return nil, err
+	}
+	return s.issueToken(creds.Username)
+}
+
+func (s *chatServer) Login(ctx context.Context, creds *pb.Credentials) (*pb.AuthResponse, error) {
+	if err := s.users.Verify(creds.Username, creds.Password); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return s.issueToken(creds.Username)
+}
+
+func (s *chatServer) issueToken(username string) (*pb.AuthResponse, error) {
+	token, claims, err := s.tokens.Issue(username)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't issue token: %v", err)
+	}
+	return &pb.AuthResponse{
+		Token:     token,
+		Username:  username,
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+func accountTopic(user string) string {
+	return "user/" + user
+}
+
+// sharedAccount is an account as the other nodes are sent it.
+type sharedAccount struct {
+	CreatedAt time.Time `json:"created_at"`
+	Sealed    []byte    `json:"sealed"` // the password hash
+}
+
+func sealAccount(key []byte, username string, rec userRecord) ([]byte, error) {
+	sealed, err := seal(key, rec.PasswordHash, accountTopic(username))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sharedAccount{CreatedAt: rec.CreatedAt, Sealed: sealed})
+}
+
+func openAccount(key []byte, username string, data []byte) (userRecord, error) {
+	var shared sharedAccount
+	if err := json.Unmarshal(data, &shared); err != nil {
+		return userRecord{}, err
+	}
+	hash, err := unseal(key, shared.Sealed, accountTopic(username))
+	if err != nil {
+		return userRecord{}, fmt.Errorf("opening password hash: %w", err)
+	}
+	return userRecord{PasswordHash: hash, CreatedAt: shared.CreatedAt}, nil
+}
+
+// pendingAccounts are the Register calls on this node waiting for their
+// claim on a name to come back from the broker.
+type pendingAccounts struct {
+	mu      sync.Mutex
+	waiting map[string]*accountClaim
+}
+
+type accountClaim struct {
+	hash []byte
+	done chan error // nil if the name was still free
+}
+
+func newPendingAccounts() *pendingAccounts {
+	return &pendingAccounts{waiting: make(map[string]*accountClaim)}
+}
+
+// add reports false if the name is already being registered here.
+func (p *pendingAccounts) add(username string, hash []byte) (*accountClaim, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.waiting[username] != nil {
+		return nil, false
+	}
+	claim := &accountClaim{hash: hash, done: make(chan error, 1)}
+	p.waiting[username] = claim
+	return claim, true
+}
+
+func (p *pendingAccounts) remove(username string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.waiting, username)
+}
+
+// resolve tells the claim with hash how it went.
+func (p *pendingAccounts) resolve(username string, hash []byte, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if claim := p.waiting[username]; claim != nil && bytes.Equal(claim.hash, hash) {
+		claim.done <- err
+	}
+}
+
+// claimAccount registers username on every node. Claims from all nodes go
+// through the leader of the user's topic, which hands them to everyone in
+// the same order, so the first one gets the name everywhere and the others
+// are turned down.
+func (s *chatServer) claimAccount(ctx context.Context, username string, rec userRecord) error {
+	claim, ok := s.registering.add(username, rec.PasswordHash)
+	if !ok {
+		return status.Error(codes.AlreadyExists, errUserExists.Error())
+	}
+	defer s.registering.remove(username)
+
+	data, err := sealAccount(s.tokens.key, username, rec)
+	if err != nil {
+		return status.Errorf(codes.Internal, "couldn't register user: %v", err)
+	}
+	if err := s.publish(accountTopic(username), &pb.ClusterEvent{Account: data}); err != nil {
+		return status.Error(codes.Unavailable, "couldn't reach the other servers, try again")
+	}
+
+	select {
+	case err := <-claim.done:
+		if errors.Is(err, errUserExists) {
+			return status.Error(codes.AlreadyExists, err.Error())
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "couldn't register user: %v", err)
+		}
+		return nil
+	case <-time.After(deliverTimeout):
+		return status.Error(codes.Unavailable, "couldn't reach the other servers, try again")
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// onAccountEvent is subscribed to every user topic.
+func (s *chatServer) onAccountEvent(event *pb.ClusterEvent) {
+	if event.Account == nil {
+		return
+	}
+	username := strings.TrimPrefix(event.Topic, "user/")
+	rec, err := openAccount(s.tokens.key, username, event.Account)
+	if err != nil {
+		log.Printf("failed to decode the account of %s: %v", username, err)
+		return
+	}
+	err = s.users.Add(username, rec)
+	if err != nil && !errors.Is(err, errUserExists) {
+		log.Printf("failed to save the account of %s: %v", username, err)
+	}
+	if event.Origin == s.broker.Node() {
+		s.registering.resolve(username, rec.PasswordHash, err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// signedToken makes a token for claims the way tokenSigner.Issue does.
+func signedToken(t *testing.T, signer *tokenSigner, claims sessionClaims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signer.sign(encoded))
+}
+
+func TestLoginAndTokens(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	ctx := context.Background()
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	if _, err := node.client.Register(ctx, &pb.Credentials{Username: "alice", Password: "short"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("registering with a short password: %v", err)
+	}
+	if _, err := node.client.Register(ctx, &pb.Credentials{Username: "alice", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.client.Login(ctx, &pb.Credentials{Username: "alice", Password: "battery staple"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("logging in with the wrong password: %v", err)
+	}
+	auth, err := node.client.Login(ctx, &pb.Credentials{Username: "alice", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.Username != "alice" || auth.ExpiresAt <= time.Now().Unix() {
+		t.Fatalf("logged in as %v", auth)
+	}
+	if _, err := node.client.GetExistingChatRooms(withToken(auth.Token), &pb.Empty{}); err != nil {
+		t.Fatalf("using the token: %v", err)
+	}
+
+	if _, err := node.client.GetExistingChatRooms(ctx, &pb.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("without a token: %v", err)
+	}
+	forged := auth.Token[:len(auth.Token)-2] + "xx"
+	if _, err := node.client.GetExistingChatRooms(withToken(forged), &pb.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("with a forged token: %v", err)
+	}
+	expired := signedToken(t, node.cs.tokens, sessionClaims{
+		Username:  "alice",
+		SessionID: "old",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+	_, err = node.client.GetExistingChatRooms(withToken(expired), &pb.Empty{})
+	if status.Code(err) != codes.Unauthenticated || !strings.Contains(status.Convert(err).Message(), "expired") {
+		t.Fatalf("with an expired token: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// Broker carries events between the chat servers sharing rooms. Events on
+// one topic reach subscribers one at a time, in the same order on every
+// node, the node that published them included.
+type Broker interface {
+	// Node names this server among the ones sharing the broker.
+	Node() string
+	Publish(ctx context.Context, topic string, event *pb.ClusterEvent) error
+	// Subscribe calls handler for every event on topics starting with prefix
+	// until cancel is called.
+	Subscribe(prefix string, handler func(event *pb.ClusterEvent)) (cancel func())
+}
+
+func roomTopic(room string) string {
+	return "room/" + room
+}
+
+// conversationTopic carries the private messages between a and b.
+func conversationTopic(a, b string) string {
+	return "dm/" + conversationKey(a, b)
+}
+
+type subscription struct {
+	prefix  string
+	handler func(event *pb.ClusterEvent)
+}
+
+// dispatcher hands events to the local subscribers. Every topic with
+// pending events has one goroutine working through them, so a slow handler
+// only holds up its own topic.
+type dispatcher struct {
+	mu      sync.Mutex
+	subs    map[int]subscription
+	nextSub int
+	pending map[string][]*pb.ClusterEvent // a topic is in here while its goroutine runs
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{
+		subs:    make(map[int]subscription),
+		pending: make(map[string][]*pb.ClusterEvent),
+	}
+}
+
+func (d *dispatcher) subscribe(prefix string, handler func(event *pb.ClusterEvent)) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := d.nextSub
+	d.nextSub++
+	d.subs[id] = subscription{prefix: prefix, handler: handler}
+	return func() {
+		d.mu.Lock()
+		delete(d.subs, id)
+		d.mu.Unlock()
+	}
+}
+
+func (d *dispatcher) dispatch(event *pb.ClusterEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue, running := d.pending[event.Topic]
+	d.pending[event.Topic] = append(queue, event)
+	if !running {
+		go d.run(event.Topic)
+	}
+}
+
+func (d *dispatcher) run(topic string) {
+	for {
+		d.mu.Lock()
+		queue := d.pending[topic]
+		if len(queue) == 0 {
+			delete(d.pending, topic)
+			d.mu.Unlock()
+			return
+		}
+		event := queue[0]
+		queue[0] = nil
+		d.pending[topic] = queue[1:]
+
+		var handlers []func(event *pb.ClusterEvent)
+		for _, sub := range d.subs {
+			if strings.HasPrefix(topic, sub.prefix) {
+				handlers = append(handlers, sub.handler)
+			}
+		}
+		d.mu.Unlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// memoryBroker is the Broker of a server running on its own.
+type memoryBroker struct {
+	*dispatcher
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{dispatcher: newDispatcher()}
+}
+
+func (b *memoryBroker) Node() string {
+	return "local"
+}
+
+func (b *memoryBroker) Publish(_ context.Context, topic string, event *pb.ClusterEvent) error {
+	event.Topic = topic
+	event.Origin = b.Node()
+	b.dispatch(event)
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(prefix string, handler func(event *pb.ClusterEvent)) func() {
+	return b.subscribe(prefix, handler)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// BenchmarkRoomChat drives thousands of simulated clients over bufconn. Every
+// client joins one of the rooms, keeps a RoomChat stream open and reads
+// everything it is sent; b.N messages are spread over all of them. One big
+// room measures fan-out, many small rooms measure how well rooms stay out of
+// each other's way.
+func BenchmarkRoomChat(b *testing.B) {
+	for _, bc := range []struct{ clients, rooms int }{
+		{clients: 1000, rooms: 1},
+		{clients: 1000, rooms: 100},
+		{clients: 4000, rooms: 1000},
+	} {
+		b.Run(fmt.Sprintf("clients=%d/rooms=%d", bc.clients, bc.rooms), func(b *testing.B) {
+			benchmarkRoomChat(b, bc.clients, bc.rooms)
+		})
+	}
+}
+
+// benchConns spreads the clients over a few connections like real clients
+// on different machines would be.
+const benchConns = 16
+
+func benchmarkRoomChat(b *testing.B, clients, rooms int) {
+	// every slow update subscriber gets logged, which is all of them here
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	key, err := loadSigningKey(filepath.Join(b.TempDir(), "token.key"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// big enough that nothing is dropped, we want to see every delivery
+	cs := newTestServer(b, key, 1<<20, newMemoryBroker())
+	tokens := cs.tokens
+
+	lis := bufconn.Listen(1 << 20)
+	srv := serveChat(b, cs, lis)
+
+	conns := make([]pb.ChatClient, benchConns)
+	for i := range conns {
+		conn, err := grpc.NewClient("passthrough:///bufconn",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = pb.NewChatClient(conn)
+	}
+
+	var receipts, delivered atomic.Int64
+	streams := make([]pb.Chat_RoomChatClient, clients)
+	var readers sync.WaitGroup
+	for i := range streams {
+		user := fmt.Sprintf("user%d", i)
+		room := fmt.Sprintf("room%d", i%rooms)
+		token, _, err := tokens.Issue(user)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		client := conns[i%benchConns]
+
+		if _, err := client.JoinRoom(ctx, &pb.JoinRequest{Room: room}); err != nil {
+			b.Fatalf("%s joining %s: %v", user, room, err)
+		}
+		stream, err := client.RoomChat(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: room}); err != nil {
+			b.Fatal(err)
+		}
+		streams[i] = stream
+
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				msg, err := stream.Recv()
+				if err != nil {
+					return
+				}
+				switch {
+				case msg.Type == msgTypeReceipt && msg.Receipt.GetMember() == user:
+					receipts.Add(1)
+				case msg.Type == msgTypeMessage:
+					delivered.Add(1)
+				}
+			}
+		}()
+	}
+
+	// one message per room first, so opening the room logs isn't timed
+	for i := 0; i < rooms; i++ {
+		if err := streams[i].Send(&pb.ChatRoomMessage{Room: fmt.Sprintf("room%d", i), Content: "warming up"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	waitForReceipts(b, &receipts, int64(rooms))
+	receipts.Store(0)
+	delivered.Store(0)
+
+	b.ResetTimer()
+	start := time.Now()
+
+	var senders sync.WaitGroup
+	for i, stream := range streams {
+		room := fmt.Sprintf("room%d", i%rooms)
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			for n := i; n < b.N; n += clients {
+				if err := stream.Send(&pb.ChatRoomMessage{Room: room, Content: "hello"}); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	senders.Wait()
+
+	waitForReceipts(b, &receipts, int64(b.N))
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "msgs/s")
+	b.ReportMetric(float64(delivered.Load())/elapsed.Seconds(), "deliveries/s")
+
+	for _, stream := range streams {
+		stream.CloseSend()
+	}
+	srv.Stop()
+	readers.Wait()
+}
+
+// waitForReceipts waits until n messages were acknowledged to their senders,
+// which is when a message counts as done.
+func waitForReceipts(b *testing.B, receipts *atomic.Int64, n int64) {
+	deadline := time.Now().Add(time.Minute)
+	for receipts.Load() < n {
+		if time.Now().After(deadline) {
+			b.Fatalf("only %d of %d messages were acknowledged", receipts.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// globalRooms is how rooms were locked before each had a lock of its own:
+// one map and one mutex for every room.
+type globalRooms struct {
+	mu    sync.Mutex
+	rooms map[string]*chatRoom
+}
+
+// BenchmarkRoomLocks compares the room table against globalRooms on what
+// the server does with a room locked: copying the member queues to deliver
+// a message to, and reading the history a join replays. Every goroutine
+// works on rooms of its own, so only the global lock makes them wait.
+//
+// Per-room locks don't win on one core, at any -cpu: the work is all CPU,
+// nothing blocks with a room locked, and the two come out within a few
+// percent of each other. They can only pay off with rooms being worked on in
+// parallel on several cores, which hasn't been measured.
+func BenchmarkRoomLocks(b *testing.B) {
+	const rooms, members, replay = 64, 50, 50
+
+	history, err := newHistoryStore(b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer history.Close()
+	table := newRoomTable()
+	global := &globalRooms{rooms: make(map[string]*chatRoom)}
+	names := make([]string, rooms)
+	for i := range names {
+		names[i] = fmt.Sprintf("room%d", i)
+		r := table.getOrCreate(names[i])
+		for m := 0; m < members; m++ {
+			user := fmt.Sprintf("user%d", m)
+			r.members[user] = newDeliveryQueue[*pb.ChatRoomMessage](user, deliveryConfig{policy: overflowDropOldest, queueSize: 1})
+		}
+		global.rooms[names[i]] = r
+		for m := 0; m < replay; m++ {
+			if _, err := history.Append(&pb.ChatRoomMessage{Room: names[i], Sender: "alice", Content: "hello"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for _, work := range []struct {
+		name string
+		fn   func(r *chatRoom)
+	}{
+		{"fanout", func(r *chatRoom) { r.memberQueues() }},
+		{"replay", func(r *chatRoom) { history.Last(r.name, replay) }},
+	} {
+		var next atomic.Int64
+		b.Run("work="+work.name+"/locks=room", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				name := names[next.Add(1)%rooms]
+				for pb.Next() {
+					r := table.get(name)
+					r.mu.Lock()
+					work.fn(r)
+					r.mu.Unlock()
+				}
+			})
+		})
+		b.Run("work="+work.name+"/locks=global", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				name := names[next.Add(1)%rooms]
+				for pb.Next() {
+					global.mu.Lock()
+					work.fn(global.rooms[name])
+					global.mu.Unlock()
+				}
+			})
+		})
+	}
+}
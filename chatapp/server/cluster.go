@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	clusterTokenHeader = "x-cluster-token"
+	deliverTimeout     = 5 * time.Second
+)
+
+// peerBroker is the Broker of a server running next to others. Each topic is
+// led by one of the nodes, picked by rendezvous hashing so every node agrees
+// on it without talking; the leader passes the topic's events to all nodes
+// one at a time, which is what keeps them in the same order everywhere.
+//
+// All nodes have to share token.key: it signs the session tokens clients
+// bring to any node and the token nodes show each other.
+type peerBroker struct {
+	pb.UnimplementedClusterServer
+	*dispatcher
+
+	self  string
+	nodes []string
+	token string
+	creds credentials.TransportCredentials // to dial the other nodes with, plain TCP if nil
+
+	mu      sync.Mutex
+	clients map[string]pb.ClusterClient
+	conns   []*grpc.ClientConn
+	leading map[string]*sync.Mutex // per topic, held while the leader hands out an event
+}
+
+// newPeerBroker makes a broker for the node reachable at self. peers are the
+// other nodes' addresses.
+func newPeerBroker(self string, peers []string, key []byte) *peerBroker {
+	nodes := append([]string{self}, peers...)
+	sort.Strings(nodes)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("cluster"))
+
+	return &peerBroker{
+		dispatcher: newDispatcher(),
+		self:       self,
+		nodes:      nodes,
+		token:      hex.EncodeToString(mac.Sum(nil)),
+		clients:    make(map[string]pb.ClusterClient),
+		leading:    make(map[string]*sync.Mutex),
+	}
+}
+
+func (b *peerBroker) Node() string {
+	return b.self
+}
+
+func (b *peerBroker) Subscribe(prefix string, handler func(event *pb.ClusterEvent)) func() {
+	return b.subscribe(prefix, handler)
+}
+
+func (b *peerBroker) Publish(ctx context.Context, topic string, event *pb.ClusterEvent) error {
+	event.Topic = topic
+	event.Origin = b.self
+
+	leader := b.leader(topic)
+	if leader == b.self {
+		b.order(event)
+		return nil
+	}
+	client, err := b.client(leader)
+	if err != nil {
+		return err
+	}
+	_, err = client.Order(b.outgoing(ctx), event)
+	return err
+}
+
+// leader picks the node with the highest hash of node and topic.
+func (b *peerBroker) leader(topic string) string {
+	var best string
+	var bestScore uint64
+	for _, node := range b.nodes {
+		h := fnv.New64a()
+		h.Write([]byte(node))
+		h.Write([]byte{0})
+		h.Write([]byte(topic))
+		if score := h.Sum64(); best == "" || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+// order hands event to every node, this one included. Nodes that can't be
+// reached miss it.
+func (b *peerBroker) order(event *pb.ClusterEvent) {
+	b.mu.Lock()
+	lock := b.leading[event.Topic]
+	if lock == nil {
+		lock = &sync.Mutex{}
+		b.leading[event.Topic] = lock
+	}
+	b.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// handlers change what they are given, the peers still need the original
+	b.dispatch(proto.Clone(event).(*pb.ClusterEvent))
+
+	var wg sync.WaitGroup
+	for _, node := range b.nodes {
+		if node == b.self {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.deliverTo(node, event); err != nil {
+				log.Printf("failed to deliver %s event to node %s: %v", event.Topic, node, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (b *peerBroker) deliverTo(node string, event *pb.ClusterEvent) error {
+	client, err := b.client(node)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deliverTimeout)
+	defer cancel()
+	_, err = client.Deliver(b.outgoing(ctx), event)
+	return err
+}
+
+func (b *peerBroker) client(node string) (pb.ClusterClient, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if client, ok := b.clients[node]; ok {
+		return client, nil
+	}
+	creds := b.creds
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(node, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	client := pb.NewClusterClient(conn)
+	b.clients[node] = client
+	b.conns = append(b.conns, conn)
+	return client, nil
+}
+
+func (b *peerBroker) outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, clusterTokenHeader, b.token)
+}
+
+func (b *peerBroker) checkPeer(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(clusterTokenHeader)
+	if len(values) == 0 || !hmac.Equal([]byte(values[0]), []byte(b.token)) {
+		return status.Error(codes.Unauthenticated, "not a member of this cluster")
+	}
+	return nil
+}
+
+// Order is called by the other nodes on the leader of event's topic.
+func (b *peerBroker) Order(ctx context.Context, event *pb.ClusterEvent) (*pb.Empty, error) {
+	if err := b.checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	if b.leader(event.Topic) != b.self {
+		return nil, status.Errorf(codes.FailedPrecondition, "%s doesn't lead %s", b.self, event.Topic)
+	}
+	b.order(event)
+	return &pb.Empty{}, nil
+}
+
+// Deliver is called by the leader of event's topic.
+func (b *peerBroker) Deliver(ctx context.Context, event *pb.ClusterEvent) (*pb.Empty, error) {
+	if err := b.checkPeer(ctx); err != nil {
+		return nil, err
+	}
+	b.dispatch(event)
+	return &pb.Empty{}, nil
+}
+
+func (b *peerBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+	b.clients = make(map[string]pb.ClusterClient)
+	return nil
+}
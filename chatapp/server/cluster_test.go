@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testNode struct {
+	cs     *chatServer
+	client pb.ChatClient
+}
+
+// newTestServer builds a chat server the way main does, on stores in a
+// temporary directory of its own.
+func newTestServer(tb testing.TB, key []byte, queueSize int, broker Broker) *chatServer {
+	tb.Helper()
+	dir := tb.TempDir()
+	history, err := newHistoryStore(filepath.Join(dir, "rooms"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { history.Close() })
+	conversations, err := newConversationStore(filepath.Join(dir, "conversations"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conversations.Close() })
+	users, err := newUserStore(filepath.Join(dir, "users.json"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	rooms, err := newRoomStore(filepath.Join(dir, "rooms.json"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	inbox, err := newInboxStore(filepath.Join(dir, "inbox.json"), defaultInboxLimit, defaultInboxTTL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	keys, err := newKeyDirectory(filepath.Join(dir, "keys.json"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	attachments, err := newAttachmentStore(filepath.Join(dir, "attachments"), defaultMaxAttachmentSize)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return NewChatServer(history, conversations, users, rooms, inbox, keys, attachments, newRateLimiter(limitsConfig{}), &tokenSigner{key: key}, deliveryConfig{
+		policy:    overflowDropOldest,
+		queueSize: queueSize,
+		spillDir:  filepath.Join(dir, "spill"),
+	}, broker)
+}
+
+// serveChat serves cs on lis until the test is over, behind the same auth
+// interceptors as in main, and the cluster service if it has peers.
+func serveChat(tb testing.TB, cs *chatServer, lis net.Listener) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(cs.UnaryAuthInterceptor),
+		grpc.StreamInterceptor(cs.StreamAuthInterceptor),
+	)
+	pb.RegisterChatServer(srv, cs)
+	if cluster, ok := cs.broker.(*peerBroker); ok {
+		pb.RegisterClusterServer(srv, cluster)
+	}
+	go srv.Serve(lis)
+	tb.Cleanup(srv.Stop)
+	return srv
+}
+
+// startCluster runs n chat servers on localhost sharing rooms through a
+// peerBroker, each with its own data directory and the same token key.
+func startCluster(t *testing.T, n int) []*testNode {
+	t.Helper()
+
+	key, err := loadSigningKey(filepath.Join(t.TempDir(), "token.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listeners := make([]net.Listener, n)
+	addrs := make([]string, n)
+	for i := range listeners {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i] = lis
+		addrs[i] = lis.Addr().String()
+	}
+
+	nodes := make([]*testNode, n)
+	for i, lis := range listeners {
+		peers := slices.Delete(slices.Clone(addrs), i, i+1)
+		broker := newPeerBroker(addrs[i], peers, key)
+		t.Cleanup(func() { broker.Close() })
+		cs := newTestServer(t, key, 256, broker)
+		serveChat(t, cs, lis)
+
+		conn, err := grpc.NewClient(addrs[i], grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		nodes[i] = &testNode{cs: cs, client: pb.NewChatClient(conn)}
+	}
+	return nodes
+}
+
+// as returns a context carrying a session token for user, without
+// registering them first.
+func (n *testNode) as(t *testing.T, user string) context.Context {
+	t.Helper()
+	token, _, err := n.cs.tokens.Issue(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// chatIn joins user to room and returns their RoomChat stream subscribed to
+// it, which drops when the test is over.
+func (n *testNode) chatIn(t *testing.T, user, room string) pb.Chat_RoomChatClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(n.as(t, user))
+	t.Cleanup(cancel)
+	if _, err := n.client.JoinRoom(ctx, &pb.JoinRequest{Room: room}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := n.client.RoomChat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: room}); err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+// eventually retries check until it passes or a few seconds have gone by.
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// recvMessage skips ahead to the next message of msgType in room. Private
+// messages have no room.
+func recvMessage(t *testing.T, stream pb.Chat_RoomChatClient, msgType, room string) *pb.ChatRoomMessage {
+	t.Helper()
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("waiting for a %s in %s: %v", msgType, room, err)
+		}
+		if msg.Type == msgType && msg.Room == room {
+			return msg
+		}
+	}
+}
+
+func TestClusterSharesRooms(t *testing.T) {
+	nodes := startCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	alice := nodes[0].as(t, "alice")
+	bob := nodes[1].as(t, "bob")
+	carol := nodes[2].as(t, "carol")
+
+	if _, err := nodes[0].client.JoinRoom(alice, &pb.JoinRequest{Room: "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the room to reach node 2", func() bool {
+		_, ok := nodes[1].cs.roomStore.Get("lobby")
+		return ok
+	})
+	if _, err := nodes[1].client.JoinRoom(bob, &pb.JoinRequest{Room: "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "bob to show up on node 1", func() bool {
+		info, err := nodes[0].client.GetRoomInfo(alice, &pb.RoomInfoRequest{Room: "lobby"})
+		return err == nil && slices.Contains(info.Members, "bob")
+	})
+
+	aliceStream, err := nodes[0].client.RoomChat(metadata.NewOutgoingContext(ctx, mdOf(alice)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobStream, err := nodes[1].client.RoomChat(metadata.NewOutgoingContext(ctx, mdOf(bob)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stream := range []pb.Chat_RoomChatClient{aliceStream, bobStream} {
+		if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: "lobby"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := aliceStream.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "hi bob", ClientId: "m1"}); err != nil {
+		t.Fatal(err)
+	}
+	receipt := recvMessage(t, aliceStream, msgTypeReceipt, "lobby")
+	if receipt.Receipt.GetStatus() != receiptSent {
+		t.Fatalf("alice got receipt %v", receipt.Receipt)
+	}
+	got := recvMessage(t, bobStream, msgTypeMessage, "lobby")
+	if got.Content != "hi bob" || got.Sender != "alice" || got.Seq != receipt.Seq {
+		t.Fatalf("bob got %q from %s with seq %d, alice's receipt says seq %d", got.Content, got.Sender, got.Seq, receipt.Seq)
+	}
+
+	history, err := nodes[1].client.GetRoomHistory(bob, &pb.HistoryRequest{Room: "lobby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Messages) != 1 || history.Messages[0].Seq != receipt.Seq {
+		t.Fatalf("node 2 has history %v", history.Messages)
+	}
+
+	// carol's node only knows alice is in a room from the presence events
+	if _, err := nodes[2].client.JoinRoom(carol, &pb.JoinRequest{Room: "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "alice to show up on node 3", func() bool {
+		return len(nodes[2].cs.directory.roomsOf("alice")) > 0
+	})
+	if _, err := nodes[2].client.SendPrivateMessage(carol, &pb.PrivateMessage{Recipient: "alice", Content: "psst"}); err != nil {
+		t.Fatal(err)
+	}
+	pm := recvMessage(t, aliceStream, msgTypePrivate, "")
+	if pm.Content != "psst" || pm.Sender != "carol" {
+		t.Fatalf("alice got private message %q from %s", pm.Content, pm.Sender)
+	}
+
+	// alice owns the room, the kick has to reach bob on node 2
+	if _, err := nodes[0].client.KickMember(alice, &pb.ModerationRequest{Room: "lobby", Target: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if end := recvMessage(t, bobStream, msgTypeUnsubscribed, "lobby"); codes.Code(end.Code) != codes.OK {
+		t.Fatalf("bob's subscription ended with %v: %s", codes.Code(end.Code), end.Content)
+	}
+	eventually(t, "bob to leave the room everywhere", func() bool {
+		for _, node := range nodes {
+			info, err := node.client.GetRoomInfo(alice, &pb.RoomInfoRequest{Room: "lobby"})
+			if err != nil || slices.Contains(info.Members, "bob") {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func mdOf(ctx context.Context) metadata.MD {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return md
+}
+
+// The room password only goes to the other nodes sealed, see sealRoom.
+func TestClusterSharesRoomPassword(t *testing.T) {
+	nodes := startCluster(t, 2)
+	alice := nodes[0].as(t, "alice")
+	bob := nodes[1].as(t, "bob")
+
+	if _, err := nodes[0].client.JoinRoom(alice, &pb.JoinRequest{Room: "vault", Visibility: visibilityPassword, Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the room to reach node 2", func() bool {
+		_, ok := nodes[1].cs.roomStore.Get("vault")
+		return ok
+	})
+	if _, err := nodes[1].client.JoinRoom(bob, &pb.JoinRequest{Room: "vault", Password: "wrong"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("bob got in with the wrong password: %v", err)
+	}
+	if _, err := nodes[1].client.JoinRoom(bob, &pb.JoinRequest{Room: "vault", Password: "hunter2"}); err != nil {
+		t.Fatalf("bob couldn't get in with the password: %v", err)
+	}
+}
+
+func TestClusterSharesAccounts(t *testing.T) {
+	nodes := startCluster(t, 2)
+
+	// both nodes at once, the leader of the name's topic picks one
+	errs := make(chan error, 2)
+	for i, password := range []string{"first password", "second password"} {
+		go func() {
+			_, err := nodes[i].client.Register(context.Background(), &pb.Credentials{Username: "alice", Password: password})
+			errs <- err
+		}()
+	}
+	var won int
+	for range 2 {
+		switch err := <-errs; status.Code(err) {
+		case codes.OK:
+			won++
+		case codes.AlreadyExists:
+		default:
+			t.Fatalf("registering alice: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("alice was registered %d times", won)
+	}
+	for i, node := range nodes {
+		_, first := node.client.Login(context.Background(), &pb.Credentials{Username: "alice", Password: "first password"})
+		_, second := node.client.Login(context.Background(), &pb.Credentials{Username: "alice", Password: "second password"})
+		if (first == nil) == (second == nil) {
+			t.Fatalf("node %d: logging in with the first password: %v, with the second: %v", i+1, first, second)
+		}
+	}
+
+	// one after the other, node 2 gets the claim from node 1 ahead of its own
+	if _, err := nodes[0].client.Register(context.Background(), &pb.Credentials{Username: "bob", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodes[1].client.Register(context.Background(), &pb.Credentials{Username: "bob", Password: "battery staple"}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("bob registered again on node 2: %v", err)
+	}
+	if _, err := nodes[1].client.Login(context.Background(), &pb.Credentials{Username: "bob", Password: "correct horse"}); err != nil {
+		t.Fatalf("bob couldn't log in on node 2: %v", err)
+	}
+
+	// bob is offline and registered on node 1, a message from node 2 waits in their inbox
+	carol := nodes[1].as(t, "carol")
+	resp, err := nodes[1].client.SendPrivateMessage(carol, &pb.PrivateMessage{Recipient: "bob", Content: "hi"})
+	if err != nil {
+		t.Fatalf("carol couldn't message bob across nodes: %v", err)
+	}
+	if !strings.HasPrefix(resp.Status, "Message queued") {
+		t.Fatalf("the message to offline bob: %s", resp.Status)
+	}
+	if _, err := nodes[1].client.SendPrivateMessage(carol, &pb.PrivateMessage{Recipient: "nobody", Content: "hi"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("messaging someone without an account: %v", err)
+	}
+}
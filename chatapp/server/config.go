@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const envPrefix = "CHATAPP_"
+
+// serverConfig is how the server is set up. Every setting has a flag, an
+// environment variable named after it (-queue-size is CHATAPP_QUEUE_SIZE) and
+// a key in the -config file; the flag wins over the variable, which wins over
+// the file.
+type serverConfig struct {
+	Listen  string `json:"listen"`
+	HTTP    string `json:"http"`
+	Data    string `json:"data"`
+	Metrics string `json:"metrics"`
+
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+	TLSCA   string `json:"tls_ca"`
+
+	QueueSize int    `json:"queue_size"`
+	Overflow  string `json:"overflow"`
+	MaxSpill  int64  `json:"max_spill"`
+
+	Node  string `json:"node"`
+	Peers string `json:"peers"`
+
+	AwayAfter         duration `json:"away_after"`
+	LastSeenFor       duration `json:"last_seen_for"`
+	InboxLimit        int      `json:"inbox_limit"`
+	InboxTTL          duration `json:"inbox_ttl"`
+	Attachments       string   `json:"attachments"`
+	MaxAttachmentSize int64    `json:"max_attachment_size"`
+	Limits            string   `json:"limits"`
+	Gateways          string   `json:"gateways"`
+
+	Reflection      bool     `json:"reflection"`
+	ShutdownTimeout duration `json:"shutdown_timeout"`
+}
+
+func defaultConfig() serverConfig {
+	return serverConfig{
+		Listen:            ":50051",
+		Data:              "chatdata",
+		QueueSize:         256,
+		Overflow:          string(overflowDropOldest),
+		MaxSpill:          64 << 20,
+		AwayAfter:         duration(defaultAwayAfter),
+		LastSeenFor:       duration(defaultLastSeenFor),
+		InboxLimit:        defaultInboxLimit,
+		InboxTTL:          duration(defaultInboxTTL),
+		MaxAttachmentSize: defaultMaxAttachmentSize,
+		Reflection:        true,
+		ShutdownTimeout:   duration(10 * time.Second),
+	}
+}
+
+func (c *serverConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.Listen, "listen", c.Listen, "address to serve gRPC on")
+	fs.StringVar(&c.HTTP, "http", c.HTTP, "address to serve the bot API on, e.g. :8081, off if empty")
+	fs.StringVar(&c.Data, "data", c.Data, "directory for persisted rooms, users and keys")
+	fs.StringVar(&c.Metrics, "metrics", c.Metrics, "address to serve /debug/vars on, e.g. :9090")
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "PEM certificate to serve TLS with, plain TCP if empty")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "PEM private key of -tls-cert")
+	fs.StringVar(&c.TLSCA, "tls-ca", c.TLSCA, "PEM CA certificate to verify the other -peers with (the system's if empty)")
+	fs.IntVar(&c.QueueSize, "queue-size", c.QueueSize, "messages buffered per subscriber before -overflow kicks in")
+	fs.StringVar(&c.Overflow, "overflow", c.Overflow, "what to do with a subscriber that falls behind: drop-oldest, disconnect or spill")
+	fs.Int64Var(&c.MaxSpill, "max-spill", c.MaxSpill, "bytes a subscriber may spill to disk before it is disconnected")
+	fs.StringVar(&c.Node, "node", c.Node, "address the other -peers reach this server at, e.g. host1:50051")
+	fs.StringVar(&c.Peers, "peers", c.Peers, "comma-separated addresses of the other servers sharing rooms with this one")
+	fs.Var(&c.AwayAfter, "away-after", "how long a connected user can be idle before they show as away")
+	fs.Var(&c.LastSeenFor, "last-seen-for", "how long to remember when a user who went offline was last seen")
+	fs.IntVar(&c.InboxLimit, "inbox-limit", c.InboxLimit, "private messages kept for a user while they are offline")
+	fs.Var(&c.InboxTTL, "inbox-ttl", "how long private messages wait for an offline user before they are dropped")
+	fs.StringVar(&c.Attachments, "attachments", c.Attachments, "directory for uploaded attachments, shared storage if the servers share rooms (default <data>/attachments)")
+	fs.Int64Var(&c.MaxAttachmentSize, "max-attachment-size", c.MaxAttachmentSize, "largest attachment in bytes")
+	fs.StringVar(&c.Limits, "limits", c.Limits, "JSON file with rate limits, message length and duplicate settings (built-in defaults if empty)")
+	fs.StringVar(&c.Gateways, "gateways", c.Gateways, "comma-separated IP addresses of web gateways, logins through them are rate limited by the address they forward")
+	fs.BoolVar(&c.Reflection, "reflection", c.Reflection, "serve gRPC reflection so tools like grpcurl can list the services")
+	fs.Var(&c.ShutdownTimeout, "shutdown-timeout", "how long to wait for calls to finish on SIGINT or SIGTERM before cutting them off")
+}
+
+// envName is the environment variable for a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig reads the settings from args, the environment and the file
+// named by -config or CHATAPP_CONFIG.
+func loadConfig(name string, args []string) (serverConfig, error) {
+	config := defaultConfig()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "JSON file with any of these settings, keyed like config.example.json")
+	config.register(fs)
+	fs.Parse(args)
+
+	// the flags were parsed into config, set them again on top of the rest
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+	config = defaultConfig()
+
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			return config, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&config); err != nil {
+			return config, fmt.Errorf("parsing %s: %w", *configFile, err)
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || err != nil {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("%s: %w", envName(f.Name), setErr)
+			}
+		}
+	})
+	if err != nil {
+		return config, err
+	}
+	for name, v := range given {
+		fs.Lookup(name).Value.Set(v)
+	}
+	return config, config.validate()
+}
+
+func (c *serverConfig) validate() error {
+	if c.QueueSize < 1 {
+		return errors.New("queue-size must be at least 1")
+	}
+	if _, err := parseOverflowPolicy(c.Overflow); err != nil {
+		return err
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls-cert and tls-key go together")
+	}
+	if c.Peers != "" && c.Node == "" {
+		return errors.New("node is needed with peers")
+	}
+	return nil
+}
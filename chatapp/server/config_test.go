@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `{
+		"queue_size": 10,
+		"overflow": "spill",
+		"inbox_limit": 7,
+		"away_after": "2m",
+		"reflection": false
+	}`)
+	t.Setenv("CHATAPP_CONFIG", path)
+	t.Setenv("CHATAPP_QUEUE_SIZE", "20")
+	t.Setenv("CHATAPP_INBOX_LIMIT", "8")
+	t.Setenv("CHATAPP_OVERFLOW", "disconnect")
+
+	// a flag set to the default still wins over the variable
+	config, err := loadConfig("chatserver", []string{"-queue-size", "30", "-overflow", "drop-oldest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for what, ok := range map[string]bool{
+		"queue-size from the flag":      config.QueueSize == 30,
+		"overflow from the flag":        config.Overflow == string(overflowDropOldest),
+		"inbox-limit from the env":      config.InboxLimit == 8,
+		"away-after from the file":      time.Duration(config.AwayAfter) == 2*time.Minute,
+		"reflection from the file":      !config.Reflection,
+		"listen left at its default":    config.Listen == ":50051",
+		"max-spill left at its default": config.MaxSpill == 64<<20,
+	} {
+		if !ok {
+			t.Errorf("%s: got %+v", what, config)
+		}
+	}
+
+	// -config wins over CHATAPP_CONFIG like any other flag
+	other := writeConfig(t, `{"data": "elsewhere"}`)
+	config, err = loadConfig("chatserver", []string{"-config", other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Data != "elsewhere" || config.AwayAfter != duration(defaultAwayAfter) || config.QueueSize != 20 {
+		t.Errorf("with -config: got %+v", config)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	t.Setenv("CHATAPP_CONFIG", "")
+	for what, test := range map[string]struct {
+		file, env, want string
+	}{
+		"unknown key":       {file: `{"queue": 10}`, want: `unknown field "queue"`},
+		"bad variable":      {env: "lots", want: "CHATAPP_QUEUE_SIZE"},
+		"invalid after all": {env: "0", want: "queue-size must be at least 1"},
+		"bad value in file": {file: `{"away_after": "soon"}`, want: "parsing"},
+	} {
+		var args []string
+		if test.file != "" {
+			args = []string{"-config", writeConfig(t, test.file)}
+		}
+		if test.env != "" {
+			t.Setenv("CHATAPP_QUEUE_SIZE", test.env)
+		} else {
+			os.Unsetenv("CHATAPP_QUEUE_SIZE")
+		}
+		if _, err := loadConfig("chatserver", args); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error about %s", what, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultInboxLimit = 100
+	defaultInboxTTL   = 7 * 24 * time.Hour
+)
+
+// conversationKey names the conversation between two users the same way
+// whichever of them asks. Names are escaped so the "/" between them is
+// unambiguous.
+func conversationKey(a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return url.PathEscape(a) + "/" + url.PathEscape(b)
+}
+
+func conversationUsers(key string) (string, string, bool) {
+	a, b, ok := strings.Cut(key, "/")
+	if !ok {
+		return "", "", false
+	}
+	a, errA := url.PathUnescape(a)
+	b, errB := url.PathUnescape(b)
+	return a, b, errA == nil && errB == nil
+}
+
+// conversationStore keeps the private messages between each pair of users in
+// the same kind of segmented log the rooms use, one per conversation.
+type conversationStore struct {
+	logs *historyStore
+
+	mu     sync.Mutex
+	byUser map[string]map[string]bool // user -> keys of their conversations
+}
+
+func newConversationStore(dir string) (*conversationStore, error) {
+	logs, err := newHistoryStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := logs.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conversationStore{logs: logs, byUser: make(map[string]map[string]bool)}
+	for _, key := range keys {
+		c.index(key)
+	}
+	return c, nil
+}
+
+// index must be called with c.mu held, or before c is shared.
+func (c *conversationStore) index(key string) {
+	a, b, ok := conversationUsers(key)
+	if !ok {
+		return
+	}
+	for _, user := range []string{a, b} {
+		if c.byUser[user] == nil {
+			c.byUser[user] = make(map[string]bool)
+		}
+		c.byUser[user][key] = true
+	}
+}
+
+// Append assigns msg the next seq of its conversation and the server
+// timestamp, and stores it.
+func (c *conversationStore) Append(msg *pb.PrivateMessage) error {
+	key := conversationKey(msg.Sender, msg.Recipient)
+	rec := &pb.ChatRoomMessage{Type: msgTypePrivate, Room: key, Sender: msg.Sender, Private: msg}
+	if _, err := c.logs.Append(rec); err != nil {
+		return err
+	}
+	msg.Seq = rec.Seq
+	msg.Timestamp = rec.Timestamp
+
+	c.mu.Lock()
+	c.index(key)
+	c.mu.Unlock()
+	return nil
+}
+
+// Get returns the message with seq in the conversation between a and b.
+func (c *conversationStore) Get(a, b string, seq uint64) (*pb.PrivateMessage, error) {
+	recs, _, err := c.logs.Since(conversationKey(a, b), seq-1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 || recs[0].Seq != seq {
+		return nil, fmt.Errorf("no message %d between %s and %s", seq, a, b)
+	}
+	return privateOf(recs[0]), nil
+}
+
+// Last and Since work like the historyStore ones.
+func (c *conversationStore) Last(a, b string, n int) ([]*pb.PrivateMessage, uint64, error) {
+	recs, cursor, err := c.logs.Last(conversationKey(a, b), n)
+	return privatesOf(recs), cursor, err
+}
+
+func (c *conversationStore) Since(a, b string, cursor uint64, limit int) ([]*pb.PrivateMessage, uint64, error) {
+	recs, cursor, err := c.logs.Since(conversationKey(a, b), cursor, limit)
+	return privatesOf(recs), cursor, err
+}
+
+// Has reports whether a and b have a conversation.
+func (c *conversationStore) Has(a, b string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byUser[a][conversationKey(a, b)]
+}
+
+// With returns who user has private messages with.
+func (c *conversationStore) With(user string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var others []string
+	for key := range c.byUser[user] {
+		a, b, _ := conversationUsers(key)
+		if a == user {
+			others = append(others, b)
+		} else {
+			others = append(others, a)
+		}
+	}
+	return others
+}
+
+func (c *conversationStore) Close() error {
+	return c.logs.Close()
+}
+
+func privateOf(rec *pb.ChatRoomMessage) *pb.PrivateMessage {
+	msg := rec.Private
+	if msg == nil {
+		msg = &pb.PrivateMessage{Sender: rec.Sender, Content: rec.Content}
+	}
+	msg.Seq = rec.Seq
+	msg.Timestamp = rec.Timestamp
+	return msg
+}
+
+func privatesOf(recs []*pb.ChatRoomMessage) []*pb.PrivateMessage {
+	msgs := make([]*pb.PrivateMessage, 0, len(recs))
+	for _, rec := range recs {
+		msgs = append(msgs, privateOf(rec))
+	}
+	return msgs
+}
+
+// inboxEntry points at a private message its recipient hasn't got yet.
+type inboxEntry struct {
+	Sender string    `json:"sender"`
+	Seq    uint64    `json:"seq"`
+	Queued time.Time `json:"queued"`
+}
+
+var errInboxFull = status.Error(codes.ResourceExhausted, "their inbox is full, try again once they have been online")
+
+// inboxStore remembers the private messages sent to users while they were
+// offline, in a JSON file next to users.json. The messages themselves stay
+// in the conversationStore. Entries older than ttl are dropped unread.
+type inboxStore struct {
+	limit int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	path    string
+	inboxes map[string][]inboxEntry
+}
+
+func newInboxStore(path string, limit int, ttl time.Duration) (*inboxStore, error) {
+	store := &inboxStore{
+		limit:   limit,
+		ttl:     ttl,
+		path:    path,
+		inboxes: make(map[string][]inboxEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.inboxes); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Full reports whether user's inbox has no room for another message.
+func (b *inboxStore) Full(user string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pruneLocked(user)
+	return len(b.inboxes[user]) >= b.limit
+}
+
+// Hold keeps entry for user unless deliver, called under the inbox lock,
+// hands the message over right away. Take waits for the same lock, so a
+// message is either delivered or waiting in the inbox when the user
+// connects.
+func (b *inboxStore) Hold(user string, entry inboxEntry, deliver func() bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if deliver() {
+		return nil
+	}
+	// the sender's node checked the limit; whatever got past it is kept
+	b.pruneLocked(user)
+	b.inboxes[user] = append(b.inboxes[user], entry)
+	return b.save()
+}
+
+// Take empties user's inbox and returns what was in it, oldest first.
+func (b *inboxStore) Take(user string) ([]inboxEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pruneLocked(user)
+	entries := b.inboxes[user]
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	delete(b.inboxes, user)
+	return entries, b.save()
+}
+
+// Drop removes what sender sent user up to seq, after another node
+// delivered it.
+func (b *inboxStore) Drop(user, sender string, seq uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := b.inboxes[user][:0]
+	for _, entry := range b.inboxes[user] {
+		if entry.Sender != sender || entry.Seq > seq {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(b.inboxes[user]) {
+		return nil
+	}
+	if len(kept) == 0 {
+		delete(b.inboxes, user)
+	} else {
+		b.inboxes[user] = kept
+	}
+	return b.save()
+}
+
+// pruneLocked drops user's entries that waited longer than the ttl.
+func (b *inboxStore) pruneLocked(user string) {
+	cutoff := time.Now().Add(-b.ttl)
+	entries := b.inboxes[user]
+	for len(entries) > 0 && entries[0].Queued.Before(cutoff) {
+		entries = entries[1:]
+	}
+	if len(entries) == 0 {
+		delete(b.inboxes, user)
+	} else {
+		b.inboxes[user] = entries
+	}
+}
+
+// save must be called with b.mu held.
+func (b *inboxStore) save() error {
+	for user := range b.inboxes {
+		b.pruneLocked(user)
+	}
+	data, err := json.MarshalIndent(b.inboxes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.path, data, 0600)
+}
+
+// onConversationEvent is subscribed to every conversation topic. Every node
+// stores the message, so they all hand out the same seqs; the nodes the
+// recipient is on pass it on.
+func (s *chatServer) onConversationEvent(event *pb.ClusterEvent) {
+	msg := event.Private
+	if msg == nil {
+		return
+	}
+
+	if event.InboxDelivered {
+		if event.Origin == s.broker.Node() {
+			return
+		}
+		// sender and recipient are the other way round for the ack
+		if err := s.inbox.Drop(msg.Recipient, msg.Sender, msg.Seq); err != nil {
+			log.Printf("failed to update the inbox of %s: %v", msg.Recipient, err)
+		}
+		return
+	}
+
+	if err := s.conversations.Append(msg); err != nil {
+		log.Printf("failed to store private message from %s to %s: %v", msg.Sender, msg.Recipient, err)
+		return
+	}
+
+	push := func() bool {
+		q, ok := s.directory.privateQueue(msg.Recipient)
+		return ok && q.Push(msg)
+	}
+	if !event.Queued {
+		push()
+		return
+	}
+
+	delivered := false
+	err := s.inbox.Hold(msg.Recipient, inboxEntry{Sender: msg.Sender, Seq: msg.Seq, Queued: time.Now()}, func() bool {
+		delivered = push()
+		return delivered
+	})
+	if err != nil {
+		log.Printf("failed to queue private message for %s: %v", msg.Recipient, err)
+	}
+	if delivered {
+		// they came online meanwhile, the other nodes can forget it
+		s.shareInboxDelivered(msg.Recipient, msg.Sender, msg.Seq)
+	}
+}
+
+// deliverInbox passes what user got while offline to their private queue.
+func (s *chatServer) deliverInbox(user string, q *privateQueue) {
+	entries, err := s.inbox.Take(user)
+	if err != nil {
+		log.Printf("failed to update the inbox of %s: %v", user, err)
+	}
+
+	upTo := make(map[string]uint64)
+	for _, entry := range entries {
+		msg, err := s.conversations.Get(entry.Sender, user, entry.Seq)
+		if err != nil {
+			log.Printf("dropping private message for %s from the inbox: %v", user, err)
+			continue
+		}
+		q.Push(msg)
+		upTo[entry.Sender] = max(upTo[entry.Sender], entry.Seq)
+	}
+	for sender, seq := range upTo {
+		s.shareInboxDelivered(user, sender, seq)
+	}
+}
+
+func (s *chatServer) shareInboxDelivered(user, sender string, seq uint64) {
+	s.publish(conversationTopic(user, sender), &pb.ClusterEvent{
+		InboxDelivered: true,
+		Private:        &pb.PrivateMessage{Sender: sender, Recipient: user, Seq: seq},
+	})
+}
+
+func (s *chatServer) ListConversations(ctx context.Context, _ *pb.Empty) (*pb.ConversationList, error) {
+	user := userFromContext(ctx)
+
+	var list []*pb.ConversationSummary
+	for _, other := range s.conversations.With(user) {
+		last, _, err := s.conversations.Last(user, other, 1)
+		if err != nil {
+			log.Printf("failed to read conversation of %s and %s: %v", user, other, err)
+			continue
+		}
+		if len(last) > 0 {
+			list = append(list, &pb.ConversationSummary{With: other, Last: last[0]})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Last.GetTimestamp() > list[j].Last.GetTimestamp()
+	})
+	return &pb.ConversationList{Conversations: list}, nil
+}
+
+func (s *chatServer) GetConversation(ctx context.Context, req *pb.ConversationRequest) (*pb.ConversationResponse, error) {
+	if req.With == "" {
+		return nil, status.Error(codes.InvalidArgument, "with is required")
+	}
+	user := userFromContext(ctx)
+	if !s.conversations.Has(user, req.With) {
+		return &pb.ConversationResponse{}, nil
+	}
+
+	var (
+		msgs   []*pb.PrivateMessage
+		cursor uint64
+		err    error
+	)
+	if req.Since > 0 {
+		limit := 0
+		if req.Limit > 0 {
+			limit = clampHistory(req.Limit)
+		}
+		msgs, cursor, err = s.conversations.Since(user, req.With, req.Since, limit)
+	} else {
+		msgs, cursor, err = s.conversations.Last(user, req.With, clampHistory(req.Limit))
+	}
+	if err != nil {
+		log.Printf("failed to read conversation of %s and %s: %v", user, req.With, err)
+		return nil, errors.New("couldn't read the conversation")
+	}
+
+	return &pb.ConversationResponse{
+		Messages: msgs,
+		Cursor:   cursor,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOfflineInbox(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	node.cs.inbox.limit = 2
+	if _, err := node.client.Register(context.Background(), &pb.Credentials{Username: "bob", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	alice := node.as(t, "alice")
+	send := func(text string) (*pb.MessageResponse, error) {
+		return node.client.SendPrivateMessage(alice, &pb.PrivateMessage{Recipient: "bob", Content: text})
+	}
+
+	for _, text := range []string{"one", "two"} {
+		resp, err := send(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(resp.Status, "Message queued") {
+			t.Fatalf("sending %q to bob while they are offline: %s", text, resp.Status)
+		}
+	}
+	if _, err := send("three"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("sending past the inbox limit: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(node.as(t, "bob"))
+	defer cancel()
+	bob, err := node.client.RoomChat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range []string{"one", "two"} {
+		msg := recvMessage(t, bob, msgTypePrivate, "").Private
+		if msg.Content != text || msg.Sender != "alice" || msg.Seq != uint64(i+1) {
+			t.Fatalf("bob's inbox had %v, want %q", msg, text)
+		}
+	}
+	eventually(t, "bob's inbox to be emptied", func() bool {
+		node.cs.inbox.mu.Lock()
+		defer node.cs.inbox.mu.Unlock()
+		return len(node.cs.inbox.inboxes["bob"]) == 0
+	})
+
+	// bob is online now, the rest goes straight to them
+	resp, err := send("three")
+	if err != nil || resp.Status != "Message sent" {
+		t.Fatalf("sending to bob while they are online: %v %v", resp, err)
+	}
+	if msg := recvMessage(t, bob, msgTypePrivate, "").Private; msg.Content != "three" || msg.Seq != 3 {
+		t.Fatalf("bob got %v", msg)
+	}
+
+	conversation, err := node.client.GetConversation(ctx, &pb.ConversationRequest{With: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversation.Messages) != 3 || conversation.Messages[2].Content != "three" {
+		t.Fatalf("bob's conversation with alice is %v", conversation.Messages)
+	}
+}
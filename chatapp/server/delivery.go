@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// overflowPolicy says what a delivery queue does once a subscriber has
+// fallen queueSize messages behind.
+type overflowPolicy string
+
+const (
+	// drop the oldest queued message to make room for the new one
+	overflowDropOldest overflowPolicy = "drop-oldest"
+	// close the queue, the subscriber reconnects and replays from history
+	overflowDisconnect overflowPolicy = "disconnect"
+	// keep going on disk until maxSpillBytes, then disconnect
+	overflowSpill overflowPolicy = "spill"
+)
+
+func parseOverflowPolicy(s string) (overflowPolicy, error) {
+	switch p := overflowPolicy(s); p {
+	case overflowDropOldest, overflowDisconnect, overflowSpill:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q, want drop-oldest, disconnect or spill", s)
+}
+
+type deliveryConfig struct {
+	policy        overflowPolicy
+	queueSize     int
+	spillDir      string
+	maxSpillBytes int64
+}
+
+// Counters for every queue in the server, served on /debug/vars when the
+// server is started with -metrics.
+var deliveryStats = expvar.NewMap("chat_delivery")
+
+const (
+	statDropped       = "dropped"
+	statDisconnected  = "disconnected"
+	statSpilled       = "spilled"
+	statSpillFailures = "spill_failures"
+)
+
+var errSubscriberTooSlow = status.Error(codes.ResourceExhausted, "you fell too far behind and were disconnected, reconnect to catch up")
+
+// deliveryQueue sits between the fan-out and one subscriber's stream. Push
+// never waits on the subscriber, so a slow client only ever costs its own
+// queue; what happens once it is full is up to the policy.
+type deliveryQueue[T proto.Message] struct {
+	name   string // for logs, e.g. "alice in room go"
+	config deliveryConfig
+
+	mu      sync.Mutex
+	items   []T
+	spill   *spillFile
+	closed  bool
+	err     error
+	dropped uint64
+
+	ready chan struct{} // has a value while there is something to pop
+	done  chan struct{} // closed by Close
+}
+
+func newDeliveryQueue[T proto.Message](name string, config deliveryConfig) *deliveryQueue[T] {
+	return &deliveryQueue[T]{
+		name:   name,
+		config: config,
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// Push queues msg and reports whether the subscriber will get it. It only
+// returns false once the queue is closed.
+func (q *deliveryQueue[T]) Push(msg T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	// once something is on disk everything newer goes there too, so the
+	// subscriber still gets messages in order
+	if len(q.items) < q.config.queueSize && q.spill == nil {
+		q.items = append(q.items, msg)
+		q.signal()
+		return true
+	}
+
+	switch q.config.policy {
+	case overflowDropOldest:
+		var zero T
+		q.items[0] = zero
+		q.items = append(q.items[1:], msg)
+		q.dropped++
+		deliveryStats.Add(statDropped, 1)
+		if q.dropped == 1 {
+			log.Printf("%s is falling behind, dropping the oldest messages", q.name)
+		}
+		return true
+	case overflowSpill:
+		if err := q.spillLocked(msg); err == nil {
+			q.signal()
+			return true
+		} else if !errors.Is(err, errSpillFull) {
+			log.Printf("failed to spill message for %s to disk: %v", q.name, err)
+			deliveryStats.Add(statSpillFailures, 1)
+		}
+	}
+
+	log.Printf("%s fell %d messages behind, disconnecting", q.name, q.config.queueSize)
+	deliveryStats.Add(statDisconnected, 1)
+	q.closeLocked(errSubscriberTooSlow)
+	return false
+}
+
+func (q *deliveryQueue[T]) spillLocked(msg T) error {
+	if q.spill == nil {
+		f, err := newSpillFile(q.config.spillDir, q.config.maxSpillBytes)
+		if err != nil {
+			return err
+		}
+		q.spill = f
+	}
+	if err := q.spill.write(msg); err != nil {
+		return err
+	}
+	deliveryStats.Add(statSpilled, 1)
+	return nil
+}
+
+// signal must be called with q.mu held.
+func (q *deliveryQueue[T]) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Pop returns the next message without waiting. Wait on Ready for more.
+func (q *deliveryQueue[T]) Pop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var zero T
+	if len(q.items) > 0 {
+		msg := q.items[0]
+		q.items[0] = zero
+		q.items = q.items[1:]
+		q.refillLocked()
+		return msg, true
+	}
+	return zero, false
+}
+
+// refillLocked moves spilled messages back into memory as room frees up.
+func (q *deliveryQueue[T]) refillLocked() {
+	if q.spill == nil {
+		return
+	}
+	var zero T
+	msgType := zero.ProtoReflect().Type()
+	for len(q.items) < q.config.queueSize {
+		msg, err := q.spill.read(msgType)
+		if err == io.EOF {
+			q.spill.remove()
+			q.spill = nil
+			break
+		}
+		if err != nil {
+			log.Printf("failed to read spilled messages for %s: %v", q.name, err)
+			deliveryStats.Add(statSpillFailures, 1)
+			q.closeLocked(errSubscriberTooSlow)
+			return
+		}
+		q.items = append(q.items, msg.Interface().(T))
+	}
+	if len(q.items) > 0 {
+		q.signal()
+	}
+}
+
+// Ready has a value whenever Pop may return something.
+func (q *deliveryQueue[T]) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// Done is closed once the queue is closed. Messages queued before that can
+// still be popped.
+func (q *deliveryQueue[T]) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *deliveryQueue[T]) Closed() bool {
+	select {
+	case <-q.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err is errSubscriberTooSlow if the queue was closed because it overflowed.
+func (q *deliveryQueue[T]) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// Close stops the queue taking new messages.
+func (q *deliveryQueue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeLocked(nil)
+}
+
+func (q *deliveryQueue[T]) closeLocked(err error) {
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.err = err
+	if err != nil {
+		// nothing after the gap is worth delivering
+		q.items = nil
+	}
+	if q.spill != nil {
+		q.spill.remove()
+		q.spill = nil
+	}
+	if q.dropped > 0 {
+		log.Printf("%s dropped %d messages in total", q.name, q.dropped)
+	}
+	close(q.done)
+}
+
+// drain sends everything queued on q so far.
+func drain[T proto.Message](q *deliveryQueue[T], send func(T) error) error {
+	for {
+		msg, ok := q.Pop()
+		if !ok {
+			return nil
+		}
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+var errSpillFull = errors.New("spill file is full")
+
+// spillFile holds the messages a deliveryQueue couldn't keep in memory,
+// each written as a uvarint length followed by the marshalled message.
+type spillFile struct {
+	f        *os.File
+	max      int64
+	writeOff int64
+	readOff  int64
+}
+
+func newSpillFile(dir string, max int64) (*spillFile, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, "queue-*.spill")
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{f: f, max: max}, nil
+}
+
+func (s *spillFile) write(msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	record := binary.AppendUvarint(nil, uint64(len(data)))
+	record = append(record, data...)
+	if s.max > 0 && s.writeOff-s.readOff+int64(len(record)) > s.max {
+		return errSpillFull
+	}
+	if _, err := s.f.WriteAt(record, s.writeOff); err != nil {
+		return err
+	}
+	s.writeOff += int64(len(record))
+	return nil
+}
+
+func (s *spillFile) read(msgType protoreflect.MessageType) (protoreflect.Message, error) {
+	if s.readOff >= s.writeOff {
+		return nil, io.EOF
+	}
+
+	var header [binary.MaxVarintLen64]byte
+	n, err := s.f.ReadAt(header[:], s.readOff)
+	if n == 0 && err != nil {
+		return nil, err
+	}
+	size, used := binary.Uvarint(header[:n])
+	if used <= 0 {
+		return nil, errors.New("corrupt spill record")
+	}
+
+	data := make([]byte, size)
+	if _, err := s.f.ReadAt(data, s.readOff+int64(used)); err != nil {
+		return nil, err
+	}
+	msg := msgType.New()
+	if err := proto.Unmarshal(data, msg.Interface()); err != nil {
+		return nil, err
+	}
+	s.readOff += int64(used) + int64(size)
+	return msg, nil
+}
+
+func (s *spillFile) remove() {
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+func pushN(q *roomQueue, from, n int) {
+	for i := from; i < from+n; i++ {
+		q.Push(&pb.ChatRoomMessage{Seq: uint64(i)})
+	}
+}
+
+// popSeqs pops everything queued and returns the seqs in order.
+func popSeqs(q *roomQueue) []uint64 {
+	var seqs []uint64
+	drain(q, func(msg *pb.ChatRoomMessage) error {
+		seqs = append(seqs, msg.Seq)
+		return nil
+	})
+	return seqs
+}
+
+func checkRun(t *testing.T, what string, seqs []uint64, first, n int) {
+	t.Helper()
+	if len(seqs) != n {
+		t.Fatalf("%s: got %d messages %v, want %d", what, len(seqs), seqs, n)
+	}
+	for i, seq := range seqs {
+		if seq != uint64(first+i) {
+			t.Fatalf("%s: got %v, want %d to %d", what, seqs, first, first+n-1)
+		}
+	}
+}
+
+func TestDeliveryDropOldest(t *testing.T) {
+	q := newDeliveryQueue[*pb.ChatRoomMessage]("alice in room test", deliveryConfig{policy: overflowDropOldest, queueSize: 5})
+	pushN(q, 1, 8)
+	if q.Closed() {
+		t.Fatal("drop-oldest closed the queue")
+	}
+	checkRun(t, "after overflowing", popSeqs(q), 4, 5)
+
+	// once caught up it holds queueSize again
+	pushN(q, 9, 5)
+	checkRun(t, "caught up", popSeqs(q), 9, 5)
+}
+
+func TestDeliveryDisconnect(t *testing.T) {
+	q := newDeliveryQueue[*pb.ChatRoomMessage]("alice in room test", deliveryConfig{policy: overflowDisconnect, queueSize: 5})
+	pushN(q, 1, 5)
+	if q.Push(&pb.ChatRoomMessage{Seq: 6}) || !q.Closed() {
+		t.Fatal("the queue took a message past queueSize")
+	}
+	if q.Err() != errSubscriberTooSlow {
+		t.Fatalf("closed with %v", q.Err())
+	}
+	// what is queued would leave a gap, the subscriber replays from history
+	if seqs := popSeqs(q); len(seqs) != 0 {
+		t.Fatalf("popped %v after disconnecting", seqs)
+	}
+	if q.Push(&pb.ChatRoomMessage{Seq: 7}) {
+		t.Fatal("a closed queue took a message")
+	}
+
+	// closed on purpose, what is queued still goes out
+	q = newDeliveryQueue[*pb.ChatRoomMessage]("alice in room test", deliveryConfig{policy: overflowDisconnect, queueSize: 5})
+	pushN(q, 1, 3)
+	q.Close()
+	if q.Err() != nil {
+		t.Fatalf("closed with %v", q.Err())
+	}
+	checkRun(t, "after Close", popSeqs(q), 1, 3)
+}
+
+func TestDeliverySpill(t *testing.T) {
+	dir := t.TempDir()
+	q := newDeliveryQueue[*pb.ChatRoomMessage]("alice in room test", deliveryConfig{policy: overflowSpill, queueSize: 4, spillDir: dir})
+	pushN(q, 1, 20)
+	if q.Closed() {
+		t.Fatal("spilling closed the queue")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("%d spill files, want 1", len(files))
+	}
+
+	// what comes in while some are still on disk goes after them, not
+	// into the room popping makes in memory
+	var seqs []uint64
+	for range 2 {
+		msg, _ := q.Pop()
+		seqs = append(seqs, msg.Seq)
+	}
+	pushN(q, 21, 3)
+	checkRun(t, "spilled", append(seqs, popSeqs(q)...), 1, 23)
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("%d spill files left once everything was read", len(files))
+	}
+}
+
+func TestDeliverySpillFull(t *testing.T) {
+	dir := t.TempDir()
+	// room for a few small messages on disk, not a hundred
+	q := newDeliveryQueue[*pb.ChatRoomMessage]("alice in room test", deliveryConfig{policy: overflowSpill, queueSize: 2, spillDir: dir, maxSpillBytes: 64})
+	for i := 1; i <= 100 && q.Push(&pb.ChatRoomMessage{Seq: uint64(i), Content: fmt.Sprint("message ", i)}); i++ {
+	}
+	if !q.Closed() || q.Err() != errSubscriberTooSlow {
+		t.Fatalf("a full spill file didn't disconnect: closed %v, %v", q.Closed(), q.Err())
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("%d spill files left after disconnecting", len(files))
+	}
+}
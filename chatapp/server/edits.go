@@ -0,0 +1,203 @@
+package main
+
+import (
+	"slices"
+	"unicode/utf8"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	msgTypeEdit     = "edit"
+	msgTypeDelete   = "delete"
+	msgTypeReaction = "reaction"
+
+	maxReactionLength = 32
+)
+
+var errMessageNotFound = status.Error(codes.NotFound, "there is no such message in the room")
+
+// logged reports whether messages of msgType go into the room's history and
+// get a seq.
+func logged(msgType string) bool {
+	switch msgType {
+	case msgTypeMessage, msgTypeEdit, msgTypeDelete, msgTypeReaction:
+		return true
+	}
+	return false
+}
+
+// normalize drops whatever a client set on msg that only the server may set,
+// or that doesn't go with its type.
+func normalize(msg *pb.ChatRoomMessage) {
+	msg.Deleted = false
+	msg.Edited = 0
+	msg.Reactions = nil
+	if msg.Type != msgTypeMessage {
+		msg.Attachment = nil
+		msg.ReplyTo = 0
+	}
+	if msg.Type != msgTypeMessage && msg.Type != msgTypeEdit {
+		msg.Content = ""
+	}
+	switch msg.Type {
+	case msgTypeMessage:
+		msg.Target = 0
+		fallthrough
+	case msgTypeEdit, msgTypeDelete:
+		msg.Reaction = ""
+		msg.Undo = false
+	}
+}
+
+// applyChange checks that msg may do what it does to the message it is about.
+// Edits and reactions are only logged, reading the message folds them in, see
+// foldChange. A delete also scrubs the text from the log. It runs in broker
+// order on every node, so they all come to the same answer.
+func (s *chatServer) applyChange(r *chatRoom, msg *pb.ChatRoomMessage) error {
+	switch msg.Type {
+	case msgTypeMessage:
+		if msg.ReplyTo == 0 {
+			return nil
+		}
+		parent, err := s.storedMessage(r.name, msg.ReplyTo)
+		if err != nil {
+			return err
+		}
+		if parent.Type != msgTypeMessage {
+			return status.Error(codes.InvalidArgument, "you can only reply to a message")
+		}
+		return nil
+	case msgTypeEdit:
+		if msg.Content == "" {
+			return status.Error(codes.InvalidArgument, "an edit needs the new text, delete the message instead")
+		}
+	case msgTypeReaction:
+		if msg.Reaction == "" || len(msg.Reaction) > maxReactionLength || !utf8.ValidString(msg.Reaction) {
+			return status.Error(codes.InvalidArgument, "that is not a reaction")
+		}
+	}
+
+	target, err := s.storedMessage(r.name, msg.Target)
+	if err != nil {
+		return err
+	}
+	switch {
+	case target.Type != msgTypeMessage:
+		return status.Error(codes.InvalidArgument, "only messages can be changed")
+	case target.Deleted:
+		return status.Error(codes.FailedPrecondition, "that message was deleted")
+	}
+	switch msg.Type {
+	case msgTypeEdit, msgTypeDelete:
+		info, _ := s.roomStore.Get(r.name)
+		if target.Sender != msg.Sender && !info.canModerate(msg.Sender) {
+			return status.Error(codes.PermissionDenied, "only the author or a moderator can change a message")
+		}
+	}
+	if msg.Type != msgTypeDelete {
+		return nil
+	}
+
+	// the text and every earlier version of it go from the log for good
+	err = s.history.Modify(r.name, msg.Target, func(target *pb.ChatRoomMessage) error {
+		foldChange(target, msg)
+		return nil
+	})
+	if err == errNoSuchMessage {
+		return errMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.scrubEdits(r.name, msg.Target)
+}
+
+// foldChange applies the logged edit, delete or reaction change to target.
+// An edit counts from when it was logged, which is the same on every read.
+func foldChange(target, change *pb.ChatRoomMessage) {
+	if target.Deleted {
+		return
+	}
+	switch change.Type {
+	case msgTypeEdit:
+		target.Content = change.Content
+		target.Edited = change.Timestamp
+	case msgTypeDelete:
+		target.Content = ""
+		target.Attachment = nil
+		target.Reactions = nil
+		target.Deleted = true
+	case msgTypeReaction:
+		target.Reactions = react(target.Reactions, change.Reaction, change.Sender, !change.Undo)
+	}
+}
+
+func (s *chatServer) storedMessage(room string, seq uint64) (*pb.ChatRoomMessage, error) {
+	msgs, _, err := s.history.Since(room, seq-1, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || msgs[0].Seq != seq {
+		return nil, errMessageNotFound
+	}
+	return msgs[0], nil
+}
+
+func (s *chatServer) scrubEdits(room string, target uint64) error {
+	msgs, _, err := s.history.Since(room, target, 0)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if msg.Type != msgTypeEdit || msg.Target != target {
+			continue
+		}
+		err := s.history.Modify(room, msg.Seq, func(edit *pb.ChatRoomMessage) error {
+			edit.Content = ""
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// react adds user to the users of emoji, or takes them out.
+func react(reactions []*pb.Reaction, emoji, user string, add bool) []*pb.Reaction {
+	i := slices.IndexFunc(reactions, func(r *pb.Reaction) bool { return r.Emoji == emoji })
+	if i < 0 {
+		if !add {
+			return reactions
+		}
+		return append(reactions, &pb.Reaction{Emoji: emoji, Users: []string{user}})
+	}
+
+	r := reactions[i]
+	has := slices.Contains(r.Users, user)
+	switch {
+	case add && !has:
+		r.Users = append(r.Users, user)
+	case !add && has:
+		r.Users = slices.DeleteFunc(r.Users, func(u string) bool { return u == user })
+		if len(r.Users) == 0 {
+			reactions = slices.Delete(reactions, i, i+1)
+		}
+	}
+	return reactions
+}
+
+// thread picks the message with seq parent and the replies to it out of msgs.
+func thread(msgs []*pb.ChatRoomMessage, parent uint64) []*pb.ChatRoomMessage {
+	var out []*pb.ChatRoomMessage
+	for _, msg := range msgs {
+		if msg.Type == msgTypeMessage && (msg.Seq == parent || msg.ReplyTo == parent) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
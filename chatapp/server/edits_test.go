@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+func TestChangingMessages(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	// alice owns the room and moderates it
+	alice := node.chatIn(t, "alice", "lobby")
+	bob := node.chatIn(t, "bob", "lobby")
+	carol := node.chatIn(t, "carol", "lobby")
+
+	// send puts msg into the lobby on who's stream and returns its receipt
+	send := func(who pb.Chat_RoomChatClient, msg *pb.ChatRoomMessage) *pb.Receipt {
+		t.Helper()
+		msg.Room = "lobby"
+		if err := who.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		return recvMessage(t, who, msgTypeReceipt, "lobby").Receipt
+	}
+	fails := func(what string, receipt *pb.Receipt, why string) {
+		t.Helper()
+		if receipt.Status != receiptFailed || !strings.Contains(receipt.Error, why) {
+			t.Fatalf("%s: got %v, want it to fail with %q", what, receipt, why)
+		}
+	}
+	stored := func() []*pb.ChatRoomMessage {
+		t.Helper()
+		history, err := node.client.GetRoomHistory(node.as(t, "alice"), &pb.HistoryRequest{Room: "lobby"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return history.Messages
+	}
+
+	target := send(bob, &pb.ChatRoomMessage{Content: "teh message"}).Seq
+
+	fails("carol editing bob's message", send(carol, &pb.ChatRoomMessage{Type: msgTypeEdit, Target: target, Content: "mine now"}), "only the author or a moderator")
+	fails("carol deleting bob's message", send(carol, &pb.ChatRoomMessage{Type: msgTypeDelete, Target: target}), "only the author or a moderator")
+	fails("an edit without text", send(bob, &pb.ChatRoomMessage{Type: msgTypeEdit, Target: target}), "needs the new text")
+	fails("editing a message that isn't there", send(bob, &pb.ChatRoomMessage{Type: msgTypeEdit, Target: target + 100, Content: "?"}), "no such message")
+	fails("a reaction that is too long", send(carol, &pb.ChatRoomMessage{Type: msgTypeReaction, Target: target, Reaction: strings.Repeat("x", maxReactionLength+1)}), "not a reaction")
+
+	edit := send(bob, &pb.ChatRoomMessage{Type: msgTypeEdit, Target: target, Content: "the message"})
+	if edit.Status != receiptSent {
+		t.Fatalf("bob editing their message: %v", edit)
+	}
+	if msg := recvMessage(t, alice, msgTypeEdit, "lobby"); msg.Target != target || msg.Content != "the message" {
+		t.Fatalf("alice was sent %v", msg)
+	}
+	fails("editing an edit", send(bob, &pb.ChatRoomMessage{Type: msgTypeEdit, Target: edit.Seq, Content: "again"}), "only messages can be changed")
+	fails("replying to an edit", send(carol, &pb.ChatRoomMessage{Content: "re", ReplyTo: edit.Seq}), "only reply to a message")
+	// anyone in the room can react
+	if receipt := send(carol, &pb.ChatRoomMessage{Type: msgTypeReaction, Target: target, Reaction: "+1"}); receipt.Status != receiptSent {
+		t.Fatalf("carol reacting: %v", receipt)
+	}
+
+	msgs := stored()
+	if msg := msgs[0]; msg.Content != "the message" || msg.Edited != msgs[1].Timestamp || len(msg.Reactions) != 1 || msg.Reactions[0].Users[0] != "carol" {
+		t.Fatalf("the stored message is %v", msg)
+	}
+
+	// a moderator can delete someone else's message, which takes the old text with it
+	if receipt := send(alice, &pb.ChatRoomMessage{Type: msgTypeDelete, Target: target}); receipt.Status != receiptSent {
+		t.Fatalf("alice deleting bob's message: %v", receipt)
+	}
+	fails("editing a deleted message", send(bob, &pb.ChatRoomMessage{Type: msgTypeEdit, Target: target, Content: "undo"}), "was deleted")
+	msgs = stored()
+	if msg := msgs[0]; !msg.Deleted || msg.Content != "" || len(msg.Reactions) != 0 {
+		t.Fatalf("the deleted message is %v", msg)
+	}
+	if msgs[1].Type != msgTypeEdit || msgs[1].Content != "" {
+		t.Fatalf("the edit still has the old text: %v", msgs[1])
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Chat messages and acks go through the broker before anyone sees them, so
+// every node appends them to its history in the same order and hands out the
+// same seqs. Everything else is applied on the node it happens on right away
+// and only replayed by the others.
+
+func (s *chatServer) publish(topic string, event *pb.ClusterEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deliverTimeout)
+	defer cancel()
+
+	err := s.broker.Publish(ctx, topic, event)
+	if err != nil {
+		log.Printf("failed to publish %s event: %v", topic, err)
+	}
+	return err
+}
+
+// queueEvent holds event for the room's topic until unlock, so a slow peer
+// doesn't keep everyone else out of the room meanwhile. Must be called with
+// r.mu held.
+func (r *chatRoom) queueEvent(event *pb.ClusterEvent) {
+	event.Room = r.name
+	r.outbox = append(r.outbox, event)
+}
+
+// unlock releases r.mu and then publishes what was queued while it was
+// held, ahead of anything queued after.
+func (s *chatServer) unlock(r *chatRoom) {
+	events := r.outbox
+	r.outbox = nil
+	if len(events) == 0 {
+		r.mu.Unlock()
+		return
+	}
+	r.publishing.Lock()
+	defer r.publishing.Unlock()
+	r.mu.Unlock()
+	for _, event := range events {
+		s.publish(roomTopic(r.name), event)
+	}
+}
+
+// onRoomEvent is subscribed to every room topic.
+func (s *chatServer) onRoomEvent(event *pb.ClusterEvent) {
+	r := s.rooms.getOrCreate(event.Room)
+	fromHere := event.Origin == s.broker.Node()
+
+	switch {
+	case event.Message != nil && event.Message.Type == msgTypeAck:
+		s.applyAck(r, event.Message.Sender, event.Message.Receipt)
+	case event.Message != nil:
+		s.deliverRoomMessage(r, fromHere, event.Message)
+	case fromHere:
+		// already applied when it happened
+	case event.RoomInfo != nil:
+		info, err := openRoom(s.tokens.key, event.Room, event.RoomInfo)
+		if err != nil {
+			log.Printf("ignoring settings for room %s from %s: %v", event.Room, event.Origin, err)
+			return
+		}
+		if err := s.roomStore.Put(event.Room, info); err != nil {
+			log.Printf("failed to save settings for room %s: %v", event.Room, err)
+		}
+	case event.Update != nil:
+		if event.Update.Type == updatePresence && event.Update.Presence != nil {
+			s.presence.setRemote(event.Update.Presence)
+		}
+		r.mu.Lock()
+		r.pushUpdateLocked(event.Update)
+		r.mu.Unlock()
+	case event.Presence != nil:
+		s.applyPresence(r, event.Origin, event.Presence)
+	}
+}
+
+// deliverRoomMessage appends msg to the history, which assigns its seq, and
+// queues it for the members on this node. The node the sender is on also
+// tells them which seq it got.
+func (s *chatServer) deliverRoomMessage(r *chatRoom, fromHere bool, msg *pb.ChatRoomMessage) {
+	r.fanout.Lock()
+	defer r.fanout.Unlock()
+
+	receipt := &pb.Receipt{Member: msg.Sender, Status: receiptSent}
+	if err := s.applyChange(r, msg); err != nil {
+		if _, ok := status.FromError(err); !ok {
+			log.Printf("failed to apply %s in room %s: %v", msg.Type, r.name, err)
+			err = status.Error(codes.Internal, "couldn't store the message")
+		}
+		receipt.Status = receiptFailed
+		receipt.Error = status.Convert(err).Message()
+	} else if _, err := s.history.Append(msg); err != nil {
+		log.Printf("failed to persist message in room %s: %v", r.name, err)
+		receipt.Status = receiptFailed
+		receipt.Error = "couldn't store the message"
+	}
+	receipt.Seq = msg.Seq
+
+	r.mu.Lock()
+	members := r.memberQueues()
+	r.mu.Unlock()
+
+	if q := members[msg.Sender]; q != nil && fromHere {
+		q.Push(&pb.ChatRoomMessage{
+			Type:     msgTypeReceipt,
+			Room:     r.name,
+			Seq:      msg.Seq,
+			ClientId: msg.ClientId,
+			Receipt:  receipt,
+		})
+	}
+	if receipt.Status == receiptFailed {
+		return
+	}
+
+	for user, q := range members {
+		if user != msg.Sender {
+			q.Push(msg)
+		}
+	}
+
+	// only the node the message came in on calls the webhooks, and never
+	// for a bot so that two can't keep answering each other
+	if fromHere && msg.Type == msgTypeMessage && !msg.Bot {
+		if info, ok := s.roomStore.Get(r.name); ok && len(info.Webhooks) > 0 {
+			s.webhooks.fire(r.name, info.Webhooks, msg)
+		}
+	}
+}
+
+// applyAck moves the member's delivered/read mark forward and relays it to
+// the rest of the room. Acks that don't move the mark are dropped.
+func (s *chatServer) applyAck(r *chatRoom, member string, ack *pb.Receipt) {
+	r.mu.Lock()
+	if !r.moveReceiptMark(member, ack) {
+		r.mu.Unlock()
+		return
+	}
+	members := r.memberQueues()
+	r.mu.Unlock()
+
+	relay := &pb.ChatRoomMessage{
+		Type:    msgTypeReceipt,
+		Room:    r.name,
+		Seq:     ack.Seq,
+		Receipt: &pb.Receipt{Member: member, Seq: ack.Seq, Status: ack.Status},
+	}
+	for user, q := range members {
+		if user != member {
+			q.Push(relay)
+		}
+	}
+}
+
+// applyPresence keeps track of who is in the room on other nodes. An
+// eviction also drops the member here if this is a node they are on, which is
+// how a kick or ban reaches them.
+func (s *chatServer) applyPresence(r *chatRoom, origin string, presence *pb.Presence) {
+	r.mu.Lock()
+	defer s.unlock(r)
+
+	if presence.Online {
+		r.remote[presence.User] = origin
+		s.directory.joinRemote(presence.User, r.name)
+		return
+	}
+
+	if node := r.remote[presence.User]; node != "" && (node == origin || presence.Evicted) {
+		delete(r.remote, presence.User)
+		s.directory.leaveRemote(presence.User, r.name)
+	}
+	if presence.Evicted && r.sessions[presence.User] != nil {
+		s.dropMember(r, presence.User)
+	}
+}
+
+// evict drops user from the room on every node they are on. Must be
+// called with r.mu held, and released with unlock.
+func (s *chatServer) evict(r *chatRoom, user string) {
+	if r.sessions[user] != nil {
+		s.dropMember(r, user)
+	}
+	if r.remote[user] == "" {
+		return
+	}
+	r.queueEvent(&pb.ClusterEvent{
+		Presence: &pb.Presence{User: user, Evicted: true},
+	})
+}
+
+// shareRoom sends the room's settings to the other nodes after a change.
+func (s *chatServer) shareRoom(room string, info roomInfo) {
+	data, err := sealRoom(s.tokens.key, room, info)
+	if err != nil {
+		log.Printf("failed to encode settings of room %s: %v", room, err)
+		return
+	}
+	s.publish(roomTopic(room), &pb.ClusterEvent{Room: room, RoomInfo: data})
+}
+
+var errNoSuchUser = status.Error(codes.NotFound, "couldn't send the message -- No such user")
+
+// sendPrivate hands msg to the broker. It is stored and passed on to the
+// recipient when it comes back, see onConversationEvent; if they aren't
+// online anywhere it waits in their inbox. It reports whether it was queued.
+func (s *chatServer) sendPrivate(msg *pb.PrivateMessage) (bool, error) {
+	_, here := s.directory.privateQueue(msg.Recipient)
+	queued := !here && len(s.directory.roomsOf(msg.Recipient)) == 0
+	if queued {
+		// every node has every account, and anyone online has logged in, so
+		// only offline recipients have to be checked
+		if !s.users.Exists(msg.Recipient) {
+			return false, errNoSuchUser
+		}
+		if s.inbox.Full(msg.Recipient) {
+			return false, errInboxFull
+		}
+	}
+
+	event := &pb.ClusterEvent{Private: msg, Queued: queued}
+	if err := s.publish(conversationTopic(msg.Sender, msg.Recipient), event); err != nil {
+		return false, status.Error(codes.Unavailable, "couldn't reach the recipient's server")
+	}
+	return queued, nil
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const segmentMaxEntries = 1000
+
+// One line in a segment file. The message is stored as protojson so new
+// ChatRoomMessage fields end up in the log without touching this format.
+type logRecord struct {
+	Seq     uint64          `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Target  uint64          `json:"target,omitempty"` // the message an edit, delete or reaction is about
+	Message json.RawMessage `json:"message"`
+}
+
+type segment struct {
+	base  uint64 // seq of the first record in the file
+	path  string
+	count int
+}
+
+// roomLog is an append-only log for a single room, split into segment files
+// named after the first sequence number they contain.
+type roomLog struct {
+	mu       sync.Mutex
+	dir      string
+	segments []*segment
+	active   *os.File
+	lastSeq  uint64
+
+	// the edits, deletes and reactions in the log by the seq of the message
+	// they are about, folded into it whenever it is read
+	changes map[uint64][]*pb.ChatRoomMessage
+}
+
+type historyStore struct {
+	mu   sync.Mutex
+	dir  string
+	logs map[string]*roomLog
+}
+
+func newHistoryStore(dir string) (*historyStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating history dir: %w", err)
+	}
+	return &historyStore{
+		dir:  dir,
+		logs: make(map[string]*roomLog),
+	}, nil
+}
+
+// roomDirName keeps room names from escaping the data dir.
+func roomDirName(room string) string {
+	return strings.ReplaceAll(url.PathEscape(room), ".", "%2E")
+}
+
+// Names returns the rooms that have a log on disk.
+func (h *historyStore) Names() ([]string, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if name, err := url.PathUnescape(e.Name()); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (h *historyStore) room(room string) (*roomLog, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if l, ok := h.logs[room]; ok {
+		return l, nil
+	}
+	l, err := openRoomLog(filepath.Join(h.dir, roomDirName(room)))
+	if err != nil {
+		return nil, err
+	}
+	h.logs[room] = l
+	return l, nil
+}
+
+func (h *historyStore) Append(msg *pb.ChatRoomMessage) (uint64, error) {
+	l, err := h.room(msg.Room)
+	if err != nil {
+		return 0, err
+	}
+	return l.Append(msg)
+}
+
+func (h *historyStore) Last(room string, n int) ([]*pb.ChatRoomMessage, uint64, error) {
+	l, err := h.room(room)
+	if err != nil {
+		return nil, 0, err
+	}
+	return l.Last(n)
+}
+
+func (h *historyStore) Since(room string, cursor uint64, limit int) ([]*pb.ChatRoomMessage, uint64, error) {
+	l, err := h.room(room)
+	if err != nil {
+		return nil, 0, err
+	}
+	return l.Since(cursor, limit)
+}
+
+func (h *historyStore) Modify(room string, seq uint64, fn func(msg *pb.ChatRoomMessage) error) error {
+	l, err := h.room(room)
+	if err != nil {
+		return err
+	}
+	return l.Modify(seq, fn)
+}
+
+func (h *historyStore) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var firstErr error
+	for room, l := range h.logs {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("closing log for room %s: %w", room, err)
+		}
+	}
+	return firstErr
+}
+
+func openRoomLog(dir string) (*roomLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating room log dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &roomLog{dir: dir, changes: make(map[uint64][]*pb.ChatRoomMessage)}
+	for _, e := range entries {
+		var base uint64
+		if !strings.HasSuffix(e.Name(), ".log") {
+			// like a rewrite that didn't finish, see Modify
+			continue
+		}
+		if _, err := fmt.Sscanf(e.Name(), "%020d.log", &base); err != nil {
+			continue
+		}
+		l.segments = append(l.segments, &segment{base: base, path: filepath.Join(dir, e.Name())})
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].base < l.segments[j].base })
+
+	for _, seg := range l.segments {
+		records, err := readSegment(seg.path)
+		if err != nil {
+			return nil, err
+		}
+		seg.count = len(records)
+		if len(records) > 0 {
+			l.lastSeq = records[len(records)-1].Seq
+		}
+		for i, rec := range records {
+			if rec.Target == 0 {
+				continue
+			}
+			msgs, _, err := decodeRecords(records[i:i+1], rec.Seq)
+			if err != nil {
+				return nil, err
+			}
+			l.addChange(rec.Target, msgs[0])
+		}
+	}
+
+	if len(l.segments) > 0 {
+		last := l.segments[len(l.segments)-1]
+		l.active, err = os.OpenFile(last.path, os.O_RDWR|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := terminateLastLine(l.active); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// terminateLastLine makes sure a half-written record left by a crash does not
+// swallow the next append.
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = file.Write([]byte{'\n'})
+	}
+	return err
+}
+
+func readSegment(path string) ([]logRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []logRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// a torn write at the end of the segment, everything before it is fine
+			log.Printf("skipping corrupt record in %s: %v", path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+func (l *roomLog) rollSegment() error {
+	if l.active != nil {
+		if err := l.active.Close(); err != nil {
+			return err
+		}
+	}
+	base := l.lastSeq + 1
+	path := filepath.Join(l.dir, fmt.Sprintf("%020d.log", base))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	l.active = file
+	l.segments = append(l.segments, &segment{base: base, path: path})
+	return nil
+}
+
+// Append assigns the next seq and the server timestamp to msg and writes it
+// to the active segment.
+func (l *roomLog) Append(msg *pb.ChatRoomMessage) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active == nil || l.segments[len(l.segments)-1].count >= segmentMaxEntries {
+		if err := l.rollSegment(); err != nil {
+			return 0, fmt.Errorf("rolling segment: %w", err)
+		}
+	}
+
+	rec := logRecord{
+		Seq:    l.lastSeq + 1,
+		Time:   time.Now().UTC(),
+		Target: msg.Target,
+	}
+	msg.Seq = rec.Seq
+	msg.Timestamp = rec.Time.UnixMilli()
+
+	raw, err := protojson.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	rec.Message = raw
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := l.active.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+
+	l.lastSeq = rec.Seq
+	l.segments[len(l.segments)-1].count++
+	if rec.Target != 0 {
+		l.addChange(rec.Target, proto.Clone(msg).(*pb.ChatRoomMessage))
+	}
+	return rec.Seq, nil
+}
+
+// addChange remembers that change, which was logged after everything already
+// in changes, is about the message with seq target. Once a message is
+// deleted nothing before the delete matters to it any more.
+func (l *roomLog) addChange(target uint64, change *pb.ChatRoomMessage) {
+	if change.Type == msgTypeDelete {
+		l.changes[target] = nil
+	}
+	l.changes[target] = append(l.changes[target], change)
+}
+
+// fold applies the changes logged so far to the messages they are about.
+func (l *roomLog) fold(msgs []*pb.ChatRoomMessage) {
+	for _, msg := range msgs {
+		for _, change := range l.changes[msg.Seq] {
+			foldChange(msg, change)
+		}
+	}
+}
+
+// Last returns the newest n messages in order, along with the seq of the
+// newest one so callers can continue with Since.
+func (l *roomLog) Last(n int) ([]*pb.ChatRoomMessage, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n <= 0 {
+		return nil, l.lastSeq, nil
+	}
+
+	var records []logRecord
+	for i := len(l.segments) - 1; i >= 0 && len(records) < n; i-- {
+		segRecords, err := readSegment(l.segments[i].path)
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(segRecords, records...)
+	}
+	if len(records) > n {
+		records = records[len(records)-n:]
+	}
+	msgs, cursor, err := decodeRecords(records, l.lastSeq)
+	l.fold(msgs)
+	return msgs, cursor, err
+}
+
+// Since returns messages after the cursor, oldest first. A limit of 0 means
+// everything up to the end of the log.
+func (l *roomLog) Since(cursor uint64, limit int) ([]*pb.ChatRoomMessage, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var records []logRecord
+	for i, seg := range l.segments {
+		if i+1 < len(l.segments) && l.segments[i+1].base <= cursor+1 {
+			continue
+		}
+		segRecords, err := readSegment(seg.path)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rec := range segRecords {
+			if rec.Seq > cursor {
+				records = append(records, rec)
+			}
+		}
+		if limit > 0 && len(records) >= limit {
+			records = records[:limit]
+			break
+		}
+	}
+
+	next := cursor
+	if len(records) > 0 {
+		next = records[len(records)-1].Seq
+	}
+	msgs, _, err := decodeRecords(records, next)
+	l.fold(msgs)
+	return msgs, next, err
+}
+
+var errNoSuchMessage = errors.New("no such message")
+
+// Modify rewrites the stored message with seq to what fn makes of it. The
+// segment holding it is written out again, so this is meant for the odd
+// delete, not for every message. fn gets the message as it was logged, without
+// the changes folded in. If fn fails nothing changes.
+func (l *roomLog) Modify(seq uint64, fn func(msg *pb.ChatRoomMessage) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := sort.Search(len(l.segments), func(i int) bool { return l.segments[i].base > seq }) - 1
+	if seq == 0 || seq > l.lastSeq || i < 0 {
+		return errNoSuchMessage
+	}
+	seg := l.segments[i]
+	records, err := readSegment(seg.path)
+	if err != nil {
+		return err
+	}
+	j := sort.Search(len(records), func(j int) bool { return records[j].Seq >= seq })
+	if j == len(records) || records[j].Seq != seq {
+		return errNoSuchMessage
+	}
+
+	msgs, _, err := decodeRecords(records[j:j+1], seq)
+	if err != nil {
+		return err
+	}
+	if err := fn(msgs[0]); err != nil {
+		return err
+	}
+	if records[j].Message, err = protojson.Marshal(msgs[0]); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	last := i == len(l.segments)-1
+	if last && l.active != nil {
+		// the active file is replaced below, append to the new one from now on
+		if err := l.active.Close(); err != nil {
+			return err
+		}
+		l.active = nil
+	}
+	if err := writeFileAtomic(seg.path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	seg.count = len(records)
+	if last {
+		l.active, err = os.OpenFile(seg.path, os.O_RDWR|os.O_APPEND, 0644)
+	}
+	return err
+}
+
+func decodeRecords(records []logRecord, cursor uint64) ([]*pb.ChatRoomMessage, uint64, error) {
+	msgs := make([]*pb.ChatRoomMessage, 0, len(records))
+	for _, rec := range records {
+		msg := &pb.ChatRoomMessage{}
+		if err := protojson.Unmarshal(rec.Message, msg); err != nil {
+			return nil, 0, fmt.Errorf("decoding record %d: %w", rec.Seq, err)
+		}
+		msg.Seq = rec.Seq
+		msg.Timestamp = rec.Time.UnixMilli()
+		msgs = append(msgs, msg)
+	}
+	return msgs, cursor, nil
+}
+
+func (l *roomLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active == nil {
+		return nil
+	}
+	err := l.active.Close()
+	l.active = nil
+	return err
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+func appendMessages(t *testing.T, h *historyStore, room string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := h.Append(&pb.ChatRoomMessage{Room: room, Sender: "alice", Content: fmt.Sprint("message ", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkSeqs(t *testing.T, what string, msgs []*pb.ChatRoomMessage, first, n uint64) {
+	t.Helper()
+	if uint64(len(msgs)) != n {
+		t.Fatalf("%s: got %d messages, want %d", what, len(msgs), n)
+	}
+	for i, msg := range msgs {
+		if msg.Seq != first+uint64(i) {
+			t.Fatalf("%s: message %d has seq %d, want %d", what, i, msg.Seq, first+uint64(i))
+		}
+	}
+}
+
+func TestHistorySegments(t *testing.T) {
+	dir := t.TempDir()
+	h, err := newHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, h, "lobby", 2*segmentMaxEntries+segmentMaxEntries/2)
+
+	segments, err := filepath.Glob(filepath.Join(dir, "lobby", "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		fmt.Sprintf("%020d.log", 1),
+		fmt.Sprintf("%020d.log", segmentMaxEntries+1),
+		fmt.Sprintf("%020d.log", 2*segmentMaxEntries+1),
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("got segments %v, want %v", segments, want)
+	}
+	for i, path := range segments {
+		if filepath.Base(path) != want[i] {
+			t.Fatalf("got segments %v, want %v", segments, want)
+		}
+	}
+
+	msgs, next, err := h.Since("lobby", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeqs(t, "everything", msgs, 1, 2*segmentMaxEntries+segmentMaxEntries/2)
+	if next != 2*segmentMaxEntries+segmentMaxEntries/2 {
+		t.Fatalf("everything: next cursor is %d", next)
+	}
+
+	// a page that starts at the end of one segment and runs into the next
+	msgs, next, err = h.Since("lobby", segmentMaxEntries-1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeqs(t, "across segments", msgs, segmentMaxEntries, 3)
+	if next != segmentMaxEntries+2 {
+		t.Fatalf("across segments: next cursor is %d, want %d", next, segmentMaxEntries+2)
+	}
+	if msgs[0].Content != fmt.Sprint("message ", segmentMaxEntries-1) || msgs[0].Timestamp == 0 {
+		t.Fatalf("read back %+v", msgs[0])
+	}
+
+	msgs, next, err = h.Since("lobby", next, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeqs(t, "the rest", msgs, segmentMaxEntries+3, segmentMaxEntries+segmentMaxEntries/2-2)
+	if msgs, again, err := h.Since("lobby", next, 0); err != nil || len(msgs) != 0 || again != next {
+		t.Fatalf("past the end: %d messages, cursor %d, %v", len(msgs), again, err)
+	}
+
+	msgs, last, err := h.Last("lobby", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeqs(t, "last", msgs, last-4, 5)
+	if msgs, _, err := h.Since("empty", 0, 0); err != nil || len(msgs) != 0 {
+		t.Fatalf("a room nobody wrote to: %d messages, %v", len(msgs), err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistoryReopen(t *testing.T) {
+	dir := t.TempDir()
+	h, err := newHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, h, "lobby", segmentMaxEntries+10)
+	h.Close()
+
+	// a crash in the middle of a write leaves half a line behind
+	active := filepath.Join(dir, "lobby", fmt.Sprintf("%020d.log", segmentMaxEntries+1))
+	file, err := os.OpenFile(active, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":`)
+	file.Close()
+
+	h, err = newHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	seq, err := h.Append(&pb.ChatRoomMessage{Room: "lobby", Sender: "alice", Content: "after the crash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != segmentMaxEntries+11 {
+		t.Fatalf("seq after reopening is %d, want %d", seq, segmentMaxEntries+11)
+	}
+	msgs, _, err := h.Since("lobby", segmentMaxEntries, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeqs(t, "after reopening", msgs, segmentMaxEntries+1, 11)
+	if msgs[10].Content != "after the crash" {
+		t.Fatalf("the last message is %q", msgs[10].Content)
+	}
+}
+
+func TestHistoryFoldsChanges(t *testing.T) {
+	dir := t.TempDir()
+	h, err := newHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendMessages(t, h, "lobby", 1)
+	segment := filepath.Join(dir, "lobby", fmt.Sprintf("%020d.log", 1))
+	before, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []*pb.ChatRoomMessage{
+		{Type: msgTypeEdit, Sender: "alice", Target: 1, Content: "hello"},
+		{Type: msgTypeReaction, Sender: "bob", Target: 1, Reaction: "+1"},
+		{Type: msgTypeReaction, Sender: "carol", Target: 1, Reaction: "+1"},
+		{Type: msgTypeReaction, Sender: "carol", Target: 1, Reaction: "+1", Undo: true},
+	} {
+		change.Room = "lobby"
+		if _, err := h.Append(change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(after, before) {
+		t.Fatal("the changes rewrote the message instead of being appended")
+	}
+
+	check := func(what string) {
+		t.Helper()
+		msgs, _, err := h.Since("lobby", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSeqs(t, what, msgs, 1, 5)
+		msg := msgs[0]
+		if msg.Content != "hello" || msg.Edited != msgs[1].Timestamp {
+			t.Fatalf("%s: the edit made it %q edited at %d, want %q at %d", what, msg.Content, msg.Edited, "hello", msgs[1].Timestamp)
+		}
+		if len(msg.Reactions) != 1 || msg.Reactions[0].Emoji != "+1" || len(msg.Reactions[0].Users) != 1 || msg.Reactions[0].Users[0] != "bob" {
+			t.Fatalf("%s: the reactions are %v", what, msg.Reactions)
+		}
+		last, _, err := h.Last("lobby", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last[0].Content != msg.Content || last[0].Edited != msg.Edited || len(last[0].Reactions) != 1 {
+			t.Fatalf("%s: Last read back %+v, Since %+v", what, last[0], msg)
+		}
+	}
+	check("as logged")
+	h.Close()
+
+	h, err = newHistoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	check("after reopening")
+
+	if _, err := h.Append(&pb.ChatRoomMessage{Type: msgTypeDelete, Room: "lobby", Sender: "alice", Target: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Append(&pb.ChatRoomMessage{Type: msgTypeReaction, Room: "lobby", Sender: "bob", Target: 1, Reaction: "+1"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _, err := h.Since("lobby", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := msgs[0]; !msg.Deleted || msg.Content != "" || len(msg.Reactions) != 0 {
+		t.Fatalf("after the delete the message is %+v", msg)
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	updateIntegration = "integration"
+
+	maxBotRequestSize = 64 << 10
+)
+
+// botInfo is a bot that may post to the room. Only a hash of its token is
+// kept.
+type botInfo struct {
+	TokenHash []byte    `json:"token_hash"`
+	Creator   string    `json:"creator"`
+	Created   time.Time `json:"created"`
+}
+
+type webhookInfo struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	Command string    `json:"command,omitempty"`
+	Pattern string    `json:"pattern,omitempty"`
+	Secret  string    `json:"-"` // kept in roomSecrets
+	Creator string    `json:"creator"`
+	Created time.Time `json:"created"`
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// botFor returns the name of the room's bot token belongs to.
+func (r *roomInfo) botFor(token string) (string, bool) {
+	hash := hashToken(token)
+	for name, bot := range r.Bots {
+		if subtle.ConstantTimeCompare(bot.TokenHash, hash) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// webhookNamed reports whether one of the room's webhooks posts as name.
+func (r *roomInfo) webhookNamed(name string) bool {
+	for _, hook := range r.Webhooks {
+		if hook.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *chatServer) CreateBot(ctx context.Context, req *pb.BotRequest) (*pb.BotToken, error) {
+	actor := userFromContext(ctx)
+	if !usernamePattern.MatchString(req.Name) {
+		return nil, status.Error(codes.InvalidArgument, "bot names are 1-32 letters, digits, '.', '_' or '-'")
+	}
+	if s.users.Exists(req.Name) {
+		return nil, status.Errorf(codes.AlreadyExists, "%s is taken by a user", req.Name)
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't create the token: %v", err)
+	}
+	_, err = s.updateRoom(req.Room, func(info *roomInfo) error {
+		if !info.canModerate(actor) {
+			return status.Error(codes.PermissionDenied, "only the room owner or a moderator can add bots")
+		}
+		if _, ok := info.Bots[req.Name]; ok {
+			return status.Errorf(codes.AlreadyExists, "there is already a bot called %s, delete it to get a new token", req.Name)
+		}
+		if info.webhookNamed(req.Name) {
+			return status.Errorf(codes.AlreadyExists, "there is already a webhook called %s", req.Name)
+		}
+		if info.Bots == nil {
+			info.Bots = make(map[string]botInfo)
+		}
+		info.Bots[req.Name] = botInfo{TokenHash: hashToken(token), Creator: actor, Created: time.Now().UTC()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: actor + " added the bot " + req.Name,
+		Sender: actor,
+		Room:   req.Room,
+		Type:   updateIntegration,
+		Actor:  actor,
+		Detail: req.Name,
+	})
+	return &pb.BotToken{Room: req.Room, Name: req.Name, Token: token}, nil
+}
+
+func (s *chatServer) DeleteBot(ctx context.Context, req *pb.BotRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	_, err := s.updateRoom(req.Room, func(info *roomInfo) error {
+		if !info.canModerate(actor) {
+			return status.Error(codes.PermissionDenied, "only the room owner or a moderator can delete bots")
+		}
+		if _, ok := info.Bots[req.Name]; !ok {
+			return status.Errorf(codes.NotFound, "there is no bot called %s", req.Name)
+		}
+		delete(info.Bots, req.Name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: actor + " deleted the bot " + req.Name,
+		Sender: actor,
+		Room:   req.Room,
+		Type:   updateIntegration,
+		Actor:  actor,
+		Detail: req.Name,
+	})
+	return &pb.MessageResponse{Status: "Bot deleted"}, nil
+}
+
+func checkWebhook(ctx context.Context, hook *pb.Webhook) error {
+	if !usernamePattern.MatchString(hook.Name) {
+		return status.Error(codes.InvalidArgument, "webhook names are 1-32 letters, digits, '.', '_' or '-', replies are posted under it")
+	}
+	u, err := url.Parse(hook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return status.Error(codes.InvalidArgument, "the url must be http:// or https://")
+	}
+	if err := checkWebhookHost(ctx, u.Hostname()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if hook.Command == "" && hook.Pattern == "" {
+		return status.Error(codes.InvalidArgument, "a webhook needs a command, a pattern or both")
+	}
+	if hook.Command != "" && (!strings.HasPrefix(hook.Command, "/") || len(hook.Command) < 2 || strings.ContainsAny(hook.Command, " \t\n")) {
+		return status.Error(codes.InvalidArgument, "commands look like /deploy")
+	}
+	if hook.Pattern != "" {
+		if _, err := regexp.Compile(hook.Pattern); err != nil {
+			return status.Errorf(codes.InvalidArgument, "bad pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *chatServer) AddWebhook(ctx context.Context, req *pb.Webhook) (*pb.Webhook, error) {
+	actor := userFromContext(ctx)
+	if err := checkWebhook(ctx, req); err != nil {
+		return nil, err
+	}
+	if s.users.Exists(req.Name) {
+		return nil, status.Errorf(codes.AlreadyExists, "%s is taken by a user", req.Name)
+	}
+
+	id, err := newInviteCode()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't create the webhook: %v", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't create the webhook: %v", err)
+	}
+	hook := webhookInfo{
+		Name:    req.Name,
+		URL:     req.Url,
+		Command: req.Command,
+		Pattern: req.Pattern,
+		Secret:  hex.EncodeToString(secret),
+		Creator: actor,
+		Created: time.Now().UTC(),
+	}
+	_, err = s.updateRoom(req.Room, func(info *roomInfo) error {
+		if !info.canModerate(actor) {
+			return status.Error(codes.PermissionDenied, "only the room owner or a moderator can add webhooks")
+		}
+		if _, ok := info.Bots[req.Name]; ok || info.webhookNamed(req.Name) {
+			return status.Errorf(codes.AlreadyExists, "there is already a bot or webhook called %s", req.Name)
+		}
+		if info.Webhooks == nil {
+			info.Webhooks = make(map[string]webhookInfo)
+		}
+		info.Webhooks[id] = hook
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: actor + " added the webhook " + hook.Name,
+		Sender: actor,
+		Room:   req.Room,
+		Type:   updateIntegration,
+		Actor:  actor,
+		Detail: hook.Name,
+	})
+	out := toWebhook(req.Room, id, hook)
+	out.Secret = hook.Secret
+	return out, nil
+}
+
+func (s *chatServer) RemoveWebhook(ctx context.Context, req *pb.WebhookRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	var name string
+	_, err := s.updateRoom(req.Room, func(info *roomInfo) error {
+		if !info.canModerate(actor) {
+			return status.Error(codes.PermissionDenied, "only the room owner or a moderator can remove webhooks")
+		}
+		hook, ok := info.Webhooks[req.Id]
+		if !ok {
+			return status.Errorf(codes.NotFound, "there is no webhook %s", req.Id)
+		}
+		name = hook.Name
+		delete(info.Webhooks, req.Id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: actor + " removed the webhook " + name,
+		Sender: actor,
+		Room:   req.Room,
+		Type:   updateIntegration,
+		Actor:  actor,
+		Detail: name,
+	})
+	return &pb.MessageResponse{Status: "Webhook removed"}, nil
+}
+
+func (s *chatServer) ListIntegrations(ctx context.Context, req *pb.RoomInfoRequest) (*pb.Integrations, error) {
+	actor := userFromContext(ctx)
+	info, ok := s.roomStore.Get(req.Room)
+	if !ok || !info.readableBy(actor) {
+		return nil, status.Error(codes.NotFound, errRoomNotFound.Error())
+	}
+	if !info.canModerate(actor) {
+		return nil, status.Error(codes.PermissionDenied, "only the room owner or a moderator can see the integrations")
+	}
+
+	out := &pb.Integrations{}
+	for name, bot := range info.Bots {
+		out.Bots = append(out.Bots, &pb.Bot{Name: name, Creator: bot.Creator, Created: bot.Created.Unix()})
+	}
+	for id, hook := range info.Webhooks {
+		out.Webhooks = append(out.Webhooks, toWebhook(req.Room, id, hook))
+	}
+	sort.Slice(out.Bots, func(i, j int) bool { return out.Bots[i].Name < out.Bots[j].Name })
+	sort.Slice(out.Webhooks, func(i, j int) bool { return out.Webhooks[i].Created < out.Webhooks[j].Created })
+	return out, nil
+}
+
+// toWebhook leaves the secret out.
+func toWebhook(room, id string, hook webhookInfo) *pb.Webhook {
+	return &pb.Webhook{
+		Id:      id,
+		Room:    room,
+		Name:    hook.Name,
+		Url:     hook.URL,
+		Command: hook.Command,
+		Pattern: hook.Pattern,
+		Creator: hook.Creator,
+		Created: hook.Created.Unix(),
+	}
+}
+
+// postAsBot sends msg to the room as the bot or webhook called name. Unlike
+// a user's message nothing comes back to say which seq it got.
+func (s *chatServer) postAsBot(room, name string, msg *pb.ChatRoomMessage) error {
+	info, ok := s.roomStore.Get(room)
+	if !ok {
+		return status.Error(codes.NotFound, errRoomNotFound.Error())
+	}
+	if until, banned := info.bannedUntil(name); banned {
+		return status.Errorf(codes.PermissionDenied, "%s is banned from this room %s", name, describeUntil(until))
+	}
+	if until, muted := info.mutedUntil(name); muted {
+		return status.Errorf(codes.PermissionDenied, "%s is muted in this room %s", name, describeUntil(until))
+	}
+
+	msg.Type = msgTypeMessage
+	normalize(msg)
+	msg.Room = room
+	msg.Sender = name
+	msg.Bot = true
+	msg.Attachment = nil
+	msg.Receipt = nil
+	msg.Seq = 0
+	if err := s.publish(roomTopic(room), &pb.ClusterEvent{Room: room, Message: msg}); err != nil {
+		return status.Error(codes.Unavailable, "couldn't deliver the message")
+	}
+	return nil
+}
+
+// webhookReply posts what a webhook answered with, as a bot named after it.
+func (s *chatServer) webhookReply(room, name, text string) {
+	msg := &pb.ChatRoomMessage{Type: msgTypeMessage, Content: text, Bot: true}
+	if _, err := s.limits.checkRoomMessage("bot/"+room+"/"+name, room, msg); err != nil {
+		log.Printf("dropping reply of webhook %s in room %s: %v", name, room, err)
+		return
+	}
+	if err := s.postAsBot(room, name, msg); err != nil {
+		log.Printf("failed to post reply of webhook %s in room %s: %v", name, room, err)
+	}
+}
+
+// botAPI is the HTTP API bots post with:
+//
+//	POST /api/rooms/{room}/messages
+//	Authorization: Bearer <token from CreateBot>
+//
+// The body is either JSON, {"text": "...", "reply_to": 12}, or the text
+// itself as text/plain.
+func (s *chatServer) botAPI() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/rooms/{room}/messages", s.handleBotMessage)
+	return mux
+}
+
+func (s *chatServer) handleBotMessage(w http.ResponseWriter, req *http.Request) {
+	room := req.PathValue("room")
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	info, found := s.roomStore.Get(room)
+	var name string
+	if ok && found {
+		name, ok = info.botFor(token)
+	}
+	if !ok || !found {
+		writeHTTPError(w, status.Error(codes.Unauthenticated, "no such bot in this room"))
+		return
+	}
+
+	msg := &pb.ChatRoomMessage{}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBotRequestSize))
+	if err != nil {
+		writeHTTPError(w, status.Error(codes.InvalidArgument, "the message is too big"))
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if contentType == "text/plain" {
+		msg.Content = string(body)
+	} else {
+		var post struct {
+			Text    string `json:"text"`
+			ReplyTo uint64 `json:"reply_to"`
+		}
+		if err := json.Unmarshal(body, &post); err != nil {
+			writeHTTPError(w, status.Errorf(codes.InvalidArgument, "the body must be JSON or text/plain: %v", err))
+			return
+		}
+		msg.Content, msg.ReplyTo = post.Text, post.ReplyTo
+	}
+	msg.Content = strings.TrimRight(msg.Content, "\r\n")
+	if msg.Content == "" {
+		writeHTTPError(w, status.Error(codes.InvalidArgument, "the message has no text"))
+		return
+	}
+	if msg.ReplyTo != 0 {
+		if _, err := s.storedMessage(room, msg.ReplyTo); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+	}
+	msg.Type = msgTypeMessage
+	msg.Bot = true
+	if wait, err := s.limits.checkRoomMessage("bot/"+room+"/"+name, room, msg); err != nil {
+		if wait > 0 {
+			w.Header().Set("Retry-After", retryAfter(wait).Get("retry-after")[0])
+		}
+		writeHTTPError(w, err)
+		return
+	}
+
+	if err := s.postAsBot(room, name, msg); err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+var httpStatus = map[codes.Code]int{
+	codes.InvalidArgument:   http.StatusBadRequest,
+	codes.Unauthenticated:   http.StatusUnauthorized,
+	codes.PermissionDenied:  http.StatusForbidden,
+	codes.NotFound:          http.StatusNotFound,
+	codes.ResourceExhausted: http.StatusTooManyRequests,
+	codes.Unavailable:       http.StatusServiceUnavailable,
+}
+
+func writeHTTPError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, ok := httpStatus[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": st.Message()})
+}
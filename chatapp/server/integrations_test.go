@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWebhookAddresses(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.215.14":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := publicAddress(netip.MustParseAddr(addr)); got != public {
+			t.Errorf("publicAddress(%s) = %v, want %v", addr, got, public)
+		}
+	}
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5/hook",
+		"http://[::1]/hook",
+		"http://localhost/hook",
+	} {
+		err := checkWebhook(context.Background(), &pb.Webhook{Name: "ci", Url: url, Command: "/deploy"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s was taken: %v", url, err)
+		}
+	}
+	if err := checkWebhook(context.Background(), &pb.Webhook{Name: "ci", Url: "https://93.184.215.14/hook", Command: "/deploy"}); err != nil {
+		t.Errorf("a public address was refused: %v", err)
+	}
+
+	if err := dialPublic("tcp", "127.0.0.1:80", nil); err == nil {
+		t.Error("the webhook dialer would connect to loopback")
+	}
+	if err := dialPublic("tcp6", "[fd00::1]:443", nil); err == nil {
+		t.Error("the webhook dialer would connect to a private address")
+	}
+	if err := dialPublic("tcp", "93.184.215.14:443", nil); err != nil {
+		t.Errorf("the webhook dialer refused a public address: %v", err)
+	}
+}
+
+func TestIntegrations(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	alice := node.as(t, "alice")
+	if _, err := node.client.JoinRoom(alice, &pb.JoinRequest{Room: "ops"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := node.client.CreateBot(alice, &pb.BotRequest{Room: "ops", Name: "helper"}); err != nil {
+		t.Fatal(err)
+	}
+	hook := &pb.Webhook{Room: "ops", Name: "deploy", Url: "https://93.184.215.14/hook", Command: "/deploy"}
+	if _, err := node.client.AddWebhook(alice, hook); err != nil {
+		t.Fatal(err)
+	}
+	for what, err := range map[string]error{
+		"a second webhook called deploy": func() error { _, err := node.client.AddWebhook(alice, hook); return err }(),
+		"a webhook called helper": func() error {
+			_, err := node.client.AddWebhook(alice, &pb.Webhook{Room: "ops", Name: "helper", Url: hook.Url, Command: "/help"})
+			return err
+		}(),
+		"a bot called deploy": func() error {
+			_, err := node.client.CreateBot(alice, &pb.BotRequest{Room: "ops", Name: "deploy"})
+			return err
+		}(),
+		// bots and webhooks post in the same namespace users are in
+		"registering helper": func() error {
+			_, err := node.client.Register(context.Background(), &pb.Credentials{Username: "helper", Password: "correct horse"})
+			return err
+		}(),
+		"registering deploy": func() error {
+			_, err := node.client.Register(context.Background(), &pb.Credentials{Username: "deploy", Password: "correct horse"})
+			return err
+		}(),
+	} {
+		if status.Code(err) != codes.AlreadyExists {
+			t.Errorf("%s: got %v, want AlreadyExists", what, err)
+		}
+	}
+
+	if err := node.cs.postAsBot("ops", "helper", &pb.ChatRoomMessage{Content: "hello"}); err != nil {
+		t.Fatalf("the bot couldn't post: %v", err)
+	}
+	if _, err := node.client.BanMember(alice, &pb.ModerationRequest{Room: "ops", Target: "helper"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.cs.postAsBot("ops", "helper", &pb.ChatRoomMessage{Content: "still here"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("a banned bot posted: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	publicKeySize = 32 // X25519
+	boxNonceSize  = 24
+)
+
+func keyTopic(user string) string {
+	return "key/" + user
+}
+
+type publishedKey struct {
+	Key           []byte    `json:"key"`
+	Published     time.Time `json:"published"`
+	EncryptedOnly bool      `json:"encrypted_only,omitempty"`
+}
+
+// keyDirectory is the public key each user last published, saved to a JSON
+// file like the users. Only public keys ever reach the server.
+type keyDirectory struct {
+	mu   sync.Mutex
+	path string
+	keys map[string]publishedKey
+}
+
+func newKeyDirectory(path string) (*keyDirectory, error) {
+	d := &keyDirectory{
+		path: path,
+		keys: make(map[string]publishedKey),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.keys); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return d, nil
+}
+
+func (d *keyDirectory) Get(user string) (publishedKey, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key, ok := d.keys[user]
+	return key, ok
+}
+
+func (d *keyDirectory) Put(user string, key publishedKey) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.keys[user] = key
+	data, err := json.MarshalIndent(d.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(d.path, data, 0644)
+}
+
+func (k publishedKey) proto(user string) *pb.PublicKey {
+	return &pb.PublicKey{
+		User:          user,
+		Key:           k.Key,
+		Published:     k.Published.UnixMilli(),
+		EncryptedOnly: k.EncryptedOnly,
+	}
+}
+
+func (s *chatServer) PublishKey(ctx context.Context, req *pb.PublicKey) (*pb.MessageResponse, error) {
+	user := userFromContext(ctx)
+	if len(req.Key) != publicKeySize {
+		return nil, status.Errorf(codes.InvalidArgument, "the key must be %d bytes", publicKeySize)
+	}
+
+	key := publishedKey{Key: req.Key, Published: time.Now().UTC(), EncryptedOnly: req.EncryptedOnly}
+	if err := s.keys.Put(user, key); err != nil {
+		log.Printf("failed to save the key of %s: %v", user, err)
+		return nil, status.Error(codes.Internal, "couldn't save the key")
+	}
+	s.publish(keyTopic(user), &pb.ClusterEvent{PublicKey: key.proto(user)})
+
+	return &pb.MessageResponse{Status: "Key published"}, nil
+}
+
+func (s *chatServer) GetPublicKey(ctx context.Context, req *pb.PublicKeyRequest) (*pb.PublicKey, error) {
+	key, ok := s.keys.Get(req.User)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s hasn't published a key", req.User)
+	}
+	return key.proto(req.User), nil
+}
+
+// onKeyEvent is subscribed to every key topic.
+func (s *chatServer) onKeyEvent(event *pb.ClusterEvent) {
+	if event.Origin == s.broker.Node() || event.PublicKey == nil {
+		// already saved when it was published
+		return
+	}
+	key := event.PublicKey
+	err := s.keys.Put(key.User, publishedKey{
+		Key:           key.Key,
+		Published:     time.UnixMilli(key.Published).UTC(),
+		EncryptedOnly: key.EncryptedOnly,
+	})
+	if err != nil {
+		log.Printf("failed to save the key of %s: %v", key.User, err)
+	}
+}
+
+// checkEncrypted makes sure an encrypted private message is well formed. The
+// server can't read it, but a client shouldn't get one it can't even try to
+// open. Plaintext is only turned down for recipients who asked for that when
+// publishing their key: having a key doesn't mean every client they use has
+// it, the web gateway never does.
+func (s *chatServer) checkEncrypted(msg *pb.PrivateMessage) error {
+	enc := msg.Encrypted
+	if enc == nil {
+		if key, ok := s.keys.Get(msg.Recipient); ok && key.EncryptedOnly {
+			return status.Errorf(codes.FailedPrecondition, "%s only takes encrypted private messages", msg.Recipient)
+		}
+		return nil
+	}
+	switch {
+	case msg.Content != "":
+		return status.Error(codes.InvalidArgument, "an encrypted message can't have plaintext content")
+	case len(enc.Nonce) != boxNonceSize:
+		return status.Errorf(codes.InvalidArgument, "the nonce must be %d bytes", boxNonceSize)
+	case len(enc.SenderKey) != publicKeySize || len(enc.RecipientKey) != publicKeySize:
+		return status.Errorf(codes.InvalidArgument, "keys must be %d bytes", publicKeySize)
+	case len(enc.Ciphertext) == 0:
+		return status.Error(codes.InvalidArgument, "the message is empty")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPrivateMessagesStayEncrypted(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	alice := node.as(t, "alice")
+	if _, err := node.client.Register(context.Background(), &pb.Credentials{Username: "bob", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	aliceKey, bobKey := bytes.Repeat([]byte{1}, publicKeySize), bytes.Repeat([]byte{2}, publicKeySize)
+
+	// without keys there is nothing to encrypt with yet
+	if _, err := node.client.SendPrivateMessage(alice, &pb.PrivateMessage{Recipient: "bob", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := node.client.PublishKey(alice, &pb.PublicKey{Key: aliceKey}); err != nil {
+		t.Fatal(err)
+	}
+	bob := node.as(t, "bob")
+	if _, err := node.client.PublishKey(bob, &pb.PublicKey{Key: bobKey}); err != nil {
+		t.Fatal(err)
+	}
+	// having keys isn't enough, alice might be in a browser that has none
+	if _, err := node.client.SendPrivateMessage(alice, &pb.PrivateMessage{Recipient: "bob", Content: "from a browser"}); err != nil {
+		t.Fatalf("a plaintext message to a user with a key: %v", err)
+	}
+	if _, err := node.client.PublishKey(bob, &pb.PublicKey{Key: bobKey, EncryptedOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.client.SendPrivateMessage(alice, &pb.PrivateMessage{Recipient: "bob", Content: "the plans"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("a plaintext message to someone who only takes encrypted ones got %v", err)
+	}
+
+	sealed := []byte("opaque box bytes")
+	_, err := node.client.SendPrivateMessage(alice, &pb.PrivateMessage{Recipient: "bob", Encrypted: &pb.EncryptedContent{
+		Nonce:        bytes.Repeat([]byte{3}, boxNonceSize),
+		SenderKey:    aliceKey,
+		RecipientKey: bobKey,
+		Ciphertext:   sealed,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry inboxEntry
+	eventually(t, "the message to wait in bob's inbox", func() bool {
+		node.cs.inbox.mu.Lock()
+		defer node.cs.inbox.mu.Unlock()
+		entries := node.cs.inbox.inboxes["bob"]
+		if len(entries) < 3 {
+			return false
+		}
+		entry = entries[2]
+		return true
+	})
+	stored, err := node.cs.conversations.Get("alice", "bob", entry.Seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Content != "" || !bytes.Equal(stored.GetEncrypted().GetCiphertext(), sealed) {
+		t.Fatalf("bob's inbox has %q, %v", stored.Content, stored.Encrypted)
+	}
+
+	// nor does it show up in the clear anywhere on disk
+	filepath.Walk(filepath.Dir(node.cs.inbox.path), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("the plans")) {
+				t.Errorf("%s has the message in plaintext", path)
+			}
+		}
+		return nil
+	})
+}
@@ -2,317 +2,614 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
-	"io"
+	"fmt"
 	"log"
 	"net"
-	"sync"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	pb "example/hello/chatapp/grpc"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+// chatServer has no lock of its own: live room state sits in rooms, one
+// lock per room, and directory knows which rooms each user is in.
 type chatServer struct {
 	pb.UnimplementedChatServer
-	rooms     map[string]map[string]chan *pb.ChatRoomMessage
-	activeUsers map[string]chan *pb.PrivateMessage
-	mu          sync.Mutex
-	updates     map[string]map[string]chan *pb.Update
+	rooms         *roomTable
+	directory     *userDirectory
+	history       *historyStore
+	conversations *conversationStore
+	users         *userStore
+	roomStore     *roomStore
+	inbox         *inboxStore
+	keys          *keyDirectory
+	attachments   *attachmentStore
+	limits        *rateLimiter
+	tokens        *tokenSigner
+	delivery      deliveryConfig
+	broker        Broker
+	presence      *presenceTracker
+	webhooks      *webhookSender
+	registering   *pendingAccounts
+	closing       chan struct{} // closed when the server starts shutting down
 }
 
-func NewChatServer() *chatServer {
-	return &chatServer{
-		rooms:     make(map[string]map[string]chan *pb.ChatRoomMessage),
-		activeUsers: make(map[string]chan *pb.PrivateMessage),
-		updates:     make(map[string]map[string]chan *pb.Update),
+// Highest seq each member has acknowledged in a room.
+type memberReceipts struct {
+	delivered uint64
+	read      uint64
+}
+
+const (
+	msgTypeMessage = "message"
+	msgTypeAck     = "ack"
+	msgTypeReceipt = "receipt"
+
+	receiptSent      = "sent"
+	receiptDelivered = "delivered"
+	receiptRead      = "read"
+	receiptFailed    = "failed"
+)
+
+const maxHistoryBatch = 500
+
+func NewChatServer(history *historyStore, conversations *conversationStore, users *userStore, rooms *roomStore, inbox *inboxStore, keys *keyDirectory, attachments *attachmentStore, limits *rateLimiter, tokens *tokenSigner, delivery deliveryConfig, broker Broker) *chatServer {
+	s := &chatServer{
+		rooms:         newRoomTable(),
+		directory:     newUserDirectory(),
+		history:       history,
+		conversations: conversations,
+		users:         users,
+		roomStore:     rooms,
+		inbox:         inbox,
+		keys:          keys,
+		attachments:   attachments,
+		limits:        limits,
+		tokens:        tokens,
+		delivery:      delivery,
+		broker:        broker,
+		registering:   newPendingAccounts(),
+		closing:       make(chan struct{}),
 	}
+	s.presence = newPresenceTracker(s.announcePresence)
+	s.webhooks = newWebhookSender(s.webhookReply)
+	broker.Subscribe("room/", s.onRoomEvent)
+	broker.Subscribe("dm/", s.onConversationEvent)
+	broker.Subscribe("key/", s.onKeyEvent)
+	broker.Subscribe("user/", s.onAccountEvent)
+	return s
 }
 
 func (s *chatServer) GetExistingChatRooms(ctx context.Context, _ *pb.Empty) (*pb.AvailableRooms, error) {
-	var rooms []string
-	for room := range s.rooms {
-		rooms = append(rooms, room)
+	visible := s.roomStore.Visible(userFromContext(ctx))
+
+	names := make([]string, 0, len(visible))
+	for name := range visible {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	details := make([]*pb.RoomSummary, 0, len(names))
+	for _, name := range names {
+		info := visible[name]
+		details = append(details, &pb.RoomSummary{
+			Name:       name,
+			Visibility: info.visibility(),
+			Topic:      info.Topic,
+		})
 	}
-	return &pb.AvailableRooms{Rooms: rooms}, nil
+	return &pb.AvailableRooms{Rooms: names, Details: details}, nil
 }
 
 func (s *chatServer) JoinRoom(ctx context.Context, joinReq *pb.JoinRequest) (*pb.JoinRoomResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	room := joinReq.Room
-	sender := joinReq.Sender
+	sender := userFromContext(ctx)
+	if room == "" {
+		return nil, status.Error(codes.InvalidArgument, "room is required")
+	}
+
+	// checked before taking the room lock, the password check is slow on purpose
+	info, err := s.admit(room, sender, joinReq)
+	if err != nil {
+		return nil, err
+	}
 
-	var users []string
+	r := s.rooms.getOrCreate(room)
+	r.mu.Lock()
+	defer s.unlock(r)
 
-	if s.rooms[room] == nil {
-		s.rooms[room] = make(map[string]chan *pb.ChatRoomMessage)
-	} else {
-		for user := range s.rooms[room] {
-			users = append(users, user)
-		}
+	users := r.memberNames()
+	for user := range r.remote {
+		users = append(users, user)
 	}
-	
-	if _, exists := s.rooms[room][sender]; exists {
+
+	if sess := r.sessions[sender]; sess != nil {
+		if sess.att != nil {
+			return &pb.JoinRoomResponse{
+				Status:  "Failed",
+				Members: users,
+			}, errors.New("username already taken in this room")
+		}
+		// dropped but not expired yet, hand the session back so it can be resumed
 		return &pb.JoinRoomResponse{
-			Status:  "Failed",
-			Members: users,
-		}, errors.New("username already taken in this room")
+			Status:       "Success",
+			Members:      users,
+			SessionId:    sess.id,
+			ResumeWindow: int32(resumeGracePeriod.Seconds()),
+			Topic:        info.Topic,
+			Role:         info.role(sender),
+		}, nil
 	}
 
-	s.rooms[room][sender] = make(chan *pb.ChatRoomMessage, 10)
-	s.activeUsers[sender] = make(chan *pb.PrivateMessage, 10)
+	// queued until the chat stream attaches
+	r.members[sender] = s.newRoomQueue(room, sender)
+	s.directory.join(sender, room, func() *privateQueue { return s.newPrivateQueue(sender) })
 
-	if s.updates[room] == nil {
-		s.updates[room] = make(map[string]chan *pb.Update)
-	}
-	s.updates[room][sender] = make(chan *pb.Update, 10)
+	sess := &roomSession{id: newSessionID(), user: sender, room: r}
+	r.sessions[sender] = sess
+	// covers clients that join but never open the chat stream
+	s.detachLocked(sess)
 
-	s.notifyRoomUpdate(room, sender, "joined")
+	r.updates[sender] = s.newUpdateQueue(room, sender)
+
+	s.notifyUpdate(r, sender, "joined")
+	r.queueEvent(&pb.ClusterEvent{
+		Presence: &pb.Presence{User: sender, Online: true},
+	})
+
+	var history []*pb.ChatRoomMessage
+	if joinReq.Replay > 0 {
+		msgs, _, err := s.history.Last(room, clampHistory(joinReq.Replay))
+		if err != nil {
+			log.Printf("failed to load history for room %s: %v", room, err)
+		}
+		history = msgs
+	}
 
 	return &pb.JoinRoomResponse{
-		Status: "Success",
-		Members: users,
+		Status:       "Success",
+		Members:      users,
+		History:      history,
+		SessionId:    sess.id,
+		ResumeWindow: int32(resumeGracePeriod.Seconds()),
+		Topic:        info.Topic,
+		Role:         info.role(sender),
 	}, nil
 }
 
-func (s *chatServer) notifyRoomUpdate(room, user, action string) {
-	update := &pb.Update{
-		Sender: user,
-		Room:   room,
-		Update: user + " has " + action + " the room",
-		Type:   action,
+func (s *chatServer) GetRoomHistory(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	if req.Room == "" {
+		return nil, errors.New("room is required")
+	}
+	if err := s.checkReadable(req.Room, userFromContext(ctx)); err != nil {
+		return nil, err
 	}
 
-	if roomUsers, ok := s.updates[room]; ok {
-		for _, updateChan := range roomUsers {
-			select {
-			case updateChan <- update:
-			default:
-				log.Printf("Failed to send update to a user: buffer full")
-			}
+	var (
+		msgs   []*pb.ChatRoomMessage
+		cursor uint64
+		err    error
+	)
+	switch {
+	case req.Thread > 0:
+		// replies can be anywhere after the message they reply to
+		msgs, cursor, err = s.history.Since(req.Room, req.Thread-1, 0)
+	case req.Since > 0:
+		limit := 0
+		if req.Limit > 0 {
+			limit = clampHistory(req.Limit)
 		}
+		msgs, cursor, err = s.history.Since(req.Room, req.Since, limit)
+	default:
+		msgs, cursor, err = s.history.Last(req.Room, clampHistory(req.Limit))
 	}
-}
-
-func (s *chatServer) BroadcastRoomUpdate(req *pb.JoinRequest, stream pb.Chat_BroadcastRoomUpdateServer) error {
-	room := req.Room
-	sender := req.Sender
-
-	s.mu.Lock()
-	userChan, ok := s.updates[room][sender]
-	s.mu.Unlock()
-
-	if !ok {
-		return errors.New("user not registered for updates")
+	if err != nil {
+		log.Printf("failed to read history for room %s: %v", req.Room, err)
+		return nil, errors.New("couldn't read room history")
 	}
-
-	for update := range userChan {
-		if update.Type != "sigexit" {
-			if err := stream.Send(update); err != nil {
-				log.Printf("error sending update to %s: %v", sender, err)
-				break
-			}
+	if req.Thread > 0 {
+		msgs = thread(msgs, req.Thread)
+		if limit := clampHistory(req.Limit); len(msgs) > limit {
+			msgs = msgs[len(msgs)-limit:]
 		}
 	}
 
-	return nil
+	return &pb.HistoryResponse{
+		Messages: msgs,
+		Cursor:   cursor,
+	}, nil
 }
 
-func (s *chatServer) LeaveChatRoom(ctx context.Context, leaveReq *pb.LeaveRequest) (*pb.MessageResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func clampHistory(n int32) int {
+	if n <= 0 || n > maxHistoryBatch {
+		return maxHistoryBatch
+	}
+	return int(n)
+}
 
+// notifyUpdate must be called with r.mu held, and released with unlock.
+func (s *chatServer) notifyUpdate(r *chatRoom, user, action string) {
+	s.announceLocked(r, &pb.Update{
+		Sender: user,
+		Room:   r.name,
+		Update: user + " has " + action + " the room",
+		Type:   action,
+	})
+}
+
+func (s *chatServer) LeaveChatRoom(ctx context.Context, leaveReq *pb.LeaveRequest) (*pb.MessageResponse, error) {
 	room := leaveReq.Room
-	sender := leaveReq.Sender
+	sender := userFromContext(ctx)
 	leaveType := leaveReq.Type
+	if leaveType == "" {
+		leaveType = "left"
+	}
 
-	if _, exists := s.rooms[room]; !exists {
+	r := s.rooms.get(room)
+	if r == nil {
 		return &pb.MessageResponse{Status: "Failed"}, errors.New("room does not exist")
 	}
 
-	if _, exists := s.rooms[room][sender]; !exists {
+	r.mu.Lock()
+	defer s.unlock(r)
+
+	if _, exists := r.members[sender]; !exists {
 		return &pb.MessageResponse{Status: "Failed"}, errors.New("user not in the room")
 	}
 
-	s.notifyRoomUpdate(room, sender, leaveType)
-
-	if ch := s.rooms[room][sender]; ch != nil {
-		close(ch)
-	}
+	s.removeMember(r, sender, leaveType)
 
-	delete(s.rooms[room], sender)
+	return &pb.MessageResponse{
+		Status: "User left the room successfully",
+	}, nil
+}
 
-	userInOtherRooms := false
-	for r, users := range s.rooms {
-		if r != room && users[sender] != nil {
-			userInOtherRooms = true
-			break
-		}
+func (s *chatServer) SendPrivateMessage(ctx context.Context, msg *pb.PrivateMessage) (*pb.MessageResponse, error) {
+	msg.Sender = userFromContext(ctx)
+	if err := s.checkEncrypted(msg); err != nil {
+		return &pb.MessageResponse{Status: "Operation failed"}, err
 	}
-
-	if !userInOtherRooms {
-		if pmChan, exists := s.activeUsers[sender]; exists {
-			close(pmChan)
-			delete(s.activeUsers, sender)
+	if wait, err := s.limits.checkPrivate(msg); err != nil {
+		if wait > 0 {
+			grpc.SetTrailer(ctx, retryAfter(wait))
 		}
+		return &pb.MessageResponse{Status: "Operation failed -- " + status.Convert(err).Message()}, err
 	}
 
-	if s.updates[room] != nil {
-		if updateChan := s.updates[room][sender]; updateChan != nil {
-			close(updateChan)
+	// a mute in a room also covers private messages to the people in it
+	for _, room := range s.directory.roomsOf(msg.Recipient) {
+		info, _ := s.roomStore.Get(room)
+		if _, muted := info.mutedUntil(msg.Sender); muted {
+			return &pb.MessageResponse{
+				Status: "Operation failed -- You are muted",
+			}, status.Errorf(codes.PermissionDenied, "you are muted in %s, where %s is a member", room, msg.Recipient)
 		}
-		delete(s.updates[room], sender)
 	}
 
+	queued, err := s.sendPrivate(msg)
+	switch {
+	case err == errNoSuchUser:
+		return &pb.MessageResponse{
+			Status: "Operation failed -- No user found",
+		}, err
+	case err == errInboxFull:
+		return &pb.MessageResponse{
+			Status: "Operation failed -- Recipient's inbox is full",
+		}, err
+	case err != nil:
+		return &pb.MessageResponse{Status: "Operation failed"}, err
+	case queued:
+		return &pb.MessageResponse{
+			Status: "Message queued -- " + msg.Recipient + " is offline and gets it when they are back",
+		}, nil
+	}
 	return &pb.MessageResponse{
-		Status: "User left the room successfully",
+		Status: "Message sent",
 	}, nil
 }
 
-func (s *chatServer) SendPrivateMessage(ctx context.Context, msg *pb.PrivateMessage) (*pb.MessageResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// broadcastRoomMessage checks that sender may talk in the room and hands msg
+// to the broker. It gets its seq when it comes back, see deliverRoomMessage.
+func (s *chatServer) broadcastRoomMessage(r *chatRoom, sender string, msg *pb.ChatRoomMessage) {
+	if msg.Type == "" {
+		msg.Type = msgTypeMessage
+	}
+	normalize(msg)
+	msg.Room = r.name
+	msg.Sender = sender
+	msg.Receipt = nil
+	msg.Seq = 0
+
+	receipt := &pb.Receipt{Member: sender, Status: receiptFailed}
+	info, _ := s.roomStore.Get(r.name)
+	if until, muted := info.mutedUntil(sender); muted {
+		receipt.Error = "you are muted in this room " + describeUntil(until)
+	} else if err := s.checkAttachment(msg); err != nil {
+		receipt.Error = status.Convert(err).Message()
+	} else if err := s.publish(roomTopic(r.name), &pb.ClusterEvent{Room: r.name, Message: msg}); err != nil {
+		receipt.Error = "couldn't deliver the message"
+	} else {
+		return
+	}
 
-	recipientChan, exists := s.activeUsers[msg.Recipient]
-	if !exists {
-		return &pb.MessageResponse{
-			Status: "Operation failed -- No user found",
-		}, errors.New("couldn't send the message -- User not present or might've disconnected")
+	r.mu.Lock()
+	q := r.members[sender]
+	r.mu.Unlock()
+	if q != nil {
+		q.Push(&pb.ChatRoomMessage{
+			Type:     msgTypeReceipt,
+			Room:     r.name,
+			ClientId: msg.ClientId,
+			Receipt:  receipt,
+		})
 	}
+}
 
-	select {
-	case recipientChan <- msg:
-		return &pb.MessageResponse{
-			Status: "Message sent",
-		}, nil
-	default:
-		return &pb.MessageResponse{
-			Status: "Operation failed -- Message buffer full",
-		}, errors.New("couldn't send the message -- Recipient message buffer is full")
+// recordAck shares the member's ack with the room, see applyAck.
+func (s *chatServer) recordAck(r *chatRoom, member string, ack *pb.Receipt) {
+	if ack == nil || ack.Seq == 0 {
+		return
 	}
+	s.publish(roomTopic(r.name), &pb.ClusterEvent{
+		Room:    r.name,
+		Message: &pb.ChatRoomMessage{Type: msgTypeAck, Sender: member, Room: r.name, Receipt: ack},
+	})
 }
 
-func (s *chatServer) RoomChat(stream pb.Chat_RoomChatServer) error {
-	var sender, room string
-	clientChan := make(chan *pb.ChatRoomMessage, 10) // So when a user calls RoomChat() from their end, the stream instance is unique to them and the associated chan is also unique?
-	privateMessageChan := make(chan *pb.PrivateMessage, 10)
+// moveReceiptMark reports whether ack moved the member's mark forward. Must
+// be called with r.mu held.
+func (r *chatRoom) moveReceiptMark(member string, ack *pb.Receipt) bool {
+	mark := r.receipts[member]
+	if mark == nil {
+		mark = &memberReceipts{}
+		r.receipts[member] = mark
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(3)
+	switch ack.Status {
+	case receiptDelivered:
+		if ack.Seq <= mark.delivered {
+			return false
+		}
+		mark.delivered = ack.Seq
+	case receiptRead:
+		if ack.Seq <= mark.read {
+			return false
+		}
+		mark.read = ack.Seq
+		if mark.delivered < mark.read {
+			mark.delivered = mark.read
+		}
+	default:
+		return false
+	}
+	return true
+}
 
-	go func() {
-		defer wg.Done()
-		for {
-			msg, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Println("recv error", err)
-				break
-			}
+func main() {
+	config, err := loadConfig(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, _ := parseOverflowPolicy(config.Overflow)
 
-			if room == "" {
-				room = msg.Room
-				sender = msg.Sender
+	// spill files only make sense to the process that wrote them
+	spillDir := filepath.Join(config.Data, "spill")
+	if err := os.RemoveAll(spillDir); err != nil {
+		log.Fatalf("Failed to clear spill directory: %v", err)
+	}
 
-				s.mu.Lock()
-				if s.rooms[room] == nil {
-					s.rooms[room] = make(map[string]chan *pb.ChatRoomMessage)
-				}
-				s.rooms[room][sender] = clientChan
-				s.activeUsers[sender] = privateMessageChan
-				s.mu.Unlock()
+	history, err := newHistoryStore(filepath.Join(config.Data, "rooms"))
+	if err != nil {
+		log.Fatalf("Failed to open history store: %v", err)
+	}
+	defer history.Close()
 
-				continue
-			}
+	conversations, err := newConversationStore(filepath.Join(config.Data, "conversations"))
+	if err != nil {
+		log.Fatalf("Failed to open conversation store: %v", err)
+	}
+	defer conversations.Close()
 
-			s.mu.Lock()
-			for user, ch := range s.rooms[room] {
-				if user != msg.Sender && ch != nil {
+	users, err := newUserStore(filepath.Join(config.Data, "users.json"))
+	if err != nil {
+		log.Fatalf("Failed to open user store: %v", err)
+	}
 
-					select {
-					case ch <- msg:
-					default:
-						log.Printf("Failed to send message to %s: buffer full", user)
-					}
-				}
-			}
-			s.mu.Unlock()
-		}
+	rooms, err := newRoomStore(filepath.Join(config.Data, "rooms.json"))
+	if err != nil {
+		log.Fatalf("Failed to open room store: %v", err)
+	}
 
-		s.mu.Lock()
-		if s.rooms[room] != nil {
-			delete(s.rooms[room], sender)
-			if len(s.rooms[room]) == 0 {
-				delete(s.rooms, room)
-			}
-		}
-		s.notifyRoomUpdate(room, sender, "left")
-		s.mu.Unlock()
-	}()
+	inbox, err := newInboxStore(filepath.Join(config.Data, "inbox.json"), config.InboxLimit, time.Duration(config.InboxTTL))
+	if err != nil {
+		log.Fatalf("Failed to open inbox store: %v", err)
+	}
 
-	go func() {
-		defer wg.Done()
-
-		for {
-			select {
-			case roomMsg, ok := <-clientChan:
-				if !ok {
-					return
-				}
-				if err := stream.Send(roomMsg); err != nil {
-					log.Printf("Error sending room message: %v", err)
-					return
-				}
-			case privateMsg, ok := <-privateMessageChan:
-				if !ok {
-					return
-				}
-
-				roomMsg := &pb.ChatRoomMessage{
-					Sender:  privateMsg.Sender,
-					Room:    "private",
-					Content: 
-					privateMsg.Content,
-				}
-				if err := stream.Send(roomMsg); err != nil {
-					log.Printf("Error sending private message: %v", err)
-					return
-				}
-			}
-		}
-	}()
+	keys, err := newKeyDirectory(filepath.Join(config.Data, "keys.json"))
+	if err != nil {
+		log.Fatalf("Failed to open key directory: %v", err)
+	}
 
-	wg.Wait()
+	attachmentDir := config.Attachments
+	if attachmentDir == "" {
+		attachmentDir = filepath.Join(config.Data, "attachments")
+	}
+	attachments, err := newAttachmentStore(attachmentDir, config.MaxAttachmentSize)
+	if err != nil {
+		log.Fatalf("Failed to open attachment store: %v", err)
+	}
 
-	s.mu.Lock()
-	delete(s.activeUsers, sender)
-	if s.updates[room] != nil {
-		if updateCh := s.updates[room][sender]; updateCh != nil {
-			close(updateCh)
-		}
-		delete(s.updates[room], sender)
+	limitSettings, err := loadLimits(config.Limits)
+	if err != nil {
+		log.Fatalf("Failed to load rate limits: %v", err)
 	}
-	s.mu.Unlock()
+	limits := newRateLimiter(limitSettings)
+	limits.trustGateways(strings.Split(config.Gateways, ","))
 
-	return nil
-}
+	key, err := loadSigningKey(filepath.Join(config.Data, "token.key"))
+	if err != nil {
+		log.Fatalf("Failed to load token signing key: %v", err)
+	}
 
-func main() {
-	lis, err := net.Listen("tcp", ":50051")
+	serverCreds, peerCreds, err := loadTLS(&config)
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificates: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", config.Listen)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	chatSrv := NewChatServer()
+	var broker Broker = newMemoryBroker()
+	var cluster *peerBroker
+	if config.Peers != "" {
+		cluster = newPeerBroker(config.Node, strings.Split(config.Peers, ","), key)
+		cluster.creds = peerCreds
+		defer cluster.Close()
+		broker = cluster
+	}
+
+	chatSrv := NewChatServer(history, conversations, users, rooms, inbox, keys, attachments, limits, &tokenSigner{key: key}, deliveryConfig{
+		policy:        policy,
+		queueSize:     config.QueueSize,
+		spillDir:      spillDir,
+		maxSpillBytes: config.MaxSpill,
+	}, broker)
+	chatSrv.presence.awayAfter = time.Duration(config.AwayAfter)
+	chatSrv.presence.lastSeenFor = time.Duration(config.LastSeenFor)
+
+	if config.Metrics != "" {
+		go func() {
+			// expvar serves the delivery counters on /debug/vars
+			log.Printf("Serving metrics on %s/debug/vars", config.Metrics)
+			if err := http.ListenAndServe(config.Metrics, nil); err != nil {
+				log.Printf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(chatSrv.UnaryAuthInterceptor, limits.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(chatSrv.StreamAuthInterceptor, limits.StreamInterceptor),
+	}
+	if serverCreds != nil {
+		opts = append(opts, grpc.Creds(serverCreds))
+	}
+	grpcServer := grpc.NewServer(opts...)
 
 	pb.RegisterChatServer(grpcServer, chatSrv)
-	log.Println("Server is listening on port 50051...")
+	if cluster != nil {
+		pb.RegisterClusterServer(grpcServer, cluster)
+		log.Printf("Sharing rooms with %s", config.Peers)
+	}
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus(pb.Chat_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthSrv)
+	if config.Reflection {
+		reflection.Register(grpcServer)
+	}
+
+	var apiSrv *http.Server
+	if config.HTTP != "" {
+		apiSrv = &http.Server{Addr: config.HTTP, Handler: chatSrv.botAPI()}
+		go func() {
+			log.Printf("Serving the bot API on %s", config.HTTP)
+			var err error
+			if config.TLSCert != "" {
+				err = apiSrv.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+			} else {
+				err = apiSrv.ListenAndServe()
+			}
+			if err != http.ErrServerClosed {
+				log.Printf("Bot API server stopped: %v", err)
+			}
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		signal.Stop(signals)
+		log.Print("Shutting down...")
+		gracefulStop(grpcServer, healthSrv, apiSrv, chatSrv, time.Duration(config.ShutdownTimeout))
+		close(stopped)
+	}()
+
+	log.Printf("Server is listening on %s...", lis.Addr())
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
+	// Serve returns as soon as we stop listening, the calls still running
+	// have to finish before the stores close
+	<-stopped
+	log.Print("Server stopped")
+}
+
+// gracefulStop tells load balancers and everyone in a room that the server is
+// going away, stops taking bot posts, ends the RoomChat streams and waits up
+// to timeout for the other calls to finish.
+func gracefulStop(grpcServer *grpc.Server, healthSrv *health.Server, apiSrv *http.Server, chatSrv *chatServer, timeout time.Duration) {
+	healthSrv.Shutdown()
+	if apiSrv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		apiSrv.Shutdown(ctx)
+		cancel()
+	}
+	chatSrv.shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Calls still running after %s, cutting them off", timeout)
+		grpcServer.Stop()
+	}
+}
+
+// loadTLS makes the credentials to serve with and to dial the other nodes
+// with, both nil without -tls-cert.
+func loadTLS(config *serverConfig) (credentials.TransportCredentials, credentials.TransportCredentials, error) {
+	if config.TLSCert == "" {
+		return nil, nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	peerConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSCA != "" {
+		pem, err := os.ReadFile(config.TLSCA)
+		if err != nil {
+			return nil, nil, err
+		}
+		peerConfig.RootCAs = x509.NewCertPool()
+		if !peerConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates in %s", config.TLSCA)
+		}
+	}
+	serving := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	return credentials.NewTLS(serving), credentials.NewTLS(peerConfig), nil
 }
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxTopicLength = 256
+
+const (
+	updateKicked   = "kicked"
+	updateBanned   = "banned"
+	updateUnbanned = "unbanned"
+	updateMuted    = "muted"
+	updateUnmuted  = "unmuted"
+	updateRole     = "role"
+	updateTopic    = "topic"
+
+	updateVisibility = "visibility"
+)
+
+// checkModerator makes sure actor may act on target in the room.
+func checkModerator(info *roomInfo, actor, target string) error {
+	if !info.canModerate(actor) {
+		return status.Error(codes.PermissionDenied, "only the room owner or a moderator can do that")
+	}
+	if target == "" {
+		return status.Error(codes.InvalidArgument, "target is required")
+	}
+	if target == actor {
+		return status.Error(codes.InvalidArgument, "you can't moderate yourself")
+	}
+	if !info.outranks(actor, target) {
+		return status.Errorf(codes.PermissionDenied, "you can't moderate %s in this room", target)
+	}
+	return nil
+}
+
+func restrictionEnd(seconds int64) (time.Time, error) {
+	if seconds < 0 {
+		return time.Time{}, status.Error(codes.InvalidArgument, "duration can't be negative")
+	}
+	if seconds == 0 {
+		return time.Time{}, nil
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second).UTC(), nil
+}
+
+func withReason(text, reason string) string {
+	if reason == "" {
+		return text
+	}
+	return text + " (" + reason + ")"
+}
+
+// updateRoom changes the room's settings and shares them with the other
+// nodes.
+func (s *chatServer) updateRoom(room string, fn func(info *roomInfo) error) (roomInfo, error) {
+	info, err := s.roomStore.Update(room, fn)
+	if err == errRoomNotFound {
+		return info, status.Error(codes.NotFound, err.Error())
+	}
+	if _, ok := status.FromError(err); err != nil && !ok {
+		return info, status.Errorf(codes.Internal, "couldn't save room: %v", err)
+	}
+	if err == nil {
+		s.shareRoom(room, info)
+	}
+	return info, err
+}
+
+// announce queues update for everyone subscribed to the room's update stream.
+func (s *chatServer) announce(update *pb.Update) {
+	r := s.rooms.get(update.Room)
+	if r == nil {
+		// nobody has joined since the server started
+		return
+	}
+	r.mu.Lock()
+	s.announceLocked(r, update)
+	s.unlock(r)
+}
+
+// announceLocked must be called with r.mu held, and released with unlock.
+func (s *chatServer) announceLocked(r *chatRoom, update *pb.Update) {
+	r.pushUpdateLocked(update)
+	r.queueEvent(&pb.ClusterEvent{Update: update})
+}
+
+// pushUpdateLocked queues update for the subscribers on this node. Must be
+// called with r.mu held.
+func (r *chatRoom) pushUpdateLocked(update *pb.Update) {
+	for _, updates := range r.updates {
+		updates.Push(update)
+	}
+}
+
+func (s *chatServer) GetRoomInfo(ctx context.Context, req *pb.RoomInfoRequest) (*pb.RoomInfo, error) {
+	info, ok := s.roomStore.Get(req.Room)
+	if !ok || !info.readableBy(userFromContext(ctx)) {
+		return nil, status.Error(codes.NotFound, errRoomNotFound.Error())
+	}
+
+	var members []string
+	if r := s.rooms.get(req.Room); r != nil {
+		r.mu.Lock()
+		for user := range r.sessions {
+			members = append(members, user)
+		}
+		for user := range r.remote {
+			members = append(members, user)
+		}
+		r.mu.Unlock()
+	}
+
+	return &pb.RoomInfo{
+		Room:       req.Room,
+		Owner:      info.Owner,
+		Topic:      info.Topic,
+		Roles:      info.Roles,
+		Members:    members,
+		Visibility: info.visibility(),
+	}, nil
+}
+
+func (s *chatServer) KickMember(ctx context.Context, req *pb.ModerationRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+
+	info, ok := s.roomStore.Get(req.Room)
+	if !ok {
+		return nil, status.Error(codes.NotFound, errRoomNotFound.Error())
+	}
+	if err := checkModerator(&info, actor, req.Target); err != nil {
+		return nil, err
+	}
+
+	r := s.rooms.get(req.Room)
+	if r == nil {
+		return nil, status.Errorf(codes.NotFound, "%s is not in the room", req.Target)
+	}
+	r.mu.Lock()
+	if r.sessions[req.Target] == nil && r.remote[req.Target] == "" {
+		s.unlock(r)
+		return nil, status.Errorf(codes.NotFound, "%s is not in the room", req.Target)
+	}
+	s.announceLocked(r, &pb.Update{
+		Update: withReason(req.Target+" was kicked by "+actor, req.Reason),
+		Sender: req.Target,
+		Room:   req.Room,
+		Type:   updateKicked,
+		Actor:  actor,
+	})
+	s.evict(r, req.Target)
+	s.unlock(r)
+
+	// shares the room's settings, which mustn't wait on the broker holding r.mu
+	s.revokeAdmission(req.Room, req.Target)
+
+	return &pb.MessageResponse{Status: req.Target + " was kicked"}, nil
+}
+
+func (s *chatServer) BanMember(ctx context.Context, req *pb.ModerationRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	until, err := restrictionEnd(req.DurationSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.updateRoom(req.Room, func(info *roomInfo) error {
+		if err := checkModerator(info, actor, req.Target); err != nil {
+			return err
+		}
+		if info.Bans == nil {
+			info.Bans = make(map[string]time.Time)
+		}
+		info.Bans[req.Target] = until
+		delete(info.Allowed, req.Target)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := s.rooms.getOrCreate(req.Room)
+	r.mu.Lock()
+	defer s.unlock(r)
+
+	s.announceLocked(r, &pb.Update{
+		Update: withReason(fmt.Sprintf("%s was banned by %s %s", req.Target, actor, describeUntil(until)), req.Reason),
+		Sender: req.Target,
+		Room:   req.Room,
+		Type:   updateBanned,
+		Actor:  actor,
+		Detail: describeUntil(until),
+	})
+	if r.sessions[req.Target] != nil || r.remote[req.Target] != "" {
+		s.evict(r, req.Target)
+	}
+
+	return &pb.MessageResponse{Status: req.Target + " was banned"}, nil
+}
+
+func (s *chatServer) UnbanMember(ctx context.Context, req *pb.ModerationRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+
+	_, err := s.updateRoom(req.Room, func(info *roomInfo) error {
+		if err := checkModerator(info, actor, req.Target); err != nil {
+			return err
+		}
+		if _, banned := info.bannedUntil(req.Target); !banned {
+			return status.Errorf(codes.NotFound, "%s is not banned", req.Target)
+		}
+		delete(info.Bans, req.Target)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: req.Target + " was unbanned by " + actor,
+		Sender: req.Target,
+		Room:   req.Room,
+		Type:   updateUnbanned,
+		Actor:  actor,
+	})
+
+	return &pb.MessageResponse{Status: req.Target + " was unbanned"}, nil
+}
+
+func (s *chatServer) MuteMember(ctx context.Context, req *pb.ModerationRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	until, err := restrictionEnd(req.DurationSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.updateRoom(req.Room, func(info *roomInfo) error {
+		if err := checkModerator(info, actor, req.Target); err != nil {
+			return err
+		}
+		if info.Mutes == nil {
+			info.Mutes = make(map[string]time.Time)
+		}
+		info.Mutes[req.Target] = until
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: withReason(fmt.Sprintf("%s was muted by %s %s", req.Target, actor, describeUntil(until)), req.Reason),
+		Sender: req.Target,
+		Room:   req.Room,
+		Type:   updateMuted,
+		Actor:  actor,
+		Detail: describeUntil(until),
+	})
+
+	return &pb.MessageResponse{Status: req.Target + " was muted"}, nil
+}
+
+func (s *chatServer) UnmuteMember(ctx context.Context, req *pb.ModerationRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+
+	_, err := s.updateRoom(req.Room, func(info *roomInfo) error {
+		if err := checkModerator(info, actor, req.Target); err != nil {
+			return err
+		}
+		if _, muted := info.mutedUntil(req.Target); !muted {
+			return status.Errorf(codes.NotFound, "%s is not muted", req.Target)
+		}
+		delete(info.Mutes, req.Target)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: req.Target + " was unmuted by " + actor,
+		Sender: req.Target,
+		Room:   req.Room,
+		Type:   updateUnmuted,
+		Actor:  actor,
+	})
+
+	return &pb.MessageResponse{Status: req.Target + " was unmuted"}, nil
+}
+
+func (s *chatServer) SetMemberRole(ctx context.Context, req *pb.RoleRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	if req.Role != roleModerator && req.Role != roleMember {
+		return nil, status.Error(codes.InvalidArgument, "role must be moderator or member")
+	}
+
+	_, err := s.updateRoom(req.Room, func(info *roomInfo) error {
+		if info.role(actor) != roleOwner {
+			return status.Error(codes.PermissionDenied, "only the room owner can change roles")
+		}
+		if req.Target == "" || req.Target == info.Owner {
+			return status.Error(codes.InvalidArgument, "the owner's role can't be changed")
+		}
+		if req.Role == roleMember {
+			delete(info.Roles, req.Target)
+			return nil
+		}
+		if info.Roles == nil {
+			info.Roles = make(map[string]string)
+		}
+		info.Roles[req.Target] = req.Role
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.announce(&pb.Update{
+		Update: fmt.Sprintf("%s is now a %s (set by %s)", req.Target, req.Role, actor),
+		Sender: req.Target,
+		Room:   req.Room,
+		Type:   updateRole,
+		Actor:  actor,
+		Detail: req.Role,
+	})
+
+	return &pb.MessageResponse{Status: req.Target + " is now a " + req.Role}, nil
+}
+
+func (s *chatServer) SetRoomTopic(ctx context.Context, req *pb.TopicRequest) (*pb.MessageResponse, error) {
+	actor := userFromContext(ctx)
+	if len(req.Topic) > maxTopicLength {
+		return nil, status.Errorf(codes.InvalidArgument, "topic can't be longer than %d bytes", maxTopicLength)
+	}
+
+	_, err := s.updateRoom(req.Room, func(info *roomInfo) error {
+		if !info.canModerate(actor) {
+			return status.Error(codes.PermissionDenied, "only the room owner or a moderator can set the topic")
+		}
+		info.Topic = req.Topic
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text := actor + " changed the topic to: " + req.Topic
+	if req.Topic == "" {
+		text = actor + " cleared the topic"
+	}
+	s.announce(&pb.Update{
+		Update: text,
+		Sender: actor,
+		Room:   req.Room,
+		Type:   updateTopic,
+		Actor:  actor,
+		Detail: req.Topic,
+	})
+
+	return &pb.MessageResponse{Status: "Topic updated"}, nil
+}
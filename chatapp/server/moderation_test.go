@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recvUpdate skips ahead to the next update of updateType in room.
+func recvUpdate(t *testing.T, stream pb.Chat_RoomChatClient, updateType, room string) *pb.Update {
+	t.Helper()
+	for {
+		if msg := recvMessage(t, stream, msgTypeUpdate, room); msg.Update.GetType() == updateType {
+			return msg.Update
+		}
+	}
+}
+
+func TestKickBanMute(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	// the first one in owns the room
+	alice := node.chatIn(t, "alice", "lobby")
+	bob := node.chatIn(t, "bob", "lobby")
+	carol := node.chatIn(t, "carol", "lobby")
+	asAlice, asBob := node.as(t, "alice"), node.as(t, "bob")
+	lobby := func(target string) *pb.ModerationRequest {
+		return &pb.ModerationRequest{Room: "lobby", Target: target, Reason: "testing"}
+	}
+
+	if _, err := node.client.KickMember(asBob, lobby("carol")); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("a member kicking someone: %v", err)
+	}
+	if _, err := node.client.KickMember(asAlice, lobby("dave")); status.Code(err) != codes.NotFound {
+		t.Fatalf("kicking someone who isn't there: %v", err)
+	}
+
+	if _, err := node.client.KickMember(asAlice, lobby("bob")); err != nil {
+		t.Fatal(err)
+	}
+	if update := recvUpdate(t, carol, updateKicked, "lobby"); update.Sender != "bob" || update.Actor != "alice" {
+		t.Fatalf("carol was told %v", update)
+	}
+	if msg := recvMessage(t, bob, msgTypeUnsubscribed, "lobby"); codes.Code(msg.Code) != codes.OK {
+		t.Fatalf("bob's subscription ended with %v", msg)
+	}
+	// a kick isn't a ban
+	bob = node.chatIn(t, "bob", "lobby")
+
+	if _, err := node.client.MuteMember(asAlice, lobby("carol")); err != nil {
+		t.Fatal(err)
+	}
+	if err := carol.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "hello?", ClientId: "muted"}); err != nil {
+		t.Fatal(err)
+	}
+	if receipt := recvMessage(t, carol, msgTypeReceipt, "lobby"); receipt.Receipt.GetStatus() != receiptFailed {
+		t.Fatalf("carol sent while muted: %v", receipt.Receipt)
+	}
+	if _, err := node.client.UnmuteMember(asAlice, lobby("carol")); err != nil {
+		t.Fatal(err)
+	}
+	if err := carol.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "hello", ClientId: "unmuted"}); err != nil {
+		t.Fatal(err)
+	}
+	if receipt := recvMessage(t, carol, msgTypeReceipt, "lobby"); receipt.Receipt.GetStatus() != receiptSent {
+		t.Fatalf("carol sent after being unmuted: %v", receipt.Receipt)
+	}
+	if msg := recvMessage(t, alice, msgTypeMessage, "lobby"); msg.Content != "hello" || msg.Sender != "carol" {
+		t.Fatalf("alice got %v", msg)
+	}
+
+	if _, err := node.client.BanMember(asAlice, lobby("bob")); err != nil {
+		t.Fatal(err)
+	}
+	recvMessage(t, bob, msgTypeUnsubscribed, "lobby")
+	if _, err := node.client.JoinRoom(asBob, &pb.JoinRequest{Room: "lobby"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("bob joining while banned: %v", err)
+	}
+	if _, err := node.client.UnbanMember(asAlice, lobby("bob")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.client.JoinRoom(asBob, &pb.JoinRequest{Room: "lobby"}); err != nil {
+		t.Fatalf("bob joining after the ban was lifted: %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	presenceOnline  = "online"
+	presenceAway    = "away"
+	presenceOffline = "offline"
+
+	updatePresence = "presence"
+	updateTyping   = "typing"
+
+	msgTypeTyping = "typing"
+
+	defaultAwayAfter = 5 * time.Minute
+	// how long the last seen time of someone gone offline is kept
+	defaultLastSeenFor = 24 * time.Hour
+	// users one GetPresence call can ask about by name
+	maxPresenceUsers = 500
+	// clients refresh the typing state more often than this while typing
+	typingTimeout = 6 * time.Second
+)
+
+// userPresence is what this node knows about one user. Users connected here
+// are tracked from their RoomChat streams; everyone else from the presence
+// updates other nodes send.
+type userPresence struct {
+	streams  int
+	status   string
+	lastSeen time.Time
+	away     *time.Timer
+	forget   *time.Timer
+	remote   *pb.UserPresence
+}
+
+// presenceTracker works out who is online, away or offline. A user is online
+// while they have a RoomChat stream open, and away once none of their
+// streams carried anything for awayAfter. Users offline for lastSeenFor are
+// forgotten.
+type presenceTracker struct {
+	awayAfter   time.Duration
+	lastSeenFor time.Duration
+	// onChange is called without mu held whenever a local user's status
+	// changes.
+	onChange func(p *pb.UserPresence)
+
+	mu    sync.Mutex
+	users map[string]*userPresence
+}
+
+func newPresenceTracker(onChange func(p *pb.UserPresence)) *presenceTracker {
+	return &presenceTracker{
+		awayAfter:   defaultAwayAfter,
+		lastSeenFor: defaultLastSeenFor,
+		onChange:    onChange,
+		users:       make(map[string]*userPresence),
+	}
+}
+
+func (t *presenceTracker) user(name string) *userPresence {
+	u := t.users[name]
+	if u == nil {
+		u = &userPresence{status: presenceOffline}
+		t.users[name] = u
+	}
+	return u
+}
+
+// connect counts a new stream of user.
+func (t *presenceTracker) connect(user string) {
+	t.mu.Lock()
+	u := t.user(user)
+	u.streams++
+	changed := t.activeLocked(user, u)
+	t.mu.Unlock()
+
+	if changed != nil {
+		t.onChange(changed)
+	}
+}
+
+// disconnect forgets a stream of user, they go offline with the last one.
+func (t *presenceTracker) disconnect(user string) {
+	t.mu.Lock()
+	u := t.user(user)
+	u.streams--
+	var changed *pb.UserPresence
+	if u.streams == 0 {
+		if u.away != nil {
+			u.away.Stop()
+			u.away = nil
+		}
+		u.status = presenceOffline
+		u.lastSeen = time.Now()
+		changed = u.proto(user)
+		t.forgetLocked(user, u)
+	}
+	t.mu.Unlock()
+
+	if changed != nil {
+		t.onChange(changed)
+	}
+}
+
+// touch records activity on one of user's streams.
+func (t *presenceTracker) touch(user string) {
+	t.mu.Lock()
+	u := t.user(user)
+	var changed *pb.UserPresence
+	if u.streams > 0 {
+		changed = t.activeLocked(user, u)
+	}
+	t.mu.Unlock()
+
+	if changed != nil {
+		t.onChange(changed)
+	}
+}
+
+// activeLocked marks user online and restarts their away timer. It returns
+// the new presence if that changed the status. Must be called with t.mu held.
+func (t *presenceTracker) activeLocked(user string, u *userPresence) *pb.UserPresence {
+	u.lastSeen = time.Now()
+	if u.away != nil {
+		u.away.Reset(t.awayAfter)
+	} else {
+		u.away = time.AfterFunc(t.awayAfter, func() { t.goAway(user) })
+	}
+	if u.status == presenceOnline {
+		return nil
+	}
+	u.status = presenceOnline
+	return u.proto(user)
+}
+
+func (t *presenceTracker) goAway(user string) {
+	t.mu.Lock()
+	u := t.users[user]
+	var changed *pb.UserPresence
+	if u != nil && u.streams > 0 && u.status == presenceOnline && time.Since(u.lastSeen) >= t.awayAfter {
+		u.status = presenceAway
+		changed = u.proto(user)
+	}
+	t.mu.Unlock()
+
+	if changed != nil {
+		t.onChange(changed)
+	}
+}
+
+// setRemote records the presence of a user as another node saw it.
+func (t *presenceTracker) setRemote(p *pb.UserPresence) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.user(p.User)
+	u.remote = p
+	if p.Status == presenceOffline {
+		t.forgetLocked(p.User, u)
+	}
+}
+
+// forgetLocked drops user lastSeenFor from now, unless they are back by
+// then. Must be called with t.mu held.
+func (t *presenceTracker) forgetLocked(user string, u *userPresence) {
+	if u.forget != nil {
+		u.forget.Reset(t.lastSeenFor)
+		return
+	}
+	u.forget = time.AfterFunc(t.lastSeenFor, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if u := t.users[user]; u != nil && u.streams == 0 && (u.remote == nil || u.remote.Status == presenceOffline) {
+			delete(t.users, user)
+		}
+	})
+}
+
+// get returns user's presence, preferring what this node sees over what
+// other nodes sent.
+func (t *presenceTracker) get(user string) *pb.UserPresence {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, ok := t.users[user]
+	if !ok {
+		return &pb.UserPresence{User: user, Status: presenceOffline}
+	}
+	p := u.proto(user)
+	if u.streams == 0 && u.remote != nil {
+		if u.remote.Status != presenceOffline || u.remote.LastSeen > p.LastSeen {
+			p.Status = u.remote.Status
+			p.LastSeen = u.remote.LastSeen
+		}
+	}
+	return p
+}
+
+func (u *userPresence) proto(user string) *pb.UserPresence {
+	p := &pb.UserPresence{User: user, Status: u.status}
+	if !u.lastSeen.IsZero() {
+		p.LastSeen = u.lastSeen.UnixMilli()
+	}
+	return p
+}
+
+// announcePresence tells every room the user is in about their new status.
+func (s *chatServer) announcePresence(p *pb.UserPresence) {
+	for _, room := range s.directory.localRoomsOf(p.User) {
+		s.announce(&pb.Update{
+			Update:   p.User + " is " + p.Status,
+			Sender:   p.User,
+			Room:     room,
+			Type:     updatePresence,
+			Presence: p,
+		})
+	}
+}
+
+// setTypingLocked starts or stops showing user as typing in the room. Started
+// typing runs out after typingTimeout unless refreshed. Must be called with
+// r.mu held, and released with unlock.
+func (s *chatServer) setTypingLocked(r *chatRoom, user string, typing bool) {
+	timer, wasTyping := r.typing[user]
+	if typing {
+		if wasTyping {
+			timer.Reset(typingTimeout)
+			return
+		}
+		r.typing[user] = time.AfterFunc(typingTimeout, func() {
+			r.mu.Lock()
+			defer s.unlock(r)
+			if r.typing[user] != nil {
+				s.setTypingLocked(r, user, false)
+			}
+		})
+	} else {
+		if !wasTyping {
+			return
+		}
+		timer.Stop()
+		delete(r.typing, user)
+	}
+
+	text := user + " stopped typing"
+	if typing {
+		text = user + " is typing…"
+	}
+	s.announceLocked(r, &pb.Update{
+		Update: text,
+		Sender: user,
+		Room:   r.name,
+		Type:   updateTyping,
+		Typing: typing,
+	})
+}
+
+func (s *chatServer) setTyping(r *chatRoom, user string, typing bool) {
+	r.mu.Lock()
+	defer s.unlock(r)
+	if r.sessions[user] != nil {
+		s.setTypingLocked(r, user, typing)
+	}
+}
+
+func (s *chatServer) GetPresence(ctx context.Context, req *pb.PresenceRequest) (*pb.PresenceResponse, error) {
+	if len(req.Users) > maxPresenceUsers {
+		return nil, status.Errorf(codes.InvalidArgument, "ask about at most %d users at a time", maxPresenceUsers)
+	}
+	users := req.Users
+	if req.Room != "" {
+		if err := s.checkReadable(req.Room, userFromContext(ctx)); err != nil {
+			return nil, err
+		}
+		if r := s.rooms.get(req.Room); r != nil {
+			r.mu.Lock()
+			for user := range r.sessions {
+				users = append(users, user)
+			}
+			for user := range r.remote {
+				users = append(users, user)
+			}
+			r.mu.Unlock()
+		}
+	}
+	if len(users) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "name a room or some users")
+	}
+
+	sort.Strings(users)
+	resp := &pb.PresenceResponse{}
+	for i, user := range users {
+		if i > 0 && users[i-1] == user {
+			continue
+		}
+		resp.Users = append(resp.Users, s.presence.get(user))
+	}
+	return resp, nil
+}
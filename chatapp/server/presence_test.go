@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPresenceForgetsOfflineUsers(t *testing.T) {
+	tracker := newPresenceTracker(func(*pb.UserPresence) {})
+	tracker.lastSeenFor = 50 * time.Millisecond
+	known := func(user string) bool {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		return tracker.users[user] != nil
+	}
+
+	tracker.connect("alice")
+	tracker.disconnect("alice")
+	if p := tracker.get("alice"); p.Status != presenceOffline || p.LastSeen == 0 {
+		t.Fatalf("alice just went offline: %+v", p)
+	}
+	tracker.setRemote(&pb.UserPresence{User: "bob", Status: presenceOffline, LastSeen: time.Now().UnixMilli()})
+	tracker.setRemote(&pb.UserPresence{User: "carol", Status: presenceOnline})
+	// dave went offline and came back before they were forgotten
+	tracker.connect("dave")
+	tracker.disconnect("dave")
+	tracker.connect("dave")
+
+	eventually(t, "the offline users to be forgotten", func() bool {
+		return !known("alice") && !known("bob")
+	})
+	time.Sleep(2 * tracker.lastSeenFor)
+	if !known("carol") || !known("dave") {
+		t.Fatal("forgot a user who is online")
+	}
+	if p := tracker.get("alice"); p.Status != presenceOffline || p.LastSeen != 0 {
+		t.Fatalf("alice after being forgotten: %+v", p)
+	}
+}
+
+func TestPresenceRequestSize(t *testing.T) {
+	s := &chatServer{presence: newPresenceTracker(func(*pb.UserPresence) {})}
+	users := make([]string, maxPresenceUsers+1)
+	for i := range users {
+		users[i] = fmt.Sprint("user", i)
+	}
+	_, err := s.GetPresence(context.Background(), &pb.PresenceRequest{Users: users})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("asking about %d users: %v", len(users), err)
+	}
+	resp, err := s.GetPresence(context.Background(), &pb.PresenceRequest{Users: users[:maxPresenceUsers]})
+	if err != nil || len(resp.Users) != maxPresenceUsers {
+		t.Fatalf("asking about %d users: %v", maxPresenceUsers, err)
+	}
+}
+
+func TestTypingAndPresence(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	node.cs.presence.awayAfter = 200 * time.Millisecond
+	alice := node.chatIn(t, "alice", "lobby")
+	bob := node.chatIn(t, "bob", "lobby")
+
+	if err := bob.Send(&pb.ChatRoomMessage{Type: msgTypeTyping, Room: "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	if update := recvUpdate(t, alice, updateTyping, "lobby"); update.Sender != "bob" || !update.Typing {
+		t.Fatalf("alice was told %v", update)
+	}
+	// sending the message is the end of typing it
+	if err := bob.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if update := recvUpdate(t, alice, updateTyping, "lobby"); update.Sender != "bob" || update.Typing {
+		t.Fatalf("alice was told %v", update)
+	}
+
+	statusOf := func(user string) *pb.UserPresence {
+		resp, err := node.client.GetPresence(node.as(t, "alice"), &pb.PresenceRequest{Users: []string{user}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Users[0]
+	}
+	resp, err := node.client.GetPresence(node.as(t, "alice"), &pb.PresenceRequest{Room: "lobby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Users) != 2 || resp.Users[0].User != "alice" || resp.Users[1].User != "bob" {
+		t.Fatalf("the presence of the room is %v", resp.Users)
+	}
+
+	// bob goes quiet and shows as away, alice keeps talking
+	for {
+		update := recvUpdate(t, alice, updatePresence, "lobby")
+		if update.Presence.GetUser() == "bob" && update.Presence.GetStatus() == presenceAway {
+			break
+		}
+		if err := alice.Send(&pb.ChatRoomMessage{Type: msgTypeTyping, Room: "lobby"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p := statusOf("bob"); p.Status != presenceAway || p.LastSeen == 0 {
+		t.Fatalf("bob is %v", p)
+	}
+
+	carol, err := node.client.RoomChat(node.as(t, "carol"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "carol to be online", func() bool { return statusOf("carol").Status == presenceOnline })
+	carol.CloseSend()
+	eventually(t, "carol to be offline", func() bool {
+		p := statusOf("carol")
+		return p.Status == presenceOffline && p.LastSeen > 0
+	})
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// boxOverhead is what NaCl box adds to an encrypted private message.
+const boxOverhead = 16
+
+type bucketConfig struct {
+	Rate  float64 `json:"rate"` // tokens added per second, 0 means no limit
+	Burst int     `json:"burst"`
+}
+
+// duration reads "30s" and the like from JSON and flags.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
+
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// limitsConfig is the -limits file. Anything left out keeps its default.
+type limitsConfig struct {
+	RPC      bucketConfig `json:"rpc"`      // calls and new streams per user, or per address before logging in
+	Messages bucketConfig `json:"messages"` // room messages, edits and reactions per user
+	Rooms    bucketConfig `json:"rooms"`    // messages per room from everyone together
+	Private  bucketConfig `json:"private"`  // private messages per user
+	Typing   bucketConfig `json:"typing"`   // typing notices per user
+	Acks     bucketConfig `json:"acks"`     // delivery and read receipts per user
+
+	MaxMessageLength int      `json:"max_message_length"` // bytes, 0 means no limit
+	DuplicateWindow  duration `json:"duplicate_window"`   // how long the same text can't be sent again to the same place
+}
+
+func defaultLimits() limitsConfig {
+	return limitsConfig{
+		RPC:              bucketConfig{Rate: 10, Burst: 30},
+		Messages:         bucketConfig{Rate: 2, Burst: 10},
+		Rooms:            bucketConfig{Rate: 20, Burst: 50},
+		Private:          bucketConfig{Rate: 1, Burst: 5},
+		Typing:           bucketConfig{Rate: 1, Burst: 5},
+		Acks:             bucketConfig{Rate: 50, Burst: 200},
+		MaxMessageLength: 4000,
+		DuplicateWindow:  duration(30 * time.Second),
+	}
+}
+
+func loadLimits(path string) (limitsConfig, error) {
+	limits := defaultLimits()
+	if path == "" {
+		return limits, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return limits, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&limits); err != nil {
+		return limits, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return limits, nil
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucketSet is one token bucket per key, all with the same config. Buckets
+// that filled up again are forgotten now and then, a new one starts full.
+type bucketSet struct {
+	config bucketConfig
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time
+}
+
+func newBucketSet(config bucketConfig) *bucketSet {
+	return &bucketSet{config: config, buckets: make(map[string]*tokenBucket)}
+}
+
+// take uses up a token of key's bucket, or says how long until there is one.
+func (b *bucketSet) take(key string, now time.Time) (bool, time.Duration) {
+	if b.config.Rate <= 0 {
+		return true, 0
+	}
+	burst := float64(max(b.config.Burst, 1))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Sub(b.pruned) > time.Minute {
+		for k, bucket := range b.buckets {
+			if bucket.tokens+now.Sub(bucket.last).Seconds()*b.config.Rate >= burst {
+				delete(b.buckets, k)
+			}
+		}
+		b.pruned = now
+	}
+
+	bucket := b.buckets[key]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		b.buckets[key] = bucket
+	}
+	bucket.tokens = min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*b.config.Rate)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := (1 - bucket.tokens) / b.config.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+type sentRecently struct {
+	sum [sha256.Size]byte
+	at  time.Time
+}
+
+// rateLimiter enforces limitsConfig. Limits are per server: with several
+// servers sharing rooms each one counts what goes through it.
+type rateLimiter struct {
+	config   limitsConfig
+	rpc      *bucketSet
+	messages *bucketSet
+	rooms    *bucketSet
+	private  *bucketSet
+	typing   *bucketSet
+	acks     *bucketSet
+
+	// gateways are the hosts trusted to say which address they call for,
+	// see callerKey
+	gateways map[string]bool
+
+	mu   sync.Mutex
+	last map[string]sentRecently // user and where to -> what they last sent there
+}
+
+func newRateLimiter(config limitsConfig) *rateLimiter {
+	return &rateLimiter{
+		config:   config,
+		rpc:      newBucketSet(config.RPC),
+		messages: newBucketSet(config.Messages),
+		rooms:    newBucketSet(config.Rooms),
+		private:  newBucketSet(config.Private),
+		typing:   newBucketSet(config.Typing),
+		acks:     newBucketSet(config.Acks),
+		last:     make(map[string]sentRecently),
+	}
+}
+
+// trustGateways has calls from hosts count against the address they
+// forward in x-forwarded-for rather than their own.
+func (l *rateLimiter) trustGateways(hosts []string) {
+	l.gateways = make(map[string]bool)
+	for _, host := range hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			l.gateways[host] = true
+		}
+	}
+}
+
+func rateLimited(wait time.Duration) error {
+	return status.Errorf(codes.ResourceExhausted, "slow down, try again in %s", wait.Round(100*time.Millisecond))
+}
+
+// retryAfter is the retry-after metadata for wait, in whole seconds like the
+// HTTP header.
+func retryAfter(wait time.Duration) metadata.MD {
+	return metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// checkLength rejects content longer than the configured maximum.
+func (l *rateLimiter) checkLength(n int) error {
+	if max := l.config.MaxMessageLength; max > 0 && n > max {
+		return status.Errorf(codes.InvalidArgument, "messages can be at most %d bytes", max)
+	}
+	return nil
+}
+
+// duplicate reports whether user sent content to the same place within the
+// duplicate window, and remembers it otherwise.
+func (l *rateLimiter) duplicate(user, to, content string, now time.Time) bool {
+	window := time.Duration(l.config.DuplicateWindow)
+	if window <= 0 || content == "" {
+		return false
+	}
+	key := user + "\x00" + to
+	sum := sha256.Sum256([]byte(content))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev, ok := l.last[key]
+	if ok && prev.sum == sum && now.Sub(prev.at) < window {
+		return true
+	}
+	if len(l.last) > 10000 {
+		for k, sent := range l.last {
+			if now.Sub(sent.at) >= window {
+				delete(l.last, k)
+			}
+		}
+	}
+	l.last[key] = sentRecently{sum: sum, at: now}
+	return false
+}
+
+// checkRoomMessage decides whether user may send msg to room. When they are
+// sending too fast it also says how long to wait.
+func (l *rateLimiter) checkRoomMessage(user, room string, msg *pb.ChatRoomMessage) (time.Duration, error) {
+	if err := l.checkLength(len(msg.Content)); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if ok, wait := l.messages.take(user, now); !ok {
+		return wait, rateLimited(wait)
+	}
+	if ok, wait := l.rooms.take(room, now); !ok {
+		return wait, status.Errorf(codes.ResourceExhausted, "%s is busy, try again in %s", room, wait.Round(100*time.Millisecond))
+	}
+	// bots repeat themselves, the same alert can fire twice
+	isMessage := msg.Type == "" || msg.Type == msgTypeMessage
+	if isMessage && msg.Attachment == nil && !msg.Bot && l.duplicate(user, "room/"+room, msg.Content, now) {
+		return 0, status.Error(codes.InvalidArgument, "you just sent that")
+	}
+	return 0, nil
+}
+
+// checkPrivate is checkRoomMessage for private messages. Encrypted ones
+// can't be compared, every one looks different.
+func (l *rateLimiter) checkPrivate(msg *pb.PrivateMessage) (time.Duration, error) {
+	n := len(msg.Content)
+	if msg.Encrypted != nil {
+		n = len(msg.Encrypted.Ciphertext) - boxOverhead
+	}
+	if err := l.checkLength(n); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if ok, wait := l.private.take(msg.Sender, now); !ok {
+		return wait, rateLimited(wait)
+	}
+	if l.duplicate(msg.Sender, "dm/"+msg.Recipient, msg.Content, now) {
+		return 0, status.Error(codes.InvalidArgument, "you just sent that")
+	}
+	return 0, nil
+}
+
+// allowSignal reports whether user may send a typing notice or a receipt.
+// Those get no receipt of their own, one over the limit is just dropped.
+func (l *rateLimiter) allowSignal(user, msgType string) bool {
+	bucket := l.acks
+	if msgType == msgTypeTyping {
+		bucket = l.typing
+	}
+	ok, _ := bucket.take(user, time.Now())
+	return ok
+}
+
+// forwardedFor is the metadata a gateway passes its client's address in.
+const forwardedFor = "x-forwarded-for"
+
+// callerKey is who a call counts against: the user once they are logged in,
+// their address before. Behind a trusted gateway that is the address the
+// gateway forwards, or everyone logging in through it would share a bucket.
+func (l *rateLimiter) callerKey(ctx context.Context) string {
+	if user := userFromContext(ctx); user != "" {
+		return user
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	if l.gateways[host] {
+		md, _ := metadata.FromIncomingContext(ctx)
+		// the last one is what the gateway added, anything before came from its client
+		if forwarded := md.Get(forwardedFor); len(forwarded) > 0 {
+			if client := strings.TrimSpace(forwarded[len(forwarded)-1]); client != "" {
+				return "addr/" + client
+			}
+		}
+	}
+	return "addr/" + host
+}
+
+// limited reports whether method counts against the rpc limit. Only the
+// chat service does, the other servers must get through.
+func limited(method string) bool {
+	return strings.HasPrefix(method, "/chat.Chat/")
+}
+
+// UnaryInterceptor limits how fast each caller makes calls. It runs after
+// UnaryAuthInterceptor, which tells it who the caller is.
+func (l *rateLimiter) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if limited(info.FullMethod) {
+		if ok, wait := l.rpc.take(l.callerKey(ctx), time.Now()); !ok {
+			grpc.SetTrailer(ctx, retryAfter(wait))
+			return nil, rateLimited(wait)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor limits how fast each caller opens streams. What comes
+// over RoomChat is checked message by message, see chatStream.handle.
+func (l *rateLimiter) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if limited(info.FullMethod) {
+		if ok, wait := l.rpc.take(l.callerKey(ss.Context()), time.Now()); !ok {
+			ss.SetTrailer(retryAfter(wait))
+			return rateLimited(wait)
+		}
+	}
+	return handler(srv, ss)
+}
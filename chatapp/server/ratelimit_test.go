@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestSignalLimits(t *testing.T) {
+	limits := defaultLimits()
+	limits.Typing = bucketConfig{Rate: 0.001, Burst: 3}
+	limits.Acks = bucketConfig{Rate: 0.001, Burst: 5}
+	l := newRateLimiter(limits)
+
+	for i := 0; i < 3; i++ {
+		if !l.allowSignal("alice", msgTypeTyping) {
+			t.Fatalf("typing notice %d was dropped", i+1)
+		}
+	}
+	if l.allowSignal("alice", msgTypeTyping) {
+		t.Fatal("a fourth typing notice got through a burst of 3")
+	}
+	// typing has a bucket of its own, it doesn't use up receipts or messages
+	if !l.allowSignal("alice", msgTypeAck) {
+		t.Fatal("a receipt was dropped after too many typing notices")
+	}
+	if ok, _ := l.messages.take("alice", time.Now()); !ok {
+		t.Fatal("a message was limited after too many typing notices")
+	}
+	if !l.allowSignal("bob", msgTypeTyping) {
+		t.Fatal("bob is limited by alice's typing")
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	b := newBucketSet(bucketConfig{Rate: 2, Burst: 3})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.take("alice", now); !ok {
+			t.Fatalf("token %d of a burst of 3 was refused", i+1)
+		}
+	}
+	ok, wait := b.take("alice", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("an empty bucket at 2/s: got %v, wait %v, want a wait of 500ms", ok, wait)
+	}
+
+	// half a token back after a quarter second, a whole one after half
+	if ok, wait := b.take("alice", now.Add(250*time.Millisecond)); ok || wait != 250*time.Millisecond {
+		t.Fatalf("after 250ms: got %v, wait %v", ok, wait)
+	}
+	if ok, _ := b.take("alice", now.Add(500*time.Millisecond)); !ok {
+		t.Fatal("no token after 500ms at 2/s")
+	}
+
+	// a long pause fills it up to the burst, no further
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.take("alice", later); !ok {
+			t.Fatalf("token %d after a long pause was refused", i+1)
+		}
+	}
+	if ok, _ := b.take("alice", later); ok {
+		t.Fatal("the bucket filled up past its burst")
+	}
+
+	if ok, _ := newBucketSet(bucketConfig{}).take("alice", now); !ok {
+		t.Fatal("a bucket with no rate limited")
+	}
+}
+
+// callStream stands in for the transport under a unary call, which is
+// where grpc.SetTrailer puts the trailer.
+type callStream struct {
+	trailer metadata.MD
+}
+
+func (s *callStream) Method() string                  { return "/chat.Chat/JoinRoom" }
+func (s *callStream) SetHeader(metadata.MD) error     { return nil }
+func (s *callStream) SendHeader(metadata.MD) error    { return nil }
+func (s *callStream) SetTrailer(md metadata.MD) error { s.trailer = md; return nil }
+
+type trailerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	trailer metadata.MD
+}
+
+func (s *trailerStream) Context() context.Context  { return s.ctx }
+func (s *trailerStream) SetTrailer(md metadata.MD) { s.trailer = md }
+
+func TestRetryAfter(t *testing.T) {
+	limits := defaultLimits()
+	limits.RPC = bucketConfig{Rate: 0.25, Burst: 1}
+	l := newRateLimiter(limits)
+
+	call := &callStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), call)
+	ctx = context.WithValue(ctx, claimsKey{}, sessionClaims{Username: "alice"})
+	unary := &grpc.UnaryServerInfo{FullMethod: "/chat.Chat/JoinRoom"}
+	handler := func(context.Context, any) (any, error) { return "joined", nil }
+
+	if resp, err := l.UnaryInterceptor(ctx, nil, unary, handler); err != nil || resp != "joined" {
+		t.Fatalf("the first call: %v, %v", resp, err)
+	}
+	_, err := l.UnaryInterceptor(ctx, nil, unary, handler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("the second call: got %v, want ResourceExhausted", err)
+	}
+	// 4s at a quarter token a second, in whole seconds like the HTTP header
+	if got := call.trailer.Get("retry-after"); len(got) != 1 || got[0] != "4" {
+		t.Fatalf("retry-after is %v, want 4", got)
+	}
+
+	// the other servers sharing rooms aren't limited
+	peerCall := &grpc.UnaryServerInfo{FullMethod: "/chat.Cluster/Publish"}
+	if _, err := l.UnaryInterceptor(ctx, nil, peerCall, handler); err != nil {
+		t.Fatalf("a cluster call was limited: %v", err)
+	}
+
+	stream := &trailerStream{ctx: ctx}
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/chat.Chat/RoomChat"}
+	err = l.StreamInterceptor(nil, stream, streamInfo, func(any, grpc.ServerStream) error { return nil })
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("opening a stream: got %v, want ResourceExhausted", err)
+	}
+	if got := stream.trailer.Get("retry-after"); len(got) != 1 || got[0] != "4" {
+		t.Fatalf("retry-after on the stream is %v, want 4", got)
+	}
+}
+
+func TestGatewayForwardsAddress(t *testing.T) {
+	l := newRateLimiter(defaultLimits())
+	l.trustGateways([]string{"10.0.0.1", " "})
+
+	from := func(addr, forwarded string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 40000}})
+		if forwarded != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", forwarded))
+		}
+		return ctx
+	}
+	for what, test := range map[string]struct {
+		ctx  context.Context
+		want string
+	}{
+		"through the gateway":             {from("10.0.0.1", "203.0.113.5"), "addr/203.0.113.5"},
+		"the gateway itself":              {from("10.0.0.1", ""), "addr/10.0.0.1"},
+		"anyone else claiming an address": {from("10.0.0.2", "203.0.113.5"), "addr/10.0.0.2"},
+		"logged in":                       {context.WithValue(from("10.0.0.1", "203.0.113.5"), claimsKey{}, sessionClaims{Username: "alice"}), "alice"},
+	} {
+		if got := l.callerKey(test.ctx); got != test.want {
+			t.Errorf("%s: counted against %q, want %q", what, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	roleOwner     = "owner"
+	roleModerator = "moderator"
+	roleMember    = "member"
+)
+
+const (
+	visibilityPublic   = "public"
+	visibilityUnlisted = "unlisted"
+	visibilityPassword = "password"
+	visibilityInvite   = "invite"
+)
+
+type roomInvite struct {
+	Invitee   string    `json:"invitee,omitempty"` // empty means anyone holding the code
+	CreatedBy string    `json:"created_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// roomInfo is the persisted part of a room. A zero time in Bans or Mutes
+// means the restriction lasts until it is lifted. Allowed holds the users who
+// got past the password or an invite, so they can come back without one.
+//
+// The password hash and webhook secrets are left out of its JSON, see
+// roomSecrets.
+type roomInfo struct {
+	Owner        string                 `json:"owner"`
+	Topic        string                 `json:"topic,omitempty"`
+	Roles        map[string]string      `json:"roles,omitempty"`
+	Bans         map[string]time.Time   `json:"bans,omitempty"`
+	Mutes        map[string]time.Time   `json:"mutes,omitempty"`
+	Visibility   string                 `json:"visibility,omitempty"`
+	PasswordHash []byte                 `json:"-"`
+	Invites      map[string]roomInvite  `json:"invites,omitempty"`
+	Allowed      map[string]bool        `json:"allowed,omitempty"`
+	Bots         map[string]botInfo     `json:"bots,omitempty"`     // by name
+	Webhooks     map[string]webhookInfo `json:"webhooks,omitempty"` // by id
+	Created      time.Time              `json:"created"`
+}
+
+func (r *roomInfo) role(user string) string {
+	if user == r.Owner {
+		return roleOwner
+	}
+	if role, ok := r.Roles[user]; ok {
+		return role
+	}
+	return roleMember
+}
+
+func (r *roomInfo) canModerate(user string) bool {
+	role := r.role(user)
+	return role == roleOwner || role == roleModerator
+}
+
+// outranks reports whether actor may moderate target: owners can act on
+// everyone, moderators only on plain members.
+func (r *roomInfo) outranks(actor, target string) bool {
+	switch r.role(actor) {
+	case roleOwner:
+		return target != r.Owner
+	case roleModerator:
+		return r.role(target) == roleMember
+	}
+	return false
+}
+
+func restrictedUntil(restrictions map[string]time.Time, user string) (time.Time, bool) {
+	until, ok := restrictions[user]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !until.IsZero() && time.Now().After(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+func (r *roomInfo) bannedUntil(user string) (time.Time, bool) {
+	return restrictedUntil(r.Bans, user)
+}
+
+func (r *roomInfo) mutedUntil(user string) (time.Time, bool) {
+	return restrictedUntil(r.Mutes, user)
+}
+
+func (r *roomInfo) pruneExpired() {
+	now := time.Now()
+	for user, until := range r.Bans {
+		if !until.IsZero() && now.After(until) {
+			delete(r.Bans, user)
+		}
+	}
+	for user, until := range r.Mutes {
+		if !until.IsZero() && now.After(until) {
+			delete(r.Mutes, user)
+		}
+	}
+	for code, invite := range r.Invites {
+		if now.After(invite.ExpiresAt) {
+			delete(r.Invites, code)
+		}
+	}
+}
+
+func (r *roomInfo) clone() roomInfo {
+	c := *r
+	c.Roles = make(map[string]string, len(r.Roles))
+	for k, v := range r.Roles {
+		c.Roles[k] = v
+	}
+	c.Bans = make(map[string]time.Time, len(r.Bans))
+	for k, v := range r.Bans {
+		c.Bans[k] = v
+	}
+	c.Mutes = make(map[string]time.Time, len(r.Mutes))
+	for k, v := range r.Mutes {
+		c.Mutes[k] = v
+	}
+	c.Invites = make(map[string]roomInvite, len(r.Invites))
+	for k, v := range r.Invites {
+		c.Invites[k] = v
+	}
+	c.Allowed = make(map[string]bool, len(r.Allowed))
+	for k, v := range r.Allowed {
+		c.Allowed[k] = v
+	}
+	c.Bots = make(map[string]botInfo, len(r.Bots))
+	for k, v := range r.Bots {
+		c.Bots[k] = v
+	}
+	c.Webhooks = make(map[string]webhookInfo, len(r.Webhooks))
+	for k, v := range r.Webhooks {
+		c.Webhooks[k] = v
+	}
+	return c
+}
+
+func describeUntil(until time.Time) string {
+	if until.IsZero() {
+		return "until lifted"
+	}
+	return "until " + until.Format(time.RFC3339)
+}
+
+// roomSecrets are the settings of a room nobody but the servers may see.
+// rooms.json keeps them next to the rest, and the other nodes get them
+// sealed with a key made from token.key.
+type roomSecrets struct {
+	PasswordHash   []byte            `json:"password_hash,omitempty"`
+	WebhookSecrets map[string]string `json:"webhook_secrets,omitempty"` // by webhook id
+}
+
+func (r *roomInfo) secrets() roomSecrets {
+	secrets := roomSecrets{PasswordHash: r.PasswordHash}
+	for id, hook := range r.Webhooks {
+		if secrets.WebhookSecrets == nil {
+			secrets.WebhookSecrets = make(map[string]string)
+		}
+		secrets.WebhookSecrets[id] = hook.Secret
+	}
+	return secrets
+}
+
+func (r *roomInfo) setSecrets(secrets roomSecrets) {
+	r.PasswordHash = secrets.PasswordHash
+	for id, hook := range r.Webhooks {
+		hook.Secret = secrets.WebhookSecrets[id]
+		r.Webhooks[id] = hook
+	}
+}
+
+// storedRoom is a room as rooms.json has it.
+type storedRoom struct {
+	roomInfo
+	roomSecrets
+}
+
+// sharedRoom is a room as the other nodes are sent it.
+type sharedRoom struct {
+	roomInfo
+	Sealed []byte `json:"sealed"` // its roomSecrets
+}
+
+// secretsCipher is what room secrets and password hashes are sealed with on
+// their way to the other nodes, which all have the same key.
+func secretsCipher(key []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("room secrets"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain for the other nodes. aad says what it is, so it can't
+// be passed off as anything else.
+func seal(key, plain []byte, aad string) ([]byte, error) {
+	aead, err := secretsCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, []byte(aad)), nil
+}
+
+// unseal opens what seal made on another node.
+func unseal(key, sealed []byte, aad string) ([]byte, error) {
+	aead, err := secretsCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(aad))
+}
+
+// sealRoom encodes info for the other nodes.
+func sealRoom(key []byte, room string, info roomInfo) ([]byte, error) {
+	plain, err := json.Marshal(info.secrets())
+	if err != nil {
+		return nil, err
+	}
+	// sealed for this room only, so they can't be passed off as another's
+	sealed, err := seal(key, plain, room)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sharedRoom{roomInfo: info, Sealed: sealed})
+}
+
+// openRoom decodes what sealRoom made on another node.
+func openRoom(key []byte, room string, data []byte) (roomInfo, error) {
+	var shared sharedRoom
+	if err := json.Unmarshal(data, &shared); err != nil {
+		return roomInfo{}, err
+	}
+	if len(shared.Sealed) == 0 {
+		return roomInfo{}, errors.New("room secrets missing")
+	}
+	plain, err := unseal(key, shared.Sealed, room)
+	if err != nil {
+		return roomInfo{}, fmt.Errorf("opening room secrets: %w", err)
+	}
+	var secrets roomSecrets
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return roomInfo{}, err
+	}
+	info := shared.roomInfo
+	info.setSecrets(secrets)
+	return info, nil
+}
+
+var errRoomNotFound = errors.New("room does not exist")
+
+// roomStore keeps room metadata in a JSON file next to users.json. Callers
+// get copies, changes go through Update so they are always saved.
+type roomStore struct {
+	mu    sync.Mutex
+	path  string
+	rooms map[string]*roomInfo
+}
+
+func newRoomStore(path string) (*roomStore, error) {
+	store := &roomStore{
+		path:  path,
+		rooms: make(map[string]*roomInfo),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored map[string]*storedRoom
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for name, room := range stored {
+		info := room.roomInfo
+		info.setSecrets(room.roomSecrets)
+		store.rooms[name] = &info
+	}
+	return store, nil
+}
+
+// Ensure returns the room, creating it from init if it is new. The bool
+// reports whether it was created.
+func (r *roomStore) Ensure(room string, init func() roomInfo) (roomInfo, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, ok := r.rooms[room]; ok {
+		return info.clone(), false, nil
+	}
+	created := init()
+	info := &created
+	r.rooms[room] = info
+	if err := r.save(); err != nil {
+		delete(r.rooms, room)
+		return roomInfo{}, false, err
+	}
+	return info.clone(), true, nil
+}
+
+func (r *roomStore) Get(room string) (roomInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.rooms[room]
+	if !ok {
+		return roomInfo{}, false
+	}
+	return info.clone(), true
+}
+
+// Update applies fn to the room and saves the result. Nothing is saved when
+// fn returns an error.
+func (r *roomStore) Update(room string, fn func(info *roomInfo) error) (roomInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.rooms[room]
+	if !ok {
+		return roomInfo{}, errRoomNotFound
+	}
+	updated := info.clone()
+	updated.pruneExpired()
+	if err := fn(&updated); err != nil {
+		return roomInfo{}, err
+	}
+	r.rooms[room] = &updated
+	if err := r.save(); err != nil {
+		r.rooms[room] = info
+		return roomInfo{}, err
+	}
+	return updated.clone(), nil
+}
+
+// Put replaces the room's settings with ones changed on another node.
+func (r *roomStore) Put(room string, info roomInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, existed := r.rooms[room]
+	updated := info.clone()
+	r.rooms[room] = &updated
+	if err := r.save(); err != nil {
+		if existed {
+			r.rooms[room] = old
+		} else {
+			delete(r.rooms, room)
+		}
+		return err
+	}
+	return nil
+}
+
+// Visible returns the rooms that show up in user's room list.
+func (r *roomStore) Visible(user string) map[string]roomInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	visible := make(map[string]roomInfo)
+	for name, info := range r.rooms {
+		if info.listedFor(user) {
+			visible[name] = info.clone()
+		}
+	}
+	return visible
+}
+
+// PostsAs reports whether a bot or a webhook in any room posts as name,
+// which keeps users from registering it.
+func (r *roomStore) PostsAs(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, info := range r.rooms {
+		if _, ok := info.Bots[name]; ok || info.webhookNamed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// save must be called with r.mu held. The file has the room secrets, so
+// only the server may read it.
+func (r *roomStore) save() error {
+	stored := make(map[string]storedRoom, len(r.rooms))
+	for name, info := range r.rooms {
+		stored[name] = storedRoom{roomInfo: *info, roomSecrets: info.secrets()}
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.path, data, 0600)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRoomSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rooms.json")
+	rooms, err := newRoomStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := []byte("$2a$10$not-really-a-bcrypt-hash")
+	secret := "5ec7e75ec7e75ec7e75ec7e75ec7e75e"
+	_, _, err = rooms.Ensure("ops", func() roomInfo {
+		return roomInfo{
+			Owner:        "alice",
+			Visibility:   visibilityPassword,
+			PasswordHash: hash,
+			Webhooks:     map[string]webhookInfo{"hook1": {Name: "deploy", URL: "https://ci.example.com", Secret: secret}},
+			Created:      time.Now().UTC(),
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0o600 {
+		t.Errorf("rooms.json is %o, want 600", perm)
+	}
+	reopened, err := newRoomStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := reopened.Get("ops")
+	if !bytes.Equal(info.PasswordHash, hash) || info.Webhooks["hook1"].Secret != secret {
+		t.Fatalf("secrets didn't survive a restart: %q, %+v", info.PasswordHash, info.Webhooks)
+	}
+
+	key := bytes.Repeat([]byte{7}, 32)
+	shared, err := sealRoom(key, "ops", info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range [][]byte{hash, []byte(secret), []byte(hex.EncodeToString(hash))} {
+		if bytes.Contains(shared, leak) {
+			t.Fatalf("the shared settings have a secret in the clear: %s", shared)
+		}
+	}
+	opened, err := openRoom(key, "ops", shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened.PasswordHash, hash) || opened.Webhooks["hook1"].Secret != secret || opened.Owner != "alice" {
+		t.Fatalf("opened %+v", opened)
+	}
+	if _, err := openRoom(key, "other", shared); err == nil {
+		t.Error("opened the settings of ops as another room's")
+	}
+	if _, err := openRoom(bytes.Repeat([]byte{8}, 32), "ops", shared); err == nil {
+		t.Error("opened the settings with another key")
+	}
+}
+
+func TestUnknownRoomHistory(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	alice := node.as(t, "alice")
+
+	for _, req := range []*pb.HistoryRequest{{Room: "nowhere"}, {Room: "nowhere", Since: 1}, {Room: "nowhere", Thread: 1}} {
+		if _, err := node.client.GetRoomHistory(alice, req); status.Code(err) != codes.NotFound {
+			t.Fatalf("history of a room nobody created %+v: %v", req, err)
+		}
+	}
+	if _, err := node.client.GetPresence(alice, &pb.PresenceRequest{Room: "nowhere"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("presence in a room nobody created: %v", err)
+	}
+	node.cs.history.mu.Lock()
+	_, opened := node.cs.history.logs["nowhere"]
+	node.cs.history.mu.Unlock()
+	if _, err := os.Stat(filepath.Join(node.cs.history.dir, "nowhere")); opened || !os.IsNotExist(err) {
+		t.Fatalf("asking about a room left a log behind: open %v, %v", opened, err)
+	}
+}
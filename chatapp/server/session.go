@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const resumeGracePeriod = 2 * time.Minute
+
+// roomSession is a user's membership in a room. It is created by JoinRoom and
+// outlives a dropped RoomChat stream by resumeGracePeriod, so a client on a
+// flaky connection can subscribe again and get what it missed from the history.
+type roomSession struct {
+	id     string
+	user   string
+	room   *chatRoom
+	att    *attachment // nil while no stream is attached
+	expiry *time.Timer
+}
+
+// attachment is the RoomChat stream subscription currently serving a session.
+type attachment struct {
+	messages *roomQueue
+	updates  *updateQueue
+	done     chan struct{} // closed when another subscription takes the session over
+}
+
+type (
+	roomQueue    = deliveryQueue[*pb.ChatRoomMessage]
+	privateQueue = deliveryQueue[*pb.PrivateMessage]
+	updateQueue  = deliveryQueue[*pb.Update]
+)
+
+func (s *chatServer) newRoomQueue(room, user string) *roomQueue {
+	return newDeliveryQueue[*pb.ChatRoomMessage](user+" in room "+room, s.delivery)
+}
+
+func (s *chatServer) newPrivateQueue(user string) *privateQueue {
+	return newDeliveryQueue[*pb.PrivateMessage](user+"'s private messages", s.delivery)
+}
+
+func (s *chatServer) newUpdateQueue(room, user string) *updateQueue {
+	return newDeliveryQueue[*pb.Update](user+"'s updates for room "+room, s.delivery)
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// attach binds a stream subscription to the user's session in the room named
+// in the "subscribe" message, and returns what it missed since resume.last_seq.
+func (s *chatServer) attach(user string, subscribe *pb.ChatRoomMessage) (*roomSession, *attachment, []*pb.ChatRoomMessage, error) {
+	room := subscribe.Room
+	resume := subscribe.Resume
+
+	r := s.rooms.get(room)
+	if r == nil {
+		return nil, nil, nil, status.Error(codes.FailedPrecondition, "join the room before subscribing to it")
+	}
+
+	// no message can be appended to the room while we pick the replay below
+	r.fanout.Lock()
+	defer r.fanout.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sess := r.sessions[user]
+	if sess == nil {
+		return nil, nil, nil, status.Error(codes.FailedPrecondition, "join the room before subscribing to it")
+	}
+	if resume != nil && resume.SessionId != "" && resume.SessionId != sess.id {
+		return nil, nil, nil, status.Error(codes.NotFound, "session expired, join the room again")
+	}
+
+	if sess.expiry != nil {
+		sess.expiry.Stop()
+		sess.expiry = nil
+	}
+	// A detached session keeps its queues, anything in the room queue that
+	// the replay also covers is skipped by the stream. A subscription being
+	// taken over may still be reading from them, so the new one starts fresh.
+	messages, updates := r.members[user], r.updates[user]
+	if sess.att != nil {
+		close(sess.att.done)
+		messages, updates = nil, nil
+	}
+	if messages == nil || messages.Closed() {
+		messages = s.newRoomQueue(room, user)
+		r.members[user] = messages
+	}
+	if updates == nil || updates.Closed() {
+		updates = s.newUpdateQueue(room, user)
+		r.updates[user] = updates
+	}
+
+	att := &attachment{
+		messages: messages,
+		updates:  updates,
+		done:     make(chan struct{}),
+	}
+	sess.att = att
+
+	// Read under the fan-out lock, so every message is either in the replay
+	// or will arrive on att.messages.
+	var replay []*pb.ChatRoomMessage
+	if resume != nil && resume.LastSeq > 0 {
+		msgs, _, err := s.history.Since(room, resume.LastSeq, 0)
+		if err != nil {
+			log.Printf("failed to replay history for %s in room %s: %v", user, room, err)
+		}
+		if len(msgs) > maxHistoryBatch {
+			msgs = msgs[len(msgs)-maxHistoryBatch:]
+		}
+		replay = msgs
+	}
+
+	return sess, att, replay, nil
+}
+
+// detach marks the session as disconnected and removes the member once the
+// grace period runs out without a new stream attaching.
+func (s *chatServer) detach(sess *roomSession, att *attachment) {
+	sess.room.mu.Lock()
+	defer s.unlock(sess.room)
+
+	if sess.att != att {
+		// already taken over or the user left
+		return
+	}
+	s.detachLocked(sess)
+}
+
+// detachLocked must be called with sess.room.mu held.
+func (s *chatServer) detachLocked(sess *roomSession) {
+	sess.att = nil
+	if sess.expiry != nil {
+		sess.expiry.Stop()
+	}
+	r := sess.room
+	sess.expiry = time.AfterFunc(resumeGracePeriod, func() {
+		r.mu.Lock()
+		defer s.unlock(r)
+
+		if r.sessions[sess.user] == sess && sess.att == nil {
+			log.Printf("session of %s in room %s expired", sess.user, r.name)
+			s.removeMember(r, sess.user, "left")
+		}
+	})
+}
+
+// leaveSession removes the member if sess is still the live session for it.
+func (s *chatServer) leaveSession(sess *roomSession, action string) {
+	r := sess.room
+	r.mu.Lock()
+	defer s.unlock(r)
+
+	if r.sessions[sess.user] == sess {
+		s.removeMember(r, sess.user, action)
+	}
+}
+
+// removeMember tells the room that user is gone and drops the membership.
+// Must be called with r.mu held, and released with unlock.
+func (s *chatServer) removeMember(r *chatRoom, user, action string) {
+	s.notifyUpdate(r, user, action)
+	s.dropMember(r, user)
+}
+
+// dropMember removes every trace of user from the room and tells the other
+// nodes they are gone. Must be called with r.mu held, and released with
+// unlock.
+func (s *chatServer) dropMember(r *chatRoom, user string) {
+	if sess := r.sessions[user]; sess != nil {
+		if sess.expiry != nil {
+			sess.expiry.Stop()
+		}
+		delete(r.sessions, user)
+		r.queueEvent(&pb.ClusterEvent{
+			Presence: &pb.Presence{User: user, Online: false},
+		})
+	}
+
+	if timer := r.typing[user]; timer != nil {
+		timer.Stop()
+		delete(r.typing, user)
+	}
+
+	if q := r.members[user]; q != nil {
+		q.Close()
+	}
+	delete(r.members, user)
+	s.directory.leave(user, r.name)
+
+	if updates := r.updates[user]; updates != nil {
+		updates.Close()
+	}
+	delete(r.updates, user)
+}
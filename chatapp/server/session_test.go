@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestResumeAfterDroppedStream(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	bob := node.chatIn(t, "bob", "lobby")
+	alice := node.as(t, "alice")
+
+	joined, err := node.client.JoinRoom(alice, &pb.JoinRequest{Room: "lobby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, drop := context.WithCancel(alice)
+	stream, err := node.client.RoomChat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "before"}); err != nil {
+		t.Fatal(err)
+	}
+	seen := recvMessage(t, stream, msgTypeMessage, "lobby").Seq
+
+	// the connection goes, alice stays in the room while bob carries on
+	drop()
+	eventually(t, "the stream to be detached", func() bool {
+		r := node.cs.rooms.get("lobby")
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.sessions["alice"].att == nil
+	})
+	for i := 0; i < 2; i++ {
+		if err := bob.Send(&pb.ChatRoomMessage{Room: "lobby", Content: fmt.Sprint("missed ", i), ClientId: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+		recvMessage(t, bob, msgTypeReceipt, "lobby")
+	}
+	info, err := node.client.GetRoomInfo(alice, &pb.RoomInfoRequest{Room: "lobby"})
+	if err != nil || !slices.Contains(info.Members, "alice") {
+		t.Fatalf("alice left the room with the stream: %v %v", info.GetMembers(), err)
+	}
+
+	// someone else's session doesn't resume
+	stream, err = node.client.RoomChat(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: "lobby", Resume: &pb.Resume{SessionId: "somebody-else", LastSeq: seen}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recvMessage(t, stream, msgTypeUnsubscribed, "lobby"); codes.Code(msg.Code) != codes.NotFound {
+		t.Fatalf("resuming a session that isn't alice's: %v", msg)
+	}
+
+	if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: "lobby", Resume: &pb.Resume{SessionId: joined.SessionId, LastSeq: seen}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		msg := recvMessage(t, stream, msgTypeMessage, "lobby")
+		if msg.Content != fmt.Sprint("missed ", i) || msg.Seq != seen+uint64(i)+1 {
+			t.Fatalf("replayed %q with seq %d after %d", msg.Content, msg.Seq, seen)
+		}
+	}
+	if err := bob.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recvMessage(t, stream, msgTypeMessage, "lobby"); msg.Content != "after" || msg.Seq != seen+3 {
+		t.Fatalf("after resuming alice got %q with seq %d", msg.Content, msg.Seq)
+	}
+}
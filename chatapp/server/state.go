@@ -0,0 +1,273 @@
+package main
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+// roomShards is how many locks the room table is split over, so looking up
+// one room doesn't wait on joins to unrelated ones.
+const roomShards = 64
+
+// chatRoom is the live state of one room: who is in it and how to reach
+// them. mu guards the maps; fanout is held across appending a message to the
+// history and queueing it for the members, so everyone sees the room in seq
+// order. Take fanout before mu when both are needed.
+type chatRoom struct {
+	name   string
+	fanout sync.Mutex
+
+	mu       sync.Mutex
+	members  map[string]*roomQueue
+	updates  map[string]*updateQueue
+	sessions map[string]*roomSession
+	receipts map[string]*memberReceipts
+	remote   map[string]string // members on other nodes, to the node they are on
+	typing   map[string]*time.Timer
+	outbox   []*pb.ClusterEvent // published by unlock once mu is released
+
+	publishing sync.Mutex // keeps each outbox published before the next
+}
+
+func newChatRoom(name string) *chatRoom {
+	return &chatRoom{
+		name:     name,
+		members:  make(map[string]*roomQueue),
+		updates:  make(map[string]*updateQueue),
+		sessions: make(map[string]*roomSession),
+		receipts: make(map[string]*memberReceipts),
+		remote:   make(map[string]string),
+		typing:   make(map[string]*time.Timer),
+	}
+}
+
+// memberNames must be called with r.mu held.
+func (r *chatRoom) memberNames() []string {
+	var names []string
+	for user := range r.members {
+		names = append(names, user)
+	}
+	return names
+}
+
+// memberQueues copies the member queues so they can be pushed to after r.mu
+// is released. Must be called with r.mu held.
+func (r *chatRoom) memberQueues() map[string]*roomQueue {
+	members := make(map[string]*roomQueue, len(r.members))
+	for user, q := range r.members {
+		members[user] = q
+	}
+	return members
+}
+
+type roomShard struct {
+	mu    sync.RWMutex
+	rooms map[string]*chatRoom
+}
+
+// roomTable finds the live state of a room. Rooms stay in it once created,
+// like they do in the roomStore, so a *chatRoom never goes stale.
+type roomTable struct {
+	shards [roomShards]roomShard
+}
+
+func newRoomTable() *roomTable {
+	t := &roomTable{}
+	for i := range t.shards {
+		t.shards[i].rooms = make(map[string]*chatRoom)
+	}
+	return t
+}
+
+func (t *roomTable) shard(room string) *roomShard {
+	h := fnv.New32a()
+	h.Write([]byte(room))
+	return &t.shards[h.Sum32()%roomShards]
+}
+
+// get returns nil if nobody has joined the room since the server started.
+func (t *roomTable) get(room string) *chatRoom {
+	shard := t.shard(room)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.rooms[room]
+}
+
+func (t *roomTable) getOrCreate(room string) *chatRoom {
+	if r := t.get(room); r != nil {
+		return r
+	}
+
+	shard := t.shard(room)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	r, ok := shard.rooms[room]
+	if !ok {
+		r = newChatRoom(room)
+		shard.rooms[room] = r
+	}
+	return r
+}
+
+// all returns every room anybody joined since the server started.
+func (t *roomTable) all() []*chatRoom {
+	var rooms []*chatRoom
+	for i := range t.shards {
+		shard := &t.shards[i]
+		shard.mu.RLock()
+		for _, r := range shard.rooms {
+			rooms = append(rooms, r)
+		}
+		shard.mu.RUnlock()
+	}
+	return rooms
+}
+
+// userDirectory tracks which rooms each user is in and where their private
+// messages go. A user has one private message queue however many rooms they
+// are in, kept while they are in a room or have a RoomChat stream open. It is
+// only ever locked on its own or after a chatRoom's mu.
+type userDirectory struct {
+	mu      sync.Mutex
+	private map[string]*privateQueue
+	rooms   map[string]map[string]bool
+	streams map[string]int             // open RoomChat streams per user
+	remote  map[string]map[string]bool // rooms users are in on other nodes
+}
+
+func newUserDirectory() *userDirectory {
+	return &userDirectory{
+		private: make(map[string]*privateQueue),
+		rooms:   make(map[string]map[string]bool),
+		streams: make(map[string]int),
+		remote:  make(map[string]map[string]bool),
+	}
+}
+
+// join records that user is in room and returns their private message queue,
+// creating it if they have none or the old one was closed.
+func (d *userDirectory) join(user, room string, newQueue func() *privateQueue) *privateQueue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.rooms[user] == nil {
+		d.rooms[user] = make(map[string]bool)
+	}
+	d.rooms[user][room] = true
+	return d.privateLocked(user, newQueue)
+}
+
+// leave forgets that user is in room, closing their private messages once
+// they are in no room and have no stream open.
+func (d *userDirectory) leave(user, room string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.rooms[user], room)
+	if len(d.rooms[user]) == 0 {
+		delete(d.rooms, user)
+	}
+	d.releaseLocked(user)
+}
+
+// connect records an open RoomChat stream of user and returns their private
+// message queue, like join.
+func (d *userDirectory) connect(user string, newQueue func() *privateQueue) *privateQueue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.streams[user]++
+	return d.privateLocked(user, newQueue)
+}
+
+func (d *userDirectory) disconnect(user string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.streams[user]--; d.streams[user] <= 0 {
+		delete(d.streams, user)
+	}
+	d.releaseLocked(user)
+}
+
+func (d *userDirectory) privateLocked(user string, newQueue func() *privateQueue) *privateQueue {
+	q := d.private[user]
+	if q == nil || q.Closed() {
+		q = newQueue()
+		d.private[user] = q
+	}
+	return q
+}
+
+// releaseLocked closes user's private messages if nothing needs them anymore.
+func (d *userDirectory) releaseLocked(user string) {
+	if len(d.rooms[user]) > 0 || d.streams[user] > 0 {
+		return
+	}
+	if q := d.private[user]; q != nil {
+		q.Close()
+		delete(d.private, user)
+	}
+}
+
+func (d *userDirectory) privateQueue(user string) (*privateQueue, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q, ok := d.private[user]
+	return q, ok
+}
+
+func (d *userDirectory) joinRemote(user, room string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.remote[user] == nil {
+		d.remote[user] = make(map[string]bool)
+	}
+	d.remote[user][room] = true
+}
+
+func (d *userDirectory) leaveRemote(user, room string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.remote[user], room)
+	if len(d.remote[user]) == 0 {
+		delete(d.remote, user)
+	}
+}
+
+// localRoomsOf returns the rooms user is in on this node.
+func (d *userDirectory) localRoomsOf(user string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rooms := make([]string, 0, len(d.rooms[user]))
+	for room := range d.rooms[user] {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// roomsOf returns the rooms user is in on any node.
+func (d *userDirectory) roomsOf(user string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var rooms []string
+	for _, joined := range []map[string]bool{d.rooms[user], d.remote[user]} {
+		for room := range joined {
+			if !seen[room] {
+				seen[room] = true
+				rooms = append(rooms, room)
+			}
+		}
+	}
+	return rooms
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sync"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	msgTypeSubscribe    = "subscribe"
+	msgTypeUnsubscribed = "unsubscribed"
+	msgTypeUpdate       = "update"
+	msgTypePrivate      = "private"
+
+	updateShutdown = "shutdown"
+)
+
+// chatStream is one RoomChat stream. It carries every room the client
+// subscribed to on it, tagged with the room, and the user's private
+// messages. Only the goroutine running RoomChat sends on the stream; each
+// subscription has a watcher that wakes it up when the room has something.
+type chatStream struct {
+	s      *chatServer
+	user   string
+	stream pb.Chat_RoomChatServer
+	wake   chan struct{} // has a value when pending or dirty has something
+	quit   chan struct{} // closed once RoomChat returns
+
+	mu      sync.Mutex
+	subs    map[string]*roomSubscription
+	dirty   map[*roomSubscription]bool
+	pending []*pb.ChatRoomMessage // to send as they are, like subscribe errors
+	closed  bool
+}
+
+// roomSubscription is a room the stream is subscribed to.
+type roomSubscription struct {
+	sess         *roomSession
+	att          *attachment
+	replay       []*pb.ChatRoomMessage // sent before anything else
+	replayedUpTo uint64
+	stop         chan struct{} // closed once the stream lets go of it
+}
+
+func (sub *roomSubscription) stopped() bool {
+	select {
+	case <-sub.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func newChatStream(s *chatServer, user string, stream pb.Chat_RoomChatServer) *chatStream {
+	return &chatStream{
+		s:      s,
+		user:   user,
+		stream: stream,
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+		subs:   make(map[string]*roomSubscription),
+		dirty:  make(map[*roomSubscription]bool),
+	}
+}
+
+// RoomChat serves a client's chat stream. A "subscribe" message attaches the
+// stream to the session JoinRoom handed out for that room, optionally resuming
+// after a seq; every other message names the room it is for. LeaveChatRoom
+// only ends that room's subscription. A stream that drops without closing
+// only detaches its sessions, so the client can subscribe again within
+// resumeGracePeriod; one the client closes leaves all its rooms.
+func (s *chatServer) RoomChat(stream pb.Chat_RoomChatServer) error {
+	sender := userFromContext(stream.Context())
+	cs := newChatStream(s, sender, stream)
+	defer close(cs.quit)
+
+	private := s.directory.connect(sender, func() *privateQueue { return s.newPrivateQueue(sender) })
+	defer s.directory.disconnect(sender)
+	s.deliverInbox(sender, private)
+
+	s.presence.connect(sender)
+	defer s.presence.disconnect(sender)
+
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			s.presence.touch(sender)
+			cs.handle(msg)
+		}
+	}()
+
+	sendPrivate := func(msg *pb.PrivateMessage) error {
+		return stream.Send(&pb.ChatRoomMessage{
+			Sender:    msg.Sender,
+			Content:   msg.Content,
+			Type:      msgTypePrivate,
+			Timestamp: msg.Timestamp,
+			Private:   msg,
+		})
+	}
+
+	for {
+		var err error
+		select {
+		case <-cs.wake:
+			err = cs.flush()
+		case <-private.Ready():
+			err = drain(private, sendPrivate)
+		case <-private.Done():
+			// only closed under us when we fell too far behind
+			if err = drain(private, sendPrivate); err == nil {
+				err = private.Err()
+			}
+		case <-cs.s.closing:
+			// send what is queued, the shutdown update last, then let the
+			// client resume elsewhere or once we are back
+			if err = cs.drainAll(private, sendPrivate); err != nil {
+				log.Printf("Error sending to %s: %v", sender, err)
+			}
+			cs.close(func(sub *roomSubscription) { s.detach(sub.sess, sub.att) })
+			return status.Error(codes.Unavailable, "server shutting down")
+		case err := <-recvErr:
+			if err == io.EOF {
+				cs.close(func(sub *roomSubscription) { s.leaveSession(sub.sess, "left") })
+				return nil
+			}
+			log.Printf("chat stream of %s dropped: %v", sender, err)
+			cs.close(func(sub *roomSubscription) { s.detach(sub.sess, sub.att) })
+			return nil
+		}
+		if err != nil {
+			log.Printf("Error sending to %s: %v", sender, err)
+			cs.close(func(sub *roomSubscription) { s.detach(sub.sess, sub.att) })
+			return err
+		}
+	}
+}
+
+// shutdown tells everyone in a room on this node that the server is going
+// away and ends the RoomChat streams. Other nodes keep their rooms going.
+func (s *chatServer) shutdown() {
+	for _, r := range s.rooms.all() {
+		r.mu.Lock()
+		r.pushUpdateLocked(&pb.Update{Room: r.name, Update: "server shutting down", Type: updateShutdown})
+		r.mu.Unlock()
+	}
+	close(s.closing)
+	s.webhooks.stop()
+}
+
+// drainAll flushes every subscription and the private messages, without
+// waiting for the watchers to notice there is something.
+func (cs *chatStream) drainAll(private *privateQueue, sendPrivate func(*pb.PrivateMessage) error) error {
+	cs.mu.Lock()
+	for _, sub := range cs.subs {
+		cs.dirty[sub] = true
+	}
+	cs.mu.Unlock()
+	if err := cs.flush(); err != nil {
+		return err
+	}
+	return drain(private, sendPrivate)
+}
+
+// handle acts on a message from the client.
+func (cs *chatStream) handle(msg *pb.ChatRoomMessage) {
+	if msg.Type == msgTypeSubscribe {
+		cs.subscribe(msg)
+		return
+	}
+
+	cs.mu.Lock()
+	sub := cs.subs[msg.Room]
+	cs.mu.Unlock()
+	if sub == nil {
+		if msg.Type == "" || logged(msg.Type) {
+			cs.tell(&pb.ChatRoomMessage{
+				Type:     msgTypeReceipt,
+				Room:     msg.Room,
+				ClientId: msg.ClientId,
+				Receipt: &pb.Receipt{
+					Member: cs.user,
+					Status: receiptFailed,
+					Error:  "subscribe to the room before sending to it",
+				},
+			})
+		}
+		return
+	}
+
+	r := sub.sess.room
+	msg.Bot = false // only the bot API posts as a bot
+	if msg.Type == "" || logged(msg.Type) {
+		if wait, err := cs.s.limits.checkRoomMessage(cs.user, r.name, msg); err != nil {
+			cs.tell(&pb.ChatRoomMessage{
+				Type:     msgTypeReceipt,
+				Room:     msg.Room,
+				ClientId: msg.ClientId,
+				Receipt: &pb.Receipt{
+					Member:     cs.user,
+					Status:     receiptFailed,
+					Error:      status.Convert(err).Message(),
+					RetryAfter: wait.Milliseconds(),
+				},
+			})
+			return
+		}
+	} else if (msg.Type == msgTypeTyping || msg.Type == msgTypeAck) && !cs.s.limits.allowSignal(cs.user, msg.Type) {
+		return
+	}
+
+	switch msg.Type {
+	case msgTypeAck:
+		cs.s.recordAck(r, cs.user, msg.Receipt)
+	case msgTypeTyping:
+		cs.s.setTyping(r, cs.user, true)
+	case "", msgTypeMessage:
+		cs.s.setTyping(r, cs.user, false)
+		cs.s.broadcastRoomMessage(r, cs.user, msg)
+	case msgTypeEdit, msgTypeDelete, msgTypeReaction:
+		cs.s.broadcastRoomMessage(r, cs.user, msg)
+	default:
+		log.Printf("ignoring message of unknown type %q from %s", msg.Type, cs.user)
+	}
+}
+
+// subscribe attaches the stream to the user's session in msg.Room. Subscribing
+// again to a room the stream is already in starts that subscription over.
+func (cs *chatStream) subscribe(msg *pb.ChatRoomMessage) {
+	cs.mu.Lock()
+	old := cs.subs[msg.Room]
+	if old != nil {
+		close(old.stop)
+		delete(cs.subs, msg.Room)
+		delete(cs.dirty, old)
+	}
+	cs.mu.Unlock()
+
+	sess, att, replay, err := cs.s.attach(cs.user, msg)
+	if err != nil {
+		if old != nil {
+			cs.s.detach(old.sess, old.att)
+		}
+		cs.tell(unsubscribed(msg.Room, status.Convert(err)))
+		return
+	}
+
+	sub := &roomSubscription{
+		sess:   sess,
+		att:    att,
+		replay: replay,
+		stop:   make(chan struct{}),
+	}
+	cs.mu.Lock()
+	if cs.closed {
+		cs.mu.Unlock()
+		cs.s.detach(sess, att)
+		return
+	}
+	cs.subs[msg.Room] = sub
+	cs.mu.Unlock()
+
+	// the replay goes out even if the room is quiet
+	cs.markDirty(sub)
+	go cs.watch(sub)
+}
+
+// watch marks sub dirty whenever one of its queues has something or closes.
+func (cs *chatStream) watch(sub *roomSubscription) {
+	for {
+		closed := false
+		select {
+		case <-sub.att.messages.Ready():
+		case <-sub.att.updates.Ready():
+		case <-sub.att.messages.Done():
+			closed = true
+		case <-sub.att.updates.Done():
+			closed = true
+		case <-sub.att.done:
+			closed = true
+		case <-sub.stop:
+			return
+		case <-cs.quit:
+			return
+		}
+		cs.markDirty(sub)
+		if closed {
+			// flush ends the subscription, there is nothing more to watch
+			return
+		}
+	}
+}
+
+func (cs *chatStream) markDirty(sub *roomSubscription) {
+	cs.mu.Lock()
+	cs.dirty[sub] = true
+	cs.mu.Unlock()
+	cs.signal()
+}
+
+// tell queues msg to go out on the stream as it is.
+func (cs *chatStream) tell(msg *pb.ChatRoomMessage) {
+	cs.mu.Lock()
+	cs.pending = append(cs.pending, msg)
+	cs.mu.Unlock()
+	cs.signal()
+}
+
+func (cs *chatStream) signal() {
+	select {
+	case cs.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flush sends whatever is waiting. Only RoomChat's own goroutine calls it.
+func (cs *chatStream) flush() error {
+	cs.mu.Lock()
+	pending := cs.pending
+	cs.pending = nil
+	dirty := make([]*roomSubscription, 0, len(cs.dirty))
+	for sub := range cs.dirty {
+		dirty = append(dirty, sub)
+	}
+	clear(cs.dirty)
+	cs.mu.Unlock()
+
+	for _, msg := range pending {
+		if err := cs.stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	for _, sub := range dirty {
+		if err := cs.serve(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serve sends what sub has queued and ends it if its room is done with us.
+func (cs *chatStream) serve(sub *roomSubscription) error {
+	if sub.stopped() {
+		return nil
+	}
+
+	for _, msg := range sub.replay {
+		if err := cs.stream.Send(msg); err != nil {
+			return err
+		}
+		sub.replayedUpTo = msg.Seq
+	}
+	sub.replay = nil
+
+	sendRoom := func(msg *pb.ChatRoomMessage) error {
+		if logged(msg.Type) && msg.Seq <= sub.replayedUpTo {
+			return nil
+		}
+		return cs.stream.Send(msg)
+	}
+	sendUpdate := func(update *pb.Update) error {
+		return cs.stream.Send(&pb.ChatRoomMessage{
+			Type:   msgTypeUpdate,
+			Room:   update.Room,
+			Update: update,
+		})
+	}
+	// looked at first, so nothing queued before a close is left behind
+	closed := sub.att.messages.Closed() || sub.att.updates.Closed()
+	if err := drain(sub.att.messages, sendRoom); err != nil {
+		return err
+	}
+	if err := drain(sub.att.updates, sendUpdate); err != nil {
+		return err
+	}
+
+	room := sub.sess.room.name
+	select {
+	case <-sub.att.done:
+		return cs.end(sub, status.New(codes.Aborted, "session resumed on another connection"))
+	default:
+	}
+	if !closed {
+		return nil
+	}
+	err := sub.att.messages.Err()
+	if err == nil {
+		err = sub.att.updates.Err()
+	}
+	if err != nil {
+		// we fell too far behind, the client subscribes again to catch up
+		cs.s.detach(sub.sess, sub.att)
+		return cs.end(sub, status.Convert(err))
+	}
+	// LeaveChatRoom, a kick or the session expired
+	return cs.end(sub, status.Newf(codes.OK, "you are no longer in %s", room))
+}
+
+// end drops sub from the stream and tells the client why.
+func (cs *chatStream) end(sub *roomSubscription, why *status.Status) error {
+	room := sub.sess.room.name
+	cs.mu.Lock()
+	if cs.subs[room] != sub {
+		// subscribed again or the stream is closing
+		cs.mu.Unlock()
+		return nil
+	}
+	delete(cs.subs, room)
+	close(sub.stop)
+	cs.mu.Unlock()
+	return cs.stream.Send(unsubscribed(room, why))
+}
+
+// close lets go of every subscription once the stream is done, doing
+// release with each.
+func (cs *chatStream) close(release func(sub *roomSubscription)) {
+	cs.mu.Lock()
+	cs.closed = true
+	subs := make([]*roomSubscription, 0, len(cs.subs))
+	for _, sub := range cs.subs {
+		close(sub.stop)
+		subs = append(subs, sub)
+	}
+	clear(cs.subs)
+	cs.mu.Unlock()
+
+	for _, sub := range subs {
+		release(sub)
+	}
+}
+
+func unsubscribed(room string, why *status.Status) *pb.ChatRoomMessage {
+	return &pb.ChatRoomMessage{
+		Type:    msgTypeUnsubscribed,
+		Room:    room,
+		Code:    int32(why.Code()),
+		Content: why.Message(),
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	pb "example/hello/chatapp/grpc"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestSeqAndReceipts(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	alice := node.chatIn(t, "alice", "lobby")
+	bob := node.chatIn(t, "bob", "lobby")
+
+	var last uint64
+	for _, id := range []string{"m1", "m2", "m3"} {
+		if err := alice.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "hello " + id, ClientId: id, Sender: "mallory"}); err != nil {
+			t.Fatal(err)
+		}
+		receipt := recvMessage(t, alice, msgTypeReceipt, "lobby")
+		if receipt.ClientId != id || receipt.Receipt.GetStatus() != receiptSent || receipt.Receipt.GetMember() != "alice" {
+			t.Fatalf("the receipt for %s: %v", id, receipt)
+		}
+		if last > 0 && receipt.Seq != last+1 {
+			t.Fatalf("%s got seq %d after %d", id, receipt.Seq, last)
+		}
+		last = receipt.Seq
+
+		got := recvMessage(t, bob, msgTypeMessage, "lobby")
+		if got.Seq != receipt.Seq || got.Content != "hello "+id || got.Sender != "alice" || got.Timestamp == 0 {
+			t.Fatalf("bob got %v for %s with seq %d", got, id, receipt.Seq)
+		}
+	}
+
+	// bob's acks come back to alice as receipts of theirs
+	for _, ack := range []string{receiptDelivered, receiptRead} {
+		if err := bob.Send(&pb.ChatRoomMessage{Type: msgTypeAck, Room: "lobby", Receipt: &pb.Receipt{Seq: last, Status: ack}}); err != nil {
+			t.Fatal(err)
+		}
+		receipt := recvMessage(t, alice, msgTypeReceipt, "lobby")
+		if receipt.Receipt.GetMember() != "bob" || receipt.Receipt.GetStatus() != ack || receipt.Seq != last {
+			t.Fatalf("bob's %s ack reached alice as %v", ack, receipt.Receipt)
+		}
+	}
+
+	if err := alice.Send(&pb.ChatRoomMessage{Room: "elsewhere", Content: "hi", ClientId: "m4"}); err != nil {
+		t.Fatal(err)
+	}
+	receipt := recvMessage(t, alice, msgTypeReceipt, "elsewhere")
+	if receipt.ClientId != "m4" || receipt.Receipt.GetStatus() != receiptFailed || receipt.Seq != 0 {
+		t.Fatalf("a message to a room alice isn't subscribed to: %v", receipt.Receipt)
+	}
+}
+
+func TestOneStreamManyRooms(t *testing.T) {
+	node := startCluster(t, 1)[0]
+	bob := node.chatIn(t, "bob", "lobby")
+	carol := node.chatIn(t, "carol", "games")
+	alice := node.as(t, "alice")
+
+	stream, err := node.client.RoomChat(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: "games"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recvMessage(t, stream, msgTypeUnsubscribed, "games"); codes.Code(msg.Code) != codes.FailedPrecondition {
+		t.Fatalf("subscribing before joining: %v", msg)
+	}
+	for _, room := range []string{"lobby", "games"} {
+		if _, err := node.client.JoinRoom(alice, &pb.JoinRequest{Room: room}); err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&pb.ChatRoomMessage{Type: msgTypeSubscribe, Room: room}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := bob.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "in the lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recvMessage(t, stream, msgTypeMessage, "lobby"); msg.Content != "in the lobby" {
+		t.Fatalf("alice got %q in the lobby", msg.Content)
+	}
+	if err := carol.Send(&pb.ChatRoomMessage{Room: "games", Content: "in games"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recvMessage(t, stream, msgTypeMessage, "games"); msg.Content != "in games" {
+		t.Fatalf("alice got %q in games", msg.Content)
+	}
+
+	// leaving one room ends only its subscription
+	if _, err := node.client.LeaveChatRoom(alice, &pb.LeaveRequest{Room: "games"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := recvMessage(t, stream, msgTypeUnsubscribed, "games"); codes.Code(msg.Code) != codes.OK {
+		t.Fatalf("leaving games: %v", msg)
+	}
+	if err := stream.Send(&pb.ChatRoomMessage{Room: "games", Content: "still here?", ClientId: "g1"}); err != nil {
+		t.Fatal(err)
+	}
+	if receipt := recvMessage(t, stream, msgTypeReceipt, "games"); receipt.Receipt.GetStatus() != receiptFailed {
+		t.Fatalf("sending to a room alice left: %v", receipt.Receipt)
+	}
+	if err := stream.Send(&pb.ChatRoomMessage{Room: "lobby", Content: "still here", ClientId: "l1"}); err != nil {
+		t.Fatal(err)
+	}
+	if receipt := recvMessage(t, stream, msgTypeReceipt, "lobby"); receipt.Receipt.GetStatus() != receiptSent {
+		t.Fatalf("sending to the lobby after leaving games: %v", receipt.Receipt)
+	}
+	if msg := recvMessage(t, bob, msgTypeMessage, "lobby"); msg.Content != "still here" || msg.Sender != "alice" {
+		t.Fatalf("bob got %v", msg)
+	}
+}
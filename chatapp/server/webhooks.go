@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "example/hello/chatapp/grpc"
+)
+
+const (
+	webhookWorkers  = 4
+	webhookQueue    = 1024
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 5
+	maxWebhookRetry = time.Minute
+	maxWebhookReply = 64 << 10
+)
+
+var errPrivateAddress = errors.New("webhooks can only be sent to public addresses")
+
+// reservedPrefixes are the ranges besides the private, loopback and
+// link-local ones that don't lead to the internet.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, may lead anywhere
+}
+
+// publicAddress reports whether a webhook may be sent to ip, which keeps
+// room owners from using the server to reach into the network it is on.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookHost resolves host and makes sure every address it has is
+// public.
+func checkWebhookHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("couldn't resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialPublic is the Control of the webhook dialer. It sees the address a
+// connection is about to be made to, after the name was resolved, so a host
+// that resolved to something else since the webhook was added is caught
+// too, as is a redirect.
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !publicAddress(addrPort.Addr()) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// webhookPayload is what a webhook gets POSTed. Signed with the webhook's
+// secret: X-Chat-Signature is "sha256=" and the hex HMAC-SHA256 of
+// X-Chat-Timestamp, a '.' and the body.
+type webhookPayload struct {
+	Webhook   string `json:"webhook"`
+	Delivery  string `json:"delivery"`
+	Room      string `json:"room"`
+	Seq       uint64 `json:"seq"`
+	Sender    string `json:"sender"`
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"`
+	Command   string `json:"command,omitempty"`
+	Args      string `json:"args,omitempty"`
+}
+
+type webhookDelivery struct {
+	id      string
+	room    string
+	hook    webhookInfo
+	body    []byte
+	attempt int
+}
+
+// webhookSender POSTs room messages to the webhooks they match, off the
+// fan-out path, retrying with backoff when the other end is down.
+type webhookSender struct {
+	client *http.Client
+	// reply posts what a webhook answered with back to the room.
+	reply func(room, name, text string)
+
+	queue  chan *webhookDelivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func newWebhookSender(reply func(room, name, text string)) *webhookSender {
+	ctx, cancel := context.WithCancel(context.Background())
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: dialPublic}
+	transport := &http.Transport{
+		// no proxy, it would be the only address dialPublic saw
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        webhookWorkers,
+		IdleConnTimeout:     time.Minute,
+	}
+	w := &webhookSender{
+		client:   &http.Client{Timeout: webhookTimeout, Transport: transport},
+		reply:    reply,
+		queue:    make(chan *webhookDelivery, webhookQueue),
+		ctx:      ctx,
+		cancel:   cancel,
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	for range webhookWorkers {
+		w.wg.Add(1)
+		go w.run()
+	}
+	return w
+}
+
+// stop gives up on what is queued or waiting to be retried.
+func (w *webhookSender) stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// match says whether a message saying content triggers hook, and if it was
+// with its command, what followed it.
+func (w *webhookSender) match(hook webhookInfo, content string) (command, args string, ok bool) {
+	if hook.Command != "" {
+		if content == hook.Command {
+			return hook.Command, "", true
+		}
+		if rest, found := strings.CutPrefix(content, hook.Command+" "); found {
+			return hook.Command, strings.TrimSpace(rest), true
+		}
+	}
+	if hook.Pattern == "" {
+		return "", "", false
+	}
+
+	w.mu.Lock()
+	re := w.patterns[hook.Pattern]
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(hook.Pattern); err != nil {
+			w.mu.Unlock()
+			return "", "", false
+		}
+		w.patterns[hook.Pattern] = re
+	}
+	w.mu.Unlock()
+	return "", "", re.MatchString(content)
+}
+
+// fire queues msg for every webhook of room it matches. It doesn't block: when
+// the queue is full the delivery is dropped.
+func (w *webhookSender) fire(room string, hooks map[string]webhookInfo, msg *pb.ChatRoomMessage) {
+	for id, hook := range hooks {
+		command, args, ok := w.match(hook, msg.Content)
+		if !ok {
+			continue
+		}
+		payload := webhookPayload{
+			Webhook:   id,
+			Delivery:  fmt.Sprintf("%s-%s-%d", id, room, msg.Seq),
+			Room:      room,
+			Seq:       msg.Seq,
+			Sender:    msg.Sender,
+			Text:      msg.Content,
+			Timestamp: msg.Timestamp,
+			Command:   command,
+			Args:      args,
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("failed to encode webhook %s: %v", id, err)
+			continue
+		}
+		w.enqueue(&webhookDelivery{id: payload.Delivery, room: room, hook: hook, body: body})
+	}
+}
+
+func (w *webhookSender) enqueue(d *webhookDelivery) {
+	select {
+	case w.queue <- d:
+	case <-w.ctx.Done():
+	default:
+		log.Printf("webhook queue is full, dropping delivery %s to %s", d.id, d.hook.URL)
+	}
+}
+
+func (w *webhookSender) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case d := <-w.queue:
+			w.deliver(d)
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver makes one attempt at d and schedules the next if it failed in a way
+// worth retrying.
+func (w *webhookSender) deliver(d *webhookDelivery) {
+	d.attempt++
+	retry, wait, err := w.post(d)
+	if err == nil {
+		return
+	}
+	if !retry || d.attempt >= webhookAttempts {
+		log.Printf("giving up on webhook delivery %s to %s after %d attempts: %v", d.id, d.hook.URL, d.attempt, err)
+		return
+	}
+	if wait <= 0 {
+		wait = time.Second << (d.attempt - 1)
+	}
+	wait = min(wait, maxWebhookRetry)
+	log.Printf("webhook delivery %s to %s failed, retrying in %s: %v", d.id, d.hook.URL, wait, err)
+	time.AfterFunc(wait, func() { w.enqueue(d) })
+}
+
+// post sends d once. It says whether to try again, and when if the other end
+// said so.
+func (w *webhookSender) post(d *webhookDelivery) (retry bool, wait time.Duration, err error) {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, d.hook.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatapp-webhook")
+	req.Header.Set("X-Chat-Webhook", d.hook.Name)
+	req.Header.Set("X-Chat-Delivery", d.id)
+	req.Header.Set("X-Chat-Timestamp", ts)
+	req.Header.Set("X-Chat-Signature", "sha256="+sign(d.hook.Secret, ts, d.body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return w.ctx.Err() == nil, 0, err
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookReply))
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		w.answer(d, resp.Header.Get("Content-Type"), reply)
+		return false, 0, nil
+	case code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500:
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+		return true, wait, fmt.Errorf("%s", resp.Status)
+	default:
+		return false, 0, fmt.Errorf("%s", resp.Status)
+	}
+}
+
+// answer posts the webhook's reply, a JSON {"text": "..."}, to the room.
+func (w *webhookSender) answer(d *webhookDelivery, contentType string, reply []byte) {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+		return
+	}
+	var answer struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(reply, &answer); err != nil || strings.TrimSpace(answer.Text) == "" {
+		return
+	}
+	w.reply(d.room, d.hook.Name, answer.Text)
+}
+
+func sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
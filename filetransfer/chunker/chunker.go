@@ -0,0 +1,92 @@
+// Package chunker splits data into content-defined chunks: the places it
+// cuts depend on the bytes around them rather than on where they are, so
+// inserting or removing bytes in one part of a file only changes the chunks
+// in that part. Client and server both use it, and a chunk the server
+// already has is one the client doesn't have to send.
+//
+// A cut is made where a gear hash of the bytes before it has its top bits
+// clear, once the chunk is at least MinSize, and at MaxSize regardless.
+// Changing any of that changes where every file is cut, which turns every
+// chunk the server has into one nobody asks for.
+package chunker
+
+import "io"
+
+const (
+	MinSize = 16 << 10
+	MaxSize = 256 << 10
+
+	// 16 bits make a chunk about 64KB past MinSize on average
+	mask = 0xffff << 48
+)
+
+// gear maps each byte to a random-looking 64-bit number, the same ones
+// every time.
+var gear [256]uint64
+
+func init() {
+	// splitmix64
+	x := uint64(0x6a09e667f3bcc908)
+	for i := range gear {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		gear[i] = z ^ z>>31
+	}
+}
+
+// Chunker reads chunks from a reader.
+type Chunker struct {
+	r     io.Reader
+	buf   []byte
+	start int // of what isn't returned yet in buf
+	end   int
+	err   error // from r, once it has no more
+}
+
+func New(r io.Reader) *Chunker {
+	return &Chunker{r: r, buf: make([]byte, MaxSize)}
+}
+
+// Next returns the next chunk, which is only good until the next call, and
+// io.EOF after the last one. An empty reader has no chunks.
+func (c *Chunker) Next() ([]byte, error) {
+	if c.end-c.start < MaxSize && c.err == nil {
+		c.end = copy(c.buf, c.buf[c.start:c.end])
+		c.start = 0
+		for c.end < len(c.buf) && c.err == nil {
+			var n int
+			n, c.err = c.r.Read(c.buf[c.end:])
+			c.end += n
+		}
+	}
+	if c.err != nil && c.err != io.EOF {
+		return nil, c.err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	data := c.buf[c.start:c.end]
+	n := cut(data)
+	c.start += n
+	return data[:n], nil
+}
+
+// cut says where the first chunk of data ends.
+func cut(data []byte) int {
+	if len(data) <= MinSize {
+		return len(data)
+	}
+	n := min(len(data), MaxSize)
+	var h uint64
+	// the hash only depends on the last 64 bytes, so the ones before those
+	// in MinSize don't need hashing
+	for i := MinSize - 64; i < n; i++ {
+		h = h<<1 + gear[data[i]]
+		if i >= MinSize && h&mask == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package chunker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func chunks(t *testing.T, data []byte) [][32]byte {
+	t.Helper()
+	var sums [][32]byte
+	c := New(bytes.NewReader(data))
+	total := 0
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) > MaxSize || len(chunk) < MinSize && total+len(chunk) != len(data) {
+			t.Fatalf("chunk of %d bytes at %d", len(chunk), total)
+		}
+		total += len(chunk)
+		sums = append(sums, sha256.Sum256(chunk))
+	}
+	if total != len(data) {
+		t.Fatalf("chunks add up to %d of %d bytes", total, len(data))
+	}
+	return sums
+}
+
+func TestChunksFollowContent(t *testing.T) {
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	before := chunks(t, data)
+	if n := len(before); n < 30 || n > 100 {
+		t.Errorf("%d chunks in 4MB", n)
+	}
+
+	// bytes inserted in the middle change the chunks around them only
+	edited := append(append(append([]byte{}, data[:2<<20]...), "inserted"...), data[2<<20:]...)
+	after := chunks(t, edited)
+	have := make(map[[32]byte]bool)
+	for _, sum := range before {
+		have[sum] = true
+	}
+	changed := 0
+	for _, sum := range after {
+		if !have[sum] {
+			changed++
+		}
+	}
+	if changed > 2 {
+		t.Errorf("%d of %d chunks changed after inserting 8 bytes", changed, len(after))
+	}
+}
+
+func TestSmallInputs(t *testing.T) {
+	if sums := chunks(t, nil); len(sums) != 0 {
+		t.Errorf("%d chunks of nothing", len(sums))
+	}
+	if sums := chunks(t, []byte("hello")); len(sums) != 1 {
+		t.Errorf("%d chunks of 5 bytes", len(sums))
+	}
+}
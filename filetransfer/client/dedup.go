@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"example/hello/filetransfer/chunker"
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errNoChunks is what uploadChunked says when the server doesn't store
+// files as chunks.
+var errNoChunks = errors.New("the server doesn't store chunks")
+
+// uploadChunked sends the file at path to the server, stored as name, by
+// sending only the chunks of it the server doesn't have yet, on up to
+// streams streams at once. The chunks sent stay on the server for a while,
+// so an upload that broke off only sends what didn't get there when it is
+// tried again.
+func uploadChunked(ctx context.Context, client pb.FileTransferServiceClient, path, name string, streams int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	refs, sum, size, err := listChunks(file)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		needs, err := client.NegotiateChunks(ctx, &pb.ChunkList{FileName: name, Chunks: refs})
+		if status.Code(err) == codes.Unimplemented {
+			return errNoChunks
+		}
+		if err == nil {
+			err = sendChunks(ctx, client, file, refs, needs.Missing, streams)
+		}
+		if err == nil {
+			_, err = client.CommitChunks(ctx, &pb.ChunkList{FileName: name, Chunks: refs, Sha256: sum})
+		}
+		if err == nil {
+			log.Printf("Uploaded %s as %s, %d bytes", path, name, size)
+			return nil
+		}
+		if !retryable(err) || attempt >= maxAttempts {
+			return fmt.Errorf("upload failed: %w", err)
+		}
+		wait(attempt, err)
+	}
+}
+
+// listChunks cuts the file into chunks, returning them with the SHA-256
+// and size of all of it.
+func listChunks(file *os.File) ([]*pb.ChunkRef, []byte, int64, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, 0, err
+	}
+	var refs []*pb.ChunkRef
+	var size int64
+	hash := sha256.New()
+	for split := chunker.New(file); ; {
+		data, err := split.Next()
+		if err == io.EOF {
+			return refs, hash.Sum(nil), size, nil
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		sum := sha256.Sum256(data)
+		hash.Write(data)
+		refs = append(refs, &pb.ChunkRef{Sha256: sum[:], Size: int64(len(data))})
+		size += int64(len(data))
+	}
+}
+
+// sendChunks sends the chunks of the file at the indexes in missing, spread
+// over up to streams streams.
+func sendChunks(ctx context.Context, client pb.FileTransferServiceClient, file *os.File, refs []*pb.ChunkRef, missing []int32, streams int) error {
+	if len(missing) == 0 {
+		log.Printf("The server has all %d chunks already", len(refs))
+		return nil
+	}
+	send := make(map[int]bool, len(missing))
+	var total int64
+	for _, i := range missing {
+		if i < 0 || int(i) >= len(refs) {
+			return fmt.Errorf("the server asked for chunk %d of %d", i, len(refs))
+		}
+		send[int(i)] = true
+		total += refs[i].Size
+	}
+	streams = min(streams, len(send))
+	log.Printf("Sending %d of %d chunks, %d bytes, on %d streams", len(send), len(refs), total, streams)
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chunks := make(chan *pb.Chunk)
+	errs := make(chan error, streams)
+	for range streams {
+		go func() {
+			err := sendChunkStream(ctx, client, chunks)
+			if err != nil {
+				// the chunks left would have nowhere to go
+				cancel()
+			}
+			errs <- err
+		}()
+	}
+
+	err := func() error {
+		defer close(chunks)
+		split := chunker.New(file)
+		for i := 0; len(send) > 0; i++ {
+			data, err := split.Next()
+			if err == io.EOF {
+				return errors.New("the file changed while it was being uploaded")
+			}
+			if err != nil {
+				return err
+			}
+			if !send[i] {
+				continue
+			}
+			delete(send, i)
+			select {
+			case chunks <- &pb.Chunk{Data: bytes.Clone(data), Sha256: refs[i].Sha256}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}()
+	for range streams {
+		// what went wrong on a stream is why the chunks stopped
+		if streamErr := <-errs; streamErr != nil && (err == nil || err == context.Canceled) {
+			err = streamErr
+		}
+	}
+	return err
+}
+
+// sendChunkStream sends the chunks that come in on one stream.
+func sendChunkStream(ctx context.Context, client pb.FileTransferServiceClient, chunks <-chan *pb.Chunk) error {
+	stream, err := client.UploadChunks(ctx)
+	if err != nil {
+		return err
+	}
+	for chunk := range chunks {
+		if err := stream.Send(chunk); err != nil {
+			// the server said why in the status CloseAndRecv returns
+			break
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// download fetches name from the server into the file at path, on up to
+// streams streams at once when it is big enough. What came in so far waits
+// in path.part, which a download that broke off resumes from.
+func download(ctx context.Context, client pb.FileTransferServiceClient, name, path string, chunkSize, streams int) error {
+	if streams > 1 {
+		if err := downloadRanges(ctx, client, name, path, chunkSize, streams); err != errSingleStream {
+			return err
+		}
+	}
+
+	partPath := path + ".part"
+	part, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	for attempt := 0; ; attempt++ {
+		offset, err := part.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if offset > 0 {
+			log.Printf("Resuming download of %s at %d bytes", name, offset)
+		}
+		sum, err := receiveFrom(ctx, client, part, name, offset, chunkSize)
+		if err == nil {
+			err = checkSHA256(part, sum)
+			if err != nil {
+				// start over, what we have doesn't add up to the file
+				part.Truncate(0)
+			}
+		}
+		if err == nil {
+			break
+		}
+		if status.Code(err) == codes.OutOfRange && offset > 0 {
+			// the file got smaller on the server since the part was written
+			part.Truncate(0)
+			continue
+		}
+		if !retryable(err) || attempt >= maxAttempts {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		wait(attempt, err)
+	}
+
+	if err := part.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Downloaded %s to %s, %d bytes", name, path, info.Size())
+	return nil
+}
+
+// receiveFrom appends name from offset on to part, checking each chunk's CRC.
+// It returns the SHA-256 the server sent for the whole file.
+func receiveFrom(ctx context.Context, client pb.FileTransferServiceClient, part *os.File, name string, offset int64, chunkSize int) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.DownloadFile(ctx, &pb.FileRequest{FileName: name, ChunkSize: int32(chunkSize), Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil, status.Errorf(codes.Unavailable, "download of %s ended early", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk.IsLastChunk {
+			return chunk.Sha256, nil
+		}
+		if chunk.Offset != offset {
+			return nil, status.Errorf(codes.DataLoss, "got the chunk at %d when expecting %d", chunk.Offset, offset)
+		}
+		if crc32.Checksum(chunk.ChunkData, castagnoli) != chunk.Crc32C {
+			return nil, status.Errorf(codes.DataLoss, "chunk at %d doesn't match its CRC", offset)
+		}
+		if _, err := part.Write(chunk.ChunkData); err != nil {
+			return nil, err
+		}
+		offset += int64(len(chunk.ChunkData))
+	}
+}
+
+func checkSHA256(f *os.File, want []byte) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if !bytes.Equal(hash.Sum(nil), want) {
+		return status.Error(codes.DataLoss, "the file doesn't match its SHA-256")
+	}
+	return nil
+}
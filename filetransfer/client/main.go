@@ -0,0 +1,177 @@
+// Command client moves files to and from a filetransfer server:
+//
+//	client [-server host:port] upload <file> [name]
+//	client [-server host:port] download <name> [file]
+//	client [-server host:port] list [dir]
+//	client [-server host:port] stat <name>
+//	client [-server host:port] rm <name>
+//	client [-server host:port] mv <name> <new-name>
+//
+// Names on the server are slash-separated paths, like "builds/app.tar.gz".
+//
+// Transfers that break off are tried again from where they stopped, and
+// running the same upload or download again later resumes it too. A server
+// storing files as chunks is only sent the chunks of an upload it doesn't
+// have. Files of a few megabytes or more go in ranges on several streams at
+// once, each on a connection of its own (-streams).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var errUsage = errors.New("usage")
+
+func main() {
+	serverAddr := flag.String("server", "localhost:50052", "address of the filetransfer server")
+	chunkSize := flag.Int("chunk-size", 64<<10, "bytes sent per message")
+	user := flag.String("user", os.Getenv("USER"), "name to give the server for its log; it can't check it, so files record the address they came from instead")
+	streams := flag.Int("streams", 4, "streams a big file is sent or received on at once")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage:\n")
+		fmt.Fprintf(out, "  %s [flags] upload <file> [name]    upload file, as name if given\n", os.Args[0])
+		fmt.Fprintf(out, "  %s [flags] download <name> [file]  download name, to file if given\n", os.Args[0])
+		fmt.Fprintf(out, "  %s [flags] list [dir]              list the files on the server\n", os.Args[0])
+		fmt.Fprintf(out, "  %s [flags] stat <name>             show what the server knows about name\n", os.Args[0])
+		fmt.Fprintf(out, "  %s [flags] rm <name>               delete name\n", os.Args[0])
+		fmt.Fprintf(out, "  %s [flags] mv <name> <new-name>    rename name\n", os.Args[0])
+		fmt.Fprintf(out, "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *chunkSize < 1 {
+		log.Fatal("-chunk-size must be at least 1")
+	}
+	if *streams < 1 {
+		log.Fatal("-streams must be at least 1")
+	}
+
+	conns, err := dialPool(*serverAddr, *streams, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer conns.Close()
+	client := pb.NewFileTransferServiceClient(conns)
+
+	ctx := context.Background()
+	if *user != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "user", *user)
+	}
+	args := flag.Args()
+	switch {
+	case len(args) >= 2 && len(args) <= 3 && args[0] == "upload":
+		name := filepath.Base(args[1])
+		if len(args) == 3 {
+			name = args[2]
+		}
+		err = uploadChunked(ctx, client, args[1], name, *streams)
+		if err == errNoChunks {
+			err = upload(ctx, client, *serverAddr, args[1], name, *chunkSize, *streams)
+		}
+	case len(args) >= 2 && len(args) <= 3 && args[0] == "download":
+		path := filepath.Base(filepath.FromSlash(args[1]))
+		if len(args) == 3 {
+			path = args[2]
+		}
+		err = download(ctx, client, args[1], path, *chunkSize, *streams)
+	case len(args) >= 1 && len(args) <= 2 && args[0] == "list":
+		dir := ""
+		if len(args) == 2 {
+			dir = args[1]
+		}
+		err = list(ctx, client, dir)
+	case len(args) == 2 && args[0] == "stat":
+		err = stat(ctx, client, args[1])
+	case len(args) == 2 && args[0] == "rm":
+		_, err = client.DeleteFile(ctx, &pb.DeleteRequest{FileName: args[1]})
+	case len(args) == 3 && args[0] == "mv":
+		_, err = client.RenameFile(ctx, &pb.RenameRequest{FileName: args[1], NewName: args[2]})
+	default:
+		err = errUsage
+	}
+	if err == errUsage {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// maxAttempts is how many more times a transfer is tried after it breaks
+// off, waiting longer each time.
+const maxAttempts = 5
+
+// retryable says whether a transfer that failed with err may get through if
+// it picks up where it stopped.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded, codes.FailedPrecondition, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+func wait(attempt int, err error) {
+	backoff := time.Second << attempt
+	log.Printf("Transfer interrupted, trying again in %s: %v", backoff, err)
+	time.Sleep(backoff)
+}
+
+func list(ctx context.Context, client pb.FileTransferServiceClient, dir string) error {
+	files, err := client.ListFiles(ctx, &pb.ListRequest{Dir: dir})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, f := range files.Files {
+		name := filepath.Base(filepath.FromSlash(f.FileName))
+		if f.IsDir {
+			fmt.Fprintf(w, "%s/\t-\t%s\n", name, time.Unix(f.Modified, 0).Format(time.DateTime))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", name, f.Size, time.Unix(f.Modified, 0).Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func stat(ctx context.Context, client pb.FileTransferServiceClient, name string) error {
+	f, err := client.StatFile(ctx, &pb.StatRequest{FileName: name})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "name:\t%s\n", f.FileName)
+	if f.IsDir {
+		fmt.Fprintf(w, "type:\tdirectory\n")
+		return w.Flush()
+	}
+	fmt.Fprintf(w, "size:\t%d\n", f.Size)
+	fmt.Fprintf(w, "type:\t%s\n", f.ContentType)
+	if f.Sha256 != nil {
+		fmt.Fprintf(w, "sha256:\t%x\n", f.Sha256)
+	}
+	if f.Uploader != "" {
+		fmt.Fprintf(w, "uploader:\t%s\n", f.Uploader)
+	}
+	fmt.Fprintf(w, "created:\t%s\n", time.Unix(f.Created, 0).Format(time.DateTime))
+	fmt.Fprintf(w, "modified:\t%s\n", time.Unix(f.Modified, 0).Format(time.DateTime))
+	return w.Flush()
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minRangeSize is the least a stream of a transfer gets of the file; what
+// a stream more would save on smaller ranges, setting it up costs.
+const minRangeSize = 1 << 20
+
+// connPool takes turns handing the calls made on it to a connection each,
+// so the streams of a transfer don't all wait on the flow control window
+// and congestion control of the one connection.
+type connPool struct {
+	conns []*grpc.ClientConn
+	next  atomic.Uint32
+}
+
+// dialPool sets up n connections to target; like grpc.NewClient, they
+// only connect when first used.
+func dialPool(target string, n int, opts ...grpc.DialOption) (*connPool, error) {
+	p := &connPool{}
+	for range n {
+		conn, err := grpc.NewClient(target, opts...)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.conns = append(p.conns, conn)
+	}
+	return p, nil
+}
+
+func (p *connPool) pick() *grpc.ClientConn {
+	return p.conns[int(p.next.Add(1)-1)%len(p.conns)]
+}
+
+func (p *connPool) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	return p.pick().Invoke(ctx, method, args, reply, opts...)
+}
+
+func (p *connPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return p.pick().NewStream(ctx, desc, method, opts...)
+}
+
+func (p *connPool) Close() error {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// rangeSize is how big the ranges of a file of size are to send it on up
+// to streams streams, or 0 to send it on one.
+func rangeSize(size int64, streams int) int64 {
+	n := min(int64(streams), size/minRangeSize)
+	if n <= 1 {
+		return 0
+	}
+	return (size + n - 1) / n
+}
+
+// rangeBounds says where range i of the upload starts and ends.
+func rangeBounds(session *pb.UploadSession, i int) (start, end int64) {
+	if len(session.RangeOffsets) <= 1 {
+		return 0, session.Size
+	}
+	start = int64(i) * session.RangeSize
+	return start, min(start+session.RangeSize, session.Size)
+}
+
+// sentBytes is how much of the upload the server has.
+func sentBytes(session *pb.UploadSession) int64 {
+	var sent int64
+	for i, offset := range session.RangeOffsets {
+		start, _ := rangeBounds(session, i)
+		sent += offset - start
+	}
+	return sent
+}
+
+// errSingleStream is what downloadRanges says when the file is better
+// downloaded on one stream.
+var errSingleStream = errors.New("download on one stream")
+
+// rangeProgress is how far each range of a download got, kept next to the
+// part file while the download isn't done.
+type rangeProgress struct {
+	Size      int64   `json:"size"`
+	SHA256    []byte  `json:"sha256"`
+	RangeSize int64   `json:"range_size"`
+	Offsets   []int64 `json:"offsets"` // where each range is written up to
+
+	path  string
+	mu    sync.Mutex
+	saved time.Time
+}
+
+func newRangeProgress(path string, info *pb.FileInfo, streams int) *rangeProgress {
+	p := &rangeProgress{path: path, Size: info.Size, SHA256: info.Sha256, RangeSize: rangeSize(info.Size, streams)}
+	for i := int64(0); i < info.Size; i += p.RangeSize {
+		p.Offsets = append(p.Offsets, i)
+	}
+	return p
+}
+
+func loadRangeProgress(path string) *rangeProgress {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	p := &rangeProgress{path: path}
+	if json.Unmarshal(data, p) != nil || p.RangeSize <= 0 {
+		return nil
+	}
+	return p
+}
+
+func (p *rangeProgress) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.Offsets {
+		p.Offsets[i] = int64(i) * p.RangeSize
+	}
+}
+
+func (p *rangeProgress) end(i int) int64 {
+	return min(int64(i+1)*p.RangeSize, p.Size)
+}
+
+// advance records that range i is written up to offset, saving that now
+// and then.
+func (p *rangeProgress) advance(i int, offset int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Offsets[i] = offset
+	if time.Since(p.saved) > time.Second {
+		p.saveLocked()
+	}
+}
+
+func (p *rangeProgress) save() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.saveLocked()
+}
+
+func (p *rangeProgress) saveLocked() {
+	p.saved = time.Now()
+	data, err := json.Marshal(p)
+	if err == nil {
+		err = os.WriteFile(p.path, data, 0o644)
+	}
+	if err != nil {
+		log.Printf("Couldn't save the download to resume it later: %v", err)
+	}
+}
+
+// downloadRanges fetches name into the file at path on up to streams
+// streams at once, each writing its range of the file into path.part. How
+// far each got is kept in path.part.json, so running a download that broke
+// off again resumes every range.
+func downloadRanges(ctx context.Context, client pb.FileTransferServiceClient, name, path string, chunkSize, streams int) error {
+	partPath := path + ".part"
+	progressPath := partPath + ".json"
+	progress := loadRangeProgress(progressPath)
+	if _, err := os.Stat(partPath); err == nil && progress == nil {
+		// a download on one stream to pick up
+		return errSingleStream
+	}
+
+	for attempt := 0; ; attempt++ {
+		info, err := client.StatFile(ctx, &pb.StatRequest{FileName: name})
+		if err == nil && (rangeSize(info.Size, streams) == 0 || info.Sha256 == nil) {
+			return errSingleStream
+		}
+		if err == nil {
+			if progress == nil || progress.Size != info.Size || !bytes.Equal(progress.SHA256, info.Sha256) {
+				// nothing yet, or what there is is of a file since replaced
+				os.Remove(partPath)
+				progress = newRangeProgress(progressPath, info, streams)
+			} else if attempt == 0 {
+				log.Printf("Resuming download of %s in %d ranges", name, len(progress.Offsets))
+			}
+			err = fetchRanges(ctx, client, name, partPath, progress, chunkSize)
+		}
+		if err == nil {
+			break
+		}
+		if progress != nil {
+			progress.save()
+		}
+		if !retryable(err) || attempt >= maxAttempts {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		wait(attempt, err)
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return err
+	}
+	os.Remove(progressPath)
+	log.Printf("Downloaded %s to %s, %d bytes on %d streams", name, path, progress.Size, len(progress.Offsets))
+	return nil
+}
+
+// fetchRanges downloads what is left of every range into the part file and
+// checks all of it against its SHA-256.
+func fetchRanges(ctx context.Context, client pb.FileTransferServiceClient, name, partPath string, progress *rangeProgress, chunkSize int) error {
+	part, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(progress.Offsets))
+	var wg sync.WaitGroup
+	for i := range progress.Offsets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = fetchRange(ctx, client, part, name, progress, i, chunkSize); errs[i] != nil {
+				// the others would only be tried again with it
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	// the error that had the others canceled
+	var first error
+	for _, err := range errs {
+		if err != nil && (first == nil || status.Code(first) == codes.Canceled) {
+			first = err
+		}
+	}
+	if first != nil {
+		return first
+	}
+
+	if err := checkSHA256(part, progress.SHA256); err != nil {
+		// start over, what we have doesn't add up to the file
+		progress.reset()
+		part.Truncate(0)
+		return err
+	}
+	return part.Close()
+}
+
+// fetchRange downloads what is left of range i into part.
+func fetchRange(ctx context.Context, client pb.FileTransferServiceClient, part *os.File, name string, progress *rangeProgress, i, chunkSize int) error {
+	progress.mu.Lock()
+	offset, end := progress.Offsets[i], progress.end(i)
+	progress.mu.Unlock()
+	if offset == end {
+		return nil
+	}
+
+	stream, err := client.DownloadFile(ctx, &pb.FileRequest{FileName: name, ChunkSize: int32(chunkSize), Offset: offset, Length: end - offset})
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return status.Errorf(codes.Unavailable, "download of %s ended early", name)
+		}
+		if err != nil {
+			return err
+		}
+		if chunk.IsLastChunk {
+			if offset != end || chunk.Sha256 != nil && !bytes.Equal(chunk.Sha256, progress.SHA256) {
+				return status.Errorf(codes.DataLoss, "%s changed on the server during the download", name)
+			}
+			progress.advance(i, offset)
+			return nil
+		}
+		if chunk.Offset != offset {
+			return status.Errorf(codes.DataLoss, "got the chunk at %d when expecting %d", chunk.Offset, offset)
+		}
+		if crc32.Checksum(chunk.ChunkData, castagnoli) != chunk.Crc32C {
+			return status.Errorf(codes.DataLoss, "chunk at %d doesn't match its CRC", offset)
+		}
+		if _, err := part.WriteAt(chunk.ChunkData, offset); err != nil {
+			return err
+		}
+		offset += int64(len(chunk.ChunkData))
+		progress.advance(i, offset)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// upload sends the file at path to the server, stored as name, on up to
+// streams streams at once, each with a range of the file. The upload is
+// remembered until it is done, so running the same upload again after it
+// broke off picks up where it stopped.
+func upload(ctx context.Context, client pb.FileTransferServiceClient, server, path, name string, chunkSize, streams int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+	sum := hash.Sum(nil)
+	key := server + "|" + name + "|" + hex.EncodeToString(sum)
+
+	for attempt := 0; ; attempt++ {
+		id := savedUpload(key)
+		var session *pb.UploadSession
+		if id != "" {
+			session, err = client.GetUploadOffset(ctx, &pb.UploadOffsetRequest{UploadId: id})
+			if status.Code(err) == codes.NotFound {
+				forgetUpload(key)
+				continue
+			}
+			if err != nil {
+				if retryable(err) && attempt < maxAttempts {
+					wait(attempt, err)
+					continue
+				}
+				return err
+			}
+			if sent := sentBytes(session); sent > 0 {
+				log.Printf("Resuming upload of %s with %d of %d bytes there", path, sent, size)
+			}
+		} else {
+			session, err = client.StartUpload(ctx, &pb.UploadRequest{FileName: name, Size: size, Sha256: sum, RangeSize: rangeSize(size, streams)})
+			if err != nil {
+				return err
+			}
+			saveUpload(key, session.UploadId)
+		}
+
+		result, err := sendRanges(ctx, client, file, session, chunkSize)
+		if err == nil && result.Success {
+			forgetUpload(key)
+			log.Printf("Uploaded %s as %s, %d bytes: %s", path, name, size, result.Message)
+			return nil
+		}
+		if err == nil {
+			err = status.Error(codes.Unavailable, result.Message)
+		}
+		if !retryable(err) || attempt >= maxAttempts {
+			return fmt.Errorf("upload failed: %w", err)
+		}
+		wait(attempt, err)
+	}
+}
+
+// sendRanges sends what the server doesn't have yet of each range of the
+// upload, all at once. The stream that completes the last range has the
+// status of the file.
+func sendRanges(ctx context.Context, client pb.FileTransferServiceClient, file *os.File, session *pb.UploadSession, chunkSize int) (*pb.UploadStatus, error) {
+	results := make([]*pb.UploadStatus, len(session.RangeOffsets))
+	errs := make([]error, len(session.RangeOffsets))
+	var wg sync.WaitGroup
+	for i, offset := range session.RangeOffsets {
+		_, end := rangeBounds(session, i)
+		if offset == end {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = sendFrom(ctx, client, file, session.UploadId, session.FileName, offset, end, chunkSize)
+		}()
+	}
+	wg.Wait()
+
+	var last *pb.UploadStatus
+	for i, result := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if result != nil && (result.Success || last == nil) {
+			last = result
+		}
+	}
+	if last == nil {
+		// every range is there, but the stream that would have finished
+		// the upload broke off; a chunk with nothing in it at the end does
+		return sendFrom(ctx, client, file, session.UploadId, session.FileName, session.Size, session.Size, chunkSize)
+	}
+	return last, nil
+}
+
+// sendFrom streams the file from offset up to end as upload id.
+func sendFrom(ctx context.Context, client pb.FileTransferServiceClient, file io.ReaderAt, id, name string, offset, end int64, chunkSize int) (*pb.UploadStatus, error) {
+	r := io.NewSectionReader(file, offset, end-offset)
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, chunkSize)
+	for chunkIndex := int32(0); ; chunkIndex++ {
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			stream.CloseSend()
+			return nil, err
+		}
+		// with nothing left to send, a chunk still has to say so
+		if n > 0 || chunkIndex == 0 {
+			if err := stream.Send(&pb.FileChunk{
+				FileName:    name,
+				UploadId:    id,
+				Offset:      offset,
+				ChunkData:   buf[:n],
+				Crc32C:      crc32.Checksum(buf[:n], castagnoli),
+				ChunkIndex:  chunkIndex,
+				IsLastChunk: last || offset+int64(n) == end,
+			}); err != nil {
+				// the server said why in the status CloseAndRecv returns
+				break
+			}
+			offset += int64(n)
+		}
+		if last || offset == end {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// The uploads not yet done are kept by server, name and SHA-256 in the
+// user's cache directory.
+func uploadsFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "filetransfer", "uploads.json")
+}
+
+func loadUploads() map[string]string {
+	uploads := make(map[string]string)
+	if data, err := os.ReadFile(uploadsFile()); err == nil {
+		json.Unmarshal(data, &uploads)
+	}
+	return uploads
+}
+
+func storeUploads(uploads map[string]string) {
+	data, err := json.MarshalIndent(uploads, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(uploadsFile()), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(uploadsFile(), data, 0o600)
+	}
+	if err != nil {
+		log.Printf("Couldn't save the upload to resume it later: %v", err)
+	}
+}
+
+func savedUpload(key string) string {
+	return loadUploads()[key]
+}
+
+func saveUpload(key, id string) {
+	uploads := loadUploads()
+	uploads[key] = id
+	storeUploads(uploads)
+}
+
+func forgetUpload(key string) {
+	uploads := loadUploads()
+	delete(uploads, key)
+	storeUploads(uploads)
+}
@@ -25,6 +25,8 @@ type FileRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
 	ChunkSize     int32                  `protobuf:"varint,2,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+	Offset        int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Length        int64                  `protobuf:"varint,4,opt,name=length,proto3" json:"length,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,12 +75,30 @@ func (x *FileRequest) GetChunkSize() int32 {
 	return 0
 }
 
+func (x *FileRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *FileRequest) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
 type FileChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChunkData     []byte                 `protobuf:"bytes,1,opt,name=chunk_data,json=chunkData,proto3" json:"chunk_data,omitempty"`
 	ChunkIndex    int32                  `protobuf:"varint,2,opt,name=chunk_index,json=chunkIndex,proto3" json:"chunk_index,omitempty"`
 	IsLastChunk   bool                   `protobuf:"varint,3,opt,name=is_last_chunk,json=isLastChunk,proto3" json:"is_last_chunk,omitempty"`
 	FileName      string                 `protobuf:"bytes,4,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	UploadId      string                 `protobuf:"bytes,5,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,6,opt,name=offset,proto3" json:"offset,omitempty"`
+	Crc32C        uint32                 `protobuf:"varint,7,opt,name=crc32c,proto3" json:"crc32c,omitempty"`
+	Sha256        []byte                 `protobuf:"bytes,8,opt,name=sha256,proto3" json:"sha256,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -141,10 +161,39 @@ func (x *FileChunk) GetFileName() string {
 	return ""
 }
 
+func (x *FileChunk) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *FileChunk) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *FileChunk) GetCrc32C() uint32 {
+	if x != nil {
+		return x.Crc32C
+	}
+	return 0
+}
+
+func (x *FileChunk) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
 type UploadStatus struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Offset        int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -193,29 +242,872 @@ func (x *UploadStatus) GetMessage() string {
 	return ""
 }
 
-var File_grpc_filetransfer_proto protoreflect.FileDescriptor
+func (x *UploadStatus) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
 
-const file_grpc_filetransfer_proto_rawDesc = "" +
-	"\n" +
-	"\x17grpc/filetransfer.proto\x12\ffiletransfer\"I\n" +
-	"\vFileRequest\x12\x1b\n" +
-	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x1d\n" +
-	"\n" +
-	"chunk_size\x18\x02 \x01(\x05R\tchunkSize\"\x8c\x01\n" +
-	"\tFileChunk\x12\x1d\n" +
-	"\n" +
-	"chunk_data\x18\x01 \x01(\fR\tchunkData\x12\x1f\n" +
-	"\vchunk_index\x18\x02 \x01(\x05R\n" +
-	"chunkIndex\x12\"\n" +
-	"\ris_last_chunk\x18\x03 \x01(\bR\visLastChunk\x12\x1b\n" +
-	"\tfile_name\x18\x04 \x01(\tR\bfileName\"B\n" +
-	"\fUploadStatus\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2\xa0\x01\n" +
-	"\x13FileTransferService\x12C\n" +
-	"\n" +
-	"UploadFile\x12\x17.filetransfer.FileChunk\x1a\x1a.filetransfer.UploadStatus(\x01\x12D\n" +
-	"\fDownloadFile\x12\x19.filetransfer.FileRequest\x1a\x17.filetransfer.FileChunk0\x01B!Z\x1fexample/hello/filetransfer/grpcb\x06proto3"
+type UploadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        []byte                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	RangeSize     int64                  `protobuf:"varint,4,opt,name=range_size,json=rangeSize,proto3" json:"range_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadRequest) Reset() {
+	*x = UploadRequest{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadRequest) ProtoMessage() {}
+
+func (x *UploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadRequest.ProtoReflect.Descriptor instead.
+func (*UploadRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UploadRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *UploadRequest) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadRequest) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+func (x *UploadRequest) GetRangeSize() int64 {
+	if x != nil {
+		return x.RangeSize
+	}
+	return 0
+}
+
+type UploadOffsetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadOffsetRequest) Reset() {
+	*x = UploadOffsetRequest{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadOffsetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadOffsetRequest) ProtoMessage() {}
+
+func (x *UploadOffsetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadOffsetRequest.ProtoReflect.Descriptor instead.
+func (*UploadOffsetRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UploadOffsetRequest) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+type UploadSession struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UploadId      string                 `protobuf:"bytes,1,opt,name=upload_id,json=uploadId,proto3" json:"upload_id,omitempty"`
+	FileName      string                 `protobuf:"bytes,2,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Offset        int64                  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	RangeSize     int64                  `protobuf:"varint,5,opt,name=range_size,json=rangeSize,proto3" json:"range_size,omitempty"`
+	RangeOffsets  []int64                `protobuf:"varint,6,rep,packed,name=range_offsets,json=rangeOffsets,proto3" json:"range_offsets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadSession) Reset() {
+	*x = UploadSession{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadSession) ProtoMessage() {}
+
+func (x *UploadSession) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadSession.ProtoReflect.Descriptor instead.
+func (*UploadSession) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UploadSession) GetUploadId() string {
+	if x != nil {
+		return x.UploadId
+	}
+	return ""
+}
+
+func (x *UploadSession) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *UploadSession) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadSession) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *UploadSession) GetRangeSize() int64 {
+	if x != nil {
+		return x.RangeSize
+	}
+	return 0
+}
+
+func (x *UploadSession) GetRangeOffsets() []int64 {
+	if x != nil {
+		return x.RangeOffsets
+	}
+	return nil
+}
+
+type ListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dir           string                 `protobuf:"bytes,1,opt,name=dir,proto3" json:"dir,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListRequest) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+type FileInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Modified      int64                  `protobuf:"varint,3,opt,name=modified,proto3" json:"modified,omitempty"`
+	IsDir         bool                   `protobuf:"varint,4,opt,name=is_dir,json=isDir,proto3" json:"is_dir,omitempty"`
+	ContentType   string                 `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Sha256        []byte                 `protobuf:"bytes,6,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Uploader      string                 `protobuf:"bytes,7,opt,name=uploader,proto3" json:"uploader,omitempty"`
+	Created       int64                  `protobuf:"varint,8,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileInfo) Reset() {
+	*x = FileInfo{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileInfo) ProtoMessage() {}
+
+func (x *FileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
+func (*FileInfo) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FileInfo) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *FileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileInfo) GetModified() int64 {
+	if x != nil {
+		return x.Modified
+	}
+	return 0
+}
+
+func (x *FileInfo) GetIsDir() bool {
+	if x != nil {
+		return x.IsDir
+	}
+	return false
+}
+
+func (x *FileInfo) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *FileInfo) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+func (x *FileInfo) GetUploader() string {
+	if x != nil {
+		return x.Uploader
+	}
+	return ""
+}
+
+func (x *FileInfo) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+type FileList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*FileInfo            `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileList) Reset() {
+	*x = FileList{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileList) ProtoMessage() {}
+
+func (x *FileList) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileList.ProtoReflect.Descriptor instead.
+func (*FileList) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FileList) GetFiles() []*FileInfo {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type StatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatRequest) Reset() {
+	*x = StatRequest{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatRequest) ProtoMessage() {}
+
+func (x *StatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatRequest.ProtoReflect.Descriptor instead.
+func (*StatRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StatRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{11}
+}
+
+type RenameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	NewName       string                 `protobuf:"bytes,2,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenameRequest) Reset() {
+	*x = RenameRequest{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenameRequest) ProtoMessage() {}
+
+func (x *RenameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenameRequest.ProtoReflect.Descriptor instead.
+func (*RenameRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RenameRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *RenameRequest) GetNewName() string {
+	if x != nil {
+		return x.NewName
+	}
+	return ""
+}
+
+type ChunkRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha256        []byte                 `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkRef) Reset() {
+	*x = ChunkRef{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkRef) ProtoMessage() {}
+
+func (x *ChunkRef) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkRef.ProtoReflect.Descriptor instead.
+func (*ChunkRef) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ChunkRef) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+func (x *ChunkRef) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type ChunkList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Chunks        []*ChunkRef            `protobuf:"bytes,2,rep,name=chunks,proto3" json:"chunks,omitempty"`
+	Sha256        []byte                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkList) Reset() {
+	*x = ChunkList{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkList) ProtoMessage() {}
+
+func (x *ChunkList) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkList.ProtoReflect.Descriptor instead.
+func (*ChunkList) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ChunkList) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *ChunkList) GetChunks() []*ChunkRef {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+func (x *ChunkList) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+type ChunkNeeds struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Missing       []int32                `protobuf:"varint,1,rep,packed,name=missing,proto3" json:"missing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkNeeds) Reset() {
+	*x = ChunkNeeds{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkNeeds) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkNeeds) ProtoMessage() {}
+
+func (x *ChunkNeeds) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkNeeds.ProtoReflect.Descriptor instead.
+func (*ChunkNeeds) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ChunkNeeds) GetMissing() []int32 {
+	if x != nil {
+		return x.Missing
+	}
+	return nil
+}
+
+type Chunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Sha256        []byte                 `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_grpc_filetransfer_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_filetransfer_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_grpc_filetransfer_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Chunk) GetSha256() []byte {
+	if x != nil {
+		return x.Sha256
+	}
+	return nil
+}
+
+var File_grpc_filetransfer_proto protoreflect.FileDescriptor
+
+const file_grpc_filetransfer_proto_rawDesc = "" +
+	"\n" +
+	"\x17grpc/filetransfer.proto\x12\ffiletransfer\"y\n" +
+	"\vFileRequest\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x02 \x01(\x05R\tchunkSize\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12\x16\n" +
+	"\x06length\x18\x04 \x01(\x03R\x06length\"\xf1\x01\n" +
+	"\tFileChunk\x12\x1d\n" +
+	"\n" +
+	"chunk_data\x18\x01 \x01(\fR\tchunkData\x12\x1f\n" +
+	"\vchunk_index\x18\x02 \x01(\x05R\n" +
+	"chunkIndex\x12\"\n" +
+	"\ris_last_chunk\x18\x03 \x01(\bR\visLastChunk\x12\x1b\n" +
+	"\tfile_name\x18\x04 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tupload_id\x18\x05 \x01(\tR\buploadId\x12\x16\n" +
+	"\x06offset\x18\x06 \x01(\x03R\x06offset\x12\x16\n" +
+	"\x06crc32c\x18\a \x01(\rR\x06crc32c\x12\x16\n" +
+	"\x06sha256\x18\b \x01(\fR\x06sha256\"Z\n" +
+	"\fUploadStatus\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\"w\n" +
+	"\rUploadRequest\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\fR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"range_size\x18\x04 \x01(\x03R\trangeSize\"2\n" +
+	"\x13UploadOffsetRequest\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\"\xb9\x01\n" +
+	"\rUploadSession\x12\x1b\n" +
+	"\tupload_id\x18\x01 \x01(\tR\buploadId\x12\x1b\n" +
+	"\tfile_name\x18\x02 \x01(\tR\bfileName\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x03R\x06offset\x12\x1d\n" +
+	"\n" +
+	"range_size\x18\x05 \x01(\x03R\trangeSize\x12#\n" +
+	"\rrange_offsets\x18\x06 \x03(\x03R\frangeOffsets\"\x1f\n" +
+	"\vListRequest\x12\x10\n" +
+	"\x03dir\x18\x01 \x01(\tR\x03dir\"\xdf\x01\n" +
+	"\bFileInfo\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x1a\n" +
+	"\bmodified\x18\x03 \x01(\x03R\bmodified\x12\x15\n" +
+	"\x06is_dir\x18\x04 \x01(\bR\x05isDir\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\x12\x16\n" +
+	"\x06sha256\x18\x06 \x01(\fR\x06sha256\x12\x1a\n" +
+	"\buploader\x18\a \x01(\tR\buploader\x12\x18\n" +
+	"\acreated\x18\b \x01(\x03R\acreated\"8\n" +
+	"\bFileList\x12,\n" +
+	"\x05files\x18\x01 \x03(\v2\x16.filetransfer.FileInfoR\x05files\"*\n" +
+	"\vStatRequest\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\",\n" +
+	"\rDeleteRequest\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\"\x10\n" +
+	"\x0eDeleteResponse\"G\n" +
+	"\rRenameRequest\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x19\n" +
+	"\bnew_name\x18\x02 \x01(\tR\anewName\"6\n" +
+	"\bChunkRef\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\fR\x06sha256\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\"p\n" +
+	"\tChunkList\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12.\n" +
+	"\x06chunks\x18\x02 \x03(\v2\x16.filetransfer.ChunkRefR\x06chunks\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\fR\x06sha256\"&\n" +
+	"\n" +
+	"ChunkNeeds\x12\x18\n" +
+	"\amissing\x18\x01 \x03(\x05R\amissing\"3\n" +
+	"\x05Chunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\fR\x06sha2562\x91\x06\n" +
+	"\x13FileTransferService\x12C\n" +
+	"\n" +
+	"UploadFile\x12\x17.filetransfer.FileChunk\x1a\x1a.filetransfer.UploadStatus(\x01\x12D\n" +
+	"\fDownloadFile\x12\x19.filetransfer.FileRequest\x1a\x17.filetransfer.FileChunk0\x01\x12>\n" +
+	"\tListFiles\x12\x19.filetransfer.ListRequest\x1a\x16.filetransfer.FileList\x12G\n" +
+	"\vStartUpload\x12\x1b.filetransfer.UploadRequest\x1a\x1b.filetransfer.UploadSession\x12Q\n" +
+	"\x0fGetUploadOffset\x12!.filetransfer.UploadOffsetRequest\x1a\x1b.filetransfer.UploadSession\x12=\n" +
+	"\bStatFile\x12\x19.filetransfer.StatRequest\x1a\x16.filetransfer.FileInfo\x12G\n" +
+	"\n" +
+	"DeleteFile\x12\x1b.filetransfer.DeleteRequest\x1a\x1c.filetransfer.DeleteResponse\x12A\n" +
+	"\n" +
+	"RenameFile\x12\x1b.filetransfer.RenameRequest\x1a\x16.filetransfer.FileInfo\x12D\n" +
+	"\x0fNegotiateChunks\x12\x17.filetransfer.ChunkList\x1a\x18.filetransfer.ChunkNeeds\x12A\n" +
+	"\fUploadChunks\x12\x13.filetransfer.Chunk\x1a\x1a.filetransfer.UploadStatus(\x01\x12?\n" +
+	"\fCommitChunks\x12\x17.filetransfer.ChunkList\x1a\x16.filetransfer.FileInfoB!Z\x1fexample/hello/filetransfer/grpcb\x06proto3"
 
 var (
 	file_grpc_filetransfer_proto_rawDescOnce sync.Once
@@ -229,22 +1121,56 @@ func file_grpc_filetransfer_proto_rawDescGZIP() []byte {
 	return file_grpc_filetransfer_proto_rawDescData
 }
 
-var file_grpc_filetransfer_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_grpc_filetransfer_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_grpc_filetransfer_proto_goTypes = []any{
-	(*FileRequest)(nil),  // 0: filetransfer.FileRequest
-	(*FileChunk)(nil),    // 1: filetransfer.FileChunk
-	(*UploadStatus)(nil), // 2: filetransfer.UploadStatus
+	(*FileRequest)(nil),         // 0: filetransfer.FileRequest
+	(*FileChunk)(nil),           // 1: filetransfer.FileChunk
+	(*UploadStatus)(nil),        // 2: filetransfer.UploadStatus
+	(*UploadRequest)(nil),       // 3: filetransfer.UploadRequest
+	(*UploadOffsetRequest)(nil), // 4: filetransfer.UploadOffsetRequest
+	(*UploadSession)(nil),       // 5: filetransfer.UploadSession
+	(*ListRequest)(nil),         // 6: filetransfer.ListRequest
+	(*FileInfo)(nil),            // 7: filetransfer.FileInfo
+	(*FileList)(nil),            // 8: filetransfer.FileList
+	(*StatRequest)(nil),         // 9: filetransfer.StatRequest
+	(*DeleteRequest)(nil),       // 10: filetransfer.DeleteRequest
+	(*DeleteResponse)(nil),      // 11: filetransfer.DeleteResponse
+	(*RenameRequest)(nil),       // 12: filetransfer.RenameRequest
+	(*ChunkRef)(nil),            // 13: filetransfer.ChunkRef
+	(*ChunkList)(nil),           // 14: filetransfer.ChunkList
+	(*ChunkNeeds)(nil),          // 15: filetransfer.ChunkNeeds
+	(*Chunk)(nil),               // 16: filetransfer.Chunk
 }
 var file_grpc_filetransfer_proto_depIdxs = []int32{
-	1, // 0: filetransfer.FileTransferService.UploadFile:input_type -> filetransfer.FileChunk
-	0, // 1: filetransfer.FileTransferService.DownloadFile:input_type -> filetransfer.FileRequest
-	2, // 2: filetransfer.FileTransferService.UploadFile:output_type -> filetransfer.UploadStatus
-	1, // 3: filetransfer.FileTransferService.DownloadFile:output_type -> filetransfer.FileChunk
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	7,  // 0: filetransfer.FileList.files:type_name -> filetransfer.FileInfo
+	13, // 1: filetransfer.ChunkList.chunks:type_name -> filetransfer.ChunkRef
+	1,  // 2: filetransfer.FileTransferService.UploadFile:input_type -> filetransfer.FileChunk
+	0,  // 3: filetransfer.FileTransferService.DownloadFile:input_type -> filetransfer.FileRequest
+	6,  // 4: filetransfer.FileTransferService.ListFiles:input_type -> filetransfer.ListRequest
+	3,  // 5: filetransfer.FileTransferService.StartUpload:input_type -> filetransfer.UploadRequest
+	4,  // 6: filetransfer.FileTransferService.GetUploadOffset:input_type -> filetransfer.UploadOffsetRequest
+	9,  // 7: filetransfer.FileTransferService.StatFile:input_type -> filetransfer.StatRequest
+	10, // 8: filetransfer.FileTransferService.DeleteFile:input_type -> filetransfer.DeleteRequest
+	12, // 9: filetransfer.FileTransferService.RenameFile:input_type -> filetransfer.RenameRequest
+	14, // 10: filetransfer.FileTransferService.NegotiateChunks:input_type -> filetransfer.ChunkList
+	16, // 11: filetransfer.FileTransferService.UploadChunks:input_type -> filetransfer.Chunk
+	14, // 12: filetransfer.FileTransferService.CommitChunks:input_type -> filetransfer.ChunkList
+	2,  // 13: filetransfer.FileTransferService.UploadFile:output_type -> filetransfer.UploadStatus
+	1,  // 14: filetransfer.FileTransferService.DownloadFile:output_type -> filetransfer.FileChunk
+	8,  // 15: filetransfer.FileTransferService.ListFiles:output_type -> filetransfer.FileList
+	5,  // 16: filetransfer.FileTransferService.StartUpload:output_type -> filetransfer.UploadSession
+	5,  // 17: filetransfer.FileTransferService.GetUploadOffset:output_type -> filetransfer.UploadSession
+	7,  // 18: filetransfer.FileTransferService.StatFile:output_type -> filetransfer.FileInfo
+	11, // 19: filetransfer.FileTransferService.DeleteFile:output_type -> filetransfer.DeleteResponse
+	7,  // 20: filetransfer.FileTransferService.RenameFile:output_type -> filetransfer.FileInfo
+	15, // 21: filetransfer.FileTransferService.NegotiateChunks:output_type -> filetransfer.ChunkNeeds
+	2,  // 22: filetransfer.FileTransferService.UploadChunks:output_type -> filetransfer.UploadStatus
+	7,  // 23: filetransfer.FileTransferService.CommitChunks:output_type -> filetransfer.FileInfo
+	13, // [13:24] is the sub-list for method output_type
+	2,  // [2:13] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_grpc_filetransfer_proto_init() }
@@ -258,7 +1184,7 @@ func file_grpc_filetransfer_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grpc_filetransfer_proto_rawDesc), len(file_grpc_filetransfer_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,8 +19,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	FileTransferService_UploadFile_FullMethodName   = "/filetransfer.FileTransferService/UploadFile"
-	FileTransferService_DownloadFile_FullMethodName = "/filetransfer.FileTransferService/DownloadFile"
+	FileTransferService_UploadFile_FullMethodName      = "/filetransfer.FileTransferService/UploadFile"
+	FileTransferService_DownloadFile_FullMethodName    = "/filetransfer.FileTransferService/DownloadFile"
+	FileTransferService_ListFiles_FullMethodName       = "/filetransfer.FileTransferService/ListFiles"
+	FileTransferService_StartUpload_FullMethodName     = "/filetransfer.FileTransferService/StartUpload"
+	FileTransferService_GetUploadOffset_FullMethodName = "/filetransfer.FileTransferService/GetUploadOffset"
+	FileTransferService_StatFile_FullMethodName        = "/filetransfer.FileTransferService/StatFile"
+	FileTransferService_DeleteFile_FullMethodName      = "/filetransfer.FileTransferService/DeleteFile"
+	FileTransferService_RenameFile_FullMethodName      = "/filetransfer.FileTransferService/RenameFile"
+	FileTransferService_NegotiateChunks_FullMethodName = "/filetransfer.FileTransferService/NegotiateChunks"
+	FileTransferService_UploadChunks_FullMethodName    = "/filetransfer.FileTransferService/UploadChunks"
+	FileTransferService_CommitChunks_FullMethodName    = "/filetransfer.FileTransferService/CommitChunks"
 )
 
 // FileTransferServiceClient is the client API for FileTransferService service.
@@ -29,6 +38,15 @@ const (
 type FileTransferServiceClient interface {
 	UploadFile(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[FileChunk, UploadStatus], error)
 	DownloadFile(ctx context.Context, in *FileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error)
+	ListFiles(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*FileList, error)
+	StartUpload(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadSession, error)
+	GetUploadOffset(ctx context.Context, in *UploadOffsetRequest, opts ...grpc.CallOption) (*UploadSession, error)
+	StatFile(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	DeleteFile(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	RenameFile(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	NegotiateChunks(ctx context.Context, in *ChunkList, opts ...grpc.CallOption) (*ChunkNeeds, error)
+	UploadChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Chunk, UploadStatus], error)
+	CommitChunks(ctx context.Context, in *ChunkList, opts ...grpc.CallOption) (*FileInfo, error)
 }
 
 type fileTransferServiceClient struct {
@@ -71,12 +89,114 @@ func (c *fileTransferServiceClient) DownloadFile(ctx context.Context, in *FileRe
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type FileTransferService_DownloadFileClient = grpc.ServerStreamingClient[FileChunk]
 
+func (c *fileTransferServiceClient) ListFiles(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*FileList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileList)
+	err := c.cc.Invoke(ctx, FileTransferService_ListFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) StartUpload(ctx context.Context, in *UploadRequest, opts ...grpc.CallOption) (*UploadSession, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadSession)
+	err := c.cc.Invoke(ctx, FileTransferService_StartUpload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) GetUploadOffset(ctx context.Context, in *UploadOffsetRequest, opts ...grpc.CallOption) (*UploadSession, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadSession)
+	err := c.cc.Invoke(ctx, FileTransferService_GetUploadOffset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) StatFile(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, FileTransferService_StatFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) DeleteFile(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_DeleteFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) RenameFile(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, FileTransferService_RenameFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) NegotiateChunks(ctx context.Context, in *ChunkList, opts ...grpc.CallOption) (*ChunkNeeds, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChunkNeeds)
+	err := c.cc.Invoke(ctx, FileTransferService_NegotiateChunks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) UploadChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Chunk, UploadStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileTransferService_ServiceDesc.Streams[2], FileTransferService_UploadChunks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Chunk, UploadStatus]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_UploadChunksClient = grpc.ClientStreamingClient[Chunk, UploadStatus]
+
+func (c *fileTransferServiceClient) CommitChunks(ctx context.Context, in *ChunkList, opts ...grpc.CallOption) (*FileInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, FileTransferService_CommitChunks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FileTransferServiceServer is the server API for FileTransferService service.
 // All implementations must embed UnimplementedFileTransferServiceServer
 // for forward compatibility.
 type FileTransferServiceServer interface {
 	UploadFile(grpc.ClientStreamingServer[FileChunk, UploadStatus]) error
 	DownloadFile(*FileRequest, grpc.ServerStreamingServer[FileChunk]) error
+	ListFiles(context.Context, *ListRequest) (*FileList, error)
+	StartUpload(context.Context, *UploadRequest) (*UploadSession, error)
+	GetUploadOffset(context.Context, *UploadOffsetRequest) (*UploadSession, error)
+	StatFile(context.Context, *StatRequest) (*FileInfo, error)
+	DeleteFile(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	RenameFile(context.Context, *RenameRequest) (*FileInfo, error)
+	NegotiateChunks(context.Context, *ChunkList) (*ChunkNeeds, error)
+	UploadChunks(grpc.ClientStreamingServer[Chunk, UploadStatus]) error
+	CommitChunks(context.Context, *ChunkList) (*FileInfo, error)
 	mustEmbedUnimplementedFileTransferServiceServer()
 }
 
@@ -93,6 +213,33 @@ func (UnimplementedFileTransferServiceServer) UploadFile(grpc.ClientStreamingSer
 func (UnimplementedFileTransferServiceServer) DownloadFile(*FileRequest, grpc.ServerStreamingServer[FileChunk]) error {
 	return status.Errorf(codes.Unimplemented, "method DownloadFile not implemented")
 }
+func (UnimplementedFileTransferServiceServer) ListFiles(context.Context, *ListRequest) (*FileList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
+}
+func (UnimplementedFileTransferServiceServer) StartUpload(context.Context, *UploadRequest) (*UploadSession, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartUpload not implemented")
+}
+func (UnimplementedFileTransferServiceServer) GetUploadOffset(context.Context, *UploadOffsetRequest) (*UploadSession, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUploadOffset not implemented")
+}
+func (UnimplementedFileTransferServiceServer) StatFile(context.Context, *StatRequest) (*FileInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StatFile not implemented")
+}
+func (UnimplementedFileTransferServiceServer) DeleteFile(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
+}
+func (UnimplementedFileTransferServiceServer) RenameFile(context.Context, *RenameRequest) (*FileInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenameFile not implemented")
+}
+func (UnimplementedFileTransferServiceServer) NegotiateChunks(context.Context, *ChunkList) (*ChunkNeeds, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NegotiateChunks not implemented")
+}
+func (UnimplementedFileTransferServiceServer) UploadChunks(grpc.ClientStreamingServer[Chunk, UploadStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadChunks not implemented")
+}
+func (UnimplementedFileTransferServiceServer) CommitChunks(context.Context, *ChunkList) (*FileInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitChunks not implemented")
+}
 func (UnimplementedFileTransferServiceServer) mustEmbedUnimplementedFileTransferServiceServer() {}
 func (UnimplementedFileTransferServiceServer) testEmbeddedByValue()                             {}
 
@@ -132,13 +279,197 @@ func _FileTransferService_DownloadFile_Handler(srv interface{}, stream grpc.Serv
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type FileTransferService_DownloadFileServer = grpc.ServerStreamingServer[FileChunk]
 
+func _FileTransferService_ListFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).ListFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_ListFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).ListFiles(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_StartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).StartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_StartUpload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).StartUpload(ctx, req.(*UploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_GetUploadOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadOffsetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).GetUploadOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_GetUploadOffset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).GetUploadOffset(ctx, req.(*UploadOffsetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_StatFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).StatFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_StatFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).StatFile(ctx, req.(*StatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).DeleteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_DeleteFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).DeleteFile(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_RenameFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).RenameFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_RenameFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).RenameFile(ctx, req.(*RenameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_NegotiateChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChunkList)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).NegotiateChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_NegotiateChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).NegotiateChunks(ctx, req.(*ChunkList))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_UploadChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileTransferServiceServer).UploadChunks(&grpc.GenericServerStream[Chunk, UploadStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_UploadChunksServer = grpc.ClientStreamingServer[Chunk, UploadStatus]
+
+func _FileTransferService_CommitChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChunkList)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).CommitChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_CommitChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).CommitChunks(ctx, req.(*ChunkList))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FileTransferService_ServiceDesc is the grpc.ServiceDesc for FileTransferService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var FileTransferService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "filetransfer.FileTransferService",
 	HandlerType: (*FileTransferServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListFiles",
+			Handler:    _FileTransferService_ListFiles_Handler,
+		},
+		{
+			MethodName: "StartUpload",
+			Handler:    _FileTransferService_StartUpload_Handler,
+		},
+		{
+			MethodName: "GetUploadOffset",
+			Handler:    _FileTransferService_GetUploadOffset_Handler,
+		},
+		{
+			MethodName: "StatFile",
+			Handler:    _FileTransferService_StatFile_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _FileTransferService_DeleteFile_Handler,
+		},
+		{
+			MethodName: "RenameFile",
+			Handler:    _FileTransferService_RenameFile_Handler,
+		},
+		{
+			MethodName: "NegotiateChunks",
+			Handler:    _FileTransferService_NegotiateChunks_Handler,
+		},
+		{
+			MethodName: "CommitChunks",
+			Handler:    _FileTransferService_CommitChunks_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "UploadFile",
@@ -150,6 +481,11 @@ var FileTransferService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _FileTransferService_DownloadFile_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "UploadChunks",
+			Handler:       _FileTransferService_UploadChunks_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "grpc/filetransfer.proto",
 }
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"example/hello/filetransfer/chunker"
+)
+
+// chunksDir holds the chunks under the root, each named after its SHA-256
+// in a directory named after the first two hex digits of it.
+const chunksDir = ".chunks"
+
+var errMissingChunk = errors.New("missing chunk")
+
+// chunkStore keeps each file as a manifest listing its chunks, and each
+// chunk once however many files have it. The manifests are kept by a
+// localDisk, which has the directories and names; the reference counts are
+// worked out from them when the store opens.
+type chunkStore struct {
+	files *localDisk
+	dir   string
+
+	mu   sync.Mutex     // held while a manifest and the counts change together
+	refs map[string]int // manifests listing each chunk, by hex SHA-256
+}
+
+type chunkRef struct {
+	SHA256 []byte `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+type manifest struct {
+	FileMeta
+	Chunks []chunkRef `json:"chunks"`
+}
+
+func newChunkStore(root string) (*chunkStore, error) {
+	files, err := newLocalDisk(root)
+	if err != nil {
+		return nil, err
+	}
+	c := &chunkStore{files: files, dir: filepath.Join(root, chunksDir), refs: make(map[string]int)}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != root {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		name, _ := filepath.Rel(root, path)
+		m, err := c.manifest(filepath.ToSlash(name))
+		if err != nil {
+			log.Printf("skipping %s, it isn't a manifest: %v", name, err)
+			return nil
+		}
+		c.count(m.Chunks, 1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *chunkStore) chunkPath(sum []byte) string {
+	h := hex.EncodeToString(sum)
+	return filepath.Join(c.dir, h[:2], h)
+}
+
+func (c *chunkStore) count(chunks []chunkRef, n int) {
+	for _, ref := range chunks {
+		h := hex.EncodeToString(ref.SHA256)
+		if c.refs[h] += n; c.refs[h] <= 0 {
+			delete(c.refs, h)
+		}
+	}
+}
+
+// has says whether the chunk is stored, and marks it as just used so
+// collect leaves it alone for a while even if no file lists it yet.
+func (c *chunkStore) has(ref chunkRef) bool {
+	path := c.chunkPath(ref.SHA256)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != ref.Size {
+		return false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return true
+}
+
+// putChunk stores data, which has the SHA-256 sum, unless it is there.
+func (c *chunkStore) putChunk(sum, data []byte) error {
+	if c.has(chunkRef{SHA256: sum, Size: int64(len(data))}) {
+		return nil
+	}
+	path := c.chunkPath(sum)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".chunk-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *chunkStore) manifest(name string) (*manifest, error) {
+	r, _, err := c.files.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var m manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	// renaming moves the manifest without rewriting it
+	m.Name = name
+	return &m, nil
+}
+
+func (c *chunkStore) Put(name string, r io.Reader, meta FileMeta) (FileMeta, error) {
+	name, err := cleanPath(name)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	br := bufio.NewReader(r)
+	// an error Peek runs into is only returned once, here
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return FileMeta{}, err
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = contentType(name, head)
+	}
+
+	var chunks []chunkRef
+	var size int64
+	hash := sha256.New()
+	for split := chunker.New(br); ; {
+		data, err := split.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return FileMeta{}, err
+		}
+		sum := sha256.Sum256(data)
+		if err := c.putChunk(sum[:], data); err != nil {
+			return FileMeta{}, err
+		}
+		hash.Write(data)
+		chunks = append(chunks, chunkRef{SHA256: sum[:], Size: int64(len(data))})
+		size += int64(len(data))
+	}
+	sum := hash.Sum(nil)
+	if meta.SHA256 != nil && !bytes.Equal(meta.SHA256, sum) {
+		return FileMeta{}, errChecksum
+	}
+	meta.Size, meta.SHA256 = size, sum
+	return c.putManifest(name, chunks, meta)
+}
+
+// commit stores name as the chunks listed, which all have to be there
+// already, checking them against meta's SHA-256 if it has one.
+func (c *chunkStore) commit(name string, chunks []chunkRef, meta FileMeta) (FileMeta, error) {
+	name, err := cleanPath(name)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	hash := sha256.New()
+	var size int64
+	for _, ref := range chunks {
+		f, err := os.Open(c.chunkPath(ref.SHA256))
+		if errors.Is(err, fs.ErrNotExist) {
+			return FileMeta{}, errMissingChunk
+		}
+		if err != nil {
+			return FileMeta{}, err
+		}
+		n, err := io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return FileMeta{}, err
+		}
+		if n != ref.Size {
+			return FileMeta{}, errMissingChunk
+		}
+		size += n
+	}
+	sum := hash.Sum(nil)
+	if meta.SHA256 != nil && !bytes.Equal(meta.SHA256, sum) {
+		return FileMeta{}, errChecksum
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = contentType(name, nil)
+	}
+	meta.Size, meta.SHA256 = size, sum
+	return c.putManifest(name, chunks, meta)
+}
+
+func (c *chunkStore) putManifest(name string, chunks []chunkRef, meta FileMeta) (FileMeta, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a chunk collect took since it was put or checked can't be listed
+	for _, ref := range chunks {
+		if !c.has(ref) {
+			return FileMeta{}, errMissingChunk
+		}
+	}
+	old, err := c.manifest(name)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return FileMeta{}, err
+	}
+	now := time.Now().UTC()
+	meta.Name, meta.Dir = name, false
+	meta.Created, meta.Modified = now, now
+	if old != nil {
+		meta.Created = old.Created
+	}
+	data, err := json.Marshal(manifest{FileMeta: meta, Chunks: chunks})
+	if err != nil {
+		return FileMeta{}, err
+	}
+	if _, err := c.files.Put(name, bytes.NewReader(data), FileMeta{ContentType: "application/json"}); err != nil {
+		return FileMeta{}, err
+	}
+	c.count(chunks, 1)
+	if old != nil {
+		c.count(old.Chunks, -1)
+	}
+	return meta, nil
+}
+
+func (c *chunkStore) Get(name string) (io.ReadSeekCloser, FileMeta, error) {
+	meta, err := c.files.Stat(name)
+	if err != nil {
+		return nil, FileMeta{}, err
+	}
+	if meta.Dir {
+		return nil, FileMeta{}, errIsDir
+	}
+	m, err := c.manifest(meta.Name)
+	if err != nil {
+		return nil, FileMeta{}, err
+	}
+	f := &chunkFile{store: c, chunks: m.Chunks, size: m.Size}
+	var offset int64
+	for _, ref := range m.Chunks {
+		f.offsets = append(f.offsets, offset)
+		offset += ref.Size
+	}
+	return f, m.FileMeta, nil
+}
+
+func (c *chunkStore) Stat(name string) (FileMeta, error) {
+	meta, err := c.files.Stat(name)
+	if err != nil || meta.Dir {
+		return meta, err
+	}
+	m, err := c.manifest(meta.Name)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	return m.FileMeta, nil
+}
+
+func (c *chunkStore) List(dir string) ([]FileMeta, error) {
+	entries, err := c.files.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := entries[:0]
+	for _, meta := range entries {
+		if !meta.Dir {
+			m, err := c.manifest(meta.Name)
+			if err != nil {
+				// removed since List, or not a manifest
+				continue
+			}
+			meta = m.FileMeta
+		}
+		list = append(list, meta)
+	}
+	return list, nil
+}
+
+func (c *chunkStore) Delete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, err := c.files.Stat(name)
+	if err != nil {
+		return err
+	}
+	if meta.Dir {
+		return errIsDir
+	}
+	m, err := c.manifest(meta.Name)
+	if err != nil {
+		return err
+	}
+	if err := c.files.Delete(name); err != nil {
+		return err
+	}
+	c.count(m.Chunks, -1)
+	return nil
+}
+
+func (c *chunkStore) Rename(from, to string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.files.Rename(from, to)
+}
+
+// collect removes the chunks no file lists that haven't been put or asked
+// about within grace, so the ones an upload in progress sent are kept. A
+// chunk still being written that old was left by a server that stopped
+// halfway through putChunk, and goes too.
+func (c *chunkStore) collect(grace time.Duration) (removed int, freed int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err = filepath.WalkDir(c.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || c.refs[entry.Name()] > 0 {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(entry.Name(), ".chunk-") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < grace {
+			// removed since, or may be listed soon
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
+
+// chunkFile reads a file out of its chunks.
+type chunkFile struct {
+	store   *chunkStore
+	chunks  []chunkRef
+	offsets []int64 // where each chunk starts in the file
+	size    int64
+
+	pos     int64
+	current *os.File // the chunk pos is in, when open
+	index   int      // of current
+}
+
+func (f *chunkFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	i := sort.Search(len(f.offsets), func(i int) bool { return f.offsets[i] > f.pos }) - 1
+	if f.current == nil || f.index != i {
+		f.closeChunk()
+		chunk, err := os.Open(f.store.chunkPath(f.chunks[i].SHA256))
+		if err != nil {
+			return 0, err
+		}
+		f.current, f.index = chunk, i
+	}
+	end := f.offsets[i] + f.chunks[i].Size
+	n, err := f.current.ReadAt(p[:min(int64(len(p)), end-f.pos)], f.pos-f.offsets[i])
+	f.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *chunkFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before the start of the file")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *chunkFile) closeChunk() {
+	if f.current != nil {
+		f.current.Close()
+		f.current = nil
+	}
+}
+
+func (f *chunkFile) Close() error {
+	f.closeChunk()
+	return nil
+}
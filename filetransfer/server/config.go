@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const envPrefix = "FILETRANSFER_"
+
+// serverConfig is how the server is set up. Every setting has a flag, an
+// environment variable named after it (-max-file-size is
+// FILETRANSFER_MAX_FILE_SIZE) and a key in the -config file; the flag wins
+// over the variable, which wins over the file.
+type serverConfig struct {
+	Listen          string   `json:"listen"`
+	Root            string   `json:"root"`
+	Storage         string   `json:"storage"`
+	MaxFileSize     int64    `json:"max_file_size"`
+	UploadTTL       duration `json:"upload_ttl"`
+	GCInterval      duration `json:"gc_interval"`
+	ShutdownTimeout duration `json:"shutdown_timeout"`
+}
+
+func defaultConfig() serverConfig {
+	return serverConfig{
+		Listen:          ":50052",
+		Root:            "files",
+		Storage:         storageDisk,
+		MaxFileSize:     1 << 30,
+		UploadTTL:       duration(defaultUploadTTL),
+		GCInterval:      duration(time.Hour),
+		ShutdownTimeout: duration(10 * time.Second),
+	}
+}
+
+func (c *serverConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.Listen, "listen", c.Listen, "address to serve gRPC on")
+	fs.StringVar(&c.Root, "root", c.Root, "directory the files are stored in")
+	fs.StringVar(&c.Storage, "storage", c.Storage, `how files are stored: "disk" as they are, or "chunks" to keep what files have in common once; a root stays the way it started`)
+	fs.Int64Var(&c.MaxFileSize, "max-file-size", c.MaxFileSize, "largest file in bytes that can be uploaded")
+	fs.Var(&c.UploadTTL, "upload-ttl", "how long an unfinished upload can go without data coming in before it is thrown away")
+	fs.Var(&c.GCInterval, "gc-interval", "how often unfinished uploads past -upload-ttl are thrown away, and with -storage chunks the chunks no file has any more")
+	fs.Var(&c.ShutdownTimeout, "shutdown-timeout", "how long to wait for transfers to finish on SIGINT or SIGTERM before cutting them off")
+}
+
+// envName is the environment variable for a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig reads the settings from args, the environment and the file
+// named by -config or FILETRANSFER_CONFIG.
+func loadConfig(name string, args []string) (serverConfig, error) {
+	config := defaultConfig()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "JSON file with any of these settings, keyed like config.example.json")
+	config.register(fs)
+	fs.Parse(args)
+
+	// the flags were parsed into config, set them again on top of the rest
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+	config = defaultConfig()
+
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			return config, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&config); err != nil {
+			return config, fmt.Errorf("parsing %s: %w", *configFile, err)
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || err != nil {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("%s: %w", envName(f.Name), setErr)
+			}
+		}
+	})
+	if err != nil {
+		return config, err
+	}
+	for name, v := range given {
+		fs.Lookup(name).Value.Set(v)
+	}
+	return config, config.validate()
+}
+
+func (c *serverConfig) validate() error {
+	if c.Root == "" {
+		return errors.New("root is required")
+	}
+	if c.Storage != storageDisk && c.Storage != storageChunks {
+		return fmt.Errorf("storage must be %q or %q", storageDisk, storageChunks)
+	}
+	if c.GCInterval <= 0 {
+		return errors.New("gc-interval must be positive")
+	}
+	if c.MaxFileSize < 1 {
+		return errors.New("max-file-size must be at least 1")
+	}
+	return nil
+}
+
+// duration reads "30s" and the like from JSON and flags.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
+
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
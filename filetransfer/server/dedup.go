@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"example/hello/filetransfer/chunker"
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkChunks turns the chunks a client listed into refs, making sure they
+// could be chunker's and add up to a file the server takes.
+func (s *server) checkChunks(chunks []*pb.ChunkRef) ([]chunkRef, error) {
+	if s.chunks == nil {
+		return nil, status.Error(codes.Unimplemented, "this server doesn't store files as chunks")
+	}
+	refs := make([]chunkRef, len(chunks))
+	var size int64
+	for i, ref := range chunks {
+		if len(ref.Sha256) != sha256.Size || ref.Size < 1 || ref.Size > chunker.MaxSize {
+			return nil, status.Errorf(codes.InvalidArgument, "chunk %d isn't one chunker would make", i)
+		}
+		size += ref.Size
+		refs[i] = chunkRef{SHA256: ref.Sha256, Size: ref.Size}
+	}
+	if size > s.maxFileSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "files can be at most %d bytes", s.maxFileSize)
+	}
+	return refs, nil
+}
+
+func (s *server) NegotiateChunks(_ context.Context, req *pb.ChunkList) (*pb.ChunkNeeds, error) {
+	if _, err := checkName(req.FileName); err != nil {
+		return nil, err
+	}
+	refs, err := s.checkChunks(req.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	needs := &pb.ChunkNeeds{}
+	asked := make(map[string]bool)
+	for i, ref := range refs {
+		// a chunk the file has twice only has to be sent once
+		key := string(ref.SHA256)
+		if asked[key] {
+			continue
+		}
+		asked[key] = true
+		if !s.chunks.has(ref) {
+			needs.Missing = append(needs.Missing, int32(i))
+		}
+	}
+	log.Printf("%s needs %d of %d chunks", req.FileName, len(needs.Missing), len(refs))
+	return needs, nil
+}
+
+func (s *server) UploadChunks(stream pb.FileTransferService_UploadChunksServer) error {
+	if s.chunks == nil {
+		return status.Error(codes.Unimplemented, "this server doesn't store files as chunks")
+	}
+	var received int64
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("error receiving chunks: %v", err)
+			return err
+		}
+		if len(chunk.Data) < 1 || len(chunk.Data) > chunker.MaxSize {
+			return status.Errorf(codes.InvalidArgument, "chunks are 1 to %d bytes", chunker.MaxSize)
+		}
+		sum := sha256.Sum256(chunk.Data)
+		if !bytes.Equal(sum[:], chunk.Sha256) {
+			return status.Error(codes.DataLoss, "chunk doesn't match its SHA-256, send it again")
+		}
+		if err := s.chunks.putChunk(sum[:], chunk.Data); err != nil {
+			log.Printf("error storing chunk %x: %v", sum, err)
+			return status.Error(codes.Internal, "couldn't store the chunk")
+		}
+		received += int64(len(chunk.Data))
+	}
+	return stream.SendAndClose(&pb.UploadStatus{
+		Success: true,
+		Message: fmt.Sprintf("%d bytes of chunks stored", received),
+		Offset:  received,
+	})
+}
+
+func (s *server) CommitChunks(ctx context.Context, req *pb.ChunkList) (*pb.FileInfo, error) {
+	fileName, err := checkName(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.checkChunks(req.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Sha256) != sha256.Size {
+		return nil, status.Error(codes.InvalidArgument, "sha256 of the whole file is required")
+	}
+	meta, err := s.chunks.commit(fileName, refs, FileMeta{SHA256: req.Sha256, Uploader: uploader(ctx)})
+	if errors.Is(err, errMissingChunk) {
+		return nil, status.Errorf(codes.FailedPrecondition, "chunks of %s are missing, negotiate again", fileName)
+	}
+	if err != nil {
+		return nil, storageError(err, fileName)
+	}
+	log.Printf("successfully committed %d bytes in %d chunks to %s", meta.Size, len(refs), fileName)
+	return toFileInfo(meta), nil
+}
+
+// collectChunks removes the chunks no file has had for a while, every
+// interval until the server stops.
+func (s *server) collectChunks(interval time.Duration) {
+	for {
+		// a chunk nobody committed within uploadTTL won't be
+		removed, freed, err := s.chunks.collect(s.uploadTTL)
+		if err != nil {
+			log.Printf("failed to collect chunks: %v", err)
+		} else if removed > 0 {
+			log.Printf("removed %d chunks nothing has, %d bytes", removed, freed)
+		}
+		time.Sleep(interval)
+	}
+}
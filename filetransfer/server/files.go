@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// storageError turns what the store says about name into a status for the
+// client. A status is passed on as it is: it came from the stream the store
+// was reading.
+func storageError(err error, name string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, errBadPath):
+		return status.Errorf(codes.InvalidArgument, "bad file name %q", name)
+	case errors.Is(err, errNotFound):
+		return status.Errorf(codes.NotFound, "no file called %s", name)
+	case errors.Is(err, errExists):
+		return status.Errorf(codes.AlreadyExists, "%s already exists", name)
+	case errors.Is(err, errIsDir):
+		return status.Errorf(codes.FailedPrecondition, "%s is a directory", name)
+	case errors.Is(err, errNotDir):
+		return status.Errorf(codes.FailedPrecondition, "a file is in the way of %s", name)
+	case errors.Is(err, errChecksum):
+		return status.Errorf(codes.DataLoss, "%s doesn't match its SHA-256, upload it again", name)
+	}
+	log.Printf("storage failed on %s: %v", name, err)
+	return status.Error(codes.Internal, "couldn't get to the file")
+}
+
+// uploader is the address a call came from, which is what files record as
+// their uploader. The server has no accounts: anybody can send any name in
+// the "user" header, so that is only advisory and goes no further than the
+// log, see caller.
+func uploader(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// caller is uploader for the log, with the name the client gave if any.
+func caller(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if user := md.Get("user"); len(user) > 0 && user[0] != "" {
+			return fmt.Sprintf("%s (says %q)", uploader(ctx), user[0])
+		}
+	}
+	return uploader(ctx)
+}
+
+func toFileInfo(meta FileMeta) *pb.FileInfo {
+	info := &pb.FileInfo{
+		FileName:    meta.Name,
+		Size:        meta.Size,
+		Modified:    meta.Modified.Unix(),
+		IsDir:       meta.Dir,
+		ContentType: meta.ContentType,
+		Sha256:      meta.SHA256,
+		Uploader:    meta.Uploader,
+	}
+	if !meta.Created.IsZero() {
+		info.Created = meta.Created.Unix()
+	}
+	return info
+}
+
+func (s *server) ListFiles(_ context.Context, req *pb.ListRequest) (*pb.FileList, error) {
+	entries, err := s.store.List(req.Dir)
+	if err != nil {
+		return nil, storageError(err, req.Dir)
+	}
+	list := &pb.FileList{}
+	for _, meta := range entries {
+		list.Files = append(list.Files, toFileInfo(meta))
+	}
+	return list, nil
+}
+
+func (s *server) StatFile(_ context.Context, req *pb.StatRequest) (*pb.FileInfo, error) {
+	meta, err := s.store.Stat(req.FileName)
+	if err != nil {
+		return nil, storageError(err, req.FileName)
+	}
+	return toFileInfo(meta), nil
+}
+
+func (s *server) DeleteFile(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	if err := s.store.Delete(req.FileName); err != nil {
+		return nil, storageError(err, req.FileName)
+	}
+	log.Printf("%s deleted %s", caller(ctx), req.FileName)
+	return &pb.DeleteResponse{}, nil
+}
+
+func (s *server) RenameFile(ctx context.Context, req *pb.RenameRequest) (*pb.FileInfo, error) {
+	from, err := checkName(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	to, err := checkName(req.NewName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Rename(from, to); err != nil {
+		if errors.Is(err, errExists) || errors.Is(err, errNotDir) {
+			return nil, storageError(err, to)
+		}
+		return nil, storageError(err, from)
+	}
+	meta, err := s.store.Stat(to)
+	if err != nil {
+		return nil, storageError(err, to)
+	}
+	log.Printf("%s renamed %s to %s", caller(ctx), from, to)
+	return toFileInfo(meta), nil
+}
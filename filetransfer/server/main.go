@@ -1,18 +1,84 @@
 package main
 
 import (
-	pb "example/hello/filetransfer/grpc"
+	"crypto/sha256"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"log"
+	"net"
 	"os"
-	"sync"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultChunkSize = 64 << 10
+	maxChunkSize     = 1 << 20 // well under gRPC's 4MB message limit
 )
 
 type server struct {
 	pb.UnimplementedFileTransferServiceServer
-	mu sync.Mutex
+	store       Storage
+	chunks      *chunkStore // when store is one, for the chunk RPCs
+	root        string      // where uploadsDir is
+	maxFileSize int64
+	uploadTTL   time.Duration // how long an unfinished upload is kept after data last came in
+
+	mu     sync.Mutex
+	active map[string]bool // uploads a stream is sending right now
+}
+
+// The ways the server can store files.
+const (
+	storageDisk   = "disk"   // as they are
+	storageChunks = "chunks" // cut into chunks, each kept once
+)
+
+func newServer(root, storage string, maxFileSize int64) (*server, error) {
+	if err := os.MkdirAll(filepath.Join(root, uploadsDir), 0o755); err != nil {
+		return nil, err
+	}
+	s := &server{
+		root:        root,
+		maxFileSize: maxFileSize,
+		uploadTTL:   defaultUploadTTL,
+		active:      make(map[string]bool),
+	}
+	var err error
+	switch storage {
+	case storageDisk:
+		s.store, err = newLocalDisk(root)
+	case storageChunks:
+		s.chunks, err = newChunkStore(root)
+		s.store = s.chunks
+	default:
+		err = fmt.Errorf("unknown storage %q", storage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkName makes sure a client-supplied name is a path the store takes,
+// returning it the way the store has it.
+func checkName(name string) (string, error) {
+	clean, err := cleanPath(name)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "bad file name %q", name)
+	}
+	return clean, nil
 }
 
 func (s *server) UploadFile(stream pb.FileTransferService_UploadFileServer) error {
@@ -20,106 +86,233 @@ func (s *server) UploadFile(stream pb.FileTransferService_UploadFileServer) erro
 
 	firstChunk, err := stream.Recv()
 	if err == io.EOF {
-		log.Println("File received completely.")
-		return stream.SendAndClose(&pb.UploadStatus{
-			Success: true,
-			Message: "File uploaded successfully",
-		})
+		return status.Error(codes.InvalidArgument, "no file was sent")
 	}
 	if err != nil {
-		log.Printf("error receiving chunk: %w", err)
+		log.Printf("error receiving chunk: %v", err)
 		return err
 	}
 
-	fileName := firstChunk.FileName
-	fileName = fmt.Sprintf("server/%s.txt", fileName)
-	file, err := os.Create(fileName)
+	if firstChunk.UploadId != "" {
+		return s.resumeUpload(stream, firstChunk)
+	}
+
+	fileName, err := checkName(firstChunk.FileName)
 	if err != nil {
-		log.Printf("failed to create output file: %v", err)
 		return err
 	}
 
-	defer file.Close()
-	
-	_, err = file.Write(firstChunk.ChunkData)
-	if err != nil {
-		log.Printf("error writing to the file %v", err)
+	// the store only keeps the file once all of it is there, so a broken
+	// upload never leaves half a file behind
+	chunks := &chunkReader{stream: stream, max: s.maxFileSize}
+	if err := chunks.add(firstChunk); err != nil {
 		return err
 	}
+	meta, err := s.store.Put(fileName, chunks, FileMeta{Uploader: uploader(stream.Context())})
+	if err != nil {
+		return storageError(err, fileName)
+	}
 
-	for {
-		chunk, err := stream.Recv()
+	log.Printf("successfully written %d bytes to %s", meta.Size, fileName)
+	return stream.SendAndClose(&pb.UploadStatus{
+		Success: true,
+		Message: "Successfully uploaded the file",
+		Offset:  meta.Size,
+	})
+}
+
+// chunkReader reads the data of the chunks coming in on an upload stream,
+// up to max bytes of it. Every chunk is checked against its CRC and has to
+// come in order, like the chunks of a resumed upload.
+type chunkReader struct {
+	stream pb.FileTransferService_UploadFileServer
+	max    int64
+	size   int64
+	next   int32  // index the next chunk should have
+	data   []byte // what is left of the last chunk
+}
+
+func (r *chunkReader) add(chunk *pb.FileChunk) error {
+	if chunk.ChunkIndex != r.next {
+		return status.Errorf(codes.InvalidArgument, "chunk %d came where chunk %d should have", chunk.ChunkIndex, r.next)
+	}
+	if crc32.Checksum(chunk.ChunkData, castagnoli) != chunk.Crc32C {
+		return status.Errorf(codes.DataLoss, "chunk %d doesn't match its CRC, upload the file again", chunk.ChunkIndex)
+	}
+	r.next++
+	r.size += int64(len(chunk.ChunkData))
+	if r.size > r.max {
+		return status.Errorf(codes.ResourceExhausted, "files can be at most %d bytes", r.max)
+	}
+	r.data = chunk.ChunkData
+	return nil
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		chunk, err := r.stream.Recv()
 		if err == io.EOF {
-			log.Printf("successfully written to the file %v", fileName)
-			return stream.SendAndClose(
-				&pb.UploadStatus{
-					Success: true,
-					Message: "Successfully uploaded the file",
-				})
+			return 0, io.EOF
 		}
 		if err != nil {
-			log.Printf("error receiving the data: %v",err)
-			return err
+			log.Printf("error receiving the data: %v", err)
+			return 0, err
 		}
-
-		_, err = file.Write(chunk.ChunkData)
-		if err != nil {
-			log.Printf("error uploading the file: %v",err)
-			return err
+		if err := r.add(chunk); err != nil {
+			return 0, err
 		}
-		log.Printf("received chunk %d",chunk.ChunkData)
 	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
 }
 
-func (s * server) DownloadFile(downloadReq *pb.FileRequest,stream pb.FileTransferService_DownloadFileServer) error  {
-	fileName := filepath.Base(downloadReq.FileName)
-	absPath, err := filepath.Abs("server")
+func (s *server) DownloadFile(downloadReq *pb.FileRequest, stream pb.FileTransferService_DownloadFileServer) error {
+	fileName, err := checkName(downloadReq.FileName)
 	if err != nil {
-		log.Print()
+		return err
 	}
 
-	file, err := os.Open(filePath)
+	file, meta, err := s.store.Get(fileName)
 	if err != nil {
-		log.Printf("no such file found %v",err)
-		return err
+		return storageError(err, fileName)
 	}
 	defer file.Close()
 
-	chunkSize := downloadReq.ChunkSize
-	buf := make([]byte,chunkSize)
+	offset := downloadReq.Offset
+	if offset < 0 || offset > meta.Size {
+		return status.Errorf(codes.OutOfRange, "offset %d is outside the %d bytes of %s", offset, meta.Size, fileName)
+	}
+	end := meta.Size
+	if downloadReq.Length != 0 {
+		end = offset + downloadReq.Length
+		if downloadReq.Length < 0 || end > meta.Size {
+			return status.Errorf(codes.OutOfRange, "%d bytes from %d run past the %d bytes of %s", downloadReq.Length, offset, meta.Size, fileName)
+		}
+	}
+	// the last chunk carries the SHA-256 of all of it. The store knows it
+	// unless the file was put there some other way, and then what comes
+	// before the offset is hashed too, which also gets us there. A range
+	// short of the end goes without.
+	sum := meta.SHA256
+	var hash hash.Hash
+	if sum == nil && end == meta.Size {
+		hash = sha256.New()
+		_, err = io.CopyN(hash, file, offset)
+	} else {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		log.Printf("error reading from the file %v: %v", fileName, err)
+		return status.Error(codes.Internal, "couldn't read the file")
+	}
+
+	chunkSize := int(downloadReq.ChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	chunkSize = min(chunkSize, maxChunkSize)
+	buf := make([]byte, chunkSize)
 	var chunkIndex int32
 
 	for {
-		n, err := file.Read(buf)
+		n, err := file.Read(buf[:min(int64(len(buf)), end-offset)])
+		if n == 0 && err == nil {
+			// at the end of the range
+			err = io.EOF
+		}
 		if err == io.EOF {
-			log.Print("Successfully sent the file")
+			if hash != nil {
+				sum = hash.Sum(nil)
+			}
+			log.Printf("Successfully sent %s", fileName)
 			return stream.Send(&pb.FileChunk{
-				FileName: fileName,
-				ChunkData: []byte{}, // apparently this is more protobuf safe than sending a nil
+				FileName:    fileName,
+				ChunkData:   []byte{}, // apparently this is more protobuf safe than sending a nil
 				IsLastChunk: true,
-				ChunkIndex: chunkIndex,
+				ChunkIndex:  chunkIndex,
+				Offset:      offset,
+				Sha256:      sum,
 			})
 		}
 		if err != nil {
-			log.Printf("error reading from the file %v",fileName)
-			return err
+			log.Printf("error reading from the file %v: %v", fileName, err)
+			return status.Error(codes.Internal, "couldn't read the file")
+		}
+		if hash != nil {
+			hash.Write(buf[:n])
 		}
-		
-		if sendErr := stream.Send(
-			&pb.FileChunk{
-				FileName: fileName,
-				ChunkData: buf[:n],
-				IsLastChunk: false,
-				ChunkIndex: chunkIndex,
-			},
-		); sendErr != nil {
-			log.Printf("Error sending chunk: %v",sendErr)
+
+		if sendErr := stream.Send(&pb.FileChunk{
+			FileName:    fileName,
+			ChunkData:   buf[:n],
+			IsLastChunk: false,
+			ChunkIndex:  chunkIndex,
+			Offset:      offset,
+			Crc32C:      crc32.Checksum(buf[:n], castagnoli),
+		}); sendErr != nil {
+			log.Printf("Error sending chunk: %v", sendErr)
 			return sendErr
 		}
 		chunkIndex++
+		offset += int64(n)
 	}
-} 
+}
 
 func main() {
+	config, err := loadConfig(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	srv, err := newServer(config.Root, config.Storage, config.MaxFileSize)
+	if err != nil {
+		log.Fatalf("Failed to open storage root: %v", err)
+	}
+	srv.uploadTTL = time.Duration(config.UploadTTL)
+	go srv.sweepUploadsEvery(time.Duration(config.GCInterval))
+	if srv.chunks != nil {
+		go srv.collectChunks(time.Duration(config.GCInterval))
+	}
+
+	lis, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterFileTransferServiceServer(grpcServer, srv)
+
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		signal.Stop(signals)
+		log.Print("Shutting down...")
+		gracefulStop(grpcServer, time.Duration(config.ShutdownTimeout))
+		close(stopped)
+	}()
+
+	log.Printf("Server is listening on %s, storing files in %s...", lis.Addr(), config.Root)
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+	<-stopped
+	log.Print("Server stopped")
+}
+
+// gracefulStop waits up to timeout for the transfers in progress to finish.
+func gracefulStop(grpcServer *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Transfers still running after %s, cutting them off", timeout)
+		grpcServer.Stop()
+	}
 }
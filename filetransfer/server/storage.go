@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	errBadPath  = errors.New("bad path")
+	errNotFound = errors.New("no such file")
+	errExists   = errors.New("already exists")
+	errIsDir    = errors.New("is a directory")
+	errNotDir   = errors.New("a file is in the way")
+	errChecksum = errors.New("doesn't match its SHA-256")
+)
+
+// Storage is where the files live. Names are slash-separated paths relative
+// to the root, like "builds/linux/app.tar.gz"; directories come and go with
+// the files in them.
+type Storage interface {
+	// Put stores what r has as name, replacing any file there, with the
+	// uploader and content type from meta. It is all or nothing: when r
+	// fails, or meta has a SHA-256 that what r had doesn't match, whatever
+	// was stored as name is left as it was.
+	Put(name string, r io.Reader, meta FileMeta) (FileMeta, error)
+	Get(name string) (io.ReadSeekCloser, FileMeta, error)
+	Stat(name string) (FileMeta, error)
+	// List returns what is directly in dir, "" being the root.
+	List(dir string) ([]FileMeta, error)
+	Delete(name string) error
+	Rename(from, to string) error
+}
+
+// FileMeta is what is known about a stored file or a directory.
+type FileMeta struct {
+	Name        string    `json:"name"`
+	Dir         bool      `json:"dir,omitempty"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	SHA256      []byte    `json:"sha256,omitempty"`
+	Uploader    string    `json:"uploader,omitempty"`
+	Created     time.Time `json:"created"`
+	Modified    time.Time `json:"modified"`
+}
+
+const (
+	maxPathLength    = 1024
+	maxElementLength = 255
+)
+
+// cleanPath checks a client-supplied name and returns it without leading
+// or trailing slashes. Every part of it has to be a plain name: no "..",
+// nothing starting with '.', which the server keeps for itself, and no
+// backslashes that would be separators on Windows.
+func cleanPath(name string) (string, error) {
+	name = strings.Trim(name, "/")
+	if name == "" || len(name) > maxPathLength || !utf8.ValidString(name) || strings.ContainsAny(name, "\\\x00") {
+		return "", errBadPath
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" || strings.HasPrefix(elem, ".") || len(elem) > maxElementLength {
+			return "", errBadPath
+		}
+	}
+	return name, nil
+}
+
+// localDisk keeps the files under root as they are named, and their
+// metadata as JSON in the same place under root/.meta.
+type localDisk struct {
+	root string
+
+	mu sync.Mutex // held while a file and its metadata change together
+}
+
+func newLocalDisk(root string) (*localDisk, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &localDisk{root: root}, nil
+}
+
+// paths returns where name's data and metadata are.
+func (d *localDisk) paths(name string) (clean, data, meta string, err error) {
+	clean, err = cleanPath(name)
+	if err != nil {
+		return "", "", "", err
+	}
+	data = filepath.Join(d.root, filepath.FromSlash(clean))
+	meta = filepath.Join(d.root, ".meta", filepath.FromSlash(clean)+".json")
+	return clean, data, meta, nil
+}
+
+func contentType(name string, head []byte) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(head)
+}
+
+func (d *localDisk) Put(name string, r io.Reader, meta FileMeta) (FileMeta, error) {
+	name, dataPath, metaPath, err := d.paths(name)
+	if err != nil {
+		return FileMeta{}, err
+	}
+
+	// written next to where it goes and renamed once all of it is there
+	d.mu.Lock()
+	tmp, err := d.createTemp(dataPath)
+	d.mu.Unlock()
+	if err != nil {
+		return FileMeta{}, err
+	}
+	defer func() {
+		tmp.Close()
+		if err := os.Remove(tmp.Name()); err == nil {
+			// not renamed into place, so the directories made for it go too
+			d.mu.Lock()
+			d.prune(name)
+			d.mu.Unlock()
+		}
+	}()
+
+	br := bufio.NewReader(r)
+	// an error Peek runs into is only returned once, here
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return FileMeta{}, err
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = contentType(name, head)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), br)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	sum := hash.Sum(nil)
+	if meta.SHA256 != nil && !bytes.Equal(meta.SHA256, sum) {
+		return FileMeta{}, errChecksum
+	}
+	if err := tmp.Sync(); err != nil {
+		return FileMeta{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return FileMeta{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now().UTC()
+	meta.Name, meta.Dir, meta.Size, meta.SHA256 = name, false, size, sum
+	meta.Created, meta.Modified = now, now
+	if old, err := d.stat(name, dataPath, metaPath); err == nil {
+		if old.Dir {
+			return FileMeta{}, errIsDir
+		}
+		meta.Created = old.Created
+	}
+	if err := os.Rename(tmp.Name(), dataPath); err != nil {
+		return FileMeta{}, err
+	}
+	if err := writeMeta(metaPath, meta); err != nil {
+		return FileMeta{}, err
+	}
+	return meta, nil
+}
+
+// createTemp makes the directories dataPath needs and a file to write it in
+// there. It is called holding mu, so prune doesn't take them away meanwhile.
+func (d *localDisk) createTemp(dataPath string) (*os.File, error) {
+	if err := mkdirs(filepath.Dir(dataPath)); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(filepath.Dir(dataPath), ".put-*")
+}
+
+// mkdirs makes dir, telling a file in the way apart from other failures.
+func mkdirs(dir string) error {
+	err := os.MkdirAll(dir, 0o755)
+	if errors.Is(err, syscall.ENOTDIR) {
+		return errNotDir
+	}
+	return err
+}
+
+func writeMeta(metaPath string, meta FileMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(metaPath), ".meta-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), metaPath)
+}
+
+func (d *localDisk) Get(name string) (io.ReadSeekCloser, FileMeta, error) {
+	name, dataPath, metaPath, err := d.paths(name)
+	if err != nil {
+		return nil, FileMeta{}, err
+	}
+	f, err := os.Open(dataPath)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return nil, FileMeta{}, errNotFound
+	}
+	if err != nil {
+		return nil, FileMeta{}, err
+	}
+	meta, err := d.stat(name, dataPath, metaPath)
+	if err == nil && meta.Dir {
+		err = errIsDir
+	}
+	if err != nil {
+		f.Close()
+		return nil, FileMeta{}, err
+	}
+	return f, meta, nil
+}
+
+func (d *localDisk) Stat(name string) (FileMeta, error) {
+	name, dataPath, metaPath, err := d.paths(name)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	return d.stat(name, dataPath, metaPath)
+}
+
+// stat reads name's metadata. A file put there some other way, or changed
+// since, gets what can be told from the file itself.
+func (d *localDisk) stat(name, dataPath, metaPath string) (FileMeta, error) {
+	info, err := os.Stat(dataPath)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return FileMeta{}, errNotFound
+	}
+	if err != nil {
+		return FileMeta{}, err
+	}
+	if info.IsDir() {
+		return FileMeta{Name: name, Dir: true, Modified: info.ModTime().UTC()}, nil
+	}
+	if !info.Mode().IsRegular() {
+		return FileMeta{}, errNotFound
+	}
+
+	var meta FileMeta
+	if data, err := os.ReadFile(metaPath); err == nil && json.Unmarshal(data, &meta) == nil && meta.Size == info.Size() {
+		meta.Name = name
+		return meta, nil
+	}
+	return FileMeta{
+		Name:        name,
+		Size:        info.Size(),
+		ContentType: contentType(name, nil),
+		Created:     info.ModTime().UTC(),
+		Modified:    info.ModTime().UTC(),
+	}, nil
+}
+
+func (d *localDisk) List(dir string) ([]FileMeta, error) {
+	dirPath := d.root
+	if dir = strings.Trim(dir, "/"); dir != "" {
+		clean, data, _, err := d.paths(dir)
+		if err != nil {
+			return nil, err
+		}
+		dir, dirPath = clean, data
+	}
+	entries, err := os.ReadDir(dirPath)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []FileMeta
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		_, dataPath, metaPath, err := d.paths(name)
+		if err != nil {
+			// a name a client couldn't have given
+			continue
+		}
+		meta, err := d.stat(name, dataPath, metaPath)
+		if err != nil {
+			// removed since ReadDir, or not a regular file
+			continue
+		}
+		list = append(list, meta)
+	}
+	return list, nil
+}
+
+func (d *localDisk) Delete(name string) error {
+	name, dataPath, metaPath, err := d.paths(name)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	meta, err := d.stat(name, dataPath, metaPath)
+	if err != nil {
+		return err
+	}
+	if meta.Dir {
+		return errIsDir
+	}
+	if err := os.Remove(dataPath); err != nil {
+		return err
+	}
+	os.Remove(metaPath)
+	d.prune(name)
+	return nil
+}
+
+func (d *localDisk) Rename(from, to string) error {
+	from, fromData, fromMeta, err := d.paths(from)
+	if err != nil {
+		return err
+	}
+	to, toData, toMeta, err := d.paths(to)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	meta, err := d.stat(from, fromData, fromMeta)
+	if err != nil {
+		return err
+	}
+	if meta.Dir {
+		return errIsDir
+	}
+	if _, err := d.stat(to, toData, toMeta); err == nil {
+		return errExists
+	} else if err != errNotFound {
+		return err
+	}
+	if err := mkdirs(filepath.Dir(toData)); err != nil {
+		return err
+	}
+	if err := os.Rename(fromData, toData); err != nil {
+		return err
+	}
+	meta.Name = to
+	meta.Modified = time.Now().UTC()
+	if err := writeMeta(toMeta, meta); err != nil {
+		return err
+	}
+	os.Remove(fromMeta)
+	d.prune(from)
+	return nil
+}
+
+// prune removes the directories name was in that are now empty.
+func (d *localDisk) prune(name string) {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(filepath.Join(d.root, filepath.FromSlash(dir))) != nil {
+			return
+		}
+		os.Remove(filepath.Join(d.root, ".meta", filepath.FromSlash(dir)))
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// The benchmarks move an 8MB file over bufconn with benchLatency added each
+// way, on one stream and split into ranges on several at once, each on a
+// connection of its own like the client's. That is what a link to another
+// region looks like, where one stream spends much of its time waiting on the
+// round trip for flow control to let it send more.
+const (
+	benchSize    = 8 << 20
+	benchLatency = 20 * time.Millisecond
+)
+
+func BenchmarkUpload(b *testing.B) {
+	for _, streams := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("streams=%d", streams), func(b *testing.B) {
+			_, clients := startLatencyServer(b, streams)
+			data := make([]byte, benchSize)
+			rand.Read(data)
+			sum := sha256.Sum256(data)
+			rangeSize := int64(0)
+			if streams > 1 {
+				rangeSize = int64((benchSize + streams - 1) / streams)
+			}
+			b.SetBytes(benchSize)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				session, err := clients[0].StartUpload(context.Background(), &pb.UploadRequest{
+					FileName:  fmt.Sprintf("upload-%d", i),
+					Size:      benchSize,
+					Sha256:    sum[:],
+					RangeSize: rangeSize,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				var wg sync.WaitGroup
+				var done bool
+				var mu sync.Mutex
+				for r, offset := range session.RangeOffsets {
+					end := int64(benchSize)
+					if rangeSize > 0 {
+						end = min(int64(r+1)*rangeSize, benchSize)
+					}
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						st, err := sendChunks(clients[r], session.UploadId, offset, data[offset:end], defaultChunkSize)
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						mu.Lock()
+						done = done || st.Success
+						mu.Unlock()
+					}()
+				}
+				wg.Wait()
+				if !done {
+					b.Fatal("no stream finished the upload")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDownload(b *testing.B) {
+	for _, streams := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("streams=%d", streams), func(b *testing.B) {
+			srv, clients := startLatencyServer(b, streams)
+			data := make([]byte, benchSize)
+			rand.Read(data)
+			if _, err := srv.store.Put("data", bytes.NewReader(data), FileMeta{}); err != nil {
+				b.Fatal(err)
+			}
+			got := make([]byte, benchSize)
+			rangeSize := int64((benchSize + streams - 1) / streams)
+			b.SetBytes(benchSize)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for r := range streams {
+					offset := int64(r) * rangeSize
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						length := min(rangeSize, benchSize-offset)
+						if err := downloadRange(clients[r], "data", offset, length, got); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+				wg.Wait()
+			}
+			b.StopTimer()
+			if !bytes.Equal(got, data) {
+				b.Fatal("downloaded bytes that don't match")
+			}
+		})
+	}
+}
+
+// downloadRange fetches length bytes of name from offset into the same
+// place in buf.
+func downloadRange(client pb.FileTransferServiceClient, name string, offset, length int64, buf []byte) error {
+	stream, err := client.DownloadFile(context.Background(), &pb.FileRequest{FileName: name, Offset: offset, Length: length})
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if chunk.IsLastChunk {
+			return nil
+		}
+		copy(buf[chunk.Offset:], chunk.ChunkData)
+	}
+}
+
+// startLatencyServer is startServer with conns connections to it, each
+// benchLatency slow each way.
+func startLatencyServer(b *testing.B, conns int) (*server, []pb.FileTransferServiceClient) {
+	// every transfer gets logged
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	srv, err := newServer(b.TempDir(), storageDisk, 1<<30)
+	if err != nil {
+		b.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	pb.RegisterFileTransferServiceServer(grpcServer, srv)
+	go grpcServer.Serve(latencyListener{lis})
+	b.Cleanup(grpcServer.Stop)
+
+	var clients []pb.FileTransferServiceClient
+	for range conns {
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				c, err := lis.DialContext(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return newLatencyConn(c), nil
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { conn.Close() })
+		clients = append(clients, pb.NewFileTransferServiceClient(conn))
+	}
+	return srv, clients
+}
+
+type latencyListener struct {
+	net.Listener
+}
+
+func (l latencyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newLatencyConn(c), nil
+}
+
+// latencyConn delivers what is written to it benchLatency later, without
+// holding up the writer meanwhile.
+type latencyConn struct {
+	net.Conn
+	queue chan delayedWrite
+	done  chan struct{}
+	once  sync.Once
+}
+
+type delayedWrite struct {
+	data []byte
+	at   time.Time
+}
+
+func newLatencyConn(c net.Conn) *latencyConn {
+	l := &latencyConn{Conn: c, queue: make(chan delayedWrite, 4096), done: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case w := <-l.queue:
+				time.Sleep(time.Until(w.at))
+				if _, err := l.Conn.Write(w.data); err != nil {
+					return
+				}
+			case <-l.done:
+				return
+			}
+		}
+	}()
+	return l
+}
+
+func (l *latencyConn) Write(p []byte) (int, error) {
+	select {
+	case l.queue <- delayedWrite{data: bytes.Clone(p), at: time.Now().Add(benchLatency)}:
+		return len(p), nil
+	case <-l.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (l *latencyConn) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.Conn.Close()
+}
@@ -0,0 +1,703 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example/hello/filetransfer/chunker"
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer runs a server storing files in a temporary directory over an
+// in-memory connection.
+func startServer(t *testing.T, maxFileSize int64) (*server, pb.FileTransferServiceClient) {
+	return startStorage(t, storageDisk, maxFileSize)
+}
+
+func startStorage(t *testing.T, storage string, maxFileSize int64) (*server, pb.FileTransferServiceClient) {
+	t.Helper()
+
+	srv, err := newServer(t.TempDir(), storage, maxFileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	pb.RegisterFileTransferServiceServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, pb.NewFileTransferServiceClient(conn)
+}
+
+func uploadBytes(client pb.FileTransferServiceClient, name string, data []byte, chunkSize int) error {
+	return uploadBytesAs(context.Background(), client, name, data, chunkSize)
+}
+
+func uploadBytesAs(ctx context.Context, client pb.FileTransferServiceClient, name string, data []byte, chunkSize int) error {
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i == 0 || i < len(data); i += chunkSize {
+		chunk := data[i:min(i+chunkSize, len(data))]
+		if err := stream.Send(&pb.FileChunk{FileName: name, ChunkData: chunk, ChunkIndex: int32(i / chunkSize), Crc32C: crc32.Checksum(chunk, castagnoli)}); err != nil {
+			break
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+func downloadBytes(client pb.FileTransferServiceClient, name string, chunkSize int32) ([]byte, error) {
+	stream, err := client.DownloadFile(context.Background(), &pb.FileRequest{FileName: name, ChunkSize: chunkSize})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(chunk.ChunkData)
+		if chunk.IsLastChunk {
+			return buf.Bytes(), nil
+		}
+	}
+}
+
+func TestUploadListDownload(t *testing.T) {
+	_, client := startServer(t, 1<<20)
+
+	data := make([]byte, 300_000)
+	rand.Read(data)
+	if err := uploadBytes(client, "build.tar.gz", data, 64<<10); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadBytes(client, "empty", nil, 64<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := client.ListFiles(context.Background(), &pb.ListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Files) != 2 || list.Files[0].FileName != "build.tar.gz" || list.Files[0].Size != int64(len(data)) || list.Files[1].FileName != "empty" {
+		t.Fatalf("listed %v", list.Files)
+	}
+
+	got, err := downloadBytes(client, "build.tar.gz", 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes that don't match the %d uploaded", len(got), len(data))
+	}
+	if got, err := downloadBytes(client, "empty", 0); err != nil || len(got) != 0 {
+		t.Fatalf("downloaded %d bytes of an empty file: %v", len(got), err)
+	}
+}
+
+func TestUploadRejects(t *testing.T) {
+	srv, client := startServer(t, 1000)
+
+	for _, name := range []string{"../escape", "a/../../b", ".hidden", "dir/.meta", "a//b", `a\b`, "/", ""} {
+		if err := uploadBytes(client, name, []byte("x"), 10); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("uploading %q: got %v, want InvalidArgument", name, err)
+		}
+	}
+	if err := uploadBytes(client, "big", make([]byte, 1001), 100); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("uploading past the limit: got %v, want ResourceExhausted", err)
+	}
+	for want, chunks := range map[codes.Code][]*pb.FileChunk{
+		codes.DataLoss: {
+			{FileName: "corrupted", ChunkData: []byte("abc"), Crc32C: crc32.Checksum([]byte("abc"), castagnoli)},
+			{FileName: "corrupted", ChunkData: []byte("def"), ChunkIndex: 1, Crc32C: 1},
+		},
+		codes.InvalidArgument: {
+			{FileName: "reordered", ChunkData: []byte("def"), ChunkIndex: 1, Crc32C: crc32.Checksum([]byte("def"), castagnoli)},
+			{FileName: "reordered", ChunkData: []byte("abc"), Crc32C: crc32.Checksum([]byte("abc"), castagnoli)},
+		},
+	} {
+		stream, err := client.UploadFile(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range chunks {
+			stream.Send(chunk)
+		}
+		if _, err := stream.CloseAndRecv(); status.Code(err) != want {
+			t.Errorf("uploading %s: got %v, want %v", chunks[0].FileName, err, want)
+		}
+	}
+	if _, err := downloadBytes(client, "missing", 0); status.Code(err) != codes.NotFound {
+		t.Errorf("downloading a missing file: got %v, want NotFound", err)
+	}
+	if _, err := downloadBytes(client, "../main.go", 0); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("downloading outside the root: got %v, want InvalidArgument", err)
+	}
+
+	// nothing half written was left behind
+	for _, dir := range []string{srv.root, filepath.Join(srv.root, uploadsDir)} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.Name() != uploadsDir {
+				t.Errorf("%s left behind after failed uploads", entry.Name())
+			}
+		}
+	}
+}
+
+func TestDirectoriesAndMetadata(t *testing.T) {
+	srv, client := startServer(t, 1<<20)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "user", "alice")
+
+	guide := []byte("<h1>Guide</h1>\n")
+	if err := uploadBytesAs(ctx, client, "docs/index.html", guide, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadBytesAs(ctx, client, "/docs/img/logo.png", []byte("\x89PNG\r\n\x1a\n"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadBytes(client, "docs", []byte("x"), 10); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("uploading over a directory: got %v, want FailedPrecondition", err)
+	}
+	if err := uploadBytes(client, "docs/index.html/x", []byte("x"), 10); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("uploading under a file: got %v, want FailedPrecondition", err)
+	}
+
+	list, err := client.ListFiles(ctx, &pb.ListRequest{})
+	if err != nil || len(list.Files) != 1 || list.Files[0].FileName != "docs" || !list.Files[0].IsDir {
+		t.Fatalf("listed the root as %v: %v", list.GetFiles(), err)
+	}
+	list, err = client.ListFiles(ctx, &pb.ListRequest{Dir: "docs"})
+	if err != nil || len(list.Files) != 2 || list.Files[0].FileName != "docs/img" || !list.Files[0].IsDir || list.Files[1].FileName != "docs/index.html" {
+		t.Fatalf("listed docs as %v: %v", list.GetFiles(), err)
+	}
+
+	info, err := client.StatFile(ctx, &pb.StatRequest{FileName: "docs/index.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(guide)
+	// anybody can say they are alice, the file goes by where it came from
+	if info.Size != int64(len(guide)) || !bytes.Equal(info.Sha256, sum[:]) || info.Uploader != "bufconn" || info.ContentType != "text/html; charset=utf-8" || info.Created == 0 {
+		t.Fatalf("stat of docs/index.html: %v", info)
+	}
+	if info, err := client.StatFile(ctx, &pb.StatRequest{FileName: "docs/img/logo.png"}); err != nil || info.ContentType != "image/png" {
+		t.Fatalf("stat of docs/img/logo.png: %v, %v", info, err)
+	}
+
+	if _, err := client.RenameFile(ctx, &pb.RenameRequest{FileName: "docs/img/logo.png", NewName: "docs/index.html"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("renaming onto a file: got %v, want AlreadyExists", err)
+	}
+	if _, err := client.RenameFile(ctx, &pb.RenameRequest{FileName: "docs/index.html", NewName: "../index.html"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("renaming out of the root: got %v, want InvalidArgument", err)
+	}
+	moved, err := client.RenameFile(ctx, &pb.RenameRequest{FileName: "docs/img/logo.png", NewName: "assets/logo.png"})
+	if err != nil || moved.FileName != "assets/logo.png" || moved.Uploader != "bufconn" {
+		t.Fatalf("renamed to %v: %v", moved, err)
+	}
+	if _, err := os.Stat(filepath.Join(srv.root, "docs", "img")); !os.IsNotExist(err) {
+		t.Errorf("the emptied directory is still there: %v", err)
+	}
+
+	if _, err := client.DeleteFile(ctx, &pb.DeleteRequest{FileName: "docs"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("deleting a directory: got %v, want FailedPrecondition", err)
+	}
+	if _, err := client.DeleteFile(ctx, &pb.DeleteRequest{FileName: "docs/index.html"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.StatFile(ctx, &pb.StatRequest{FileName: "docs/index.html"}); status.Code(err) != codes.NotFound {
+		t.Errorf("stat after delete: got %v, want NotFound", err)
+	}
+	if _, err := client.DeleteFile(ctx, &pb.DeleteRequest{FileName: "docs/index.html"}); status.Code(err) != codes.NotFound {
+		t.Errorf("deleting twice: got %v, want NotFound", err)
+	}
+	list, err = client.ListFiles(ctx, &pb.ListRequest{})
+	if err != nil || len(list.Files) != 1 || list.Files[0].FileName != "assets" {
+		t.Fatalf("listed the root as %v: %v", list.GetFiles(), err)
+	}
+
+	// a file put there some other way has no metadata, but still downloads
+	// with the SHA-256 of all of it
+	data := []byte("copied in by hand")
+	if err := os.WriteFile(filepath.Join(srv.root, "assets", "notes"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := client.StatFile(ctx, &pb.StatRequest{FileName: "assets/notes"}); err != nil || info.Size != int64(len(data)) || info.Sha256 != nil {
+		t.Fatalf("stat of a file without metadata: %v, %v", info, err)
+	}
+	stream, err := client.DownloadFile(ctx, &pb.FileRequest{FileName: "assets/notes", Offset: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunk.IsLastChunk {
+			if sum := sha256.Sum256(data); !bytes.Equal(chunk.Sha256, sum[:]) {
+				t.Fatal("the last chunk doesn't carry the SHA-256 of the whole file")
+			}
+			break
+		}
+	}
+}
+
+// sendChunks sends data as the chunks of upload id from offset on, with
+// their CRCs, and returns what the server made of it.
+func sendChunks(client pb.FileTransferServiceClient, id string, offset int64, data []byte, chunkSize int) (*pb.UploadStatus, error) {
+	stream, err := client.UploadFile(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i == 0 || i < len(data); i += chunkSize {
+		chunk := data[i:min(i+chunkSize, len(data))]
+		if err := stream.Send(&pb.FileChunk{UploadId: id, Offset: offset + int64(i), ChunkData: chunk, Crc32C: crc32.Checksum(chunk, castagnoli)}); err != nil {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func TestResumableUpload(t *testing.T) {
+	srv, client := startServer(t, 1<<20)
+	ctx := context.Background()
+
+	data := make([]byte, 200_000)
+	rand.Read(data)
+	sum := sha256.Sum256(data)
+	session, err := client.StartUpload(ctx, &pb.UploadRequest{FileName: "artifact.zip", Size: int64(len(data)), Sha256: sum[:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first stream stops halfway, the chunk after arrives corrupted
+	half := int64(len(data) / 2)
+	if st, err := sendChunks(client, session.UploadId, 0, data[:half], 30_000); err != nil || st.Success || st.Offset != half {
+		t.Fatalf("first half: %v, %v", st, err)
+	}
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&pb.FileChunk{UploadId: session.UploadId, Offset: half, ChunkData: data[half : half+100], Crc32C: 1})
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.DataLoss {
+		t.Fatalf("corrupted chunk: got %v, want DataLoss", err)
+	}
+
+	got, err := client.GetUploadOffset(ctx, &pb.UploadOffsetRequest{UploadId: session.UploadId})
+	if err != nil || got.Offset != half {
+		t.Fatalf("offset after the first stream: %v, %v", got, err)
+	}
+	if _, err := sendChunks(client, session.UploadId, half+1, data[half+1:], 30_000); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("resuming at the wrong offset: got %v, want FailedPrecondition", err)
+	}
+	if _, err := os.Stat(filepath.Join(srv.root, "artifact.zip")); !os.IsNotExist(err) {
+		t.Fatalf("the file is there before the upload is done: %v", err)
+	}
+
+	if st, err := sendChunks(client, session.UploadId, half, data[half:], 30_000); err != nil || !st.Success {
+		t.Fatalf("second half: %v, %v", st, err)
+	}
+	stored, err := os.ReadFile(filepath.Join(srv.root, "artifact.zip"))
+	if err != nil || !bytes.Equal(stored, data) {
+		t.Fatalf("stored %d bytes that don't match the %d uploaded: %v", len(stored), len(data), err)
+	}
+	if _, err := client.GetUploadOffset(ctx, &pb.UploadOffsetRequest{UploadId: session.UploadId}); status.Code(err) != codes.NotFound {
+		t.Fatalf("the finished upload is still there: %v", err)
+	}
+
+	// a file that doesn't match the SHA-256 it was started with is dropped
+	session, err = client.StartUpload(ctx, &pb.UploadRequest{FileName: "other.zip", Size: int64(len(data)), Sha256: make([]byte, sha256.Size)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sendChunks(client, session.UploadId, 0, data, 64<<10); status.Code(err) != codes.DataLoss {
+		t.Fatalf("wrong SHA-256: got %v, want DataLoss", err)
+	}
+	if _, err := os.Stat(filepath.Join(srv.root, "other.zip")); !os.IsNotExist(err) {
+		t.Fatalf("the mismatched file was stored: %v", err)
+	}
+}
+
+func TestUploadExpiry(t *testing.T) {
+	srv, client := startServer(t, 1<<20)
+	srv.uploadTTL = time.Hour
+	ctx := context.Background()
+
+	data := make([]byte, 10_000)
+	rand.Read(data)
+	sum := sha256.Sum256(data)
+	session, err := client.StartUpload(ctx, &pb.UploadRequest{FileName: "slow.bin", Size: int64(len(data)), Sha256: sum[:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sendChunks(client, session.UploadId, 0, data[:5000], 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	// started long ago, but data came in just now
+	var stored uploadSession
+	encoded, _ := os.ReadFile(srv.sessionPath(session.UploadId))
+	json.Unmarshal(encoded, &stored)
+	stored.Created = time.Now().Add(-48 * time.Hour)
+	encoded, _ = json.Marshal(stored)
+	if err := os.WriteFile(srv.sessionPath(session.UploadId), encoded, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv.sweepUploads()
+	if got, err := client.GetUploadOffset(ctx, &pb.UploadOffsetRequest{UploadId: session.UploadId}); err != nil || got.Offset != 5000 {
+		t.Fatalf("an upload still being sent was thrown away: %v, %v", got, err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(srv.partPath(session.UploadId, 0), old, old); err != nil {
+		t.Fatal(err)
+	}
+	srv.sweepUploads()
+	if _, err := os.Stat(srv.sessionPath(session.UploadId)); !os.IsNotExist(err) {
+		t.Fatalf("an upload nothing came in for in two hours is still there: %v", err)
+	}
+}
+
+func TestResumeDownload(t *testing.T) {
+	_, client := startServer(t, 1<<20)
+
+	data := make([]byte, 150_000)
+	rand.Read(data)
+	if err := uploadBytes(client, "log.txt", data, 64<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.DownloadFile(context.Background(), &pb.FileRequest{FileName: "log.txt", Offset: 100_000, ChunkSize: 20_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rest []byte
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunk.IsLastChunk {
+			if sum := sha256.Sum256(data); !bytes.Equal(chunk.Sha256, sum[:]) {
+				t.Fatal("the last chunk doesn't carry the SHA-256 of the whole file")
+			}
+			break
+		}
+		if chunk.Offset != int64(100_000+len(rest)) || crc32.Checksum(chunk.ChunkData, castagnoli) != chunk.Crc32C {
+			t.Fatalf("chunk at %d has the wrong offset or CRC", chunk.Offset)
+		}
+		rest = append(rest, chunk.ChunkData...)
+	}
+	if !bytes.Equal(rest, data[100_000:]) {
+		t.Fatalf("got %d bytes from the offset that don't match", len(rest))
+	}
+
+	stream, err = client.DownloadFile(context.Background(), &pb.FileRequest{FileName: "log.txt", Offset: 150_001})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.OutOfRange {
+		t.Fatalf("offset past the end: got %v, want OutOfRange", err)
+	}
+}
+
+// chunkList cuts data into chunks the way a client would.
+func chunkList(name string, data []byte) *pb.ChunkList {
+	sum := sha256.Sum256(data)
+	list := &pb.ChunkList{FileName: name, Sha256: sum[:]}
+	for split := chunker.New(bytes.NewReader(data)); ; {
+		chunk, err := split.Next()
+		if err == io.EOF {
+			return list
+		}
+		sum := sha256.Sum256(chunk)
+		list.Chunks = append(list.Chunks, &pb.ChunkRef{Sha256: sum[:], Size: int64(len(chunk))})
+	}
+}
+
+// uploadMissing negotiates the chunks of data, sends the ones the server
+// needs and commits the file, returning how many chunks were sent.
+func uploadMissing(t *testing.T, client pb.FileTransferServiceClient, name string, data []byte) int {
+	t.Helper()
+	ctx := context.Background()
+	list := chunkList(name, data)
+	needs, err := client.NegotiateChunks(ctx, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.UploadChunks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int64
+	var offset int64
+	for _, ref := range list.Chunks {
+		offsets = append(offsets, offset)
+		offset += ref.Size
+	}
+	for _, i := range needs.Missing {
+		chunk := data[offsets[i] : offsets[i]+list.Chunks[i].Size]
+		if err := stream.Send(&pb.Chunk{Data: chunk, Sha256: list.Chunks[i].Sha256}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.CommitChunks(ctx, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(data)) || !bytes.Equal(info.Sha256, list.Sha256) {
+		t.Fatalf("committed %v", info)
+	}
+	return len(needs.Missing)
+}
+
+func countChunks(t *testing.T, srv *server) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(filepath.Join(srv.root, chunksDir), func(_ string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestChunkStore(t *testing.T) {
+	srv, client := startStorage(t, storageChunks, 8<<20)
+	ctx := context.Background()
+
+	data := make([]byte, 2<<20)
+	rand.Read(data)
+	total := len(chunkList("", data).Chunks)
+	if sent := uploadMissing(t, client, "builds/v1.bin", data); sent != total {
+		t.Fatalf("sent %d of %d chunks of a new file", sent, total)
+	}
+
+	// a build with a few bytes changed only sends the chunks around them
+	edited := append(append(append([]byte{}, data[:1<<20]...), "patched"...), data[1<<20:]...)
+	if sent := uploadMissing(t, client, "builds/v2.bin", edited); sent > 2 {
+		t.Fatalf("sent %d chunks of a file that changed in one place", sent)
+	}
+	if got, err := downloadBytes(client, "builds/v2.bin", 100_000); err != nil || !bytes.Equal(got, edited) {
+		t.Fatalf("downloaded %d bytes that don't match: %v", len(got), err)
+	}
+
+	// uploads the old way are chunked too, and store nothing new
+	before := countChunks(t, srv)
+	if err := uploadBytes(client, "copy.bin", data, 64<<10); err != nil {
+		t.Fatal(err)
+	}
+	if after := countChunks(t, srv); after != before {
+		t.Fatalf("uploading a copy stored %d more chunks", after-before)
+	}
+
+	// committing chunks the server doesn't have fails
+	missing := chunkList("other.bin", []byte("never sent"))
+	if _, err := client.CommitChunks(ctx, missing); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("committing missing chunks: got %v, want FailedPrecondition", err)
+	}
+	wrong := chunkList("other.bin", data)
+	wrong.Sha256 = make([]byte, sha256.Size)
+	if _, err := client.CommitChunks(ctx, wrong); status.Code(err) != codes.DataLoss {
+		t.Fatalf("committing with the wrong SHA-256: got %v, want DataLoss", err)
+	}
+
+	// the counts come back from the manifests when the store opens again
+	reopened, err := newChunkStore(srv.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.refs) != len(srv.chunks.refs) {
+		t.Fatalf("%d chunks counted after reopening, %d before", len(reopened.refs), len(srv.chunks.refs))
+	}
+
+	// chunks only v1 and its copy had go once both are deleted
+	for _, name := range []string{"builds/v1.bin", "copy.bin"} {
+		if _, err := client.DeleteFile(ctx, &pb.DeleteRequest{FileName: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if removed, _, err := srv.chunks.collect(0); err != nil || removed == 0 || removed > 2 {
+		t.Fatalf("collected %d chunks: %v", removed, err)
+	}
+	if got, err := downloadBytes(client, "builds/v2.bin", 0); err != nil || !bytes.Equal(got, edited) {
+		t.Fatalf("downloaded %d bytes that don't match after collecting: %v", len(got), err)
+	}
+	if _, err := client.DeleteFile(ctx, &pb.DeleteRequest{FileName: "builds/v2.bin"}); err != nil {
+		t.Fatal(err)
+	}
+	// the chunks of an upload in progress are left alone for a while, and
+	// so is one being written, but not one a crash left half written
+	dir := filepath.Dir(srv.chunks.chunkPath(make([]byte, sha256.Size)))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".chunk-writing", ".chunk-crashed"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("half"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, ".chunk-crashed"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if removed, _, err := srv.chunks.collect(time.Hour); err != nil || removed != 1 {
+		t.Fatalf("collected %d recent chunks and left-behind temp files, want the 1 temp file: %v", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".chunk-crashed")); !os.IsNotExist(err) {
+		t.Fatalf("a temp file left by a crash is still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".chunk-writing")); err != nil {
+		t.Fatalf("a chunk being written was collected: %v", err)
+	}
+	if _, _, err := srv.chunks.collect(0); err != nil {
+		t.Fatal(err)
+	}
+	if n := countChunks(t, srv); n != 0 {
+		t.Fatalf("%d chunks left after deleting every file", n)
+	}
+}
+
+func TestNoChunksOnDisk(t *testing.T) {
+	_, client := startServer(t, 1<<20)
+	if _, err := client.NegotiateChunks(context.Background(), chunkList("a", []byte("a"))); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("negotiating with a disk server: got %v, want Unimplemented", err)
+	}
+}
+
+func TestRangedUpload(t *testing.T) {
+	srv, client := startServer(t, 1<<20)
+	ctx := context.Background()
+
+	data := make([]byte, 350_000)
+	rand.Read(data)
+	sum := sha256.Sum256(data)
+	session, err := client.StartUpload(ctx, &pb.UploadRequest{FileName: "big.iso", Size: int64(len(data)), Sha256: sum[:], RangeSize: 100_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.RangeOffsets) != 4 || session.RangeOffsets[3] != 300_000 {
+		t.Fatalf("started as %v", session)
+	}
+
+	// every range but the first at once, and half of that
+	results := make(chan error, 3)
+	for start := 100_000; start < len(data); start += 100_000 {
+		go func() {
+			st, err := sendChunks(client, session.UploadId, int64(start), data[start:min(start+100_000, len(data))], 30_000)
+			if err == nil && st.Success {
+				err = fmt.Errorf("range at %d finished the upload", start)
+			}
+			results <- err
+		}()
+	}
+	for range 3 {
+		if err := <-results; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st, err := sendChunks(client, session.UploadId, 0, data[:50_000], 30_000); err != nil || st.Success || st.Offset != 50_000 {
+		t.Fatalf("half of the first range: %v, %v", st, err)
+	}
+	got, err := client.GetUploadOffset(ctx, &pb.UploadOffsetRequest{UploadId: session.UploadId})
+	if err != nil || got.Offset != 50_000 || got.RangeOffsets[0] != 50_000 || got.RangeOffsets[2] != 300_000 || got.RangeOffsets[3] != 350_000 {
+		t.Fatalf("offsets: %v, %v", got, err)
+	}
+	if _, err := sendChunks(client, session.UploadId, 50_000, data[50_000:100_001], 60_000); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("running into the next range: got %v, want InvalidArgument", err)
+	}
+
+	st, err := sendChunks(client, session.UploadId, 50_000, data[50_000:100_000], 30_000)
+	if err != nil || !st.Success {
+		t.Fatalf("the last of the first range: %v, %v", st, err)
+	}
+	stored, err := os.ReadFile(filepath.Join(srv.root, "big.iso"))
+	if err != nil || !bytes.Equal(stored, data) {
+		t.Fatalf("stored %d bytes that don't match the %d uploaded: %v", len(stored), len(data), err)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(srv.root, uploadsDir, "*")); len(parts) != 0 {
+		t.Fatalf("left behind %v", parts)
+	}
+}
+
+func TestRangedDownload(t *testing.T) {
+	_, client := startServer(t, 1<<20)
+	data := make([]byte, 100_000)
+	rand.Read(data)
+	if err := uploadBytes(client, "data", data, 64<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.DownloadFile(context.Background(), &pb.FileRequest{FileName: "data", Offset: 1000, Length: 25_000, ChunkSize: 10_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunk.IsLastChunk {
+			if sum := sha256.Sum256(data); !bytes.Equal(chunk.Sha256, sum[:]) {
+				t.Fatal("the last chunk of a range doesn't carry the SHA-256 of the whole file")
+			}
+			break
+		}
+		got = append(got, chunk.ChunkData...)
+	}
+	if !bytes.Equal(got, data[1000:26_000]) {
+		t.Fatalf("got %d bytes of the range that don't match", len(got))
+	}
+
+	stream, err = client.DownloadFile(context.Background(), &pb.FileRequest{FileName: "data", Offset: 90_000, Length: 10_001})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.OutOfRange {
+		t.Fatalf("range past the end: got %v, want OutOfRange", err)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "example/hello/filetransfer/grpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// uploadsDir holds the uploads in progress under the root, each as a JSON
+// file describing it and the data received so far of each of its ranges,
+// id.part for the first, then id.part1, id.part2 and so on. What is in a
+// data file is committed: every chunk was checked against its CRC before
+// it was written, so its size is where the range resumes from.
+const uploadsDir = ".uploads"
+
+const defaultUploadTTL = 24 * time.Hour
+
+// maxRanges is how many streams at most an upload can be sent on.
+const maxRanges = 64
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type uploadSession struct {
+	FileName string    `json:"file_name"`
+	Size     int64     `json:"size"`
+	SHA256   []byte    `json:"sha256"`
+	Uploader string    `json:"uploader,omitempty"`
+	Created  time.Time `json:"created"`
+	// the upload is sent in ranges this big, or in one if it is 0
+	RangeSize int64 `json:"range_size,omitempty"`
+}
+
+func (u *uploadSession) ranges() int {
+	if u.RangeSize <= 0 || u.Size == 0 {
+		return 1
+	}
+	return int((u.Size + u.RangeSize - 1) / u.RangeSize)
+}
+
+// bounds says where range i starts and ends.
+func (u *uploadSession) bounds(i int) (start, end int64) {
+	if u.ranges() == 1 {
+		return 0, u.Size
+	}
+	start = int64(i) * u.RangeSize
+	return start, min(start+u.RangeSize, u.Size)
+}
+
+// rangeAt says which range a chunk at offset is in. One at the end of the
+// file, as the chunk that is sent when there is nothing left, is in the last.
+func (u *uploadSession) rangeAt(offset int64) int {
+	if u.ranges() == 1 {
+		return 0
+	}
+	return min(int(offset/u.RangeSize), u.ranges()-1)
+}
+
+// resumeAt is where the upload is committed up to from the start.
+func (u *uploadSession) resumeAt(offsets []int64) int64 {
+	for i, offset := range offsets {
+		if _, end := u.bounds(i); offset < end {
+			return offset
+		}
+	}
+	return u.Size
+}
+
+func (s *server) sessionPath(id string) string {
+	return filepath.Join(s.root, uploadsDir, id+".json")
+}
+
+// partPath is where the data of range i of the upload is.
+func (s *server) partPath(id string, i int) string {
+	path := filepath.Join(s.root, uploadsDir, id+".part")
+	if i > 0 {
+		path += strconv.Itoa(i)
+	}
+	return path
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *server) StartUpload(ctx context.Context, req *pb.UploadRequest) (*pb.UploadSession, error) {
+	fileName, err := checkName(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	if req.Size < 0 {
+		return nil, status.Error(codes.InvalidArgument, "size can't be negative")
+	}
+	if req.Size > s.maxFileSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "files can be at most %d bytes", s.maxFileSize)
+	}
+	if len(req.Sha256) != sha256.Size {
+		return nil, status.Error(codes.InvalidArgument, "sha256 of the whole file is required")
+	}
+	if req.RangeSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "range size can't be negative")
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't start the upload: %v", err)
+	}
+	session := uploadSession{
+		FileName: fileName,
+		Size:     req.Size,
+		SHA256:   req.Sha256,
+		Uploader: uploader(ctx),
+		Created:  time.Now().UTC(),
+	}
+	if req.RangeSize < req.Size {
+		session.RangeSize = req.RangeSize
+	}
+	if session.ranges() > maxRanges {
+		return nil, status.Errorf(codes.InvalidArgument, "uploads can be sent in at most %d ranges", maxRanges)
+	}
+	encoded, err := json.Marshal(session)
+	for i := 0; i < session.ranges() && err == nil; i++ {
+		err = os.WriteFile(s.partPath(id, i), nil, 0o644)
+	}
+	if err == nil {
+		err = os.WriteFile(s.sessionPath(id), encoded, 0o644)
+	}
+	var offsets []int64
+	if err == nil {
+		offsets, err = s.rangeOffsets(id, &session)
+	}
+	if err != nil {
+		log.Printf("failed to start upload of %s: %v", fileName, err)
+		s.removeSession(id)
+		return nil, status.Error(codes.Internal, "couldn't start the upload")
+	}
+
+	log.Printf("started upload %s of %s, %d bytes in %d ranges", id, fileName, req.Size, session.ranges())
+	return s.toUploadSession(id, &session, offsets), nil
+}
+
+func (s *server) toUploadSession(id string, session *uploadSession, offsets []int64) *pb.UploadSession {
+	return &pb.UploadSession{
+		UploadId:     id,
+		FileName:     session.FileName,
+		Size:         session.Size,
+		Offset:       session.resumeAt(offsets),
+		RangeSize:    session.RangeSize,
+		RangeOffsets: offsets,
+	}
+}
+
+func (s *server) GetUploadOffset(_ context.Context, req *pb.UploadOffsetRequest) (*pb.UploadSession, error) {
+	session, offsets, err := s.loadSession(req.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	return s.toUploadSession(req.UploadId, session, offsets), nil
+}
+
+// loadSession reads the upload and says where each of its ranges is
+// committed up to.
+func (s *server) loadSession(id string) (*uploadSession, []int64, error) {
+	notFound := status.Errorf(codes.NotFound, "no upload %s, it may have expired", id)
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return nil, nil, notFound
+	}
+	encoded, err := os.ReadFile(s.sessionPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, notFound
+	}
+	var session uploadSession
+	if err == nil {
+		err = json.Unmarshal(encoded, &session)
+	}
+	var offsets []int64
+	if err == nil {
+		offsets, err = s.rangeOffsets(id, &session)
+	}
+	if err != nil {
+		log.Printf("failed to read upload %s: %v", id, err)
+		return nil, nil, status.Error(codes.Internal, "couldn't read the upload")
+	}
+	if time.Since(s.lastWritten(id, &session)) > s.uploadTTL {
+		s.removeSession(id)
+		return nil, nil, notFound
+	}
+	return &session, offsets, nil
+}
+
+func (s *server) rangeOffsets(id string, session *uploadSession) ([]int64, error) {
+	offsets := make([]int64, session.ranges())
+	for i := range offsets {
+		info, err := os.Stat(s.partPath(id, i))
+		if err != nil {
+			return nil, err
+		}
+		start, _ := session.bounds(i)
+		offsets[i] = start + info.Size()
+	}
+	return offsets, nil
+}
+
+// lastWritten is when data last came in for the upload, or when it was
+// started if none has yet.
+func (s *server) lastWritten(id string, session *uploadSession) time.Time {
+	last := session.Created
+	for i := range session.ranges() {
+		if info, err := os.Stat(s.partPath(id, i)); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last
+}
+
+func (s *server) removeSession(id string) {
+	parts, _ := filepath.Glob(filepath.Join(s.root, uploadsDir, id+".part*"))
+	for _, part := range parts {
+		os.Remove(part)
+	}
+	os.Remove(s.sessionPath(id))
+}
+
+// sweepUploads removes the uploads nothing was sent for within uploadTTL.
+func (s *server) sweepUploads() {
+	entries, err := os.ReadDir(filepath.Join(s.root, uploadsDir))
+	if err != nil {
+		log.Printf("failed to list uploads: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		// loadSession drops it when it is too old
+		s.loadSession(id)
+	}
+}
+
+// sweepUploadsEvery runs sweepUploads every interval until the server stops.
+func (s *server) sweepUploadsEvery(interval time.Duration) {
+	for {
+		s.sweepUploads()
+		time.Sleep(interval)
+	}
+}
+
+// claim makes sure only one stream at a time writes to a range of an
+// upload, or finishes it.
+func (s *server) claim(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[id] {
+		return false
+	}
+	s.active[id] = true
+	return true
+}
+
+func (s *server) release(id string) {
+	s.mu.Lock()
+	delete(s.active, id)
+	s.mu.Unlock()
+}
+
+// claimFinish says where each range of the upload is committed up to, and
+// whether this stream is the one to finish the upload, which it is when it
+// is the first to find all of them in.
+func (s *server) claimFinish(id string, session *uploadSession) ([]int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets, err := s.rangeOffsets(id, session)
+	if err != nil || s.active[id] || session.resumeAt(offsets) < session.Size {
+		return offsets, false, err
+	}
+	s.active[id] = true
+	return offsets, true, nil
+}
+
+// resumeUpload receives the chunks of a range of an upload started with
+// StartUpload, first being the first of them on this stream.
+func (s *server) resumeUpload(stream pb.FileTransferService_UploadFileServer, first *pb.FileChunk) error {
+	id := first.UploadId
+	session, offsets, err := s.loadSession(id)
+	if err != nil {
+		return err
+	}
+	r := session.rangeAt(first.Offset)
+	key := fmt.Sprintf("%s/%d", id, r)
+	if !s.claim(key) {
+		return status.Errorf(codes.Aborted, "range %d of upload %s is already being sent on another stream", r, id)
+	}
+	defer s.release(key)
+	// another stream may have written to it since it was loaded
+	if offsets, err = s.rangeOffsets(id, session); err != nil {
+		log.Printf("failed to read upload %s: %v", id, err)
+		return status.Error(codes.Internal, "couldn't read the upload")
+	}
+	offset := offsets[r]
+	_, end := session.bounds(r)
+
+	data, err := os.OpenFile(s.partPath(id, r), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		log.Printf("failed to open upload %s: %v", id, err)
+		return status.Error(codes.Internal, "couldn't store the file")
+	}
+	defer data.Close()
+
+	for chunk := first; offset < end || chunk != nil; {
+		if chunk == nil {
+			if chunk, err = stream.Recv(); err == io.EOF {
+				log.Printf("upload %s of %s stopped at %d of %d bytes", id, session.FileName, offset, session.Size)
+				message := fmt.Sprintf("%d of %d bytes received, resume from %d", offset, session.Size, offset)
+				if session.ranges() > 1 {
+					message = fmt.Sprintf("range %d stopped at %d, resume it from there", r, offset)
+				}
+				offsets[r] = offset
+				return stream.SendAndClose(&pb.UploadStatus{Message: message, Offset: session.resumeAt(offsets)})
+			}
+			if err != nil {
+				log.Printf("error receiving upload %s: %v", id, err)
+				return err
+			}
+		}
+		if chunk.UploadId != "" && chunk.UploadId != id {
+			return status.Error(codes.InvalidArgument, "chunks of different uploads on one stream")
+		}
+		if chunk.Offset != offset {
+			return status.Errorf(codes.FailedPrecondition, "chunk is at %d but the upload is at %d", chunk.Offset, offset)
+		}
+		if offset+int64(len(chunk.ChunkData)) > end {
+			if session.ranges() > 1 {
+				return status.Errorf(codes.InvalidArgument, "chunk runs past the end of range %d at %d", r, end)
+			}
+			return status.Errorf(codes.InvalidArgument, "chunk runs past the %d bytes the upload was started with", session.Size)
+		}
+		if crc32.Checksum(chunk.ChunkData, castagnoli) != chunk.Crc32C {
+			return status.Errorf(codes.DataLoss, "chunk at %d doesn't match its CRC, send it again", offset)
+		}
+		if _, err := data.Write(chunk.ChunkData); err != nil {
+			log.Printf("error writing upload %s: %v", id, err)
+			return status.Error(codes.Internal, "couldn't store the file")
+		}
+		offset += int64(len(chunk.ChunkData))
+		chunk = nil
+	}
+	data.Close()
+
+	offsets, finish, err := s.claimFinish(id, session)
+	if err != nil {
+		log.Printf("failed to read upload %s: %v", id, err)
+		return status.Error(codes.Internal, "couldn't read the upload")
+	}
+	if !finish {
+		return stream.SendAndClose(&pb.UploadStatus{
+			Message: fmt.Sprintf("range %d is in, the upload finishes with the last of them", r),
+			Offset:  session.resumeAt(offsets),
+		})
+	}
+	defer s.release(id)
+	if err := s.finishUpload(id, session); err != nil {
+		return err
+	}
+	log.Printf("successfully written %d bytes to %s", session.Size, session.FileName)
+	return stream.SendAndClose(&pb.UploadStatus{
+		Success: true,
+		Message: "Successfully uploaded the file",
+		Offset:  session.Size,
+	})
+}
+
+// finishUpload hands the ranges of the upload to the store, which checks
+// them against the SHA-256 of the file on the way. One that doesn't match
+// is thrown away.
+func (s *server) finishUpload(id string, session *uploadSession) error {
+	var parts []io.Reader
+	for i := range session.ranges() {
+		part, err := os.Open(s.partPath(id, i))
+		if err != nil {
+			log.Printf("failed to read upload %s: %v", id, err)
+			return status.Error(codes.Internal, "couldn't store the file")
+		}
+		defer part.Close()
+		parts = append(parts, part)
+	}
+	_, err := s.store.Put(session.FileName, io.MultiReader(parts...), FileMeta{SHA256: session.SHA256, Uploader: session.Uploader})
+	if errors.Is(err, errChecksum) || err == nil {
+		s.removeSession(id)
+	}
+	if err != nil {
+		return storageError(err, session.FileName)
+	}
+	return nil
+}